@@ -3,19 +3,27 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/codesenju/kubectl-nuke-go/internal/kube"
 	"github.com/codesenju/kubectl-nuke-go/internal/updater"
+	"github.com/codesenju/kubectl-nuke-go/pkg/argocd"
+	"github.com/codesenju/kubectl-nuke-go/pkg/flux"
+	"github.com/codesenju/kubectl-nuke-go/pkg/storage"
 )
 
 var (
@@ -35,6 +43,9 @@ Features:
 • Namespace deletion with automatic finalizer removal
 • Force mode for aggressive resource cleanup (--force flag)
 • Pod force deletion with grace period 0
+• Bulk removal of finalizers left behind by uninstalled operators
+• Full operator uninstall by API group
+• Teardown of everything described in YAML/JSON manifests (-f)
 • Multiple resource type support (pods, services, deployments, etc.)
 • Smart finalizer removal with multiple strategies`,
 		Example: `  # Delete a namespace (standard mode)
@@ -48,13 +59,27 @@ Features:
   kubectl-nuke pod stuck-pod -n my-namespace
   kubectl-nuke pods pod1 pod2 pod3 -n production
   
+  # Remove a leftover operator finalizer from every object
+  kubectl-nuke finalizer remove foo.example.com/cleanup --all-namespaces
+  
+  # Delete everything described in a manifest, unsticking anything that hangs
+  kubectl-nuke -f stack.yaml
+  helm template my-release ./chart | kubectl-nuke -f -
+  
   # Use with custom kubeconfig
   kubectl-nuke --kubeconfig /path/to/config ns my-namespace --force
   
   # Use as kubectl plugin
   kubectl nuke ns my-namespace -f
   kubectl nuke pods nginx-123 redis-456 -n default`,
+		Args: cobra.NoArgs,
+		Run:  deleteManifests,
 	}
+	rootCmd.Flags().StringSliceP("filename", "f", nil, "Delete everything described in these manifest files or directories (use - for stdin)")
+	rootCmd.Flags().BoolP("recursive", "R", false, "Process manifest directories recursively")
+	rootCmd.Flags().StringP("namespace", "n", "default", "Namespace for namespaced objects in the manifests that don't set one")
+	rootCmd.Flags().Bool("dry-run", false, "Only show the deletion plan without removing anything")
+	rootCmd.Flags().Duration("timeout", 30*time.Second, "How long to wait for each step before removing finalizers from stuck objects")
 
 	// Add kubeconfig flag to root command
 	if home := homeDir(); home != "" {
@@ -128,8 +153,17 @@ With --force flag, it will:
 With --dry-run/--diagnose-only flag, it will only analyze issues without attempting deletion.
 When combined with --force, it shows debug-level output of what aggressive cleanup would do.
 
-With --bypass-webhooks flag, it will temporarily disable problematic webhooks that might block deletion.
-With --force-api-direct flag, it will use direct API server calls to bypass admission controllers.`,
+With --bypass-webhooks flag, it will temporarily disable problematic webhooks that might block deletion:
+fail-closed webhooks intercepting its deletes and finalizer updates whose Service is missing or has no ready
+endpoints, or that fail --probe-webhooks.
+With --force-api-direct flag, it will fall back to raw REST calls to strip finalizers and finalize the namespace.
+
+With --contents-only flag, it will run the aggressive cleanup against everything inside the namespace
+but keep the namespace object itself, including its labels and annotations.
+Use --keep-kinds and --keep-selector to leave selected objects untouched.
+
+Force and contents-only modes delete every deletable namespaced resource type found via discovery.
+Use --only-kinds or --exclude-kinds to limit which kinds are deleted.`,
 		Example: `  # Delete a namespace (standard mode with CRD discovery)
   kubectl-nuke ns my-namespace
   
@@ -147,9 +181,51 @@ With --force-api-direct flag, it will use direct API server calls to bypass admi
   # Bypass webhooks that might block deletion
   kubectl-nuke ns my-namespace --bypass-webhooks
   
+  # Also probe each webhook with a TLS handshake, e.g. when running inside the cluster
+  kubectl-nuke ns my-namespace --force --bypass-webhooks --probe-webhooks
+  
   # Use direct API calls for most aggressive deletion
   kubectl-nuke ns my-namespace --force --force-api-direct
   
+  # Empty a namespace but keep the namespace itself
+  kubectl-nuke ns my-namespace --contents-only
+  
+  # Empty a namespace but keep secrets and anything labelled keep=true
+  kubectl-nuke ns my-namespace --contents-only --keep-kinds secrets --keep-selector keep=true
+  
+  # Force delete only workloads, leaving everything else in place
+  kubectl-nuke ns my-namespace --contents-only --only-kinds deployments,statefulsets,jobs
+  
+  # Empty a namespace but keep its ArgoCD Applications, with auto-sync turned off
+  kubectl-nuke ns my-namespace --contents-only --argocd-mode disable-sync
+  
+  # Delete a namespace whose ArgoCD Applications were generated by an ApplicationSet
+  kubectl-nuke ns my-namespace --force --argocd-appset exclude
+  
+  # Give ArgoCD longer to cascade-delete large Applications
+  kubectl-nuke ns my-namespace --argocd-timeout 5m
+  
+  # Tear down a Rook-Ceph cluster and have the operator wipe its data
+  kubectl-nuke ns rook-ceph --force --destroy-storage-data
+  
+  # Uninstall Longhorn, detaching its volumes and confirming the deletion
+  kubectl-nuke ns longhorn-system --force --detach-volumes --destroy-storage-data
+  
+  # Delete a namespace's VolumeSnapshots but keep their backend snapshots
+  kubectl-nuke ns my-namespace --force --snapshot-policy retain
+  
+  # Empty a namespace deployed by Flux, suspending its Kustomizations and HelmReleases
+  kubectl-nuke ns my-namespace --contents-only --flux-mode suspend
+  
+  # Force delete a namespace whose volumes have the Delete reclaim policy, keeping released Retain volumes
+  kubectl-nuke ns my-namespace --force --allow-data-loss --released-pvs keep
+  
+  # Tear down an in-house CSI driver's resources too
+  kubectl-nuke ns my-namespace --force --storage-providers ./storage-providers.yaml
+  
+  # Force delete everything except ingresses and network policies
+  kubectl-nuke ns my-namespace --force --exclude-kinds ingresses,networkpolicies
+  
   # Delete a namespace with custom kubeconfig
   kubectl-nuke --kubeconfig /path/to/config ns my-namespace`,
 		Args: cobra.ExactArgs(1),
@@ -157,9 +233,29 @@ With --force-api-direct flag, it will use direct API server calls to bypass admi
 	}
 	nsCmd.Flags().BoolVarP(&forceDelete, "force", "f", false, "Aggressively delete all resources and auto-cleanup problematic CRDs (DESTRUCTIVE)")
 	nsCmd.Flags().BoolVar(&bypassWebhooks, "bypass-webhooks", false, "Temporarily disable webhooks that might block deletion")
-	nsCmd.Flags().BoolVar(&forceAPIDirect, "force-api-direct", false, "Use direct API server calls to bypass admission controllers (requires kubectl)")
+	nsCmd.Flags().Bool("probe-webhooks", false, "With --bypass-webhooks, also probe each webhook's endpoints or URL with a TLS handshake")
+	nsCmd.Flags().Duration("webhook-probe-timeout", kube.DefaultWebhookProbeTimeout, "How long each webhook TLS probe may take, capped by the webhook's timeoutSeconds")
+	nsCmd.Flags().BoolVar(&forceAPIDirect, "force-api-direct", false, "Fall back to raw REST calls to strip finalizers and finalize the namespace")
 	nsCmd.Flags().BoolVar(&diagnoseOnly, "diagnose-only", false, "Only analyze issues without attempting deletion (alias: --dry-run)")
 	nsCmd.Flags().BoolVar(&diagnoseOnly, "dry-run", false, "Only analyze issues without attempting deletion (alias: --diagnose-only)")
+	nsCmd.Flags().Bool("contents-only", false, "Delete everything inside the namespace but keep the namespace itself (DESTRUCTIVE)")
+	nsCmd.Flags().StringSlice("keep-kinds", nil, "Leave objects of these kinds untouched (kind, resource, short name or resource.group; repeatable)")
+	nsCmd.Flags().String("keep-selector", "", "Leave objects matching this label selector untouched")
+	nsCmd.Flags().StringSlice("only-kinds", nil, "Only touch these kinds in force/contents-only mode (kind, resource, short name or resource.group; repeatable)")
+	nsCmd.Flags().StringSlice("exclude-kinds", nil, "Never touch these kinds in force/contents-only mode (kind, resource, short name or resource.group; repeatable)")
+	nsCmd.Flags().Duration("finalizer-timeout", kube.DefaultFinalizerTimeout, "How long deleted objects get to finalize in force/contents-only mode before their finalizers are removed")
+	nsCmd.Flags().String("argocd-mode", argocd.ModeDelete, "How to handle ArgoCD Applications managing the namespace: delete, disable-sync or orphan")
+	nsCmd.Flags().String("argocd-appset", "", "What to do with ApplicationSets that generated the ArgoCD Applications: delete, exclude or ignore (default: ask)")
+	nsCmd.Flags().Duration("argocd-timeout", argocd.ArgoCDTimeout, "How long to wait for ArgoCD to delete an Application's resources before removing its finalizers")
+	nsCmd.Flags().String("flux-mode", flux.ModeDelete, "How to handle Flux Kustomizations and HelmReleases applying to the namespace: delete, suspend or orphan")
+	nsCmd.Flags().Bool("allow-data-loss", false, "Allow force mode to delete PVCs whose volumes have the Delete reclaim policy")
+	nsCmd.Flags().String("released-pvs", "", "What to do with PersistentVolumes released by the deleted PVCs: delete, rebind or keep (default: ask)")
+	nsCmd.Flags().String("snapshot-policy", "", "Override the deletionPolicy of the namespace's VolumeSnapshotContents: delete or retain (default: respect each one's)")
+	nsCmd.Flags().Duration("snapshot-timeout", kube.DefaultSnapshotTimeout, "How long to wait for the snapshot controller to delete VolumeSnapshots before removing their finalizers")
+	nsCmd.Flags().Bool("destroy-storage-data", false, "Have storage operators wipe their data during teardown, such as Rook's cleanupPolicy (DESTRUCTIVE)")
+	nsCmd.Flags().Bool("detach-volumes", false, "Scale down the workloads using a storage provider's attached volumes so it can be torn down")
+	nsCmd.Flags().String("storage-providers", "", "YAML file of storage providers to add to or override the built-in ones")
+	nsCmd.Flags().Duration("flux-timeout", flux.Timeout, "How long to wait for Flux to finalize a deleted Kustomization or HelmRelease before removing its finalizer")
 
 	// Create pod command for force deleting pods
 	var podCmd = &cobra.Command{
@@ -188,10 +284,126 @@ if the application doesn't handle sudden termination properly.`,
 	}
 	podCmd.Flags().StringP("namespace", "n", "default", "namespace to delete pods from")
 
+	// Create finalizer command for bulk finalizer removal
+	var finalizerCmd = &cobra.Command{
+		Use:     "finalizer",
+		Aliases: []string{"finalizers"},
+		Short:   "Manage finalizers left behind on Kubernetes objects",
+	}
+
+	var finalizerRemoveCmd = &cobra.Command{
+		Use:   "remove <finalizer-name>",
+		Short: "Remove a named finalizer from every object that carries it (DESTRUCTIVE)",
+		Long: `Remove a single named finalizer from every object that carries it.
+Use this after uninstalling an operator whose finalizer is still blocking deletion
+of its objects across the cluster.
+
+The command will:
+1. Discover every resource type that supports list and patch
+2. Find every object carrying the named finalizer
+3. Remove just that entry with a guarded JSON patch, leaving other finalizers intact
+4. Report how many objects were found and patched
+
+Without --all-namespaces only namespaced resources in --namespace are scanned.
+With --all-namespaces every namespace and all cluster-scoped resources are scanned.
+
+⚠️  WARNING: Removing a finalizer skips the cleanup its controller would have performed.`,
+		Example: `  # Remove an operator's finalizer from objects in one namespace
+  kubectl-nuke finalizer remove foo.example.com/cleanup -n my-namespace
+  
+  # Remove it from every object in the cluster
+  kubectl-nuke finalizer remove foo.example.com/cleanup --all-namespaces
+  
+  # Only touch specific kinds
+  kubectl-nuke finalizer remove foo.example.com/cleanup -A --kind widgets.example.com --kind Gadget
+  
+  # Show what would be patched without making changes
+  kubectl-nuke finalizer remove foo.example.com/cleanup -A --dry-run`,
+		Args: cobra.ExactArgs(1),
+		Run:  removeFinalizer,
+	}
+	finalizerRemoveCmd.Flags().StringP("namespace", "n", "default", "namespace to scan for objects carrying the finalizer")
+	finalizerRemoveCmd.Flags().BoolP("all-namespaces", "A", false, "Scan all namespaces and cluster-scoped resources")
+	finalizerRemoveCmd.Flags().StringSlice("kind", nil, "Only scan these kinds (kind, resource, short name or resource.group; repeatable)")
+	finalizerRemoveCmd.Flags().Bool("dry-run", false, "Only list objects carrying the finalizer without patching them")
+	finalizerCmd.AddCommand(finalizerRemoveCmd)
+
+	// Create argocd command for undoing changes made to ArgoCD objects
+	var argocdCmd = &cobra.Command{
+		Use:   "argocd",
+		Short: "Manage ArgoCD objects changed by kubectl-nuke",
+	}
+
+	var argocdRestoreSyncCmd = &cobra.Command{
+		Use:   "restore-sync <application> [application2]...",
+		Short: "Restore auto-sync on ArgoCD Applications disabled with --argocd-mode disable-sync",
+		Long: `Restore the automated sync policy that --argocd-mode disable-sync removed from ArgoCD Applications.
+The original spec.syncPolicy.automated is recorded in the kubectl-nuke.io/original-automated-sync
+annotation and is removed once restored.`,
+		Example: `  # Turn auto-sync back on after rebuilding the namespace
+  kubectl-nuke argocd restore-sync my-app -n argocd`,
+		Args: cobra.MinimumNArgs(1),
+		Run:  restoreArgoCDSync,
+	}
+	argocdRestoreSyncCmd.Flags().StringP("namespace", "n", "argocd", "namespace of the Applications")
+	argocdCmd.AddCommand(argocdRestoreSyncCmd)
+
+	var argocdInspectCmd = &cobra.Command{
+		Use:   "inspect <namespace>",
+		Short: "Show the ArgoCD installation, Applications and AppProject restrictions affecting a namespace",
+		Long: `Report how ArgoCD relates to a namespace without changing anything: whether ArgoCD is installed and
+which argoproj.io version it serves, the Applications deploying into the namespace on this cluster, and
+the AppProject destinations and orphanedResources settings that apply to them.`,
+		Example: `  # Show what ArgoCD manages in a namespace
+  kubectl-nuke argocd inspect my-namespace
+  
+  # Machine-readable output
+  kubectl-nuke argocd inspect my-namespace -o json`,
+		Args: cobra.ExactArgs(1),
+		Run:  inspectArgoCD,
+	}
+	argocdInspectCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+	argocdCmd.AddCommand(argocdInspectCmd)
+
+	// Create operator command for uninstalling everything an operator left behind
+	var operatorCmd = &cobra.Command{
+		Use:   "operator <api-group>",
+		Short: "Uninstall everything an operator installed for an API group (DESTRUCTIVE)",
+		Long: `Find and remove everything installed for an operator's API group, such as longhorn.io.
+Use this to tear down a half-removed operator without hunting down its objects by hand.
+
+The command will find:
+- Validating/Mutating webhook configurations whose rules target the group
+- Instances of the group's CRDs in every namespace
+- The CustomResourceDefinitions in the group
+- APIServices registered for the group
+- ClusterRoles with rules naming the group, and the ClusterRoleBindings bound to them
+
+It then shows them as a plan and, after confirmation, removes them in dependency order:
+webhooks, custom resources, CRDs, APIServices, ClusterRoleBindings, ClusterRoles.
+Custom resources and CRDs that are still present after --timeout have their finalizers removed.`,
+		Example: `  # Show what would be removed for an API group
+  kubectl-nuke operator longhorn.io --dry-run
+  
+  # Remove everything for the group after confirmation
+  kubectl-nuke operator longhorn.io
+  
+  # Remove without a confirmation prompt
+  kubectl-nuke operator ceph.rook.io --yes`,
+		Args: cobra.ExactArgs(1),
+		Run:  uninstallOperator,
+	}
+	operatorCmd.Flags().Bool("dry-run", false, "Only show the uninstall plan without removing anything")
+	operatorCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+	operatorCmd.Flags().Duration("timeout", 30*time.Second, "How long to wait for custom resources and CRDs to go before removing their finalizers")
+
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(updateCmd)
 	rootCmd.AddCommand(nsCmd)
 	rootCmd.AddCommand(podCmd)
+	rootCmd.AddCommand(finalizerCmd)
+	rootCmd.AddCommand(operatorCmd)
+	rootCmd.AddCommand(argocdCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -206,14 +418,93 @@ func deleteNamespace(cmd *cobra.Command, args []string) {
 	// Get flag values
 	forceDelete, _ := cmd.Flags().GetBool("force")
 	bypassWebhooks, _ := cmd.Flags().GetBool("bypass-webhooks")
+	probeWebhooks, _ := cmd.Flags().GetBool("probe-webhooks")
+	webhookProbeTimeout, _ := cmd.Flags().GetDuration("webhook-probe-timeout")
 	forceAPIDirect, _ := cmd.Flags().GetBool("force-api-direct")
 	diagnoseOnly, _ := cmd.Flags().GetBool("diagnose-only")
 	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	contentsOnly, _ := cmd.Flags().GetBool("contents-only")
+	keepKinds, _ := cmd.Flags().GetStringSlice("keep-kinds")
+	keepSelector, _ := cmd.Flags().GetString("keep-selector")
+	onlyKinds, _ := cmd.Flags().GetStringSlice("only-kinds")
+	excludeKinds, _ := cmd.Flags().GetStringSlice("exclude-kinds")
+	finalizerTimeout, _ := cmd.Flags().GetDuration("finalizer-timeout")
+	argoCDMode, _ := cmd.Flags().GetString("argocd-mode")
+	appSetAction, _ := cmd.Flags().GetString("argocd-appset")
+	argoCDTimeout, _ := cmd.Flags().GetDuration("argocd-timeout")
+	fluxMode, _ := cmd.Flags().GetString("flux-mode")
+	fluxTimeout, _ := cmd.Flags().GetDuration("flux-timeout")
+	storageProvidersFile, _ := cmd.Flags().GetString("storage-providers")
+	destroyStorageData, _ := cmd.Flags().GetBool("destroy-storage-data")
+	detachVolumes, _ := cmd.Flags().GetBool("detach-volumes")
+	allowDataLoss, _ := cmd.Flags().GetBool("allow-data-loss")
+	releasedPVs, _ := cmd.Flags().GetString("released-pvs")
+	snapshotPolicy, _ := cmd.Flags().GetString("snapshot-policy")
+	snapshotTimeout, _ := cmd.Flags().GetDuration("snapshot-timeout")
 
 	// Combine diagnose-only and dry-run flags
 	isDryRun := diagnoseOnly || dryRun
 
-	if forceDelete && isDryRun {
+	keep := kube.KeepFilter{Kinds: keepKinds}
+	if keepSelector != "" {
+		selector, err := labels.Parse(keepSelector)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Invalid --keep-selector %q: %v\n", keepSelector, err)
+			os.Exit(1)
+		}
+		keep.Selector = selector
+	}
+
+	switch argoCDMode {
+	case argocd.ModeDelete, argocd.ModeDisableSync, argocd.ModeOrphan:
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Invalid --argocd-mode %q: must be delete, disable-sync or orphan\n", argoCDMode)
+		os.Exit(1)
+	}
+
+	switch fluxMode {
+	case flux.ModeDelete, flux.ModeSuspend, flux.ModeOrphan:
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Invalid --flux-mode %q: must be delete, suspend or orphan\n", fluxMode)
+		os.Exit(1)
+	}
+
+	switch releasedPVs {
+	case kube.ReleasedPVActionAsk, kube.ReleasedPVActionDelete, kube.ReleasedPVActionRebind, kube.ReleasedPVActionKeep:
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Invalid --released-pvs %q: must be delete, rebind or keep\n", releasedPVs)
+		os.Exit(1)
+	}
+
+	switch snapshotPolicy {
+	case kube.SnapshotPolicyRespect, kube.SnapshotPolicyDelete, kube.SnapshotPolicyRetain:
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Invalid --snapshot-policy %q: must be delete or retain\n", snapshotPolicy)
+		os.Exit(1)
+	}
+
+	storageProviders, err := storage.LoadRegistry(storageProvidersFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Invalid --storage-providers: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch appSetAction {
+	case kube.AppSetActionAsk, kube.AppSetActionDelete, kube.AppSetActionExclude, kube.AppSetActionIgnore:
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Invalid --argocd-appset %q: must be delete, exclude or ignore\n", appSetAction)
+		os.Exit(1)
+	}
+
+	if contentsOnly && isDryRun {
+		fmt.Printf("🔍 DRY-RUN + CONTENTS-ONLY MODE: Showing what emptying the namespace would do\n")
+		fmt.Printf("⚠️  This is a dry-run - no actual changes will be made\n")
+		fmt.Printf("🧹 Would delete everything inside namespace %s and keep the namespace itself\n", namespace)
+	} else if contentsOnly {
+		fmt.Printf("🧹 CONTENTS-ONLY MODE: Preparing to empty namespace: %s\n", namespace)
+		fmt.Printf("⚠️  WARNING: This will forcefully delete ALL resources in the namespace!\n")
+		fmt.Printf("🛡️  The namespace itself will be kept\n")
+	} else if forceDelete && isDryRun {
 		fmt.Printf("🔍 DRY-RUN + FORCE MODE: Showing debug output of what aggressive deletion would do\n")
 		fmt.Printf("⚠️  This is a dry-run - no actual changes will be made\n")
 		fmt.Printf("💥 Would aggressively delete namespace: %s\n", namespace)
@@ -255,13 +546,43 @@ func deleteNamespace(cmd *cobra.Command, args []string) {
 		fmt.Printf("📋 Namespace %s is in '%s' state.\n", ns.Name, ns.Status.Phase)
 	}
 
-	// Note: bypassWebhooks and forceAPIDirect are available for future use
-	_ = bypassWebhooks
-	_ = forceAPIDirect
+	nukeOptions := kube.NukeOptions{
+		BypassWebhooks: bypassWebhooks,
+		Webhooks: kube.WebhookOptions{
+			Probe:        probeWebhooks,
+			ProbeTimeout: webhookProbeTimeout,
+		},
+		ForceAPIDirect:   forceAPIDirect,
+		ContentsOnly:     contentsOnly,
+		Kinds:            kube.KindFilter{Only: onlyKinds, Exclude: excludeKinds},
+		Keep:             keep,
+		FinalizerTimeout: finalizerTimeout,
+		ArgoCD: kube.ArgoCDOptions{
+			Mode:         argoCDMode,
+			AppSetAction: appSetAction,
+			Prompt:       promptYesNo,
+			Timeout:      argoCDTimeout,
+		},
+		Flux: kube.FluxOptions{
+			Mode:    fluxMode,
+			Timeout: fluxTimeout,
+			Prompt:  promptYesNo,
+		},
+		Snapshots: kube.SnapshotOptions{
+			DeletionPolicy: snapshotPolicy,
+			Timeout:        snapshotTimeout,
+		},
+		AllowDataLoss:      allowDataLoss,
+		ReleasedPVs:        releasedPVs,
+		Prompt:             promptYesNo,
+		StorageProviders:   storageProviders,
+		DestroyStorageData: destroyStorageData,
+		DetachVolumes:      detachVolumes,
+	}
 
 	// Use enhanced namespace deletion with ArgoCD and CRD support
 	// Pass both forceDelete and isDryRun to the enhanced function
-	err = kube.EnhancedDeleteNamespaceWithDryRun(ctx, clientset, namespace, forceDelete, isDryRun)
+	err = kube.EnhancedDeleteNamespaceWithNukeOptions(ctx, clientset, namespace, forceDelete, isDryRun, nukeOptions)
 	if err != nil {
 		// Check if the error is because namespace was already deleted (success case)
 		if strings.Contains(err.Error(), "not found") {
@@ -275,6 +596,13 @@ func deleteNamespace(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	if contentsOnly {
+		if !isDryRun {
+			fmt.Printf("🧹 Namespace %s has been emptied and kept!\n", namespace)
+		}
+		return
+	}
+
 	// If not in dry-run mode, wait for namespace deletion
 	if !isDryRun {
 		// Wait for complete deletion with longer timeout for force mode
@@ -346,6 +674,327 @@ func nukePods(cmd *cobra.Command, args []string) {
 	fmt.Printf("✅ Force delete operation completed!\n")
 }
 
+func restoreArgoCDSync(cmd *cobra.Command, args []string) {
+	ctx := context.TODO()
+	namespace, _ := cmd.Flags().GetString("namespace")
+
+	// Build config from flags
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to load kubeconfig: %v\n", err)
+		os.Exit(1)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create dynamic client: %v\n", err)
+		os.Exit(1)
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create kubernetes client: %v\n", err)
+		os.Exit(1)
+	}
+
+	installation, err := argocd.NewDetector(clientset, dynamicClient).DetectInstallation()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to check whether ArgoCD is installed: %v\n", err)
+		os.Exit(1)
+	}
+	if !installation.Installed {
+		fmt.Fprintf(os.Stderr, "❌ ArgoCD is not installed on this cluster\n")
+		os.Exit(1)
+	}
+
+	handler := argocd.NewHandler(dynamicClient).WithAPIVersion(installation.Version)
+	failed := false
+	for _, name := range args {
+		if err := handler.RestoreAutoSync(ctx, namespace, name); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			failed = true
+			continue
+		}
+		fmt.Printf("✅ Restored auto-sync on ArgoCD Application: %s/%s\n", namespace, name)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func inspectArgoCD(cmd *cobra.Command, args []string) {
+	namespace := args[0]
+	ctx := context.TODO()
+	output, _ := cmd.Flags().GetString("output")
+	if output != "text" && output != "json" {
+		fmt.Fprintf(os.Stderr, "❌ Invalid --output %q: must be text or json\n", output)
+		os.Exit(1)
+	}
+
+	// Build config from flags
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to load kubeconfig: %v\n", err)
+		os.Exit(1)
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create kubernetes client: %v\n", err)
+		os.Exit(1)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create dynamic client: %v\n", err)
+		os.Exit(1)
+	}
+
+	report, err := argocd.NewDetector(clientset, dynamicClient).WithAPIServerHost(config.Host).BuildNamespaceReport(ctx, namespace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to inspect ArgoCD for namespace %s: %v\n", namespace, err)
+		os.Exit(1)
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to encode report: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	if !report.Installation.Installed {
+		fmt.Printf("ℹ️  ArgoCD is not installed on this cluster\n")
+		return
+	}
+	fmt.Printf("🐙 ArgoCD API: %s/%s (cluster: %s)\n", report.Installation.Group, report.Installation.Version, report.Cluster)
+	if len(report.Applications) == 0 {
+		fmt.Printf("ℹ️  No ArgoCD applications manage namespace %s\n", namespace)
+	}
+	for _, app := range report.Applications {
+		fmt.Printf("📊 %s/%s (project %s, sync %s, health %s, auto-sync %v, %d resource(s))\n",
+			app.Namespace, app.Name, app.Project, app.SyncStatus, app.HealthStatus, app.AutoSync, len(app.Resources))
+	}
+	for _, project := range report.Projects {
+		switch {
+		case project.Missing:
+			fmt.Printf("❌ AppProject %s not found\n", project.Project.Name)
+		case !project.DestinationPermitted():
+			fmt.Printf("❌ AppProject %s/%s does not permit namespace %s on this cluster\n", project.Project.Namespace, project.Project.Name, namespace)
+		default:
+			fmt.Printf("✅ AppProject %s/%s permits namespace %s\n", project.Project.Namespace, project.Project.Name, namespace)
+		}
+		if project.WarnsOnOrphans {
+			fmt.Printf("   ⚠️  Warns about orphaned resources\n")
+		}
+	}
+	var unresolved []string
+	for ref := range report.Unresolved {
+		unresolved = append(unresolved, ref)
+	}
+	sort.Strings(unresolved)
+	for _, ref := range unresolved {
+		fmt.Printf("⚠️  %d resource(s) tracked by ArgoCD application %s, which was not found on this cluster\n", report.Unresolved[ref], ref)
+	}
+}
+
+func removeFinalizer(cmd *cobra.Command, args []string) {
+	finalizer := args[0]
+	ctx := context.TODO()
+
+	// Get flag values
+	namespace, _ := cmd.Flags().GetString("namespace")
+	allNamespaces, _ := cmd.Flags().GetBool("all-namespaces")
+	kinds, _ := cmd.Flags().GetStringSlice("kind")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	if dryRun {
+		fmt.Printf("🔍 DRY-RUN MODE: Listing objects carrying finalizer %s without making changes\n", finalizer)
+	} else {
+		fmt.Printf("💥 FINALIZER REMOVAL: Removing finalizer %s\n", finalizer)
+		fmt.Printf("⚠️  WARNING: The cleanup this finalizer guards will be skipped!\n")
+	}
+
+	// Build config from flags
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to load kubeconfig: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Create dynamic and discovery clients
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create dynamic client: %v\n", err)
+		os.Exit(1)
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create discovery client: %v\n", err)
+		os.Exit(1)
+	}
+
+	result, err := kube.RemoveFinalizerEverywhere(ctx, dynamicClient, discoveryClient, finalizer, kube.FinalizerRemovalOptions{
+		Namespace:     namespace,
+		AllNamespaces: allNamespaces,
+		Kinds:         kinds,
+		DryRun:        dryRun,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to remove finalizer %s: %v\n", finalizer, err)
+		os.Exit(1)
+	}
+
+	if result.Failed > 0 {
+		fmt.Fprintf(os.Stderr, "⚠️  Finalizer could not be removed from %d object(s)\n", result.Failed)
+		os.Exit(1)
+	}
+
+	if !dryRun {
+		fmt.Printf("✅ Finalizer removal completed!\n")
+	}
+}
+
+func deleteManifests(cmd *cobra.Command, args []string) {
+	ctx := context.TODO()
+
+	// Get flag values
+	filenames, _ := cmd.Flags().GetStringSlice("filename")
+	recursive, _ := cmd.Flags().GetBool("recursive")
+	namespace, _ := cmd.Flags().GetString("namespace")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+
+	if len(filenames) == 0 {
+		cmd.Help()
+		return
+	}
+
+	sources, err := kube.ReadManifests(filenames, os.Stdin, recursive)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to read manifests: %v\n", err)
+		os.Exit(1)
+	}
+
+	if dryRun {
+		fmt.Printf("🔍 DRY-RUN MODE: Planning deletion of %d manifest objects without making changes\n", len(sources))
+	} else {
+		fmt.Printf("💥 MANIFEST MODE: Deleting %d objects described in the manifests\n", len(sources))
+	}
+
+	// Build config from flags
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to load kubeconfig: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Create discovery client
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create discovery client: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Create dynamic client
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create dynamic client: %v\n", err)
+		os.Exit(1)
+	}
+
+	plan, err := kube.PlanManifestDeletion(ctx, dynamicClient, discoveryClient, sources, namespace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to plan manifest deletion: %v\n", err)
+		os.Exit(1)
+	}
+
+	kube.DisplayManifestPlan(plan)
+	if plan.TotalObjects() == 0 {
+		return
+	}
+
+	results := kube.DeleteManifestObjects(ctx, dynamicClient, plan, timeout, dryRun)
+	kube.DisplayManifestDeletionSummary(results)
+
+	for _, result := range results {
+		if result.Status == kube.ManifestStatusFailed || result.Status == kube.ManifestStatusStuck {
+			os.Exit(1)
+		}
+	}
+	if !dryRun {
+		fmt.Printf("🎉 Everything described in the manifests has been removed!\n")
+	}
+}
+
+func uninstallOperator(cmd *cobra.Command, args []string) {
+	group := args[0]
+	ctx := context.TODO()
+
+	// Get flag values
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	skipConfirmation, _ := cmd.Flags().GetBool("yes")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+
+	if dryRun {
+		fmt.Printf("🔍 DRY-RUN MODE: Planning operator uninstall for API group %s without making changes\n", group)
+	} else {
+		fmt.Printf("💥 OPERATOR UNINSTALL: Preparing to remove everything for API group %s\n", group)
+	}
+
+	// Build config from flags
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to load kubeconfig: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Create clientset
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create Kubernetes client: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Create dynamic client
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create dynamic client: %v\n", err)
+		os.Exit(1)
+	}
+
+	plan, err := kube.PlanOperatorUninstall(ctx, clientset, dynamicClient, group)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to plan uninstall for %s: %v\n", group, err)
+		os.Exit(1)
+	}
+
+	kube.DisplayOperatorPlan(plan)
+	if dryRun || plan.TotalObjects() == 0 {
+		return
+	}
+
+	if !skipConfirmation {
+		fmt.Printf("⚠️  WARNING: This will permanently remove the %d objects listed above!\n", plan.TotalObjects())
+		if !promptYesNo(fmt.Sprintf("Do you want to uninstall everything for %s? (y/N): ", group)) {
+			fmt.Printf("⏹️  Uninstall cancelled by user\n")
+			return
+		}
+	}
+
+	if err := kube.ExecuteOperatorPlan(ctx, dynamicClient, plan, timeout); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Operator uninstall incomplete: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("🎉 Everything for API group %s has been removed!\n", group)
+}
+
 func performUpdate(cmd *cobra.Command, args []string) {
 	forceUpdate, _ := cmd.Flags().GetBool("force")
 	checkOnly, _ := cmd.Flags().GetBool("check-only")
@@ -409,6 +1058,10 @@ func promptUserConfirmation(hasUpdate bool, newVersion string) bool {
 		message = fmt.Sprintf("Do you want to reinstall version %s? (y/N): ", newVersion)
 	}
 	
+	return promptYesNo(message)
+}
+
+func promptYesNo(message string) bool {
 	fmt.Print(message)
 	
 	reader := bufio.NewReader(os.Stdin)
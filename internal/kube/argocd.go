@@ -0,0 +1,655 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/codesenju/kubectl-nuke-go/pkg/argocd"
+)
+
+// What to do with an ApplicationSet that generated Applications about to be deleted
+const (
+	AppSetActionAsk     = ""
+	AppSetActionDelete  = "delete"
+	AppSetActionExclude = "exclude"
+	AppSetActionIgnore  = "ignore"
+)
+
+// ArgoCDOptions tunes how the enhanced pipeline treats ArgoCD objects
+type ArgoCDOptions struct {
+	// Mode is one of argocd.ModeDelete (default), argocd.ModeDisableSync or argocd.ModeOrphan
+	Mode string
+	// AppSetAction is one of the AppSetAction constants; AppSetActionAsk prompts through Prompt
+	AppSetAction string
+	// Prompt asks a yes/no question; without it, asking falls back to leaving ApplicationSets alone
+	Prompt func(message string) bool
+	// Timeout bounds the wait for ArgoCD's cascade, shared by deleting the Applications and waiting for their
+	// resources; zero means argocd.ArgoCDTimeout
+	Timeout time.Duration
+}
+
+// cascadeTimeout returns the timeout for ArgoCD's cascade
+func (o ArgoCDOptions) cascadeTimeout() time.Duration {
+	if o.Timeout <= 0 {
+		return argocd.ArgoCDTimeout
+	}
+	return o.Timeout
+}
+
+// newArgoCDDetector creates an ArgoCD detector that knows the API server URL of the current cluster when it can
+// be worked out
+func newArgoCDDetector(clientset kubernetes.Interface, dynamicClient dynamic.Interface) *argocd.Detector {
+	detector := argocd.NewDetector(clientset, dynamicClient)
+	if config, err := GetRESTConfig(clientset); err == nil {
+		detector.WithAPIServerHost(config.Host)
+	}
+	return detector
+}
+
+// newArgoCDHandler creates an ArgoCD handler using the API version the detector discovered and the cascade
+// timeout from opts
+func newArgoCDHandler(detector *argocd.Detector, dynamicClient dynamic.Interface, opts ArgoCDOptions) *argocd.Handler {
+	handler := argocd.NewHandler(dynamicClient).WithTimeout(opts.Timeout)
+	if installation, err := detector.DetectInstallation(); err == nil {
+		handler.WithAPIVersion(installation.Version)
+	}
+	return handler
+}
+
+// displayProjectRestrictions reports the AppProject settings that affect what ArgoCD does in the namespace:
+// destinations that don't permit it, missing projects and orphaned resource monitoring
+func displayProjectRestrictions(ctx context.Context, detector *argocd.Detector, namespace string, apps []unstructured.Unstructured) {
+	restrictions, err := detector.DetectProjectRestrictions(ctx, namespace, apps)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to check ArgoCD AppProjects: %v\n", err)
+		return
+	}
+	if len(restrictions) == 0 {
+		return
+	}
+
+	fmt.Printf("\n📁 ARGOCD PROJECTS:\n")
+	fmt.Printf("==================\n")
+	for _, r := range restrictions {
+		fmt.Printf("📁 %s (applications: %s)\n", r.Project.Name, strings.Join(r.Applications, ", "))
+		switch {
+		case r.Missing:
+			fmt.Printf("   ❌ AppProject not found: ArgoCD won't sync or cascade-delete these applications\n")
+		case !r.DestinationPermitted():
+			fmt.Printf("   ❌ No destination permits namespace %s on this cluster: ArgoCD won't act here, so a cascading delete can't finish\n", namespace)
+		default:
+			for _, destination := range r.PermittedDestinations {
+				fmt.Printf("   ✅ Permitted by destination Server=%s, Name=%s, Namespace=%s\n", destination.Server, destination.Name, destination.Namespace)
+			}
+		}
+		if r.WarnsOnOrphans {
+			fmt.Printf("   ⚠️  orphanedResources.warn is on: resources left by --argocd-mode orphan will raise OrphanedResourceWarning\n")
+		}
+		if policy := r.Project.OrphanedResources; policy != nil && len(policy.Ignore) > 0 {
+			fmt.Printf("   ℹ️  Orphaned resource ignore rules: %d\n", len(policy.Ignore))
+		}
+	}
+}
+
+// detectArgoCDApps finds the Applications deploying into the namespace on the cluster behind apiServerHost, by
+// destination and by the tracking metadata on the namespace's live resources. It reports same-named namespaces
+// on other clusters and Applications that also manage other namespaces.
+func detectArgoCDApps(ctx context.Context, detector *argocd.Detector, apiServerHost, namespace string) []unstructured.Unstructured {
+	fmt.Printf("🔍 Checking for ArgoCD applications managing namespace: %s\n", namespace)
+
+	installation, err := detector.DetectInstallation()
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to check whether ArgoCD is installed: %v\n", err)
+		return nil
+	}
+	if !installation.Installed {
+		fmt.Printf("ℹ️  ArgoCD is not installed on this cluster\n")
+		return nil
+	}
+	fmt.Printf("🐙 ArgoCD API found: %s/%s\n", installation.Group, installation.Version)
+
+	cluster, err := detector.ResolveCurrentCluster(ctx, apiServerHost)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to read ArgoCD cluster secrets, matching the in-cluster destination only: %v\n", err)
+	}
+
+	argoCDApps, otherClusterApps, err := detector.DetectArgoCDAppsForCluster(ctx, namespace, cluster)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to detect ArgoCD applications: %v\n", err)
+	}
+
+	tracking, err := detector.DetectArgoCDAppsFromTracking(ctx, namespace, cluster)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to resolve ArgoCD resource tracking: %v\n", err)
+		tracking = &argocd.TrackingResult{}
+	}
+	argoCDApps = mergeTrackedApplications(argoCDApps, tracking)
+
+	if len(argoCDApps) > 0 {
+		fmt.Printf("🎯 Found %d ArgoCD application(s) managing this namespace:\n", len(argoCDApps))
+		for _, app := range argoCDApps {
+			fmt.Printf("  - %s/%s%s\n", app.GetNamespace(), app.GetName(), trackingNote(app, tracking))
+			if others := otherNamespaces(app, namespace); len(others) > 0 {
+				fmt.Printf("    ⚠️  Also manages resources in namespace(s) %s; deleting it affects those too\n", strings.Join(others, ", "))
+			}
+		}
+	} else {
+		fmt.Printf("ℹ️  No ArgoCD applications found managing this namespace\n")
+	}
+
+	var unresolved []string
+	for ref := range tracking.Unresolved {
+		unresolved = append(unresolved, ref)
+	}
+	sort.Strings(unresolved)
+	for _, ref := range unresolved {
+		fmt.Printf("⚠️  %d resource(s) are tracked by ArgoCD application %s, which was not found on this cluster\n", tracking.Unresolved[ref], ref)
+	}
+	var unlisted []string
+	for ref := range tracking.UnlistedLabels {
+		unlisted = append(unlisted, ref)
+	}
+	sort.Strings(unlisted)
+	for _, ref := range unlisted {
+		fmt.Printf("⚠️  %d resource(s) carry the instance label of ArgoCD application %s, which doesn't list them; not attributing them to it\n",
+			tracking.UnlistedLabels[ref], ref)
+	}
+
+	if len(otherClusterApps) > 0 {
+		fmt.Printf("🌐 Ignoring %d ArgoCD application(s) deploying to a namespace named %s on another cluster (this cluster is %s):\n",
+			len(otherClusterApps), namespace, cluster)
+		for _, app := range otherClusterApps {
+			fmt.Printf("  - %s/%s → %s\n", app.GetNamespace(), app.GetName(), applicationDestination(app))
+		}
+	}
+
+	return argoCDApps
+}
+
+// mergeTrackedApplications adds the Applications found through resource tracking to those matched by destination
+func mergeTrackedApplications(apps []unstructured.Unstructured, tracking *argocd.TrackingResult) []unstructured.Unstructured {
+	seen := map[string]bool{}
+	for _, app := range apps {
+		seen[app.GetNamespace()+"/"+app.GetName()] = true
+	}
+	for _, tracked := range tracking.Applications {
+		key := tracked.Application.GetNamespace() + "/" + tracked.Application.GetName()
+		if !seen[key] {
+			seen[key] = true
+			apps = append(apps, tracked.Application)
+		}
+	}
+	return apps
+}
+
+// trackingNote describes how many live resources were tracked to an Application, if any
+func trackingNote(app unstructured.Unstructured, tracking *argocd.TrackingResult) string {
+	for _, tracked := range tracking.Applications {
+		if tracked.Application.GetNamespace() != app.GetNamespace() || tracked.Application.GetName() != app.GetName() {
+			continue
+		}
+		if tracked.LabelOnly {
+			return fmt.Sprintf(" (%d resource(s) via %s labels)", tracked.Resources, argocd.LabelArgoCDInstance)
+		}
+		return fmt.Sprintf(" (%d resource(s) via tracking annotations)", tracked.Resources)
+	}
+	return ""
+}
+
+// detectArgoCDControlPlane finds the ArgoCD objects that would lose their controller with the namespace and
+// reports them
+func detectArgoCDControlPlane(ctx context.Context, detector *argocd.Detector, namespace string) *argocd.ControlPlaneInfo {
+	info, err := detector.DetectControlPlane(ctx, namespace)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to check for the ArgoCD control plane: %v\n", err)
+		return &argocd.ControlPlaneInfo{Namespace: namespace}
+	}
+	if info.IsEmpty() {
+		return info
+	}
+
+	if info.HostsControlPlane() {
+		fmt.Printf("🐙 Namespace %s hosts the ArgoCD control plane: %s\n", namespace, strings.Join(info.Components, ", "))
+		fmt.Printf("⚠️  Once it is gone nothing removes %s from Applications\n", argocd.ResourcesFinalizer)
+	}
+	if len(info.Applications) > 0 {
+		fmt.Printf("📋 %d ArgoCD application(s) would lose their controller:\n", len(info.Applications))
+		for _, app := range info.Applications {
+			destinationNamespace, _, _ := unstructured.NestedString(app.Object, "spec", "destination", "namespace")
+			fmt.Printf("  - %s/%s → %s (namespace %s)\n", app.GetNamespace(), app.GetName(), applicationDestination(app), destinationNamespace)
+		}
+		if destinations := argocd.ApplicationDestinations(info.Applications); len(destinations) > 0 {
+			fmt.Printf("🌐 Destination clusters: %s\n", strings.Join(destinations, ", "))
+		}
+	}
+	if len(info.AppProjects) > 0 {
+		var names []string
+		for _, project := range info.AppProjects {
+			names = append(names, project.GetName())
+		}
+		fmt.Printf("📁 %d ArgoCD AppProject(s) in the namespace: %s\n", len(info.AppProjects), strings.Join(names, ", "))
+	}
+	return info
+}
+
+// stripControlPlaneFinalizers removes ArgoCD finalizers from the objects that would lose their controller, so the
+// namespace doesn't hang and deleting them doesn't cascade to what they deployed
+func stripControlPlaneFinalizers(ctx context.Context, handler *argocd.Handler, info *argocd.ControlPlaneInfo) {
+	fmt.Printf("🔧 Removing ArgoCD finalizers from %d application(s) and %d AppProject(s) before deleting the namespace...\n",
+		len(info.Applications), len(info.AppProjects))
+	stripped, err := handler.StripControlPlaneFinalizers(ctx, info)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: %v\n", err)
+	}
+	fmt.Printf("✅ Removed ArgoCD finalizers from %d object(s)\n", stripped)
+}
+
+// otherNamespaces returns the namespaces an Application manages besides the given one
+func otherNamespaces(app unstructured.Unstructured, namespace string) []string {
+	var others []string
+	for _, ns := range argocd.ManagedNamespaces(app) {
+		if ns != namespace {
+			others = append(others, ns)
+		}
+	}
+	return others
+}
+
+// applicationDestination returns an Application's destination cluster as server URL or cluster name
+func applicationDestination(app unstructured.Unstructured) string {
+	if server, _, _ := unstructured.NestedString(app.Object, "spec", "destination", "server"); server != "" {
+		return server
+	}
+	name, _, _ := unstructured.NestedString(app.Object, "spec", "destination", "name")
+	return name
+}
+
+// handleArgoCDApplications stops owning ApplicationSets from regenerating the Applications, then deletes them and
+// waits for ArgoCD to finish deleting what they deployed to the namespace. Applications whose cascade would also
+// delete resources in other namespaces only get auto-sync disabled unless the user agrees to delete them.
+func handleArgoCDApplications(ctx context.Context, detector *argocd.Detector, handler *argocd.Handler, namespace string, apps []unstructured.Unstructured, opts ArgoCDOptions) {
+	fmt.Printf("🔄 Handling ArgoCD applications before namespace deletion...\n")
+
+	apps = handleParentApplications(ctx, detector, handler, apps, opts)
+
+	if opts.Mode == argocd.ModeDelete || opts.Mode == "" {
+		var spanning []unstructured.Unstructured
+		apps, spanning = confirmSpanningApplications(namespace, apps, opts)
+		if len(spanning) > 0 {
+			warnApplicationSetsMayResync(ctx, detector, spanning)
+			if err := handler.HandleApplications(ctx, spanning, argocd.ModeDisableSync); err != nil {
+				fmt.Printf("⚠️  Warning: Failed to disable auto-sync on some ArgoCD applications: %v\n", err)
+			}
+		}
+		if len(apps) == 0 {
+			return
+		}
+	}
+
+	if opts.Mode == argocd.ModeDisableSync {
+		warnApplicationSetsMayResync(ctx, detector, apps)
+	} else {
+		handleApplicationSets(ctx, detector, handler, apps, opts)
+	}
+
+	// One deadline covers deleting the Applications and waiting for their resources
+	deadline := time.Now().Add(opts.cascadeTimeout())
+	handler.WithDeadline(deadline)
+	defer handler.WithDeadline(time.Time{})
+	if err := handler.HandleApplications(ctx, apps, opts.Mode); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to handle some ArgoCD applications: %v\n", err)
+	}
+
+	// Only a cascading delete leaves ArgoCD cleaning up resources
+	if opts.Mode == argocd.ModeDelete || opts.Mode == "" {
+		waitForArgoCDCascade(ctx, detector, namespace, apps, deadline)
+	}
+}
+
+// confirmSpanningApplications splits off the Applications whose cascading delete would reach namespaces besides
+// this one, unless the user agrees to delete them anyway. Without a prompt they are never cascade-deleted.
+func confirmSpanningApplications(namespace string, apps []unstructured.Unstructured, opts ArgoCDOptions) ([]unstructured.Unstructured, []unstructured.Unstructured) {
+	var deleting, spanning []unstructured.Unstructured
+	for _, app := range apps {
+		if argocd.CascadesOnDelete(app) && len(otherNamespaces(app, namespace)) > 0 {
+			spanning = append(spanning, app)
+		} else {
+			deleting = append(deleting, app)
+		}
+	}
+	if len(spanning) == 0 {
+		return apps, nil
+	}
+
+	fmt.Printf("⚠️  %d ArgoCD application(s) also manage other namespaces; deleting them deletes their resources there too:\n", len(spanning))
+	for _, app := range spanning {
+		fmt.Printf("  - %s/%s (also %s)\n", app.GetNamespace(), app.GetName(), strings.Join(otherNamespaces(app, namespace), ", "))
+	}
+	if opts.Prompt != nil && opts.Prompt(fmt.Sprintf("Delete these %d application(s) and everything they manage in the other namespaces? (y/N): ", len(spanning))) {
+		return apps, nil
+	}
+	fmt.Printf("⏸️  Disabling auto-sync on them instead\n")
+	return deleting, spanning
+}
+
+// waitForArgoCDCascade waits until none of the namespace's live resources are tracked to the cascading
+// Applications, or until the deadline. A background cascade removes the Application before its resources are gone.
+func waitForArgoCDCascade(ctx context.Context, detector *argocd.Detector, namespace string, apps []unstructured.Unstructured, deadline time.Time) {
+	var cascading []unstructured.Unstructured
+	for _, app := range apps {
+		if argocd.CascadesOnDelete(app) {
+			cascading = append(cascading, app)
+		}
+	}
+	if len(cascading) == 0 {
+		return
+	}
+
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		// The Applications used up the deadline; check once
+		timeout = time.Millisecond
+	}
+
+	fmt.Printf("⏳ Waiting up to %v for ArgoCD to clean up resources in namespace %s...\n", timeout.Round(time.Second), namespace)
+	remaining, err := detector.WaitForTrackedResources(ctx, namespace, cascading, timeout)
+	switch {
+	case err != nil:
+		fmt.Printf("⚠️  Warning: Could not check ArgoCD's cleanup progress: %v\n", err)
+	case remaining > 0:
+		fmt.Printf("⚠️  %d resource(s) tracked by the deleted applications are still present; continuing with namespace deletion\n", remaining)
+	default:
+		fmt.Printf("✅ ArgoCD finished cleaning up resources in namespace %s\n", namespace)
+	}
+}
+
+// handleParentApplications stops app-of-apps parents from resyncing the Applications: disable-sync mode turns off
+// their auto-sync, other modes delete them top-down after confirmation, without cascading to their other resources.
+// It returns the Applications ordered parents first.
+func handleParentApplications(ctx context.Context, detector *argocd.Detector, handler *argocd.Handler, apps []unstructured.Unstructured, opts ArgoCDOptions) []unstructured.Unstructured {
+	lineages, err := detector.FindApplicationLineages(ctx, apps)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to look up parent ArgoCD applications: %v\n", err)
+		return apps
+	}
+
+	ancestors := parentsOutside(argocd.Ancestors(lineages), apps)
+	if len(ancestors) == 0 {
+		return orderTopDown(lineages)
+	}
+
+	displayApplicationTree(argocd.BuildApplicationTree(lineages), apps)
+
+	mode := argocd.ModeDisableSync
+	if opts.Mode != argocd.ModeDisableSync {
+		if opts.Prompt != nil && opts.Prompt(fmt.Sprintf("Delete %d parent application(s) top-down so they don't recreate these applications? Their other resources are orphaned, not deleted (y/N): ", len(ancestors))) {
+			mode = argocd.ModeOrphan
+		} else {
+			fmt.Printf("⏸️  Disabling auto-sync on the parent application(s) instead\n")
+		}
+	}
+
+	if err := handler.HandleApplications(ctx, ancestors, mode); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to handle parent ArgoCD applications: %v\n", err)
+	}
+	return orderTopDown(lineages)
+}
+
+// parentsOutside drops the parents that are themselves among the Applications being handled
+func parentsOutside(parents []unstructured.Unstructured, apps []unstructured.Unstructured) []unstructured.Unstructured {
+	handled := map[string]bool{}
+	for _, app := range apps {
+		handled[app.GetNamespace()+"/"+app.GetName()] = true
+	}
+	var outside []unstructured.Unstructured
+	for _, parent := range parents {
+		if !handled[parent.GetNamespace()+"/"+parent.GetName()] {
+			outside = append(outside, parent)
+		}
+	}
+	return outside
+}
+
+// orderTopDown returns the lineages' Applications with parents before their children
+func orderTopDown(lineages []argocd.ApplicationLineage) []unstructured.Unstructured {
+	sorted := append([]argocd.ApplicationLineage(nil), lineages...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Parents) < len(sorted[j].Parents)
+	})
+	apps := make([]unstructured.Unstructured, 0, len(sorted))
+	for _, lineage := range sorted {
+		apps = append(apps, lineage.Application)
+	}
+	return apps
+}
+
+// displayApplicationHierarchy prints the app-of-apps tree above the Applications, if they have parents
+func displayApplicationHierarchy(ctx context.Context, detector *argocd.Detector, apps []unstructured.Unstructured) {
+	lineages, err := detector.FindApplicationLineages(ctx, apps)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to look up parent ArgoCD applications: %v\n", err)
+		return
+	}
+	if len(argocd.Ancestors(lineages)) > 0 {
+		displayApplicationTree(argocd.BuildApplicationTree(lineages), apps)
+	}
+}
+
+// displayApplicationHierarchyPlan prints the app-of-apps tree and what would happen to the parents
+func displayApplicationHierarchyPlan(ctx context.Context, detector *argocd.Detector, apps []unstructured.Unstructured, opts ArgoCDOptions) {
+	lineages, err := detector.FindApplicationLineages(ctx, apps)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to look up parent ArgoCD applications: %v\n", err)
+		return
+	}
+	ancestors := parentsOutside(argocd.Ancestors(lineages), apps)
+	if len(ancestors) == 0 {
+		return
+	}
+
+	fmt.Printf("\n🔍 ARGOCD PARENT APPLICATIONS (WOULD BE HANDLED FIRST):\n")
+	fmt.Printf("======================================================\n")
+	displayApplicationTree(argocd.BuildApplicationTree(lineages), apps)
+	for _, parent := range ancestors {
+		if opts.Mode == argocd.ModeDisableSync {
+			fmt.Printf("   ⏸️  WOULD DISABLE AUTO-SYNC on parent %s/%s\n", parent.GetNamespace(), parent.GetName())
+		} else {
+			fmt.Printf("   ❓ WOULD ASK to delete parent %s/%s top-down (resources orphaned), otherwise disable its auto-sync\n", parent.GetNamespace(), parent.GetName())
+		}
+	}
+}
+
+// displayApplicationTree prints app-of-apps trees, marking the Applications managing the namespace
+func displayApplicationTree(roots []*argocd.ApplicationNode, targets []unstructured.Unstructured) {
+	marked := map[string]bool{}
+	for _, app := range targets {
+		marked[app.GetNamespace()+"/"+app.GetName()] = true
+	}
+
+	fmt.Printf("🌳 App-of-apps hierarchy (🎯 = manages this namespace):\n")
+	var printNode func(node *argocd.ApplicationNode, prefix, branch string)
+	printNode = func(node *argocd.ApplicationNode, prefix, branch string) {
+		key := node.Application.GetNamespace() + "/" + node.Application.GetName()
+		marker := ""
+		if marked[key] {
+			marker = " 🎯"
+		}
+		fmt.Printf("   %s%s%s%s\n", prefix, branch, key, marker)
+
+		childPrefix := prefix
+		if branch == "└─ " {
+			childPrefix += "   "
+		} else if branch == "├─ " {
+			childPrefix += "│  "
+		}
+		for i, child := range node.Children {
+			childBranch := "├─ "
+			if i == len(node.Children)-1 {
+				childBranch = "└─ "
+			}
+			printNode(child, childPrefix, childBranch)
+		}
+	}
+	for _, root := range roots {
+		printNode(root, "", "")
+	}
+}
+
+// leavesArgoCDAppsInPlace reports whether contents-only mode must skip the Applications because deleting them
+// would cascade to the namespace itself
+func leavesArgoCDAppsInPlace(opts NukeOptions) bool {
+	return opts.ContentsOnly && (opts.ArgoCD.Mode == argocd.ModeDelete || opts.ArgoCD.Mode == "")
+}
+
+// warnApplicationSetsMayResync warns that ApplicationSets regenerate their Applications' spec, which can turn
+// auto-sync back on
+func warnApplicationSetsMayResync(ctx context.Context, detector *argocd.Detector, apps []unstructured.Unstructured) {
+	ownerships, err := detector.FindOwningApplicationSets(ctx, apps)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to look up owning ApplicationSets: %v\n", err)
+	}
+	for _, ownership := range ownerships {
+		fmt.Printf("⚠️  ApplicationSet %s/%s generated %s and may turn auto-sync back on\n",
+			ownership.ApplicationSet.GetNamespace(), ownership.ApplicationSet.GetName(), strings.Join(ownership.ApplicationNames(), ", "))
+	}
+}
+
+// argoCDModeOutcome describes what a mode does to the Applications, for dry-run headings
+func argoCDModeOutcome(mode string) string {
+	switch mode {
+	case argocd.ModeDisableSync:
+		return "WOULD HAVE AUTO-SYNC DISABLED"
+	case argocd.ModeOrphan:
+		return "WOULD BE ORPHANED (deleted without cascading)"
+	default:
+		return "WOULD BE DELETED"
+	}
+}
+
+// displayArgoCDModeAction prints the dry-run action a mode takes on one Application
+func displayArgoCDModeAction(app unstructured.Unstructured, mode string) {
+	switch mode {
+	case argocd.ModeDisableSync:
+		automated, found, _ := unstructured.NestedMap(app.Object, "spec", "syncPolicy", "automated")
+		if !found {
+			fmt.Printf("   ℹ️  Auto-sync is already disabled\n")
+			return
+		}
+		fmt.Printf("   ⏸️  WOULD DISABLE AUTO-SYNC: remove spec.syncPolicy.automated %v (recorded in %s for restore)\n",
+			automated, argocd.AnnotationOriginalAutomatedSync)
+	case argocd.ModeOrphan:
+		for _, finalizer := range app.GetFinalizers() {
+			if strings.HasPrefix(finalizer, argocd.ResourcesFinalizer) {
+				fmt.Printf("   🔓 WOULD REMOVE FINALIZER: %s\n", finalizer)
+			}
+		}
+		fmt.Printf("   🗑️  WOULD DELETE: kubectl delete application %s -n %s (resources left to the nuke)\n", app.GetName(), app.GetNamespace())
+	default:
+		fmt.Printf("   🗑️  WOULD DELETE: kubectl delete application %s -n %s\n", app.GetName(), app.GetNamespace())
+	}
+}
+
+// handleApplicationSets deletes owning ApplicationSets or removes the generating list elements, as chosen by
+// opts, so the ApplicationSet controller doesn't recreate the Applications mid-nuke
+func handleApplicationSets(ctx context.Context, detector *argocd.Detector, handler *argocd.Handler, apps []unstructured.Unstructured, opts ArgoCDOptions) {
+	ownerships, err := detector.FindOwningApplicationSets(ctx, apps)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to look up owning ApplicationSets: %v\n", err)
+	}
+
+	for _, ownership := range ownerships {
+		appSet := ownership.ApplicationSet
+		ref := appSet.GetNamespace() + "/" + appSet.GetName()
+		fmt.Printf("🧬 ApplicationSet %s generated: %s\n", ref, strings.Join(ownership.ApplicationNames(), ", "))
+
+		switch chooseAppSetAction(ref, opts) {
+		case AppSetActionDelete:
+			fmt.Printf("🗑️  Deleting ApplicationSet %s (its other applications are orphaned, not deleted)\n", ref)
+			if err := handler.DeleteApplicationSet(ctx, appSet); err != nil {
+				fmt.Printf("⚠️  Warning: %v\n", err)
+				continue
+			}
+			fmt.Printf("✅ Deleted ApplicationSet %s\n", ref)
+		case AppSetActionExclude:
+			exclusion, err := handler.ExcludeGeneratedApplications(ctx, ownership)
+			if err != nil {
+				fmt.Printf("⚠️  Warning: %v\n", err)
+				continue
+			}
+			for _, element := range exclusion.Elements {
+				fmt.Printf("✂️  Removed list generator element for %s from ApplicationSet %s\n", element.Application, ref)
+			}
+			if len(exclusion.Unmatched) > 0 {
+				fmt.Printf("⚠️  Could not find list generator elements for %s; they may be recreated by %s\n",
+					strings.Join(exclusion.Unmatched, ", "), ref)
+			}
+		default:
+			fmt.Printf("⚠️  Leaving ApplicationSet %s in place; it will likely recreate the deleted applications\n", ref)
+		}
+	}
+}
+
+// chooseAppSetAction resolves the action for one ApplicationSet, prompting when asked to
+func chooseAppSetAction(ref string, opts ArgoCDOptions) string {
+	if opts.AppSetAction != AppSetActionAsk {
+		return opts.AppSetAction
+	}
+	if opts.Prompt == nil {
+		return AppSetActionIgnore
+	}
+	if opts.Prompt(fmt.Sprintf("Delete ApplicationSet %s so it stops recreating these applications? (y/N): ", ref)) {
+		return AppSetActionDelete
+	}
+	if opts.Prompt(fmt.Sprintf("Remove the list generator elements for these applications from %s instead? (y/N): ", ref)) {
+		return AppSetActionExclude
+	}
+	return AppSetActionIgnore
+}
+
+// displayApplicationSetPlan prints what would happen to the ApplicationSets owning the Applications
+func displayApplicationSetPlan(ctx context.Context, detector *argocd.Detector, apps []unstructured.Unstructured, opts ArgoCDOptions) {
+	ownerships, err := detector.FindOwningApplicationSets(ctx, apps)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to look up owning ApplicationSets: %v\n", err)
+	}
+	if len(ownerships) == 0 {
+		return
+	}
+
+	fmt.Printf("\n🔍 ARGOCD APPLICATIONSETS (WOULD BE HANDLED FIRST):\n")
+	fmt.Printf("==================================================\n")
+	for _, ownership := range ownerships {
+		appSet := ownership.ApplicationSet
+		ref := appSet.GetNamespace() + "/" + appSet.GetName()
+		fmt.Printf("\n🧬 ApplicationSet: %s\n", ref)
+		fmt.Printf("   Generated: %s\n", strings.Join(ownership.ApplicationNames(), ", "))
+
+		exclusion := argocd.PlanApplicationSetExclusion(ownership)
+		switch opts.AppSetAction {
+		case AppSetActionDelete:
+			fmt.Printf("   🗑️  WOULD DELETE: kubectl delete applicationset %s -n %s --cascade=orphan\n", appSet.GetName(), appSet.GetNamespace())
+		case AppSetActionExclude:
+			for _, element := range exclusion.Elements {
+				fmt.Printf("   ✂️  WOULD REMOVE list generator element %d of generator %d (%s)\n", element.Element, element.Generator, element.Application)
+			}
+			if len(exclusion.Unmatched) > 0 {
+				fmt.Printf("   ⚠️  No list generator elements found for %s; they may be recreated\n", strings.Join(exclusion.Unmatched, ", "))
+			}
+		case AppSetActionIgnore:
+			fmt.Printf("   ⚠️  WOULD BE LEFT IN PLACE and will likely recreate the deleted applications\n")
+		default:
+			fmt.Printf("   ❓ WOULD ASK whether to delete it or exclude the generated applications\n")
+			if len(exclusion.Elements) > 0 {
+				fmt.Printf("   ✂️  %d of %d application(s) can be excluded through list generator elements\n",
+					len(exclusion.Elements), len(ownership.Applications))
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package kube
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/codesenju/kubectl-nuke-go/pkg/argocd"
+)
+
+func newTrackedApplication(name, destination string, resourceNamespaces ...string) unstructured.Unstructured {
+	var resources []interface{}
+	for _, ns := range resourceNamespaces {
+		resources = append(resources, map[string]interface{}{"kind": "ConfigMap", "namespace": ns, "name": name})
+	}
+	app := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Application",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "argocd"},
+		"spec":       map[string]interface{}{"destination": map[string]interface{}{"namespace": destination}},
+		"status":     map[string]interface{}{"resources": resources},
+	}}
+	app.SetFinalizers([]string{argocd.ResourcesFinalizer})
+	return app
+}
+
+func TestConfirmSpanningApplications(t *testing.T) {
+	apps := []unstructured.Unstructured{
+		newTrackedApplication("local", "team-a", "team-a"),
+		newTrackedApplication("platform", "team-a", "team-a", "flux-system"),
+	}
+	orphaning := newTrackedApplication("legacy", "team-a", "team-b")
+	orphaning.SetFinalizers(nil)
+	apps = append(apps, orphaning)
+
+	deleting, spanning := confirmSpanningApplications("team-a", apps, ArgoCDOptions{})
+	if len(deleting) != 2 || deleting[0].GetName() != "local" || deleting[1].GetName() != "legacy" {
+		t.Errorf("expected only applications that don't cascade into other namespaces to be deleted, got %v", applicationNames(deleting))
+	}
+	if len(spanning) != 1 || spanning[0].GetName() != "platform" {
+		t.Errorf("expected the application spanning namespaces to be held back without a prompt, got %v", applicationNames(spanning))
+	}
+
+	asked := 0
+	deleting, spanning = confirmSpanningApplications("team-a", apps, ArgoCDOptions{Prompt: func(string) bool { asked++; return true }})
+	if asked != 1 || len(deleting) != 3 || len(spanning) != 0 {
+		t.Errorf("expected a confirmed prompt to delete every application, got %d prompt(s), %v deleted", asked, applicationNames(deleting))
+	}
+}
+
+func applicationNames(apps []unstructured.Unstructured) []string {
+	var names []string
+	for _, app := range apps {
+		names = append(names, app.GetName())
+	}
+	return names
+}
@@ -14,6 +14,8 @@ import (
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+
+	"github.com/codesenju/kubectl-nuke-go/pkg/storage"
 )
 
 // CRDDiscoveryResult contains information about CRDs causing namespace termination issues
@@ -36,6 +38,7 @@ type ProblematicCRD struct {
 type ResourceWithFinalizers struct {
 	Name       string
 	Finalizers []string
+	Labels     map[string]string
 }
 
 // NamespaceConditionInfo contains parsed information from namespace conditions
@@ -68,6 +71,11 @@ func DiscoverProblematicCRDs(ctx context.Context, clientset kubernetes.Interface
 		return nil, fmt.Errorf("failed to create discovery client: %w", err)
 	}
 
+	return discoverProblematicCRDs(ctx, clientset, discoveryClient, dynamicClient, namespace)
+}
+
+// discoverProblematicCRDs analyzes the namespace with the given clients
+func discoverProblematicCRDs(ctx context.Context, clientset kubernetes.Interface, discoveryClient discovery.DiscoveryInterface, dynamicClient dynamic.Interface, namespace string) (*CRDDiscoveryResult, error) {
 	// Analyze namespace conditions
 	nsConditions, err := analyzeNamespaceConditions(ctx, clientset, namespace)
 	if err != nil {
@@ -131,8 +139,8 @@ func analyzeNamespaceConditions(ctx context.Context, clientset kubernetes.Interf
 func findCRDsWithFinalizers(ctx context.Context, dynamicClient dynamic.Interface, discoveryClient discovery.DiscoveryInterface, namespace string) ([]ProblematicCRD, error) {
 	var problematicCRDs []ProblematicCRD
 
-	// Get all API resources
-	apiResourceLists, err := discoveryClient.ServerPreferredNamespacedResources()
+	// Get all namespaced API resources that support list and delete operations
+	resources, err := discoverResources(discoveryClient, true, "list", "delete")
 	if err != nil {
 		// Continue with partial results if some APIs are unavailable
 		fmt.Printf("⚠️  Warning: Some API resources may not be accessible: %v\n", err)
@@ -140,44 +148,22 @@ func findCRDsWithFinalizers(ctx context.Context, dynamicClient dynamic.Interface
 
 	fmt.Printf("🔍 Scanning custom resources for finalizers...\n")
 
-	for _, apiResourceList := range apiResourceLists {
+	for _, res := range resources {
 		// Skip core Kubernetes APIs - focus on custom resources
-		if strings.Contains(apiResourceList.GroupVersion, "/v1") && !strings.Contains(apiResourceList.GroupVersion, ".") {
+		groupVersion := res.GVR.GroupVersion().String()
+		if strings.Contains(groupVersion, "/v1") && !strings.Contains(groupVersion, ".") {
 			continue
 		}
 
-		gv, err := schema.ParseGroupVersion(apiResourceList.GroupVersion)
+		// Check this CRD for resources with finalizers
+		problematicCRD, err := checkCRDForFinalizers(ctx, dynamicClient, res.GVR, res.APIResource, namespace)
 		if err != nil {
+			fmt.Printf("⚠️  Warning: Failed to check %s: %v\n", res.APIResource.Name, err)
 			continue
 		}
 
-		for _, apiResource := range apiResourceList.APIResources {
-			// Skip subresources and non-namespaced resources
-			if strings.Contains(apiResource.Name, "/") || !apiResource.Namespaced {
-				continue
-			}
-
-			// Only check resources that support list and delete operations
-			if !supportsVerb(apiResource.Verbs, "list") || !supportsVerb(apiResource.Verbs, "delete") {
-				continue
-			}
-
-			gvr := schema.GroupVersionResource{
-				Group:    gv.Group,
-				Version:  gv.Version,
-				Resource: apiResource.Name,
-			}
-
-			// Check this CRD for resources with finalizers
-			problematicCRD, err := checkCRDForFinalizers(ctx, dynamicClient, gvr, apiResource, namespace)
-			if err != nil {
-				fmt.Printf("⚠️  Warning: Failed to check %s: %v\n", apiResource.Name, err)
-				continue
-			}
-
-			if problematicCRD != nil {
-				problematicCRDs = append(problematicCRDs, *problematicCRD)
-			}
+		if problematicCRD != nil {
+			problematicCRDs = append(problematicCRDs, *problematicCRD)
 		}
 	}
 
@@ -208,6 +194,7 @@ func checkCRDForFinalizers(ctx context.Context, dynamicClient dynamic.Interface,
 			resourcesWithFinalizers = append(resourcesWithFinalizers, ResourceWithFinalizers{
 				Name:       resource.GetName(),
 				Finalizers: finalizers,
+				Labels:     resource.GetLabels(),
 			})
 		}
 	}
@@ -227,6 +214,72 @@ func checkCRDForFinalizers(ctx context.Context, dynamicClient dynamic.Interface,
 	}, nil
 }
 
+// storageStage names the force pipeline stage that tears down a resource type: the PVC handler, the snapshot
+// handler or a storage provider's teardown. It is empty for everything else.
+func storageStage(providers *storage.Registry, group, resource string) string {
+	switch {
+	case group == persistentVolumeClaimsResource.GVR.Group && resource == persistentVolumeClaimsResource.GVR.Resource:
+		return "the PVC handler"
+	case group == volumeSnapshotsResource.GVR.Group && resource == volumeSnapshotsResource.GVR.Resource:
+		return "the snapshot handler"
+	}
+	if provider := providers.ForGroup(group); provider != nil {
+		return "the " + provider.Name + " teardown"
+	}
+	return ""
+}
+
+// withoutStorageStages returns a copy of the discovery result without the VolumeSnapshots and the storage
+// providers' resources. The force pipeline tears those down later: snapshots under the --snapshot-policy override,
+// providers in their order and after checking their volumes. CRD cleanup must not delete them all at once and
+// strip their finalizers first.
+func (r *CRDDiscoveryResult) withoutStorageStages(providers *storage.Registry) *CRDDiscoveryResult {
+	filtered := *r
+	filtered.ProblematicCRDs = nil
+	for _, crd := range r.ProblematicCRDs {
+		if stage := storageStage(providers, crd.Group, crd.Name); stage != "" {
+			fmt.Printf("💾 Leaving %s to %s\n", crd.Name, stage)
+			continue
+		}
+		filtered.ProblematicCRDs = append(filtered.ProblematicCRDs, crd)
+	}
+	return &filtered
+}
+
+// withoutKept returns a copy of the discovery result with every resource the keep filter protects dropped,
+// so CRD cleanup never touches them
+func (r *CRDDiscoveryResult) withoutKept(keep KeepFilter) *CRDDiscoveryResult {
+	if keep.IsEmpty() {
+		return r
+	}
+
+	filtered := *r
+	filtered.ProblematicCRDs = nil
+	for _, crd := range r.ProblematicCRDs {
+		res := discoveredResource{
+			GVR:         schema.GroupVersionResource{Group: crd.Group, Version: crd.Version, Resource: crd.Name},
+			APIResource: metav1.APIResource{Name: crd.Name, Kind: crd.Kind},
+		}
+		if keep.keepsKind(res) {
+			continue
+		}
+
+		var resources []ResourceWithFinalizers
+		for _, resource := range crd.ResourcesWithFinalizers {
+			if !keep.keeps(res, resource.Labels) {
+				resources = append(resources, resource)
+			}
+		}
+		if len(resources) == 0 {
+			continue
+		}
+
+		crd.ResourcesWithFinalizers = resources
+		filtered.ProblematicCRDs = append(filtered.ProblematicCRDs, crd)
+	}
+	return &filtered
+}
+
 // displayDiscoveryResults shows the discovery results in a user-friendly format
 func displayDiscoveryResults(result *CRDDiscoveryResult, namespace string) {
 	fmt.Printf("\n🔍 CRD DISCOVERY RESULTS FOR NAMESPACE: %s\n", namespace)
@@ -298,8 +351,6 @@ func AttemptCRDCleanup(ctx context.Context, clientset kubernetes.Interface, resu
 		return nil
 	}
 
-	fmt.Printf("🧹 Attempting to clean up %d problematic CRDs...\n", len(result.ProblematicCRDs))
-
 	// Get REST config for dynamic operations
 	config, err := GetRESTConfig(clientset)
 	if err != nil {
@@ -311,6 +362,18 @@ func AttemptCRDCleanup(ctx context.Context, clientset kubernetes.Interface, resu
 		return fmt.Errorf("failed to create dynamic client: %w", err)
 	}
 
+	return attemptCRDCleanup(ctx, dynamicClient, result, namespace)
+}
+
+// attemptCRDCleanup cleans up the discovered problematic CRDs with the given dynamic client
+func attemptCRDCleanup(ctx context.Context, dynamicClient dynamic.Interface, result *CRDDiscoveryResult, namespace string) error {
+	if len(result.ProblematicCRDs) == 0 {
+		fmt.Printf("✅ No problematic CRDs to clean up\n")
+		return nil
+	}
+
+	fmt.Printf("🧹 Attempting to clean up %d problematic CRDs...\n", len(result.ProblematicCRDs))
+
 	var cleanupErrors []string
 	successfulCleanups := 0
 
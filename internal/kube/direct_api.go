@@ -0,0 +1,148 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// namespacesGVR is the core namespaces resource, addressed by the direct API client for the finalize endpoint
+var namespacesGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+// DirectAPI sends raw GET, PUT and PATCH requests to objects and their subresources, for --force-api-direct.
+// Requests bypass the typed and dynamic clients, so objects are sent exactly as read from the server.
+type DirectAPI struct {
+	client rest.Interface
+}
+
+// NewDirectAPI creates a direct API client sharing the clientset's connection and credentials
+func NewDirectAPI(clientset kubernetes.Interface) (*DirectAPI, error) {
+	client := clientset.Discovery().RESTClient()
+	if client == nil {
+		return nil, fmt.Errorf("clientset has no REST client")
+	}
+	return &DirectAPI{client: client}, nil
+}
+
+// resourcePath returns the API path of a resource collection, object or subresource. Core resources live under
+// /api/v1, the others under /apis/<group>/<version>; an empty namespace addresses a cluster-scoped resource.
+func resourcePath(gvr schema.GroupVersionResource, namespace, name string, subresources ...string) string {
+	segments := []string{"/apis", gvr.Group, gvr.Version}
+	if gvr.Group == "" {
+		segments = []string{"/api", gvr.Version}
+	}
+	if namespace != "" {
+		segments = append(segments, "namespaces", namespace)
+	}
+	segments = append(segments, gvr.Resource)
+	if name != "" {
+		segments = append(segments, name)
+	}
+	segments = append(segments, subresources...)
+	return path.Join(segments...)
+}
+
+// Get reads an object or one of its subresources
+func (d *DirectAPI) Get(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string, subresources ...string) (*unstructured.Unstructured, error) {
+	body, err := d.client.Get().AbsPath(resourcePath(gvr, namespace, name, subresources...)).Do(ctx).Raw()
+	if err != nil {
+		return nil, err
+	}
+	return decodeObject(body)
+}
+
+// Put replaces an object or one of its subresources with obj
+func (d *DirectAPI) Put(ctx context.Context, gvr schema.GroupVersionResource, namespace string, obj *unstructured.Unstructured, subresources ...string) (*unstructured.Unstructured, error) {
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s/%s: %w", gvr.Resource, obj.GetName(), err)
+	}
+	body, err := d.client.Put().
+		AbsPath(resourcePath(gvr, namespace, obj.GetName(), subresources...)).
+		SetHeader("Content-Type", "application/json").
+		Body(data).
+		Do(ctx).Raw()
+	if err != nil {
+		return nil, err
+	}
+	return decodeObject(body)
+}
+
+// Patch applies a patch to an object or one of its subresources
+func (d *DirectAPI) Patch(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string, pt types.PatchType, data []byte, subresources ...string) (*unstructured.Unstructured, error) {
+	body, err := d.client.Patch(pt).AbsPath(resourcePath(gvr, namespace, name, subresources...)).Body(data).Do(ctx).Raw()
+	if err != nil {
+		return nil, err
+	}
+	return decodeObject(body)
+}
+
+// RemoveFinalizers strips every finalizer from an object with a guarded JSON patch, falling back to a PUT of the
+// object with its finalizers emptied. The PUT carries the resourceVersion just read, so a concurrent change makes
+// it fail rather than be overwritten. Returns false without error if the object is gone or has no finalizers.
+func (d *DirectAPI) RemoveFinalizers(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) (bool, error) {
+	obj, err := d.Get(ctx, gvr, namespace, name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	patch, found, err := buildFinalizerStripPatch(obj.GetFinalizers())
+	if err != nil || !found {
+		return false, err
+	}
+	if _, err := d.Patch(ctx, gvr, namespace, name, types.JSONPatchType, patch); err == nil || errors.IsNotFound(err) {
+		return err == nil, nil
+	}
+
+	obj.SetFinalizers(nil)
+	if _, err := d.Put(ctx, gvr, namespace, obj); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// FinalizeNamespace clears a namespace's metadata finalizers, then PUTs it to the finalize endpoint with its spec
+// finalizers emptied, which is the only way to clear those
+func (d *DirectAPI) FinalizeNamespace(ctx context.Context, name string) error {
+	if _, err := d.RemoveFinalizers(ctx, namespacesGVR, "", name); err != nil {
+		return fmt.Errorf("failed to remove metadata finalizers: %w", err)
+	}
+
+	ns, err := d.Get(ctx, namespacesGVR, "", name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if err := unstructured.SetNestedStringSlice(ns.Object, []string{}, "spec", "finalizers"); err != nil {
+		return err
+	}
+	if _, err := d.Put(ctx, namespacesGVR, "", ns, "finalize"); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// decodeObject decodes a response body into an unstructured object
+func decodeObject(body []byte) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(body); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return obj, nil
+}
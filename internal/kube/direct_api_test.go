@@ -0,0 +1,113 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// newTestDirectAPI returns a direct API client talking to an HTTP test server
+func newTestDirectAPI(t *testing.T, handler http.HandlerFunc) *DirectAPI {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client, err := rest.UnversionedRESTClientFor(&rest.Config{
+		Host:          server.URL,
+		ContentConfig: rest.ContentConfig{NegotiatedSerializer: scheme.Codecs.WithoutConversion()},
+	})
+	if err != nil {
+		t.Fatalf("failed to create REST client: %v", err)
+	}
+	return &DirectAPI{client: client}
+}
+
+func TestResourcePath(t *testing.T) {
+	tests := []struct {
+		gvr         schema.GroupVersionResource
+		namespace   string
+		name        string
+		subresource []string
+		want        string
+	}{
+		{persistentVolumeClaimsResource.GVR, "shop", "data", nil, "/api/v1/namespaces/shop/persistentvolumeclaims/data"},
+		{widgetGVR, "shop", "w1", []string{"status"}, "/apis/example.com/v1/namespaces/shop/widgets/w1/status"},
+		{namespacesGVR, "", "shop", []string{"finalize"}, "/api/v1/namespaces/shop/finalize"},
+		{widgetGVR, "shop", "", nil, "/apis/example.com/v1/namespaces/shop/widgets"},
+	}
+	for _, tt := range tests {
+		if got := resourcePath(tt.gvr, tt.namespace, tt.name, tt.subresource...); got != tt.want {
+			t.Errorf("resourcePath(%v, %q, %q) = %q, want %q", tt.gvr, tt.namespace, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDirectAPIRemoveFinalizers(t *testing.T) {
+	const widget = `{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"it's \"quoted\"","namespace":"shop","resourceVersion":"7","finalizers":["example.com/cleanup"]}}`
+	var patched, put map[string]interface{}
+	direct := newTestDirectAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			io.WriteString(w, widget)
+		case http.MethodPatch:
+			patched = map[string]interface{}{"contentType": r.Header.Get("Content-Type"), "body": string(body)}
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			io.WriteString(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Invalid","code":422}`)
+		case http.MethodPut:
+			if err := json.Unmarshal(body, &put); err != nil {
+				t.Errorf("PUT body is not JSON: %v", err)
+			}
+			w.Write(body)
+		}
+	})
+
+	removed, err := direct.RemoveFinalizers(context.TODO(), widgetGVR, "shop", `it's "quoted"`)
+	if err != nil || !removed {
+		t.Fatalf("expected finalizers to be removed, got %v, %v", removed, err)
+	}
+	if patched["contentType"] != "application/json-patch+json" {
+		t.Errorf("expected a guarded JSON patch first, got %v", patched)
+	}
+	metadata, _ := put["metadata"].(map[string]interface{})
+	if metadata["finalizers"] != nil || metadata["resourceVersion"] != "7" || metadata["name"] != `it's "quoted"` {
+		t.Errorf("expected a PUT without finalizers keeping the name and resourceVersion, got %v", metadata)
+	}
+}
+
+func TestDirectAPIFinalizeNamespace(t *testing.T) {
+	const namespace = `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"shop"},"spec":{"finalizers":["kubernetes"]},"status":{"phase":"Terminating"}}`
+	var finalizePath string
+	var finalized map[string]interface{}
+	direct := newTestDirectAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			io.WriteString(w, namespace)
+		case http.MethodPut:
+			finalizePath = r.URL.Path
+			json.Unmarshal(body, &finalized)
+			w.Write(body)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	})
+
+	if err := direct.FinalizeNamespace(context.TODO(), "shop"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if finalizePath != "/api/v1/namespaces/shop/finalize" {
+		t.Errorf("expected a PUT to the finalize endpoint, got %q", finalizePath)
+	}
+	spec, _ := finalized["spec"].(map[string]interface{})
+	if finalizers, _ := spec["finalizers"].([]interface{}); spec == nil || len(finalizers) != 0 {
+		t.Errorf("expected spec.finalizers to be emptied, got %v", finalized["spec"])
+	}
+}
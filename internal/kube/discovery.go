@@ -0,0 +1,104 @@
+package kube
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// discoveredResource pairs a served resource type with its discovery metadata
+type discoveredResource struct {
+	GVR         schema.GroupVersionResource
+	APIResource metav1.APIResource
+}
+
+// discoverResources returns the server-preferred version of every resource type that supports all of
+// the given verbs. Subresources are skipped, and cluster-scoped types are dropped when namespacedOnly is set.
+// Results are sorted by group and resource so passes run in a stable order. Partial results are returned
+// together with the error when some API groups could not be discovered.
+func discoverResources(discoveryClient discovery.DiscoveryInterface, namespacedOnly bool, verbs ...string) ([]discoveredResource, error) {
+	apiResourceLists, err := discovery.ServerPreferredResources(discoveryClient)
+	if err != nil && len(apiResourceLists) == 0 {
+		return nil, fmt.Errorf("failed to discover API resources: %w", err)
+	}
+
+	var resources []discoveredResource
+	for _, apiResourceList := range apiResourceLists {
+		gv, parseErr := schema.ParseGroupVersion(apiResourceList.GroupVersion)
+		if parseErr != nil {
+			continue
+		}
+
+		for _, apiResource := range apiResourceList.APIResources {
+			if strings.Contains(apiResource.Name, "/") {
+				continue
+			}
+			if namespacedOnly && !apiResource.Namespaced {
+				continue
+			}
+
+			supported := true
+			for _, verb := range verbs {
+				if !supportsVerb(apiResource.Verbs, verb) {
+					supported = false
+					break
+				}
+			}
+			if !supported {
+				continue
+			}
+
+			resources = append(resources, discoveredResource{
+				GVR:         gv.WithResource(apiResource.Name),
+				APIResource: apiResource,
+			})
+		}
+	}
+
+	sort.SliceStable(resources, func(i, j int) bool {
+		if resources[i].GVR.Group != resources[j].GVR.Group {
+			return resources[i].GVR.Group < resources[j].GVR.Group
+		}
+		return resources[i].GVR.Resource < resources[j].GVR.Resource
+	})
+
+	return resources, err
+}
+
+// matchesKind reports whether a resource type is named by any of the given kind filters.
+// A filter may be the kind, the plural or singular resource name, a short name, or "resource.group".
+func (r discoveredResource) matchesKind(kinds []string) bool {
+	for _, kind := range kinds {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if kind == "" {
+			continue
+		}
+
+		if kind == strings.ToLower(r.APIResource.Kind) ||
+			kind == r.APIResource.Name ||
+			kind == r.APIResource.SingularName ||
+			(r.GVR.Group != "" && kind == r.APIResource.Name+"."+r.GVR.Group) ||
+			(r.GVR.Group != "" && kind == strings.ToLower(r.APIResource.Kind)+"."+r.GVR.Group) {
+			return true
+		}
+
+		for _, shortName := range r.APIResource.ShortNames {
+			if kind == shortName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// displayName returns the resource name qualified with its API group, as kubectl prints it
+func (r discoveredResource) displayName() string {
+	if r.GVR.Group == "" {
+		return r.GVR.Resource
+	}
+	return r.GVR.Resource + "." + r.GVR.Group
+}
@@ -0,0 +1,248 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+// finalizerPatchAttempts bounds how often a guarded finalizer patch is retried after a concurrent change
+const finalizerPatchAttempts = 3
+
+// FinalizerRemovalOptions scopes a bulk finalizer removal
+type FinalizerRemovalOptions struct {
+	Namespace     string
+	AllNamespaces bool
+	Kinds         []string
+	DryRun        bool
+}
+
+// FinalizerRemovalResult summarizes a bulk finalizer removal
+type FinalizerRemovalResult struct {
+	ResourceTypesScanned int
+	ObjectsScanned       int
+	Matched              int
+	Removed              int
+	Failed               int
+	MatchedByResource    map[string]int
+}
+
+// finalizerPatchOp is a single RFC 6902 JSON patch operation
+type finalizerPatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// RemoveFinalizerEverywhere finds every object carrying the named finalizer and removes just that entry,
+// leaving any other finalizers intact. Without AllNamespaces only namespaced resources in Namespace are scanned;
+// with it, every namespace and all cluster-scoped resources are scanned as well.
+func RemoveFinalizerEverywhere(ctx context.Context, dynamicClient dynamic.Interface, discoveryClient discovery.DiscoveryInterface, finalizer string, opts FinalizerRemovalOptions) (*FinalizerRemovalResult, error) {
+	if finalizer == "" {
+		return nil, fmt.Errorf("finalizer name must not be empty")
+	}
+
+	resources, err := discoverResources(discoveryClient, !opts.AllNamespaces, "list", "patch")
+	if err != nil {
+		if len(resources) == 0 {
+			return nil, err
+		}
+		// Continue with partial results if some APIs are unavailable
+		fmt.Printf("⚠️  Warning: Some API resources may not be accessible: %v\n", err)
+	}
+
+	scope := fmt.Sprintf("namespace %s", opts.Namespace)
+	if opts.AllNamespaces {
+		scope = "all namespaces and cluster-scoped resources"
+	}
+	fmt.Printf("🔍 Scanning %s for objects carrying finalizer %s...\n", scope, finalizer)
+
+	result := &FinalizerRemovalResult{MatchedByResource: map[string]int{}}
+
+	for _, res := range resources {
+		if len(opts.Kinds) > 0 && !res.matchesKind(opts.Kinds) {
+			continue
+		}
+		result.ResourceTypesScanned++
+
+		var resourceClient dynamic.ResourceInterface = dynamicClient.Resource(res.GVR)
+		if res.APIResource.Namespaced && !opts.AllNamespaces {
+			resourceClient = dynamicClient.Resource(res.GVR).Namespace(opts.Namespace)
+		}
+
+		list, err := resourceClient.List(ctx, metav1.ListOptions{})
+		if err != nil {
+			// Skip resources we can't list (permissions, etc.)
+			continue
+		}
+
+		for _, item := range list.Items {
+			result.ObjectsScanned++
+			if !containsString(item.GetFinalizers(), finalizer) {
+				continue
+			}
+
+			result.Matched++
+			result.MatchedByResource[res.displayName()]++
+
+			objectRef := item.GetName()
+			if item.GetNamespace() != "" {
+				objectRef = item.GetNamespace() + "/" + objectRef
+			}
+
+			if opts.DryRun {
+				fmt.Printf("   🔧 WOULD REMOVE %s from %s %s\n", finalizer, res.displayName(), objectRef)
+				continue
+			}
+
+			itemClient := resourceClient
+			if res.APIResource.Namespaced {
+				itemClient = dynamicClient.Resource(res.GVR).Namespace(item.GetNamespace())
+			}
+
+			removed, err := RemoveFinalizerFromObject(ctx, itemClient, item.GetName(), finalizer)
+			if err != nil {
+				result.Failed++
+				fmt.Printf("⚠️  Failed to remove %s from %s %s: %v\n", finalizer, res.displayName(), objectRef, err)
+				continue
+			}
+			if removed {
+				result.Removed++
+				fmt.Printf("✅ Removed %s from %s %s\n", finalizer, res.displayName(), objectRef)
+			}
+		}
+	}
+
+	displayFinalizerRemovalResult(result, finalizer, opts.DryRun)
+	return result, nil
+}
+
+// RemoveFinalizerFromObject removes a single named finalizer from an object with a guarded JSON patch.
+// The patch tests the finalizer's position before removing it, so a concurrent change to the list fails
+// the patch instead of removing the wrong entry; the object is then re-read and the patch retried.
+// Returns false without error if the object is gone or no longer carries the finalizer.
+func RemoveFinalizerFromObject(ctx context.Context, resourceClient dynamic.ResourceInterface, name, finalizer string) (bool, error) {
+	return patchFinalizers(ctx, resourceClient, name, func(finalizers []string) ([]byte, bool, error) {
+		return buildFinalizerRemovalPatch(finalizers, finalizer)
+	})
+}
+
+// stripAllFinalizers removes every finalizer from an object with a guarded JSON patch
+func stripAllFinalizers(ctx context.Context, resourceClient dynamic.ResourceInterface, name string) error {
+	_, err := patchFinalizers(ctx, resourceClient, name, buildFinalizerStripPatch)
+	return err
+}
+
+// patchFinalizers re-reads an object and applies the finalizer patch built from its current finalizers,
+// retrying only when a concurrent change makes the guarded patch's test op fail (Invalid) or the write
+// conflict; any other error is returned at once
+func patchFinalizers(ctx context.Context, resourceClient dynamic.ResourceInterface, name string, buildPatch func(finalizers []string) ([]byte, bool, error)) (bool, error) {
+	var lastErr error
+	for attempt := 0; attempt < finalizerPatchAttempts; attempt++ {
+		obj, err := resourceClient.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+
+		patch, found, err := buildPatch(obj.GetFinalizers())
+		if err != nil {
+			return false, err
+		}
+		if !found {
+			return false, nil
+		}
+
+		_, err = resourceClient.Patch(ctx, name, types.JSONPatchType, patch, metav1.PatchOptions{})
+		if err == nil {
+			return true, nil
+		}
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		if !errors.IsInvalid(err) && !errors.IsConflict(err) {
+			return false, err
+		}
+		lastErr = err
+	}
+
+	return false, fmt.Errorf("gave up after %d attempts: %w", finalizerPatchAttempts, lastErr)
+}
+
+// buildFinalizerRemovalPatch returns a JSON patch removing the named finalizer by index, guarded by a test op.
+// The boolean result is false if the finalizer is not present.
+func buildFinalizerRemovalPatch(finalizers []string, finalizer string) ([]byte, bool, error) {
+	for i, f := range finalizers {
+		if f != finalizer {
+			continue
+		}
+
+		path := fmt.Sprintf("/metadata/finalizers/%d", i)
+		patch, err := json.Marshal([]finalizerPatchOp{
+			{Op: "test", Path: path, Value: finalizer},
+			{Op: "remove", Path: path},
+		})
+		return patch, true, err
+	}
+	return nil, false, nil
+}
+
+// buildFinalizerStripPatch returns a JSON patch removing all finalizers, guarded by a test of the whole list.
+// The boolean result is false if there are no finalizers.
+func buildFinalizerStripPatch(finalizers []string) ([]byte, bool, error) {
+	if len(finalizers) == 0 {
+		return nil, false, nil
+	}
+
+	patch, err := json.Marshal([]finalizerPatchOp{
+		{Op: "test", Path: "/metadata/finalizers", Value: finalizers},
+		{Op: "remove", Path: "/metadata/finalizers"},
+	})
+	return patch, true, err
+}
+
+// displayFinalizerRemovalResult prints the counts of a bulk finalizer removal
+func displayFinalizerRemovalResult(result *FinalizerRemovalResult, finalizer string, dryRun bool) {
+	fmt.Printf("\n📊 FINALIZER REMOVAL SUMMARY: %s\n", finalizer)
+	fmt.Printf("================================================\n")
+	fmt.Printf("Resource types scanned: %d\n", result.ResourceTypesScanned)
+	fmt.Printf("Objects scanned: %d\n", result.ObjectsScanned)
+	fmt.Printf("Objects carrying the finalizer: %d\n", result.Matched)
+
+	resourceNames := make([]string, 0, len(result.MatchedByResource))
+	for name := range result.MatchedByResource {
+		resourceNames = append(resourceNames, name)
+	}
+	sort.Strings(resourceNames)
+	for _, name := range resourceNames {
+		fmt.Printf("  - %s: %d\n", name, result.MatchedByResource[name])
+	}
+
+	if dryRun {
+		fmt.Printf("🔍 DRY-RUN: no finalizers were removed\n")
+		return
+	}
+	fmt.Printf("Finalizers removed: %d\n", result.Removed)
+	if result.Failed > 0 {
+		fmt.Printf("⚠️  Failed: %d\n", result.Failed)
+	}
+}
+
+// containsString checks if a string slice contains the given value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package kube
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	discoveryfake "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+var widgetGVR = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+
+func newWidget(namespace, name string, finalizers ...string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("example.com/v1")
+	u.SetKind("Widget")
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	u.SetFinalizers(finalizers)
+	return u
+}
+
+func newFakeDiscovery(resources ...*metav1.APIResourceList) *discoveryfake.FakeDiscovery {
+	return &discoveryfake.FakeDiscovery{Fake: &clienttesting.Fake{Resources: resources}}
+}
+
+func widgetResourceList() *metav1.APIResourceList {
+	return &metav1.APIResourceList{
+		GroupVersion: "example.com/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "widgets", SingularName: "widget", Kind: "Widget", Namespaced: true, ShortNames: []string{"wd"},
+				Verbs: metav1.Verbs{"get", "list", "patch", "update", "delete", "deletecollection"}},
+		},
+	}
+}
+
+func TestRemoveFinalizerEverywhere(t *testing.T) {
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{widgetGVR: "WidgetList"},
+		newWidget("team-a", "keep-others", "other.example.com/guard", "foo.example.com/cleanup"),
+		newWidget("team-b", "only-target", "foo.example.com/cleanup"),
+		newWidget("team-b", "untouched", "other.example.com/guard"),
+	)
+	ctx := context.TODO()
+
+	result, err := RemoveFinalizerEverywhere(ctx, dynamicClient, newFakeDiscovery(widgetResourceList()), "foo.example.com/cleanup", FinalizerRemovalOptions{AllNamespaces: true})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Matched != 2 || result.Removed != 2 || result.Failed != 0 {
+		t.Errorf("expected 2 matched and 2 removed, got matched=%d removed=%d failed=%d", result.Matched, result.Removed, result.Failed)
+	}
+
+	expected := []struct {
+		namespace  string
+		name       string
+		finalizers []string
+	}{
+		{"team-a", "keep-others", []string{"other.example.com/guard"}},
+		{"team-b", "only-target", nil},
+		{"team-b", "untouched", []string{"other.example.com/guard"}},
+	}
+	for _, want := range expected {
+		obj, err := dynamicClient.Resource(widgetGVR).Namespace(want.namespace).Get(ctx, want.name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get %s/%s: %v", want.namespace, want.name, err)
+		}
+		if got := obj.GetFinalizers(); len(got) != len(want.finalizers) || (len(got) > 0 && !reflect.DeepEqual(got, want.finalizers)) {
+			t.Errorf("%s/%s: expected finalizers %v, got %v", want.namespace, want.name, want.finalizers, got)
+		}
+	}
+}
+
+func TestRemoveFinalizerEverywhere_NamespaceAndKindScope(t *testing.T) {
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{widgetGVR: "WidgetList"},
+		newWidget("team-a", "in-scope", "foo.example.com/cleanup"),
+		newWidget("team-b", "out-of-scope", "foo.example.com/cleanup"),
+	)
+	ctx := context.TODO()
+
+	result, err := RemoveFinalizerEverywhere(ctx, dynamicClient, newFakeDiscovery(widgetResourceList()), "foo.example.com/cleanup", FinalizerRemovalOptions{Namespace: "team-a", Kinds: []string{"wd"}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Removed != 1 {
+		t.Errorf("expected 1 finalizer removed, got %d", result.Removed)
+	}
+
+	obj, _ := dynamicClient.Resource(widgetGVR).Namespace("team-b").Get(ctx, "out-of-scope", metav1.GetOptions{})
+	if len(obj.GetFinalizers()) != 1 {
+		t.Errorf("expected object outside the namespace to keep its finalizer, got %v", obj.GetFinalizers())
+	}
+
+	result, err = RemoveFinalizerEverywhere(ctx, dynamicClient, newFakeDiscovery(widgetResourceList()), "foo.example.com/cleanup", FinalizerRemovalOptions{AllNamespaces: true, Kinds: []string{"gadgets"}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.ResourceTypesScanned != 0 || result.Matched != 0 {
+		t.Errorf("expected kind filter to exclude widgets, got %d types scanned and %d matched", result.ResourceTypesScanned, result.Matched)
+	}
+}
+
+func TestBuildFinalizerRemovalPatch(t *testing.T) {
+	patch, found, err := buildFinalizerRemovalPatch([]string{"a", "b", "c"}, "b")
+	if err != nil || !found {
+		t.Fatalf("expected patch for present finalizer, got found=%v err=%v", found, err)
+	}
+	want := `[{"op":"test","path":"/metadata/finalizers/1","value":"b"},{"op":"remove","path":"/metadata/finalizers/1"}]`
+	if string(patch) != want {
+		t.Errorf("expected patch %s, got %s", want, string(patch))
+	}
+
+	if _, found, _ := buildFinalizerRemovalPatch([]string{"a"}, "b"); found {
+		t.Errorf("expected no patch for missing finalizer")
+	}
+}
+
+func TestPatchFinalizers_RetriesOnlyFailedTests(t *testing.T) {
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{widgetGVR: "WidgetList"},
+		newWidget("team-a", "guarded", "foo.example.com/cleanup"),
+	)
+	patches := 0
+	patchErr := errors.NewInvalid(schema.GroupKind{Group: "example.com", Kind: "Widget"}, "guarded", nil)
+	dynamicClient.PrependReactor("patch", "widgets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		patches++
+		return true, nil, patchErr
+	})
+	resourceClient := dynamicClient.Resource(widgetGVR).Namespace("team-a")
+
+	if _, err := RemoveFinalizerFromObject(context.TODO(), resourceClient, "guarded", "foo.example.com/cleanup"); err == nil {
+		t.Fatalf("expected an error after the test op kept failing")
+	}
+	if patches != finalizerPatchAttempts {
+		t.Errorf("expected %d attempts for a failed test op, got %d", finalizerPatchAttempts, patches)
+	}
+
+	patches = 0
+	patchErr = errors.NewForbidden(schema.GroupResource{Group: "example.com", Resource: "widgets"}, "guarded", nil)
+	if _, err := RemoveFinalizerFromObject(context.TODO(), resourceClient, "guarded", "foo.example.com/cleanup"); !errors.IsForbidden(err) {
+		t.Fatalf("expected the forbidden error to be returned, got %v", err)
+	}
+	if patches != 1 {
+		t.Errorf("expected a forbidden patch not to be retried, got %d attempts", patches)
+	}
+}
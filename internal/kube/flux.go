@@ -0,0 +1,223 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/codesenju/kubectl-nuke-go/pkg/flux"
+)
+
+// FluxOptions tunes how Flux Kustomizations and HelmReleases applying to the namespace are handled
+type FluxOptions struct {
+	// Mode is one of flux.ModeDelete (default), flux.ModeSuspend or flux.ModeOrphan
+	Mode string
+	// Timeout bounds the wait for Flux to finalize each deleted reconciler; zero means flux.Timeout
+	Timeout time.Duration
+	// Prompt asks a yes/no question; without it, reconcilers pruning other namespaces are suspended, not deleted
+	Prompt func(message string) bool
+}
+
+// newFluxClients creates the Flux detector and handler for the pipeline
+func newFluxClients(clientset kubernetes.Interface, dynamicClient dynamic.Interface, opts FluxOptions) (*flux.Detector, *flux.Handler) {
+	return flux.NewDetector(clientset, dynamicClient), flux.NewHandler(dynamicClient).WithTimeout(opts.Timeout)
+}
+
+// detectFluxReconcilers finds the Kustomizations and HelmReleases applying resources to the namespace
+func detectFluxReconcilers(ctx context.Context, detector *flux.Detector, namespace string) []flux.Reconciler {
+	installed, err := detector.IsInstalled()
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to check whether Flux is installed: %v\n", err)
+		return nil
+	}
+	if !installed {
+		return nil
+	}
+
+	fmt.Printf("🔍 Checking for Flux Kustomizations and HelmReleases applying to namespace: %s\n", namespace)
+	reconcilers, err := detector.DetectReconcilersForNamespace(ctx, namespace)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to detect Flux reconcilers: %v\n", err)
+		return nil
+	}
+	if len(reconcilers) == 0 {
+		fmt.Printf("ℹ️  No Flux reconcilers found applying to this namespace\n")
+		return nil
+	}
+
+	fmt.Printf("🎯 Found %d Flux reconciler(s) applying to this namespace:\n", len(reconcilers))
+	for _, r := range reconcilers {
+		fmt.Printf("  - %s (%s)\n", r, strings.Join(r.Reasons, ", "))
+	}
+	return reconcilers
+}
+
+// leavesFluxReconcilersInPlace reports whether contents-only mode must skip deleting reconcilers because one
+// of them prunes the namespace object itself
+func leavesFluxReconcilersInPlace(reconcilers []flux.Reconciler, opts NukeOptions) bool {
+	if !opts.ContentsOnly || (opts.Flux.Mode != flux.ModeDelete && opts.Flux.Mode != "") {
+		return false
+	}
+	for _, r := range reconcilers {
+		if r.ManagesNamespace && r.Prunes() {
+			return true
+		}
+	}
+	return false
+}
+
+// handleFluxReconcilers suspends the Kustomizations that would reapply the reconcilers, then handles the
+// reconcilers according to the mode
+func handleFluxReconcilers(ctx context.Context, detector *flux.Detector, handler *flux.Handler, reconcilers []flux.Reconciler, opts FluxOptions) {
+	fmt.Printf("🔄 Handling Flux reconcilers before namespace deletion...\n")
+
+	parents, err := detector.FindParents(ctx, reconcilers)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to find parent Kustomizations: %v\n", err)
+	}
+	for _, parent := range parents {
+		if parent.Suspended() {
+			continue
+		}
+		fmt.Printf("⏸️  Suspending parent Flux %s (%s) so it doesn't reapply\n", parent, strings.Join(parent.Reasons, ", "))
+		if err := handler.Suspend(ctx, parent); err != nil {
+			fmt.Printf("⚠️ Warning: %v\n", err)
+		}
+	}
+
+	if opts.Mode == flux.ModeDelete || opts.Mode == "" {
+		var spanning []flux.Reconciler
+		reconcilers, spanning = confirmSpanningReconcilers(reconcilers, opts)
+		if err := handler.HandleReconcilers(ctx, spanning, flux.ModeSuspend); err != nil {
+			fmt.Printf("⚠️  Warning: Failed to suspend some Flux reconcilers: %v\n", err)
+		}
+	}
+	if err := handler.HandleReconcilers(ctx, reconcilers, opts.Mode); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to handle some Flux reconcilers: %v\n", err)
+	}
+}
+
+// confirmSpanningReconcilers splits off the reconcilers whose pruning would reach objects outside the namespace,
+// unless the user agrees to delete them anyway. Without a prompt they are never deleted with pruning.
+func confirmSpanningReconcilers(reconcilers []flux.Reconciler, opts FluxOptions) ([]flux.Reconciler, []flux.Reconciler) {
+	var deleting, spanning []flux.Reconciler
+	for _, r := range reconcilers {
+		if r.PrunesOutside() {
+			spanning = append(spanning, r)
+		} else {
+			deleting = append(deleting, r)
+		}
+	}
+	if len(spanning) == 0 {
+		return reconcilers, nil
+	}
+
+	fmt.Printf("⚠️  %d Flux reconciler(s) also applied objects outside this namespace; deleting them prunes those too:\n", len(spanning))
+	for _, r := range spanning {
+		fmt.Printf("  - %s (%s)\n", r, describeOutside(r))
+	}
+	if opts.Prompt != nil && opts.Prompt(fmt.Sprintf("Delete these %d reconciler(s) and everything they applied outside this namespace? (y/N): ", len(spanning))) {
+		return reconcilers, nil
+	}
+	fmt.Printf("⏸️  Suspending them instead\n")
+	return deleting, spanning
+}
+
+// describeOutside summarises the reconciler's inventory entries outside the namespace
+func describeOutside(r flux.Reconciler) string {
+	if len(r.OtherNamespaces) == 0 {
+		return fmt.Sprintf("%d cluster-scoped object(s)", r.Outside)
+	}
+	return fmt.Sprintf("%d object(s) elsewhere, in %s", r.Outside, strings.Join(r.OtherNamespaces, ", "))
+}
+
+// fluxModeOutcome describes what a mode does to the reconcilers, for dry-run headings
+func fluxModeOutcome(mode string) string {
+	switch mode {
+	case flux.ModeSuspend:
+		return "WOULD BE SUSPENDED"
+	case flux.ModeOrphan:
+		return "WOULD BE DELETED WITHOUT PRUNING"
+	default:
+		return "WOULD BE DELETED"
+	}
+}
+
+// displayFluxPlan prints the dry-run actions for the reconcilers and their parents
+func displayFluxPlan(ctx context.Context, detector *flux.Detector, reconcilers []flux.Reconciler, opts NukeOptions) {
+	fmt.Printf("\n🔍 FLUX RECONCILERS (%s):\n", fluxModeOutcome(opts.Flux.Mode))
+	fmt.Printf("=========================================\n")
+	if leavesFluxReconcilersInPlace(reconcilers, opts) {
+		fmt.Printf("⚠️  Contents-only mode keeps the namespace, and a reconciler prunes the namespace object; they would be left in place\n")
+		fmt.Printf("💡 Use --flux-mode suspend or orphan to stop them without pruning the namespace\n")
+		return
+	}
+
+	if parents, err := detector.FindParents(ctx, reconcilers); err == nil {
+		for _, parent := range parents {
+			if !parent.Suspended() {
+				fmt.Printf("⏸️  WOULD SUSPEND parent Flux %s (%s)\n", parent, strings.Join(parent.Reasons, ", "))
+			}
+		}
+	}
+
+	for _, r := range reconcilers {
+		fmt.Printf("\n📊 Flux %s\n", r)
+		fmt.Printf("   🔗 Matches: %s\n", strings.Join(r.Reasons, ", "))
+		switch opts.Flux.Mode {
+		case flux.ModeSuspend:
+			fmt.Printf("   ⏸️  WOULD SET spec.suspend: true\n")
+		case flux.ModeOrphan:
+			if r.Kind() == flux.KustomizationKind {
+				fmt.Printf("   🔓 WOULD SET spec.prune: false\n")
+			} else {
+				fmt.Printf("   🔓 WOULD SET spec.suspend: true (helm-controller skips the uninstall)\n")
+			}
+			fmt.Printf("   🗑️  WOULD DELETE: resources left to the nuke\n")
+		default:
+			if r.PrunesOutside() {
+				fmt.Printf("   ❓ WOULD ASK before deleting it, as Flux also prunes %s; otherwise suspend it\n", describeOutside(r))
+			} else if r.Prunes() {
+				fmt.Printf("   🗑️  WOULD DELETE: Flux prunes the %d object(s) it applied here\n", r.Inventory)
+			} else {
+				fmt.Printf("   🗑️  WOULD DELETE: pruning is off, resources left to the nuke\n")
+			}
+		}
+		if finalizers := r.Object.GetFinalizers(); len(finalizers) > 0 && opts.Flux.Mode != flux.ModeSuspend {
+			fmt.Printf("   🔧 WOULD REMOVE %s if Flux doesn't finalize it in time\n", flux.Finalizer)
+		}
+	}
+}
+
+// displayFluxDiagnostics prints the state of the reconcilers applying to the namespace
+func displayFluxDiagnostics(reconcilers []flux.Reconciler) {
+	fmt.Printf("\n🔍 FLUX DIAGNOSTICS:\n")
+	fmt.Printf("===================\n")
+	for _, r := range reconcilers {
+		fmt.Printf("\n📊 Flux %s\n", r)
+		fmt.Printf("🔗 Matches: %s\n", strings.Join(r.Reasons, ", "))
+		status, message := r.Ready()
+		fmt.Printf("💓 Ready: %s\n", status)
+		if message != "" {
+			fmt.Printf("   Message: %s\n", message)
+		}
+		if r.Suspended() {
+			fmt.Printf("⏸️  Suspended\n")
+		}
+		if r.ManagesNamespace {
+			fmt.Printf("⚠️  Applies the namespace object itself; deleting it with pruning deletes the namespace\n")
+		}
+		if r.Outside > 0 {
+			fmt.Printf("⚠️  Also applied %s; deleting it with pruning deletes them too\n", describeOutside(r))
+		}
+		if finalizers := r.Object.GetFinalizers(); len(finalizers) > 0 {
+			fmt.Printf("⚠️  Has finalizers: %v\n", finalizers)
+			fmt.Printf("💡 Tip: Flux removes %s after pruning; it hangs if the controller is gone\n", flux.Finalizer)
+		}
+	}
+	fmt.Printf("\n💡 Stop Flux from reapplying the namespace's contents with --flux-mode suspend, or delete the reconcilers with --flux-mode delete|orphan\n")
+}
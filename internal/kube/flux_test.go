@@ -0,0 +1,45 @@
+package kube
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/codesenju/kubectl-nuke-go/pkg/flux"
+)
+
+func newKustomizationReconciler(name string, prune bool, outside int) flux.Reconciler {
+	obj := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": flux.KustomizeGroup + "/v1",
+		"kind":       flux.KustomizationKind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": "flux-system"},
+		"spec":       map[string]interface{}{"prune": prune},
+	}}
+	r := flux.Reconciler{Object: obj, Inventory: 1, Outside: outside}
+	if outside > 0 {
+		r.OtherNamespaces = []string{"flux-system"}
+	}
+	return r
+}
+
+func TestConfirmSpanningReconcilers(t *testing.T) {
+	reconcilers := []flux.Reconciler{
+		newKustomizationReconciler("shop", true, 0),
+		newKustomizationReconciler("apps", true, 3),
+		newKustomizationReconciler("legacy", false, 2),
+	}
+
+	deleting, spanning := confirmSpanningReconcilers(reconcilers, FluxOptions{})
+	if len(deleting) != 2 || deleting[0].Object.GetName() != "shop" || deleting[1].Object.GetName() != "legacy" {
+		t.Errorf("expected only reconcilers that don't prune outside the namespace to be deleted, got %v", deleting)
+	}
+	if len(spanning) != 1 || spanning[0].Object.GetName() != "apps" {
+		t.Errorf("expected the reconciler pruning other namespaces to be held back without a prompt, got %v", spanning)
+	}
+
+	asked := 0
+	deleting, spanning = confirmSpanningReconcilers(reconcilers, FluxOptions{Prompt: func(string) bool { asked++; return true }})
+	if asked != 1 || len(deleting) != 3 || len(spanning) != 0 {
+		t.Errorf("expected a confirmed prompt to delete every reconciler, got %d prompt(s), %v deleted", asked, deleting)
+	}
+}
@@ -0,0 +1,425 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+var namespaceGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+// Manifest deletion outcomes shown in the summary table
+const (
+	ManifestStatusDeleted     = "deleted"
+	ManifestStatusNotFound    = "not found"
+	ManifestStatusUnstuck     = "deleted (finalizers removed)"
+	ManifestStatusStuck       = "still present"
+	ManifestStatusFailed      = "failed"
+	ManifestStatusWouldDelete = "would delete"
+)
+
+// ManifestSource is an object read from a manifest, with the file or stream it came from
+type ManifestSource struct {
+	Object *unstructured.Unstructured
+	Source string
+}
+
+// ManifestPlan is the ordered teardown of the objects described by a set of manifests
+type ManifestPlan struct {
+	Steps []OperatorTeardownStep
+	// Unresolved lists objects whose kind the API server does not serve
+	Unresolved []string
+}
+
+// TotalObjects returns the number of objects across all steps of the plan
+func (p *ManifestPlan) TotalObjects() int {
+	total := 0
+	for _, step := range p.Steps {
+		total += len(step.Objects)
+	}
+	return total
+}
+
+// ManifestDeletionResult records what happened to one manifest object
+type ManifestDeletionResult struct {
+	Object OperatorObject
+	Status string
+	Error  error
+}
+
+// ReadManifests reads every object from the given files, directories and "-" (stdin).
+// Directories contribute their .yaml, .yml and .json files, descending into subdirectories when recursive is set.
+func ReadManifests(paths []string, stdin io.Reader, recursive bool) ([]ManifestSource, error) {
+	var sources []ManifestSource
+	for _, path := range paths {
+		if path == "-" {
+			objects, err := decodeManifest(stdin, "stdin")
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, objects...)
+			continue
+		}
+
+		files, err := manifestFiles(path, recursive)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			f, err := os.Open(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open %s: %w", file, err)
+			}
+			objects, err := decodeManifest(f, file)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, objects...)
+		}
+	}
+	return sources, nil
+}
+
+// manifestFiles expands a path into the manifest files it names
+func manifestFiles(path string, recursive bool) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != path && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".yaml", ".yml", ".json":
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// decodeManifest decodes a multi-document YAML or JSON stream, expanding List kinds into their items
+func decodeManifest(r io.Reader, source string) ([]ManifestSource, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+
+	var sources []ManifestSource
+	for {
+		var doc map[string]interface{}
+		if err := decoder.Decode(&doc); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+		}
+		if len(doc) == 0 {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{Object: doc}
+		if obj.IsList() {
+			err := obj.EachListItem(func(item runtime.Object) error {
+				sources = append(sources, ManifestSource{Object: item.(*unstructured.Unstructured), Source: source})
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to read list in %s: %w", source, err)
+			}
+			continue
+		}
+
+		if obj.GetAPIVersion() == "" || obj.GetKind() == "" {
+			return nil, fmt.Errorf("object in %s is missing apiVersion or kind", source)
+		}
+		sources = append(sources, ManifestSource{Object: obj, Source: source})
+	}
+	return sources, nil
+}
+
+// PlanManifestDeletion maps each manifest object to its resource type and orders them for deletion:
+// custom resources first while their operators still run, then other namespaced objects, cluster-scoped objects,
+// CRDs and finally namespaces. Within a step objects are deleted in reverse manifest order.
+func PlanManifestDeletion(ctx context.Context, dynamicClient dynamic.Interface, discoveryClient discovery.DiscoveryInterface, sources []ManifestSource, defaultNamespace string) (*ManifestPlan, error) {
+	groupResources, err := restmapper.GetAPIGroupResources(discoveryClient)
+	if err != nil && len(groupResources) == 0 {
+		return nil, fmt.Errorf("failed to discover API resources: %w", err)
+	}
+	mapper := restmapper.NewDiscoveryRESTMapper(groupResources)
+
+	// Kinds defined by CRDs in the manifests themselves count as custom resources
+	manifestCRDKinds := map[schema.GroupKind]bool{}
+	for _, src := range sources {
+		gvk := src.Object.GroupVersionKind()
+		if gvk.Group != crdGVR.Group || gvk.Kind != "CustomResourceDefinition" {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(src.Object.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(src.Object.Object, "spec", "names", "kind")
+		manifestCRDKinds[schema.GroupKind{Group: group, Kind: kind}] = true
+	}
+
+	customResources := OperatorTeardownStep{Name: "Custom resources", WaitForDeletion: true}
+	namespaced := OperatorTeardownStep{Name: "Namespaced objects", WaitForDeletion: true}
+	clusterScoped := OperatorTeardownStep{Name: "Cluster-scoped objects", WaitForDeletion: true}
+	crds := OperatorTeardownStep{Name: "CustomResourceDefinitions", WaitForDeletion: true}
+	namespaces := OperatorTeardownStep{Name: "Namespaces", WaitForDeletion: true}
+
+	plan := &ManifestPlan{}
+	clusterCRDs := map[schema.GroupResource]bool{}
+
+	// Walk the manifests backwards so later objects, which usually depend on earlier ones, go first
+	for i := len(sources) - 1; i >= 0; i-- {
+		obj := sources[i].Object
+		gvk := obj.GroupVersionKind()
+
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			plan.Unresolved = append(plan.Unresolved, fmt.Sprintf("%s %s (%s): %v", gvk.Kind, obj.GetName(), sources[i].Source, err))
+			continue
+		}
+
+		object := OperatorObject{
+			GVR:  mapping.Resource,
+			Kind: gvk.Kind,
+			Name: obj.GetName(),
+		}
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			object.Namespace = obj.GetNamespace()
+			if object.Namespace == "" {
+				object.Namespace = defaultNamespace
+			}
+		}
+
+		switch {
+		case mapping.Resource == namespaceGVR:
+			namespaces.Objects = append(namespaces.Objects, object)
+		case mapping.Resource.GroupResource() == crdGVR.GroupResource():
+			crds.Objects = append(crds.Objects, object)
+		case manifestCRDKinds[gvk.GroupKind()] || isServedByCRD(ctx, dynamicClient, mapping.Resource.GroupResource(), clusterCRDs):
+			customResources.Objects = append(customResources.Objects, object)
+		case object.Namespace != "":
+			namespaced.Objects = append(namespaced.Objects, object)
+		default:
+			clusterScoped.Objects = append(clusterScoped.Objects, object)
+		}
+	}
+
+	plan.Steps = []OperatorTeardownStep{customResources, namespaced, clusterScoped, crds, namespaces}
+	return plan, nil
+}
+
+// isServedByCRD reports whether a resource type is backed by a CRD on the cluster, caching lookups per type
+func isServedByCRD(ctx context.Context, dynamicClient dynamic.Interface, gr schema.GroupResource, cache map[schema.GroupResource]bool) bool {
+	if gr.Group == "" {
+		return false
+	}
+	if served, ok := cache[gr]; ok {
+		return served
+	}
+	_, err := dynamicClient.Resource(crdGVR).Get(ctx, gr.String(), metav1.GetOptions{})
+	cache[gr] = err == nil
+	return cache[gr]
+}
+
+// DisplayManifestPlan prints the ordered steps of a manifest deletion
+func DisplayManifestPlan(plan *ManifestPlan) {
+	fmt.Printf("\n🎯 MANIFEST DELETION PLAN\n")
+	fmt.Printf("================================================\n")
+
+	for _, unresolved := range plan.Unresolved {
+		fmt.Printf("⚠️  Skipping unknown kind: %s\n", unresolved)
+	}
+
+	if plan.TotalObjects() == 0 {
+		fmt.Printf("✅ Nothing to delete\n")
+		return
+	}
+
+	step := 1
+	for _, s := range plan.Steps {
+		if len(s.Objects) == 0 {
+			continue
+		}
+		fmt.Printf("\n%d. %s (%d):\n", step, s.Name, len(s.Objects))
+		for _, obj := range s.Objects {
+			fmt.Printf("   - %s %s\n", obj.Kind, objectRef(obj))
+		}
+		step++
+	}
+
+	fmt.Printf("\n📊 Total: %d objects\n", plan.TotalObjects())
+}
+
+// DeleteManifestObjects runs the plan step by step. Each object is deleted, then given the timeout to disappear;
+// stragglers have their finalizers stripped (namespaces are also finalized) and are waited on once more.
+// In dry-run mode nothing is changed and every object that exists is reported as would-delete.
+func DeleteManifestObjects(ctx context.Context, dynamicClient dynamic.Interface, plan *ManifestPlan, timeout time.Duration, dryRun bool) []ManifestDeletionResult {
+	var results []ManifestDeletionResult
+
+	propagation := metav1.DeletePropagationBackground
+	for _, step := range plan.Steps {
+		if len(step.Objects) == 0 {
+			continue
+		}
+
+		if dryRun {
+			for _, obj := range step.Objects {
+				status := ManifestStatusWouldDelete
+				if _, err := operatorObjectClient(dynamicClient, obj).Get(ctx, obj.Name, metav1.GetOptions{}); errors.IsNotFound(err) {
+					status = ManifestStatusNotFound
+				}
+				results = append(results, ManifestDeletionResult{Object: obj, Status: status})
+			}
+			continue
+		}
+
+		fmt.Printf("\n🗑️  Deleting %s (%d)...\n", step.Name, len(step.Objects))
+		statuses := map[string]*ManifestDeletionResult{}
+		var pending []OperatorObject
+		for _, obj := range step.Objects {
+			result := &ManifestDeletionResult{Object: obj, Status: ManifestStatusDeleted}
+			statuses[objectKey(obj)] = result
+
+			err := operatorObjectClient(dynamicClient, obj).Delete(ctx, obj.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+			switch {
+			case errors.IsNotFound(err):
+				result.Status = ManifestStatusNotFound
+			case err != nil:
+				fmt.Printf("⚠️  Failed to delete %s %s: %v\n", obj.Kind, objectRef(obj), err)
+				result.Status = ManifestStatusFailed
+				result.Error = err
+			default:
+				pending = append(pending, obj)
+			}
+		}
+
+		remaining := pending
+		if step.WaitForDeletion && len(pending) > 0 {
+			remaining = waitForOperatorObjects(ctx, dynamicClient, pending, timeout)
+		}
+		if len(remaining) > 0 {
+			for _, obj := range remaining {
+				fmt.Printf("🔧 %s %s is stuck, removing finalizers...\n", obj.Kind, objectRef(obj))
+				result := statuses[objectKey(obj)]
+				if err := unstickManifestObject(ctx, dynamicClient, obj); err != nil {
+					fmt.Printf("⚠️  Failed to remove finalizers from %s %s: %v\n", obj.Kind, objectRef(obj), err)
+					result.Status = ManifestStatusFailed
+					result.Error = err
+					continue
+				}
+				result.Status = ManifestStatusUnstuck
+			}
+
+			for _, obj := range waitForOperatorObjects(ctx, dynamicClient, remaining, timeout) {
+				if result := statuses[objectKey(obj)]; result.Status != ManifestStatusFailed {
+					result.Status = ManifestStatusStuck
+				}
+			}
+		}
+
+		for _, obj := range step.Objects {
+			results = append(results, *statuses[objectKey(obj)])
+		}
+		fmt.Printf("✅ %s done\n", step.Name)
+	}
+
+	return results
+}
+
+// unstickManifestObject strips an object's finalizers; namespaces also get their spec finalizers cleared
+// through the finalize subresource
+func unstickManifestObject(ctx context.Context, dynamicClient dynamic.Interface, obj OperatorObject) error {
+	resourceClient := operatorObjectClient(dynamicClient, obj)
+	if err := stripAllFinalizers(ctx, resourceClient, obj.Name); err != nil {
+		return err
+	}
+	if obj.GVR != namespaceGVR {
+		return nil
+	}
+
+	ns, err := resourceClient.Get(ctx, obj.Name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if err := unstructured.SetNestedStringSlice(ns.Object, []string{}, "spec", "finalizers"); err != nil {
+		return err
+	}
+	_, err = resourceClient.Update(ctx, ns, metav1.UpdateOptions{}, "finalize")
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// DisplayManifestDeletionSummary prints a table of what happened to each manifest object
+func DisplayManifestDeletionSummary(results []ManifestDeletionResult) {
+	fmt.Printf("\n📊 MANIFEST DELETION SUMMARY\n")
+	fmt.Printf("================================================\n")
+	fmt.Printf("%-28s %-50s %s\n", "KIND", "NAME", "STATUS")
+
+	counts := map[string]int{}
+	for _, result := range results {
+		status := result.Status
+		if result.Error != nil {
+			status = fmt.Sprintf("%s: %v", status, result.Error)
+		}
+		fmt.Printf("%-28s %-50s %s\n", result.Object.Kind, objectRef(result.Object), status)
+		counts[result.Status]++
+	}
+
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	fmt.Printf("\nTotal: %d objects", len(results))
+	for _, status := range statuses {
+		fmt.Printf(", %d %s", counts[status], status)
+	}
+	fmt.Println()
+}
+
+// objectRef formats an object as namespace/name, or just name if cluster-scoped
+func objectRef(obj OperatorObject) string {
+	if obj.Namespace != "" {
+		return obj.Namespace + "/" + obj.Name
+	}
+	return obj.Name
+}
+
+// objectKey identifies an object by resource type, namespace and name, for use as a map key
+func objectKey(obj OperatorObject) string {
+	return obj.GVR.String() + "/" + objectRef(obj)
+}
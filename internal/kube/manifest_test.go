@@ -0,0 +1,220 @@
+package kube
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+const testManifest = `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Namespaced
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: widget-operator
+---
+# an empty document is skipped
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: team-a
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: one
+  finalizers:
+  - example.com/cleanup
+---
+apiVersion: gadgets.io/v1
+kind: Gadget
+metadata:
+  name: unknown
+---
+{"apiVersion": "v1", "kind": "List", "items": [
+  {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "extra", "namespace": "team-a"}}
+]}
+`
+
+func manifestResourceLists() []*metav1.APIResourceList {
+	return []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "namespaces", SingularName: "namespace", Kind: "Namespace", Verbs: metav1.Verbs{"get", "list", "delete"}},
+				{Name: "configmaps", SingularName: "configmap", Kind: "ConfigMap", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "delete"}},
+			},
+		},
+		{
+			GroupVersion: "apiextensions.k8s.io/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "customresourcedefinitions", SingularName: "customresourcedefinition", Kind: "CustomResourceDefinition", Verbs: metav1.Verbs{"get", "list", "delete"}},
+			},
+		},
+		{
+			GroupVersion: "rbac.authorization.k8s.io/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "clusterroles", SingularName: "clusterrole", Kind: "ClusterRole", Verbs: metav1.Verbs{"get", "list", "delete"}},
+			},
+		},
+		widgetResourceList(),
+	}
+}
+
+func TestDecodeManifest(t *testing.T) {
+	sources, err := decodeManifest(strings.NewReader(testManifest), "test.yaml")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var names []string
+	for _, src := range sources {
+		names = append(names, src.Object.GetKind()+"/"+src.Object.GetName())
+	}
+	want := "Namespace/team-a CustomResourceDefinition/widgets.example.com ClusterRole/widget-operator ConfigMap/settings Widget/one Gadget/unknown ConfigMap/extra"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("expected objects %q, got %q", want, got)
+	}
+
+	if _, err := decodeManifest(strings.NewReader("metadata:\n  name: broken\n"), "broken.yaml"); err == nil {
+		t.Errorf("expected an error for an object without apiVersion and kind")
+	}
+}
+
+func TestPlanAndDeleteManifestObjects(t *testing.T) {
+	sources, err := decodeManifest(strings.NewReader(testManifest), "test.yaml")
+	if err != nil {
+		t.Fatalf("failed to decode manifest: %v", err)
+	}
+
+	configMapGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	objects := []runtime.Object{newWidgetCRD(), newWidget("team-a", "one", "example.com/cleanup")}
+	for _, src := range sources {
+		if kind := src.Object.GetKind(); kind == "Namespace" || kind == "ClusterRole" || kind == "ConfigMap" {
+			objects = append(objects, src.Object.DeepCopy())
+		}
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			crdGVR:         "CustomResourceDefinitionList",
+			widgetGVR:      "WidgetList",
+			configMapGVR:   "ConfigMapList",
+			namespaceGVR:   "NamespaceList",
+			clusterRoleGVR: "ClusterRoleList",
+		},
+		objects...,
+	)
+	blockDeletionOnFinalizers(dynamicClient, widgetGVR)
+
+	ctx := context.TODO()
+	plan, err := PlanManifestDeletion(ctx, dynamicClient, newFakeDiscovery(manifestResourceLists()...), sources, "team-a")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(plan.Unresolved) != 1 || !strings.Contains(plan.Unresolved[0], "Gadget unknown") {
+		t.Errorf("expected the unknown Gadget to be unresolved, got %v", plan.Unresolved)
+	}
+
+	expected := []struct {
+		step  string
+		names []string
+	}{
+		{"Custom resources", []string{"team-a/one"}},
+		{"Namespaced objects", []string{"team-a/extra", "team-a/settings"}},
+		{"Cluster-scoped objects", []string{"widget-operator"}},
+		{"CustomResourceDefinitions", []string{"widgets.example.com"}},
+		{"Namespaces", []string{"team-a"}},
+	}
+	if len(plan.Steps) != len(expected) {
+		t.Fatalf("expected %d steps, got %d", len(expected), len(plan.Steps))
+	}
+	for i, want := range expected {
+		var names []string
+		for _, obj := range plan.Steps[i].Objects {
+			names = append(names, objectRef(obj))
+		}
+		if plan.Steps[i].Name != want.step || strings.Join(names, ",") != strings.Join(want.names, ",") {
+			t.Errorf("step %d: expected %s %v, got %s %v", i, want.step, want.names, plan.Steps[i].Name, names)
+		}
+	}
+
+	results := DeleteManifestObjects(ctx, dynamicClient, plan, 10*time.Millisecond, false)
+	if len(results) != plan.TotalObjects() {
+		t.Fatalf("expected %d results, got %d", plan.TotalObjects(), len(results))
+	}
+	if results[0].Object.Name != "one" || results[0].Status != ManifestStatusUnstuck {
+		t.Errorf("expected the stuck widget to be unstuck first, got %s %s", results[0].Object.Name, results[0].Status)
+	}
+	for _, result := range results[1:] {
+		if result.Status != ManifestStatusDeleted {
+			t.Errorf("expected %s to be deleted, got %s", objectRef(result.Object), result.Status)
+		}
+	}
+
+	var deleteOrder []string
+	for _, action := range dynamicClient.Actions() {
+		if action.GetVerb() == "delete" {
+			deleteOrder = append(deleteOrder, action.(clienttesting.DeleteAction).GetName())
+		}
+	}
+	if got := strings.Join(deleteOrder, " "); got != "one extra settings widget-operator widgets.example.com team-a" {
+		t.Errorf("unexpected deletion order: %s", got)
+	}
+}
+
+// blockDeletionOnFinalizers makes the fake client behave like the API server for objects with finalizers:
+// a delete only marks them, and they disappear once their finalizers are gone
+func blockDeletionOnFinalizers(dynamicClient *dynamicfake.FakeDynamicClient, gvr schema.GroupVersionResource) {
+	tracker := dynamicClient.Tracker()
+	deleting := map[string]bool{}
+
+	dynamicClient.PrependReactor("delete", gvr.Resource, func(action clienttesting.Action) (bool, runtime.Object, error) {
+		deleteAction := action.(clienttesting.DeleteAction)
+		obj, err := tracker.Get(gvr, deleteAction.GetNamespace(), deleteAction.GetName())
+		if err != nil || len(obj.(*unstructured.Unstructured).GetFinalizers()) == 0 {
+			return false, nil, nil
+		}
+		deleting[deleteAction.GetNamespace()+"/"+deleteAction.GetName()] = true
+		return true, nil, nil
+	})
+
+	dynamicClient.PrependReactor("get", gvr.Resource, func(action clienttesting.Action) (bool, runtime.Object, error) {
+		getAction := action.(clienttesting.GetAction)
+		key := getAction.GetNamespace() + "/" + getAction.GetName()
+		if !deleting[key] {
+			return false, nil, nil
+		}
+		obj, err := tracker.Get(gvr, getAction.GetNamespace(), getAction.GetName())
+		if err == nil && len(obj.(*unstructured.Unstructured).GetFinalizers()) == 0 {
+			delete(deleting, key)
+			tracker.Delete(gvr, getAction.GetNamespace(), getAction.GetName())
+		}
+		return false, nil, nil
+	})
+}
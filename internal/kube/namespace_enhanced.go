@@ -3,11 +3,10 @@ package kube
 import (
 	"context"
 	"fmt"
-	"time"
 
-	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 
@@ -29,24 +28,15 @@ func EnhancedDeleteNamespaceWithOptions(ctx context.Context, clientset kubernete
 	}
 
 	// Create ArgoCD detector and handler
-	detector := argocd.NewDetector(clientset, dynamicClient)
-	handler := argocd.NewHandler(dynamicClient)
+	detector := argocd.NewDetector(clientset, dynamicClient).WithAPIServerHost(config.Host)
+	handler := newArgoCDHandler(detector, dynamicClient, ArgoCDOptions{})
+	fluxDetector, fluxHandler := newFluxClients(clientset, dynamicClient, FluxOptions{})
 
-	// Phase 1: Detect ArgoCD applications managing this namespace
-	fmt.Printf("🔍 Checking for ArgoCD applications managing namespace: %s\n", namespace)
-	argoCDApps, err := detector.DetectArgoCDAppsForNamespace(ctx, namespace)
-	if err != nil {
-		fmt.Printf("⚠️  Warning: Failed to detect ArgoCD applications: %v\n", err)
-	}
-
-	if len(argoCDApps) > 0 {
-		fmt.Printf("🎯 Found %d ArgoCD application(s) managing this namespace:\n", len(argoCDApps))
-		for _, app := range argoCDApps {
-			fmt.Printf("  - %s/%s\n", app.GetNamespace(), app.GetName())
-		}
-	} else {
-		fmt.Printf("ℹ️  No ArgoCD applications found managing this namespace\n")
-	}
+	// Phase 1: Detect ArgoCD applications managing this namespace on this cluster, and ArgoCD objects stored in it,
+	// and Flux reconcilers applying to it
+	argoCDApps := detectArgoCDApps(ctx, detector, config.Host, namespace)
+	controlPlane := detectArgoCDControlPlane(ctx, detector, namespace)
+	fluxReconcilers := detectFluxReconcilers(ctx, fluxDetector, namespace)
 
 	// Phase 2: Discover problematic CRDs (always run for diagnostics)
 	fmt.Printf("\n🔍 Discovering CRDs that might be causing namespace termination issues...\n")
@@ -55,24 +45,27 @@ func EnhancedDeleteNamespaceWithOptions(ctx context.Context, clientset kubernete
 		fmt.Printf("⚠️  Warning: Failed to discover problematic CRDs: %v\n", err)
 		crdDiscoveryResult = &CRDDiscoveryResult{} // Continue with empty result
 	}
+	if forceDelete {
+		crdDiscoveryResult = crdDiscoveryResult.withoutStorageStages(NukeOptions{}.storageProviders())
+	}
 
 	// Phase 3: Enhanced diagnostics with ArgoCD and CRD awareness
 	if diagnoseOnly {
+		if len(fluxReconcilers) > 0 {
+			displayFluxDiagnostics(fluxReconcilers)
+		}
 		return EnhancedDiagnoseNamespaceWithCRDs(ctx, clientset, dynamicClient, namespace, argoCDApps, crdDiscoveryResult)
 	}
 
 	// Phase 4: Handle ArgoCD applications first (if any)
 	if len(argoCDApps) > 0 {
-		fmt.Printf("🔄 Handling ArgoCD applications before namespace deletion...\n")
-		
-		// Delete ArgoCD applications first
-		if err := handler.DeleteApplications(ctx, argoCDApps); err != nil {
-			fmt.Printf("⚠️  Warning: Failed to delete some ArgoCD applications: %v\n", err)
-		}
-
-		// Wait a bit for ArgoCD to clean up resources
-		fmt.Printf("⏳ Waiting for ArgoCD to clean up resources...\n")
-		time.Sleep(10 * time.Second)
+		handleArgoCDApplications(ctx, detector, handler, namespace, argoCDApps, ArgoCDOptions{})
+	}
+	if !controlPlane.IsEmpty() {
+		stripControlPlaneFinalizers(ctx, handler, controlPlane)
+	}
+	if len(fluxReconcilers) > 0 {
+		handleFluxReconcilers(ctx, fluxDetector, fluxHandler, fluxReconcilers, FluxOptions{})
 	}
 
 	// Phase 5: Intelligent CRD cleanup based on mode
@@ -105,13 +98,19 @@ func EnhancedDeleteNamespaceWithOptions(ctx context.Context, clientset kubernete
 
 	// Phase 6: Proceed with namespace deletion based on mode
 	if forceDelete {
-		return EnhancedNukeNamespace(ctx, clientset, dynamicClient, namespace, detector)
+		return EnhancedNukeNamespace(ctx, clientset, dynamicClient, namespace, detector, NukeOptions{})
 	}
 	return EnhancedStandardDeleteWithCRDRetry(ctx, clientset, namespace, crdDiscoveryResult)
 }
 
 // EnhancedDeleteNamespaceWithDryRun provides ArgoCD-aware namespace deletion with dry-run support
 func EnhancedDeleteNamespaceWithDryRun(ctx context.Context, clientset kubernetes.Interface, namespace string, forceDelete bool, isDryRun bool) error {
+	return EnhancedDeleteNamespaceWithNukeOptions(ctx, clientset, namespace, forceDelete, isDryRun, NukeOptions{})
+}
+
+// EnhancedDeleteNamespaceWithNukeOptions provides ArgoCD-aware namespace deletion with dry-run support and
+// tunable force pipeline options. Contents-only mode always uses the force pipeline and keeps the namespace.
+func EnhancedDeleteNamespaceWithNukeOptions(ctx context.Context, clientset kubernetes.Interface, namespace string, forceDelete bool, isDryRun bool, opts NukeOptions) error {
 	// Get REST config for dynamic client operations
 	config, err := GetRESTConfig(clientset)
 	if err != nil {
@@ -124,55 +123,101 @@ func EnhancedDeleteNamespaceWithDryRun(ctx context.Context, clientset kubernetes
 		return fmt.Errorf("failed to create dynamic client: %w", err)
 	}
 
-	// Create ArgoCD detector and handler
-	detector := argocd.NewDetector(clientset, dynamicClient)
-	handler := argocd.NewHandler(dynamicClient)
-
-	// Phase 1: Detect ArgoCD applications managing this namespace
-	fmt.Printf("🔍 Checking for ArgoCD applications managing namespace: %s\n", namespace)
-	argoCDApps, err := detector.DetectArgoCDAppsForNamespace(ctx, namespace)
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
 	if err != nil {
-		fmt.Printf("⚠️  Warning: Failed to detect ArgoCD applications: %v\n", err)
+		return fmt.Errorf("failed to create discovery client: %w", err)
 	}
 
-	if len(argoCDApps) > 0 {
-		fmt.Printf("🎯 Found %d ArgoCD application(s) managing this namespace:\n", len(argoCDApps))
-		for _, app := range argoCDApps {
-			fmt.Printf("  - %s/%s\n", app.GetNamespace(), app.GetName())
-		}
-	} else {
-		fmt.Printf("ℹ️  No ArgoCD applications found managing this namespace\n")
+	return enhancedDeleteNamespace(ctx, clientset, discoveryClient, dynamicClient, config.Host, namespace, forceDelete, isDryRun, opts)
+}
+
+// enhancedDeleteNamespace runs the ArgoCD-aware deletion with the given clients, against the API server at host
+func enhancedDeleteNamespace(ctx context.Context, clientset kubernetes.Interface, discoveryClient discovery.DiscoveryInterface, dynamicClient dynamic.Interface, host string, namespace string, forceDelete bool, isDryRun bool, opts NukeOptions) error {
+	if opts.ContentsOnly {
+		forceDelete = true
 	}
 
+	// Create ArgoCD detector and handler
+	detector := argocd.NewDetector(clientset, dynamicClient).WithAPIServerHost(host)
+	handler := newArgoCDHandler(detector, dynamicClient, opts.ArgoCD)
+	fluxDetector, fluxHandler := newFluxClients(clientset, dynamicClient, opts.Flux)
+
+	// Phase 1: Detect ArgoCD applications managing this namespace on this cluster, and ArgoCD objects stored in it,
+	// and Flux reconcilers applying to it
+	argoCDApps := detectArgoCDApps(ctx, detector, host, namespace)
+	controlPlane := detectArgoCDControlPlane(ctx, detector, namespace)
+	fluxReconcilers := detectFluxReconcilers(ctx, fluxDetector, namespace)
+
 	// Phase 2: Discover problematic CRDs (always run for diagnostics)
 	fmt.Printf("\n🔍 Discovering CRDs that might be causing namespace termination issues...\n")
-	crdDiscoveryResult, err := DiscoverProblematicCRDs(ctx, clientset, namespace)
+	crdDiscoveryResult, err := discoverProblematicCRDs(ctx, clientset, discoveryClient, dynamicClient, namespace)
 	if err != nil {
 		fmt.Printf("⚠️  Warning: Failed to discover problematic CRDs: %v\n", err)
 		crdDiscoveryResult = &CRDDiscoveryResult{} // Continue with empty result
 	}
+	crdDiscoveryResult = crdDiscoveryResult.withoutKept(opts.untouched())
+	if forceDelete {
+		crdDiscoveryResult = crdDiscoveryResult.withoutStorageStages(opts.storageProviders())
+	}
 
 	// Phase 3: Enhanced diagnostics with ArgoCD and CRD awareness (always run in dry-run)
 	if isDryRun {
+		if !controlPlane.IsEmpty() {
+			fmt.Printf("🔧 WOULD REMOVE ArgoCD finalizers from %d application(s) and %d AppProject(s) before deleting the namespace\n",
+				len(controlPlane.Applications), len(controlPlane.AppProjects))
+		}
+		if len(fluxReconcilers) > 0 && forceDelete {
+			displayFluxPlan(ctx, fluxDetector, fluxReconcilers, opts)
+		} else if len(fluxReconcilers) > 0 {
+			displayFluxDiagnostics(fluxReconcilers)
+		}
+		if _, err := DetectStorageProviders(ctx, clientset, namespace, opts.storageProviders()); err != nil {
+			fmt.Printf("⚠️  Warning: Failed to detect storage providers: %v\n", err)
+		}
+		diagnoseStorageProviders(ctx, clientset, dynamicClient, namespace, opts)
 		if forceDelete {
-			return EnhancedDryRunWithForceMode(ctx, clientset, dynamicClient, namespace, argoCDApps, crdDiscoveryResult)
+			if err := checkVolumeSafety(ctx, clientset, namespace, opts); err != nil {
+				fmt.Printf("🛑 WOULD REFUSE: %v\n", err)
+			}
+			planStorageProviders(ctx, clientset, dynamicClient, namespace, opts)
+			if snapshots, err := findVolumeSnapshots(ctx, dynamicClient, namespace, opts.untouched()); err != nil {
+				fmt.Printf("⚠️  Warning: Failed to find volume snapshots: %v\n", err)
+			} else if len(snapshots) > 0 {
+				displayVolumeSnapshots(snapshots, opts.Snapshots)
+			}
+		}
+		if forceDelete {
+			return EnhancedDryRunWithForceMode(ctx, clientset, dynamicClient, namespace, argoCDApps, crdDiscoveryResult, opts)
 		} else {
 			return EnhancedDiagnoseNamespaceWithCRDs(ctx, clientset, dynamicClient, namespace, argoCDApps, crdDiscoveryResult)
 		}
 	}
 
-	// Phase 4: Handle ArgoCD applications first (if any)
-	if len(argoCDApps) > 0 {
-		fmt.Printf("🔄 Handling ArgoCD applications before namespace deletion...\n")
-		
-		// Delete ArgoCD applications first
-		if err := handler.DeleteApplications(ctx, argoCDApps); err != nil {
-			fmt.Printf("⚠️  Warning: Failed to delete some ArgoCD applications: %v\n", err)
+	// The ArgoCD and Flux cascades below can delete PVCs too, so the volume check runs before them
+	if forceDelete {
+		if err := checkVolumeSafety(ctx, clientset, namespace, opts); err != nil {
+			return err
 		}
+		opts.volumesChecked = true
+	}
 
-		// Wait a bit for ArgoCD to clean up resources
-		fmt.Printf("⏳ Waiting for ArgoCD to clean up resources...\n")
-		time.Sleep(10 * time.Second)
+	// Phase 4: Handle ArgoCD applications first (if any)
+	// Deleting an application may cascade to the namespace itself, so contents-only mode leaves them alone
+	// unless they are orphaned or only have auto-sync disabled
+	if len(argoCDApps) > 0 && leavesArgoCDAppsInPlace(opts) {
+		fmt.Printf("⚠️  Leaving %d ArgoCD application(s) in place to keep the namespace; they may re-sync its contents\n", len(argoCDApps))
+		fmt.Printf("💡 Use --argocd-mode disable-sync or orphan to stop them without cascading to the namespace\n")
+	} else if len(argoCDApps) > 0 {
+		handleArgoCDApplications(ctx, detector, handler, namespace, argoCDApps, opts.ArgoCD)
+	}
+	if !controlPlane.IsEmpty() {
+		stripControlPlaneFinalizers(ctx, handler, controlPlane)
+	}
+	if len(fluxReconcilers) > 0 && leavesFluxReconcilersInPlace(fluxReconcilers, opts) {
+		fmt.Printf("⚠️  Leaving %d Flux reconciler(s) in place: one prunes the namespace object itself; they may reapply its contents\n", len(fluxReconcilers))
+		fmt.Printf("💡 Use --flux-mode suspend or orphan to stop them without pruning the namespace\n")
+	} else if len(fluxReconcilers) > 0 {
+		handleFluxReconcilers(ctx, fluxDetector, fluxHandler, fluxReconcilers, opts.Flux)
 	}
 
 	// Phase 5: Intelligent CRD cleanup based on mode
@@ -195,7 +240,7 @@ func EnhancedDeleteNamespaceWithDryRun(ctx context.Context, clientset kubernetes
 	}
 	
 	if shouldCleanupCRDs {
-		if err := AttemptCRDCleanup(ctx, clientset, crdDiscoveryResult, namespace); err != nil {
+		if err := attemptCRDCleanup(ctx, dynamicClient, crdDiscoveryResult, namespace); err != nil {
 			fmt.Printf("⚠️  Warning: Failed to clean up some CRDs: %v\n", err)
 		}
 	} else if len(crdDiscoveryResult.ProblematicCRDs) > 0 {
@@ -205,7 +250,7 @@ func EnhancedDeleteNamespaceWithDryRun(ctx context.Context, clientset kubernetes
 
 	// Phase 6: Proceed with namespace deletion based on mode
 	if forceDelete {
-		return EnhancedNukeNamespace(ctx, clientset, dynamicClient, namespace, detector)
+		return enhancedNukeNamespace(ctx, clientset, discoveryClient, dynamicClient, namespace, detector, opts)
 	}
 	return EnhancedStandardDeleteWithCRDRetry(ctx, clientset, namespace, crdDiscoveryResult)
 }
@@ -218,29 +263,48 @@ func EnhancedDryRunWithForceMode(
 	namespace string,
 	argoCDApps []unstructured.Unstructured,
 	crdResult *CRDDiscoveryResult,
+	opts NukeOptions,
 ) error {
 	fmt.Printf("🔍 DRY-RUN + FORCE MODE: Debug output for namespace: %s\n", namespace)
 	fmt.Printf("=======================================================\n")
+	if !opts.Kinds.IsEmpty() {
+		displayKindFilter(opts.Kinds)
+	}
+	if !opts.Keep.IsEmpty() {
+		displayKeepFilter(opts.Keep)
+	}
 
 	// Run standard diagnostics first
 	DiagnoseStuckNamespace(ctx, clientset, namespace)
 
 	// Show what would be done with ArgoCD applications
-	if len(argoCDApps) > 0 {
+	if len(argoCDApps) > 0 && leavesArgoCDAppsInPlace(opts) {
+		fmt.Printf("\n🔍 ARGOCD APPLICATIONS (WOULD BE LEFT IN PLACE):\n")
+		fmt.Printf("==============================================\n")
+		fmt.Printf("⚠️  Contents-only mode keeps the namespace, so %d ArgoCD application(s) would not be deleted and may re-sync:\n", len(argoCDApps))
+		for _, app := range argoCDApps {
+			fmt.Printf("  - %s/%s\n", app.GetNamespace(), app.GetName())
+		}
+	} else if len(argoCDApps) > 0 {
+		detector := newArgoCDDetector(clientset, dynamicClient)
+		displayApplicationHierarchyPlan(ctx, detector, argoCDApps, opts.ArgoCD)
+		displayApplicationSetPlan(ctx, detector, argoCDApps, opts.ArgoCD)
+		displayProjectRestrictions(ctx, detector, namespace, argoCDApps)
+
 		fmt.Printf("\n🔍 ARGOCD APPLICATIONS (WOULD BE HANDLED):\n")
 		fmt.Printf("=========================================\n")
-		fmt.Printf("🎯 Found %d ArgoCD application(s) that WOULD BE DELETED:\n", len(argoCDApps))
+		fmt.Printf("🎯 Found %d ArgoCD application(s) that %s:\n", len(argoCDApps), argoCDModeOutcome(opts.ArgoCD.Mode))
 		
 		for _, app := range argoCDApps {
 			appName := app.GetName()
 			appNamespace := app.GetNamespace()
 			
 			fmt.Printf("\n📊 ArgoCD Application: %s/%s\n", appNamespace, appName)
-			fmt.Printf("   🗑️  WOULD DELETE: kubectl delete application %s -n %s\n", appName, appNamespace)
+			displayArgoCDModeAction(app, opts.ArgoCD.Mode)
 			
 			// Check application finalizers
 			finalizers := app.GetFinalizers()
-			if len(finalizers) > 0 {
+			if len(finalizers) > 0 && opts.ArgoCD.Mode != argocd.ModeDisableSync {
 				fmt.Printf("   ⚠️  Has finalizers: %v\n", finalizers)
 				fmt.Printf("   🔧 WOULD REMOVE FINALIZERS if stuck\n")
 			}
@@ -252,11 +316,20 @@ func EnhancedDryRunWithForceMode(
 				if server, ok := destination["server"].(string); ok {
 					fmt.Printf("Server=%s, ", server)
 				}
+				if name, ok := destination["name"].(string); ok {
+					fmt.Printf("Name=%s, ", name)
+				}
 				if ns, ok := destination["namespace"].(string); ok {
 					fmt.Printf("Namespace=%s", ns)
 				}
 				fmt.Println()
 			}
+			if others := otherNamespaces(app, namespace); len(others) > 0 {
+				fmt.Printf("   ⚠️  Also manages resources in: %v (deleting it affects those namespaces too)\n", others)
+				if (opts.ArgoCD.Mode == argocd.ModeDelete || opts.ArgoCD.Mode == "") && argocd.CascadesOnDelete(app) {
+					fmt.Printf("   ❓ WOULD ASK before cascade-deleting it, otherwise disable its auto-sync\n")
+				}
+			}
 		}
 	}
 
@@ -288,15 +361,31 @@ func EnhancedDryRunWithForceMode(
 	}
 
 	// Show what would be done with namespace
-	fmt.Printf("\n💥 NAMESPACE DELETION (WOULD BE PERFORMED):\n")
-	fmt.Printf("==========================================\n")
-	fmt.Printf("FORCE MODE would perform these actions:\n")
-	fmt.Printf("1. 🚀 WOULD FORCE DELETE all pods with grace period 0\n")
-	fmt.Printf("2. 🗑️  WOULD DELETE all services, deployments, configmaps, secrets\n")
-	fmt.Printf("3. 💥 WOULD FORCE DELETE all custom resources\n")
+	if opts.ContentsOnly {
+		fmt.Printf("\n🧹 NAMESPACE CONTENTS WIPE (WOULD BE PERFORMED):\n")
+		fmt.Printf("===============================================\n")
+		fmt.Printf("CONTENTS-ONLY MODE would perform these actions:\n")
+	} else {
+		fmt.Printf("\n💥 NAMESPACE DELETION (WOULD BE PERFORMED):\n")
+		fmt.Printf("==========================================\n")
+		fmt.Printf("FORCE MODE would perform these actions:\n")
+	}
+	fmt.Printf("1. 🚀 WOULD FORCE DELETE all pods with grace period 0, after the volume snapshots and storage providers are torn down\n")
+	fmt.Printf("2. 🗑️  WOULD DELETE every deletable namespaced resource type found via discovery: custom resources, then workloads, then the rest, then core config and RBAC\n")
+	fmt.Printf("3. 💥 WOULD USE deletecollection where supported and strip finalizers from anything still hanging after %s\n", opts.finalizerTimeout())
 	fmt.Printf("4. 🔧 WOULD REMOVE finalizers from all resources\n")
-	fmt.Printf("5. 🗑️  WOULD DELETE the namespace itself\n")
-	fmt.Printf("6. 🔧 WOULD REMOVE namespace finalizers if stuck\n")
+	if !opts.Keep.IsEmpty() {
+		fmt.Printf("   🛡️  Objects matching the keep filters above would be left untouched\n")
+	}
+	if !opts.Kinds.IsEmpty() {
+		fmt.Printf("   🎯 Kinds outside the kind filters above would be left untouched by every step\n")
+	}
+	if opts.ContentsOnly {
+		fmt.Printf("5. 🛡️  WOULD KEEP the namespace itself (no delete, no finalize)\n")
+	} else {
+		fmt.Printf("5. 🗑️  WOULD DELETE the namespace itself\n")
+		fmt.Printf("6. 🔧 WOULD REMOVE namespace finalizers if stuck\n")
+	}
 
 	// Show comprehensive recommendations
 	fmt.Printf("\n💡 COMPREHENSIVE RECOMMENDATIONS:\n")
@@ -343,16 +432,27 @@ func EnhancedDryRunWithForceMode(
 }
 
 // EnhancedNukeNamespace performs aggressive namespace deletion with ArgoCD awareness
-func EnhancedNukeNamespace(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, detector *argocd.Detector) error {
+func EnhancedNukeNamespace(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, detector *argocd.Detector, opts NukeOptions) error {
 	fmt.Printf("💥 ENHANCED NUKE MODE: ArgoCD-aware aggressive deletion of namespace: %s\n", namespace)
 
-	// Phase 1: Remove any remaining ArgoCD-managed resources with finalizers
-	if err := removeArgoCDManagedResourceFinalizers(ctx, clientset, dynamicClient, namespace, detector); err != nil {
+	// Phase 1: Remove the finalizers of ArgoCD-managed resources still terminating after the cascade
+	if _, err := removeArgoCDManagedResourceFinalizers(ctx, clientset.Discovery(), dynamicClient, namespace, detector, opts); err != nil {
 		fmt.Printf("⚠️  Warning: Failed to remove ArgoCD finalizers: %v\n", err)
 	}
 
 	// Phase 2: Continue with standard nuke process
-	return NukeNamespace(ctx, clientset, namespace, false, false)
+	return NukeNamespaceWithOptions(ctx, clientset, namespace, opts)
+}
+
+// enhancedNukeNamespace is EnhancedNukeNamespace with the given discovery client, running the force pipeline
+// with the same clients
+func enhancedNukeNamespace(ctx context.Context, clientset kubernetes.Interface, discoveryClient discovery.DiscoveryInterface, dynamicClient dynamic.Interface, namespace string, detector *argocd.Detector, opts NukeOptions) error {
+	fmt.Printf("💥 ENHANCED NUKE MODE: ArgoCD-aware aggressive deletion of namespace: %s\n", namespace)
+
+	if _, err := removeArgoCDManagedResourceFinalizers(ctx, discoveryClient, dynamicClient, namespace, detector, opts); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to remove ArgoCD finalizers: %v\n", err)
+	}
+	return forceNukeNamespace(ctx, clientset, discoveryClient, dynamicClient, namespace, opts)
 }
 
 // EnhancedStandardDeleteWithCRDRetry performs standard namespace deletion with CRD retry capability
@@ -412,52 +512,33 @@ func EnhancedDiagnoseNamespaceWithCRDs(
 		fmt.Printf("\n🔍 ARGOCD DIAGNOSTICS:\n")
 		fmt.Printf("====================\n")
 		fmt.Printf("🎯 Found %d ArgoCD application(s) managing this namespace:\n", len(argoCDApps))
+		detector := newArgoCDDetector(clientset, dynamicClient)
+		displayApplicationHierarchy(ctx, detector, argoCDApps)
 		
-		for _, app := range argoCDApps {
-			appName := app.GetName()
-			appNamespace := app.GetNamespace()
-			
-			fmt.Printf("\n📊 ArgoCD Application: %s/%s\n", appNamespace, appName)
+		for _, app := range argocd.NewApplications(argoCDApps) {
+			fmt.Printf("\n📊 ArgoCD Application: %s/%s\n", app.Namespace, app.Name)
 			
 			// Check application finalizers
-			finalizers := app.GetFinalizers()
-			if len(finalizers) > 0 {
-				fmt.Printf("⚠️  Application has finalizers: %v\n", finalizers)
+			if len(app.Finalizers) > 0 {
+				fmt.Printf("⚠️  Application has finalizers: %v\n", app.Finalizers)
 				fmt.Printf("💡 Tip: These finalizers may prevent proper cleanup\n")
 			}
 			
-			// Extract and display destination info
-			destination, found, _ := unstructured.NestedMap(app.Object, "spec", "destination")
-			if found {
-				fmt.Printf("🔗 Destination: ")
-				if server, ok := destination["server"].(string); ok {
-					fmt.Printf("Server=%s, ", server)
-				}
-				if ns, ok := destination["namespace"].(string); ok {
-					fmt.Printf("Namespace=%s", ns)
-				}
-				fmt.Println()
+			fmt.Printf("🔗 Destination: Server=%s, Name=%s, Namespace=%s\n",
+				app.Destination.Server, app.Destination.Name, app.Destination.Namespace)
+			fmt.Printf("📁 Project: %s\n", app.Project)
+			if app.SyncStatus != "" {
+				fmt.Printf("🔄 Sync Status: %s\n", app.SyncStatus)
 			}
-			
-			// Extract sync status
-			syncStatus, found, _ := unstructured.NestedMap(app.Object, "status", "sync")
-			if found {
-				if status, ok := syncStatus["status"].(string); ok {
-					fmt.Printf("🔄 Sync Status: %s\n", status)
-				}
+			if app.HealthStatus != "" {
+				fmt.Printf("💓 Health Status: %s\n", app.HealthStatus)
 			}
-			
-			// Extract health status
-			healthStatus, found, _ := unstructured.NestedMap(app.Object, "status", "health")
-			if found {
-				if status, ok := healthStatus["status"].(string); ok {
-					fmt.Printf("💓 Health Status: %s\n", status)
-				}
-				if message, ok := healthStatus["message"].(string); ok && message != "" {
-					fmt.Printf("   Message: %s\n", message)
-				}
+			if app.HealthMessage != "" {
+				fmt.Printf("   Message: %s\n", app.HealthMessage)
 			}
 		}
+		
+		displayProjectRestrictions(ctx, detector, namespace, argoCDApps)
 	}
 
 	// CRD Discovery Results (already displayed by DiscoverProblematicCRDs)
@@ -517,94 +598,52 @@ func EnhancedDiagnoseNamespaceWithCRDs(
 	return nil
 }
 
-// removeArgoCDManagedResourceFinalizers removes finalizers from ArgoCD-managed resources
-func removeArgoCDManagedResourceFinalizers(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, detector *argocd.Detector) error {
-	fmt.Printf("🔧 Removing finalizers from ArgoCD-managed resources...\n")
-
-	// Get all resources in the namespace and check if they're ArgoCD-managed
-	// This is a simplified approach - in a full implementation, you'd want to
-	// iterate through all resource types dynamically
-
-	// Check pods
-	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
-	if err == nil {
-		for _, pod := range pods.Items {
-			podUnstructured := convertToUnstructured(&pod)
-			if detector.IsArgoCDManagedResource(podUnstructured) {
-				if len(pod.Finalizers) > 0 {
-					fmt.Printf("🔧 Removing finalizers from ArgoCD-managed pod: %s\n", pod.Name)
-					if err := removePodFinalizers(ctx, clientset, namespace, pod.Name); err != nil {
-						fmt.Printf("⚠️  Warning: Failed to remove finalizers from pod %s: %v\n", pod.Name, err)
-					}
-				}
-			}
+// removeArgoCDManagedResourceFinalizers strips the finalizers from the ArgoCD-managed objects in the namespace
+// that are still terminating after the ArgoCD cascade. Live objects keep theirs, and so do PVCs, VolumeSnapshots
+// and storage provider resources, which later stages tear down. Every namespaced resource type that can be listed
+// and patched is scanned through discovery, honouring the keep and kind filters. It returns the number of objects
+// whose finalizers were removed.
+func removeArgoCDManagedResourceFinalizers(ctx context.Context, discoveryClient discovery.DiscoveryInterface, dynamicClient dynamic.Interface, namespace string, detector *argocd.Detector, opts NukeOptions) (int, error) {
+	fmt.Printf("🔧 Removing finalizers from terminating ArgoCD-managed resources...\n")
+
+	resources, err := discoverResources(discoveryClient, true, "list", "patch")
+	if err != nil {
+		if len(resources) == 0 {
+			return 0, err
 		}
+		// Continue with partial results if some APIs are unavailable
+		fmt.Printf("⚠️  Warning: Some API resources may not be accessible: %v\n", err)
 	}
 
-	// Check PVCs
-	pvcs, err := clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
-	if err == nil {
-		for _, pvc := range pvcs.Items {
-			pvcUnstructured := convertToUnstructured(&pvc)
-			if detector.IsArgoCDManagedResource(pvcUnstructured) {
-				if len(pvc.Finalizers) > 0 {
-					fmt.Printf("🔧 Removing finalizers from ArgoCD-managed PVC: %s\n", pvc.Name)
-					if err := removePVCFinalizers(ctx, clientset, namespace, pvc.Name); err != nil {
-						fmt.Printf("⚠️  Warning: Failed to remove finalizers from PVC %s: %v\n", pvc.Name, err)
-					}
-				}
-			}
+	stripped := 0
+	untouched := opts.untouched()
+	for _, res := range resources {
+		if untouched.keepsKind(res) || storageStage(opts.storageProviders(), res.GVR.Group, res.GVR.Resource) != "" {
+			continue
 		}
-	}
 
-	return nil
-}
+		resourceClient := dynamicClient.Resource(res.GVR).Namespace(namespace)
+		list, err := resourceClient.List(ctx, metav1.ListOptions{})
+		if err != nil {
+			continue // Skip resources we can't list (permissions, etc.)
+		}
 
-// Helper functions for removing finalizers from specific resource types
-func removePodFinalizers(ctx context.Context, clientset kubernetes.Interface, namespace, name string) error {
-	pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
-	if err != nil {
-		return err
-	}
-	
-	pod.Finalizers = nil
-	_, err = clientset.CoreV1().Pods(namespace).Update(ctx, pod, metav1.UpdateOptions{})
-	return err
-}
+		for i := range list.Items {
+			item := &list.Items[i]
+			if len(item.GetFinalizers()) == 0 || item.GetDeletionTimestamp() == nil || !detector.IsArgoCDManagedResource(item) || untouched.keeps(res, item.GetLabels()) {
+				continue
+			}
 
-func removePVCFinalizers(ctx context.Context, clientset kubernetes.Interface, namespace, name string) error {
-	pvc, err := clientset.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
-	if err != nil {
-		return err
+			fmt.Printf("🔧 Removing finalizers %v from ArgoCD-managed %s: %s\n", item.GetFinalizers(), res.displayName(), item.GetName())
+			if err := stripAllFinalizers(ctx, resourceClient, item.GetName()); err != nil {
+				fmt.Printf("⚠️  Warning: Failed to remove finalizers from %s %s: %v\n", res.displayName(), item.GetName(), err)
+				continue
+			}
+			stripped++
+		}
 	}
-	
-	pvc.Finalizers = nil
-	_, err = clientset.CoreV1().PersistentVolumeClaims(namespace).Update(ctx, pvc, metav1.UpdateOptions{})
-	return err
-}
 
-// convertToUnstructured converts a typed Kubernetes object to unstructured
-// This is a simplified version - in production, you'd use proper conversion
-func convertToUnstructured(obj interface{}) *unstructured.Unstructured {
-	// This is a placeholder implementation
-	// In a real implementation, you'd properly convert the object
-	u := &unstructured.Unstructured{}
-	
-	// Extract metadata based on object type
-	switch v := obj.(type) {
-	case *corev1.Pod:
-		u.SetName(v.Name)
-		u.SetNamespace(v.Namespace)
-		u.SetLabels(v.Labels)
-		u.SetAnnotations(v.Annotations)
-	case *corev1.PersistentVolumeClaim:
-		u.SetName(v.Name)
-		u.SetNamespace(v.Namespace)
-		u.SetLabels(v.Labels)
-		u.SetAnnotations(v.Annotations)
-	}
-	
-	return u
+	return stripped, nil
 }
 
 // EnhancedDeleteNamespace provides ArgoCD-aware namespace deletion with CRD discovery (backward compatibility)
@@ -0,0 +1,217 @@
+package kube
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/codesenju/kubectl-nuke-go/pkg/argocd"
+	"github.com/codesenju/kubectl-nuke-go/pkg/storage"
+)
+
+func TestRemoveArgoCDManagedResourceFinalizers(t *testing.T) {
+	configMapGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	pvcGVR := persistentVolumeClaimsResource.GVR
+	deleted := metav1.Now()
+
+	tracked := newWidget("shop", "tracked", "foo.example.com/cleanup", "other.example.com/guard")
+	tracked.SetAnnotations(map[string]string{argocd.AnnotationTrackingID: "shop:example.com/Widget:shop/tracked"})
+	tracked.SetDeletionTimestamp(&deleted)
+	live := newWidget("shop", "live", "foo.example.com/cleanup")
+	live.SetAnnotations(map[string]string{argocd.AnnotationTrackingID: "shop:example.com/Widget:shop/live"})
+	kept := newWidget("shop", "kept", "foo.example.com/cleanup")
+	kept.SetAnnotations(map[string]string{argocd.AnnotationTrackingID: "shop:example.com/Widget:shop/kept"})
+	kept.SetLabels(map[string]string{"keep": "true"})
+	kept.SetDeletionTimestamp(&deleted)
+	helm := newWidget("shop", "helm", "foo.example.com/cleanup")
+	helm.SetLabels(map[string]string{argocd.LabelArgoCDInstance: "my-release"})
+	helm.SetDeletionTimestamp(&deleted)
+	settings := newWidget("shop", "settings", "foo.example.com/cleanup")
+	settings.SetAPIVersion("v1")
+	settings.SetKind("ConfigMap")
+	settings.SetAnnotations(map[string]string{argocd.AnnotationArgoCDInstance: "shop"})
+	settings.SetDeletionTimestamp(&deleted)
+	data := newWidget("shop", "data", "kubernetes.io/pvc-protection")
+	data.SetAPIVersion("v1")
+	data.SetKind("PersistentVolumeClaim")
+	data.SetAnnotations(map[string]string{argocd.AnnotationArgoCDInstance: "shop"})
+	data.SetDeletionTimestamp(&deleted)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{widgetGVR: "WidgetList", configMapGVR: "ConfigMapList", pvcGVR: "PersistentVolumeClaimList"},
+		tracked, live, kept, helm, settings, data,
+	)
+	discoveryClient := newFakeDiscovery(widgetResourceList(), &metav1.APIResourceList{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "patch"}},
+			{Name: "persistentvolumeclaims", Kind: "PersistentVolumeClaim", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "patch"}},
+		},
+	})
+	ctx := context.TODO()
+
+	opts := NukeOptions{Keep: KeepFilter{Selector: labels.SelectorFromSet(labels.Set{"keep": "true"})}}
+	stripped, err := removeArgoCDManagedResourceFinalizers(ctx, discoveryClient, dynamicClient, "shop", argocd.NewDetector(nil, dynamicClient), opts)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if stripped != 2 {
+		t.Errorf("expected finalizers to be stripped from 2 objects, got %d", stripped)
+	}
+
+	expected := []struct {
+		gvr        schema.GroupVersionResource
+		name       string
+		finalizers int
+	}{
+		{widgetGVR, "tracked", 0},
+		{configMapGVR, "settings", 0},
+		{widgetGVR, "live", 1}, // Not terminating, so its finalizers still have work to do
+		{widgetGVR, "kept", 1}, // Matches the keep selector
+		{widgetGVR, "helm", 1}, // Only a Helm instance label, not ArgoCD-managed
+		{pvcGVR, "data", 1},    // Left to the PVC handler
+	}
+	for _, e := range expected {
+		obj, err := dynamicClient.Resource(e.gvr).Namespace("shop").Get(ctx, e.name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get %s: %v", e.name, err)
+		}
+		if got := len(obj.GetFinalizers()); got != e.finalizers {
+			t.Errorf("expected %s to have %d finalizer(s), got %v", e.name, e.finalizers, obj.GetFinalizers())
+		}
+	}
+}
+
+func TestEnhancedDeleteNamespaceTearsDownStorageInOrder(t *testing.T) {
+	clustersGVR := schema.GroupVersionResource{Group: "ceph.rook.io", Version: "v1", Resource: "cephclusters"}
+	filesystemsGVR := schema.GroupVersionResource{Group: "ceph.rook.io", Version: "v1", Resource: "cephfilesystems"}
+	clientset := k8sfake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "rook-ceph"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "rook-ceph-operator-0", Namespace: "rook-ceph", Labels: map[string]string{"app": "rook-ceph-operator"}}},
+	)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			clustersGVR:                 "CephClusterList",
+			filesystemsGVR:              "CephFilesystemList",
+			podsGVR:                     "PodList",
+			volumeSnapshotsResource.GVR: "VolumeSnapshotList",
+			volumeSnapshotContentsGVR:   "VolumeSnapshotContentList",
+		},
+		newRookObject("CephCluster", "rook-ceph"),
+		newRookObject("CephFilesystem", "myfs"),
+		newOperatorPod("True"),
+	)
+	verbs := metav1.Verbs{"get", "list", "delete", "patch", "update"}
+	discoveryClient := newFakeDiscovery(
+		&metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: verbs},
+		}},
+		// Sorted by name, the cluster comes before its filesystems, so deleting every custom resource at once takes it first
+		&metav1.APIResourceList{GroupVersion: "ceph.rook.io/v1", APIResources: []metav1.APIResource{
+			{Name: "cephclusters", Kind: "CephCluster", Namespaced: true, Verbs: verbs},
+			{Name: "cephfilesystems", Kind: "CephFilesystem", Namespaced: true, Verbs: verbs},
+		}},
+	)
+
+	var deletes []string
+	record := func(action clienttesting.Action) (bool, runtime.Object, error) {
+		deletes = append(deletes, action.GetResource().Resource)
+		return false, nil, nil
+	}
+	clientset.PrependReactor("delete", "*", record)
+	dynamicClient.PrependReactor("delete", "*", record)
+
+	opts := NukeOptions{ContentsOnly: true, StorageProviders: storage.DefaultRegistry(), FinalizerTimeout: time.Millisecond}
+	if err := enhancedDeleteNamespace(context.TODO(), clientset, discoveryClient, dynamicClient, "https://cluster.example.com", "rook-ceph", true, false, opts); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	expected := []string{"cephfilesystems", "cephclusters", "pods"}
+	if len(deletes) < len(expected) || strings.Join(deletes[:len(expected)], ",") != strings.Join(expected, ",") {
+		t.Errorf("expected the filesystem, then the cluster, then the pods to be deleted, got %v", deletes)
+	}
+}
+
+func TestEnhancedDeleteNamespaceLeavesLonghornToItsTeardown(t *testing.T) {
+	volumesGVR := schema.GroupVersionResource{Group: "longhorn.io", Version: "v1beta2", Resource: "volumes"}
+	settingsGVR := schema.GroupVersionResource{Group: "longhorn.io", Version: "v1beta2", Resource: "settings"}
+	volume := newLonghornObject("Volume", "pvc-123", "longhorn.io")
+	volume.SetNamespace("longhorn-system")
+	setting := newLonghornObject("Setting", "deleting-confirmation-flag")
+	setting.SetNamespace("longhorn-system")
+	setting.Object["value"] = "false"
+
+	clientset := k8sfake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "longhorn-system"}})
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			volumesGVR:                  "VolumeList",
+			settingsGVR:                 "SettingList",
+			podsGVR:                     "PodList",
+			volumeSnapshotsResource.GVR: "VolumeSnapshotList",
+			volumeSnapshotContentsGVR:   "VolumeSnapshotContentList",
+		},
+		volume, setting,
+	)
+	verbs := metav1.Verbs{"get", "list", "delete", "patch", "update"}
+	discoveryClient := newFakeDiscovery(
+		&metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: verbs},
+		}},
+		&metav1.APIResourceList{GroupVersion: "longhorn.io/v1beta2", APIResources: []metav1.APIResource{
+			{Name: "volumes", Kind: "Volume", Namespaced: true, Verbs: verbs},
+			{Name: "settings", Kind: "Setting", Namespaced: true, Verbs: verbs},
+		}},
+	)
+
+	var writes []string
+	record := func(action clienttesting.Action) (bool, runtime.Object, error) {
+		writes = append(writes, action.GetVerb()+" "+action.GetResource().Resource)
+		return false, nil, nil
+	}
+	for _, verb := range []string{"delete", "patch", "update"} {
+		clientset.PrependReactor(verb, "*", record)
+		dynamicClient.PrependReactor(verb, "*", record)
+	}
+
+	// Without the confirmation, the Longhorn teardown is skipped and nothing may delete its volumes first
+	opts := NukeOptions{ContentsOnly: true, StorageProviders: storage.DefaultRegistry(), FinalizerTimeout: time.Millisecond}
+	if err := enhancedDeleteNamespace(context.TODO(), clientset, discoveryClient, dynamicClient, "https://cluster.example.com", "longhorn-system", true, false, opts); err == nil || len(writes) > 0 {
+		t.Errorf("expected the skipped Longhorn teardown to stop the deletion untouched, got %v after %v", err, writes)
+	}
+}
+
+func TestEnhancedDeleteNamespaceLeavesSnapshotsToTheirHandler(t *testing.T) {
+	clientset := k8sfake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}})
+	dynamicClient := newSnapshotDynamicClient(
+		newVolumeSnapshot("shop", "daily", "content-daily"),
+		newVolumeSnapshotContent("content-daily", "shop", "daily", deletionPolicyDelete),
+	)
+	discoveryClient := newFakeDiscovery(&metav1.APIResourceList{GroupVersion: "snapshot.storage.k8s.io/v1", APIResources: []metav1.APIResource{
+		{Name: "volumesnapshots", Kind: "VolumeSnapshot", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "delete", "patch"}},
+	}})
+
+	opts := NukeOptions{ContentsOnly: true, Snapshots: SnapshotOptions{DeletionPolicy: SnapshotPolicyRetain, Timeout: time.Millisecond}, FinalizerTimeout: time.Millisecond}
+	if err := enhancedDeleteNamespace(context.TODO(), clientset, discoveryClient, dynamicClient, "https://cluster.example.com", "shop", true, false, opts); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// The content must be switched to Retain before its snapshot is deleted, or the backend snapshot goes with it
+	var writes []string
+	for _, action := range dynamicClient.Actions() {
+		if action.GetVerb() == "delete" || action.GetVerb() == "patch" {
+			writes = append(writes, action.GetVerb()+" "+action.GetResource().Resource)
+		}
+	}
+	if len(writes) == 0 || writes[0] != "patch volumesnapshotcontents" {
+		t.Errorf("expected the content's deletionPolicy to be overridden first, got %v", writes)
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 )
 
@@ -83,3 +84,57 @@ func TestForceDeletePods_NotFound(t *testing.T) {
 		t.Errorf("expected error for nonexistent pod, got nil")
 	}
 }
+
+func TestForceDeleteAllPods_KeepFilter(t *testing.T) {
+	client := k8sfake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "doomed", Namespace: "wipe-ns"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "kept", Namespace: "wipe-ns", Labels: map[string]string{"keep": "true"}}},
+	)
+	ctx := context.TODO()
+
+	selector, err := labels.Parse("keep=true")
+	if err != nil {
+		t.Fatalf("failed to parse selector: %v", err)
+	}
+	if err := forceDeleteAllPods(ctx, client, "wipe-ns", KeepFilter{Selector: selector}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	pods, _ := client.CoreV1().Pods("wipe-ns").List(ctx, metav1.ListOptions{})
+	if len(pods.Items) != 1 || pods.Items[0].Name != "kept" {
+		t.Errorf("expected only the kept pod to remain, got %v", pods.Items)
+	}
+
+	if err := forceDeleteAllPods(ctx, client, "wipe-ns", KeepFilter{Kinds: []string{"po"}}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	pods, _ = client.CoreV1().Pods("wipe-ns").List(ctx, metav1.ListOptions{})
+	if len(pods.Items) != 1 {
+		t.Errorf("expected kind filter to keep all pods, got %d", len(pods.Items))
+	}
+}
+
+func TestCRDDiscoveryResultWithoutKept(t *testing.T) {
+	result := &CRDDiscoveryResult{ProblematicCRDs: []ProblematicCRD{
+		{Name: "widgets", Group: "example.com", Version: "v1", Kind: "Widget", ResourcesWithFinalizers: []ResourceWithFinalizers{
+			{Name: "one", Finalizers: []string{"a"}},
+			{Name: "two", Finalizers: []string{"a"}, Labels: map[string]string{"keep": "true"}},
+		}},
+		{Name: "gadgets", Group: "example.com", Version: "v1", Kind: "Gadget", ResourcesWithFinalizers: []ResourceWithFinalizers{
+			{Name: "three", Finalizers: []string{"a"}},
+		}},
+	}}
+
+	selector, _ := labels.Parse("keep=true")
+	filtered := result.withoutKept(KeepFilter{Kinds: []string{"Gadget"}, Selector: selector})
+
+	if len(filtered.ProblematicCRDs) != 1 {
+		t.Fatalf("expected gadgets to be dropped, got %d CRDs", len(filtered.ProblematicCRDs))
+	}
+	if resources := filtered.ProblematicCRDs[0].ResourcesWithFinalizers; len(resources) != 1 || resources[0].Name != "one" {
+		t.Errorf("expected only widget one to remain, got %v", resources)
+	}
+	if len(result.ProblematicCRDs) != 2 || len(result.ProblematicCRDs[0].ResourcesWithFinalizers) != 2 {
+		t.Errorf("expected the original result to be left unchanged")
+	}
+}
@@ -0,0 +1,429 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Well-known GVRs for the cluster-scoped objects an operator installs
+var (
+	crdGVR                     = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+	apiServiceGVR              = schema.GroupVersionResource{Group: "apiregistration.k8s.io", Version: "v1", Resource: "apiservices"}
+	validatingWebhookConfigGVR = schema.GroupVersionResource{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "validatingwebhookconfigurations"}
+	mutatingWebhookConfigGVR   = schema.GroupVersionResource{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "mutatingwebhookconfigurations"}
+	clusterRoleGVR             = schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"}
+	clusterRoleBindingGVR      = schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterrolebindings"}
+)
+
+// OperatorObject is a single object found while planning an operator uninstall
+type OperatorObject struct {
+	GVR        schema.GroupVersionResource
+	Kind       string
+	Namespace  string
+	Name       string
+	Finalizers []string
+}
+
+// OperatorTeardownStep groups objects that are removed together; steps run in order
+type OperatorTeardownStep struct {
+	Name    string
+	Objects []OperatorObject
+	// WaitForDeletion makes the step wait for its objects to disappear and strip finalizers from stragglers
+	WaitForDeletion bool
+}
+
+// OperatorPlan is the ordered teardown of everything an operator installed for an API group
+type OperatorPlan struct {
+	Group string
+	Steps []OperatorTeardownStep
+	// Shared are objects that reference the group alongside other groups; they are reported and left in place
+	Shared []OperatorObject
+}
+
+// TotalObjects returns the number of objects across all steps of the plan
+func (p *OperatorPlan) TotalObjects() int {
+	total := 0
+	for _, step := range p.Steps {
+		total += len(step.Objects)
+	}
+	return total
+}
+
+// PlanOperatorUninstall finds everything installed for an API group: webhook configurations whose webhooks all target the
+// group, instances of the group's CRDs in every namespace, the CRDs themselves, APIServices serving the group, and the
+// ClusterRoles whose rules target only the group with the ClusterRoleBindings bound to them. The returned steps are in
+// dependency order; webhook configurations and ClusterRoles that also cover other groups are reported as shared.
+func PlanOperatorUninstall(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, group string) (*OperatorPlan, error) {
+	if group == "" {
+		return nil, fmt.Errorf("API group must not be empty")
+	}
+
+	fmt.Printf("🔍 Discovering resources installed for API group %s...\n", group)
+
+	webhooks, sharedWebhooks, err := findWebhooksForGroup(ctx, clientset, group)
+	if err != nil {
+		return nil, err
+	}
+
+	crds, customResources, err := findCRDsForGroup(ctx, dynamicClient, group)
+	if err != nil {
+		return nil, err
+	}
+
+	apiServices, err := findAPIServicesForGroup(ctx, dynamicClient, group)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to list APIServices: %v\n", err)
+	}
+
+	clusterRoles, clusterRoleBindings, sharedRoles, err := findClusterRBACForGroup(ctx, clientset, group)
+	if err != nil {
+		return nil, err
+	}
+
+	// Webhooks go first so a missing operator service cannot block the deletes that follow; custom resources must
+	// be gone before their CRDs, and RBAC goes last so a still-running operator can finish its own finalizers.
+	plan := &OperatorPlan{
+		Group: group,
+		Steps: []OperatorTeardownStep{
+			{Name: "Webhook configurations", Objects: webhooks},
+			{Name: "Custom resources", Objects: customResources, WaitForDeletion: true},
+			{Name: "CustomResourceDefinitions", Objects: crds, WaitForDeletion: true},
+			{Name: "APIServices", Objects: apiServices},
+			{Name: "ClusterRoleBindings", Objects: clusterRoleBindings},
+			{Name: "ClusterRoles", Objects: clusterRoles},
+		},
+		Shared: append(sharedWebhooks, sharedRoles...),
+	}
+
+	return plan, nil
+}
+
+// findWebhooksForGroup returns webhook configurations whose webhooks all have rules naming only the group, and
+// separately those that also guard other groups, through other webhooks, other groups in a rule or a wildcard
+func findWebhooksForGroup(ctx context.Context, clientset kubernetes.Interface, group string) ([]OperatorObject, []OperatorObject, error) {
+	var objects, shared []OperatorObject
+	classify := func(obj OperatorObject, targeting, owned, total int) {
+		switch {
+		case targeting == 0:
+		case owned == total:
+			objects = append(objects, obj)
+		default:
+			shared = append(shared, obj)
+		}
+	}
+
+	validating, err := clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list webhook configurations: %w", err)
+	}
+	for _, config := range validating.Items {
+		targeting, owned := 0, 0
+		for _, webhook := range config.Webhooks {
+			if rulesTargetGroup(webhook.Rules, group) {
+				targeting++
+			}
+			if rulesTargetOnlyGroup(webhook.Rules, group) {
+				owned++
+			}
+		}
+		classify(OperatorObject{GVR: validatingWebhookConfigGVR, Kind: "ValidatingWebhookConfiguration", Name: config.Name}, targeting, owned, len(config.Webhooks))
+	}
+
+	mutating, err := clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list mutating webhook configurations: %w", err)
+	}
+	for _, config := range mutating.Items {
+		targeting, owned := 0, 0
+		for _, webhook := range config.Webhooks {
+			if rulesTargetGroup(webhook.Rules, group) {
+				targeting++
+			}
+			if rulesTargetOnlyGroup(webhook.Rules, group) {
+				owned++
+			}
+		}
+		classify(OperatorObject{GVR: mutatingWebhookConfigGVR, Kind: "MutatingWebhookConfiguration", Name: config.Name}, targeting, owned, len(config.Webhooks))
+	}
+
+	return objects, shared, nil
+}
+
+// findCRDsForGroup returns the CRDs defined for the group and every instance of them across all namespaces
+func findCRDsForGroup(ctx context.Context, dynamicClient dynamic.Interface, group string) ([]OperatorObject, []OperatorObject, error) {
+	crdList, err := dynamicClient.Resource(crdGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list CRDs: %w", err)
+	}
+
+	var crds, customResources []OperatorObject
+	for _, crd := range crdList.Items {
+		crdGroup, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+		if crdGroup != group {
+			continue
+		}
+
+		crds = append(crds, OperatorObject{
+			GVR:        crdGVR,
+			Kind:       "CustomResourceDefinition",
+			Name:       crd.GetName(),
+			Finalizers: crd.GetFinalizers(),
+		})
+
+		gvr, kind, ok := servedGVRForCRD(&crd)
+		if !ok {
+			continue
+		}
+
+		// Listing without a namespace covers both namespaced and cluster-scoped instances
+		instances, err := dynamicClient.Resource(gvr).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			fmt.Printf("⚠️  Warning: Failed to list %s: %v\n", crd.GetName(), err)
+			continue
+		}
+		for _, item := range instances.Items {
+			customResources = append(customResources, OperatorObject{
+				GVR:        gvr,
+				Kind:       kind,
+				Namespace:  item.GetNamespace(),
+				Name:       item.GetName(),
+				Finalizers: item.GetFinalizers(),
+			})
+		}
+	}
+
+	return crds, customResources, nil
+}
+
+// servedGVRForCRD returns the GVR of a CRD's storage version, falling back to any served version
+func servedGVRForCRD(crd *unstructured.Unstructured) (schema.GroupVersionResource, string, bool) {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+
+	servedVersion := ""
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := version["name"].(string)
+		served, _ := version["served"].(bool)
+		storage, _ := version["storage"].(bool)
+		if !served || name == "" {
+			continue
+		}
+		if storage {
+			servedVersion = name
+			break
+		}
+		if servedVersion == "" {
+			servedVersion = name
+		}
+	}
+
+	if plural == "" || servedVersion == "" {
+		return schema.GroupVersionResource{}, "", false
+	}
+	return schema.GroupVersionResource{Group: group, Version: servedVersion, Resource: plural}, kind, true
+}
+
+// findAPIServicesForGroup returns the APIServices registered for the group
+func findAPIServicesForGroup(ctx context.Context, dynamicClient dynamic.Interface, group string) ([]OperatorObject, error) {
+	list, err := dynamicClient.Resource(apiServiceGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []OperatorObject
+	for _, apiService := range list.Items {
+		serviceGroup, _, _ := unstructured.NestedString(apiService.Object, "spec", "group")
+		if serviceGroup == group {
+			objects = append(objects, OperatorObject{GVR: apiServiceGVR, Kind: "APIService", Name: apiService.GetName()})
+		}
+	}
+	return objects, nil
+}
+
+// findClusterRBACForGroup returns ClusterRoles whose rules target only the group, the ClusterRoleBindings bound to
+// them, and the roles that reference the group alongside others. Aggregated and system: roles are never picked up.
+func findClusterRBACForGroup(ctx context.Context, clientset kubernetes.Interface, group string) ([]OperatorObject, []OperatorObject, []OperatorObject, error) {
+	roles, err := clientset.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to list cluster roles: %w", err)
+	}
+
+	var clusterRoles, shared []OperatorObject
+	matchedRoles := map[string]bool{}
+	for _, role := range roles.Items {
+		if role.AggregationRule != nil || strings.HasPrefix(role.Name, "system:") || !policyRulesReferenceGroup(role.Rules, group) {
+			continue
+		}
+		obj := OperatorObject{GVR: clusterRoleGVR, Kind: "ClusterRole", Name: role.Name}
+		if !policyRulesTargetOnlyGroup(role.Rules, group) {
+			shared = append(shared, obj)
+			continue
+		}
+		clusterRoles = append(clusterRoles, obj)
+		matchedRoles[role.Name] = true
+	}
+
+	bindings, err := clientset.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to list cluster role bindings: %w", err)
+	}
+
+	var clusterRoleBindings []OperatorObject
+	for _, binding := range bindings.Items {
+		if binding.RoleRef.Kind == "ClusterRole" && matchedRoles[binding.RoleRef.Name] {
+			clusterRoleBindings = append(clusterRoleBindings, OperatorObject{GVR: clusterRoleBindingGVR, Kind: "ClusterRoleBinding", Name: binding.Name})
+		}
+	}
+
+	return clusterRoles, clusterRoleBindings, shared, nil
+}
+
+// policyRulesReferenceGroup checks if any RBAC rule names the group explicitly
+func policyRulesReferenceGroup(rules []rbacv1.PolicyRule, group string) bool {
+	for _, rule := range rules {
+		if containsString(rule.APIGroups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyRulesTargetOnlyGroup checks that every RBAC rule names the group and nothing else: no other API group, no
+// wildcard and no non-resource URLs
+func policyRulesTargetOnlyGroup(rules []rbacv1.PolicyRule, group string) bool {
+	for _, rule := range rules {
+		if len(rule.NonResourceURLs) > 0 || len(rule.APIGroups) == 0 {
+			return false
+		}
+		for _, g := range rule.APIGroups {
+			if g != group {
+				return false
+			}
+		}
+	}
+	return len(rules) > 0
+}
+
+// DisplayOperatorPlan prints the ordered teardown plan for an operator uninstall
+func DisplayOperatorPlan(plan *OperatorPlan) {
+	fmt.Printf("\n🎯 OPERATOR UNINSTALL PLAN FOR API GROUP: %s\n", plan.Group)
+	fmt.Printf("================================================\n")
+
+	if plan.TotalObjects() == 0 && len(plan.Shared) == 0 {
+		fmt.Printf("✅ Nothing found for API group %s\n", plan.Group)
+		return
+	}
+
+	step := 1
+	for _, s := range plan.Steps {
+		if len(s.Objects) == 0 {
+			continue
+		}
+		fmt.Printf("\n%d. %s (%d):\n", step, s.Name, len(s.Objects))
+		for _, obj := range s.Objects {
+			ref := obj.Name
+			if obj.Namespace != "" {
+				ref = obj.Namespace + "/" + obj.Name
+			}
+			if len(obj.Finalizers) > 0 {
+				fmt.Printf("   - %s %s (finalizers: %v)\n", obj.Kind, ref, obj.Finalizers)
+			} else {
+				fmt.Printf("   - %s %s\n", obj.Kind, ref)
+			}
+		}
+		step++
+	}
+
+	if len(plan.Shared) > 0 {
+		fmt.Printf("\n⚠️  Shared with other API groups, left in place (%d):\n", len(plan.Shared))
+		for _, obj := range plan.Shared {
+			fmt.Printf("   - %s %s\n", obj.Kind, obj.Name)
+		}
+	}
+
+	fmt.Printf("\n📊 Total: %d objects\n", plan.TotalObjects())
+}
+
+// ExecuteOperatorPlan removes the planned objects step by step. Steps that wait for deletion give the objects up to
+// timeout to disappear on their own before stripping the finalizers of anything still present.
+func ExecuteOperatorPlan(ctx context.Context, dynamicClient dynamic.Interface, plan *OperatorPlan, timeout time.Duration) error {
+	fmt.Printf("💥 Uninstalling everything for API group %s...\n", plan.Group)
+
+	var failures []string
+	for _, step := range plan.Steps {
+		if len(step.Objects) == 0 {
+			continue
+		}
+
+		fmt.Printf("\n🗑️  Deleting %s (%d)...\n", step.Name, len(step.Objects))
+		for _, obj := range step.Objects {
+			if err := operatorObjectClient(dynamicClient, obj).Delete(ctx, obj.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				fmt.Printf("⚠️  Failed to delete %s %s: %v\n", obj.Kind, obj.Name, err)
+				failures = append(failures, fmt.Sprintf("%s %s: %v", obj.Kind, obj.Name, err))
+			}
+		}
+
+		if step.WaitForDeletion {
+			remaining := waitForOperatorObjects(ctx, dynamicClient, step.Objects, timeout)
+			for _, obj := range remaining {
+				fmt.Printf("🔧 %s %s is stuck, removing finalizers...\n", obj.Kind, obj.Name)
+				if err := stripAllFinalizers(ctx, operatorObjectClient(dynamicClient, obj), obj.Name); err != nil {
+					fmt.Printf("⚠️  Failed to remove finalizers from %s %s: %v\n", obj.Kind, obj.Name, err)
+					failures = append(failures, fmt.Sprintf("%s %s: %v", obj.Kind, obj.Name, err))
+				}
+			}
+		}
+
+		fmt.Printf("✅ %s done\n", step.Name)
+	}
+
+	fmt.Printf("\n📊 Operator uninstall summary: %d objects planned, %d failures\n", plan.TotalObjects(), len(failures))
+	if len(failures) > 0 {
+		return fmt.Errorf("some objects could not be removed: %v", failures)
+	}
+	return nil
+}
+
+// waitForOperatorObjects polls until the objects are gone or the timeout expires, returning those still present
+func waitForOperatorObjects(ctx context.Context, dynamicClient dynamic.Interface, objects []OperatorObject, timeout time.Duration) []OperatorObject {
+	fmt.Printf("⏳ Waiting up to %s for deletion to complete...\n", timeout)
+
+	var remaining []OperatorObject
+	_ = wait.PollImmediate(2*time.Second, timeout, func() (bool, error) {
+		remaining = remaining[:0]
+		for _, obj := range objects {
+			_, err := operatorObjectClient(dynamicClient, obj).Get(ctx, obj.Name, metav1.GetOptions{})
+			if err == nil || !errors.IsNotFound(err) {
+				remaining = append(remaining, obj)
+			}
+		}
+		return len(remaining) == 0, nil
+	})
+
+	return remaining
+}
+
+// operatorObjectClient returns the dynamic client for a planned object, scoped to its namespace if it has one
+func operatorObjectClient(dynamicClient dynamic.Interface, obj OperatorObject) dynamic.ResourceInterface {
+	if obj.Namespace != "" {
+		return dynamicClient.Resource(obj.GVR).Namespace(obj.Namespace)
+	}
+	return dynamicClient.Resource(obj.GVR)
+}
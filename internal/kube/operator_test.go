@@ -0,0 +1,203 @@
+package kube
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newWidgetCRD() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": "widgets.example.com"},
+		"spec": map[string]interface{}{
+			"group": "example.com",
+			"names": map[string]interface{}{"plural": "widgets", "kind": "Widget"},
+			"scope": "Namespaced",
+			"versions": []interface{}{
+				map[string]interface{}{"name": "v1beta1", "served": true, "storage": false},
+				map[string]interface{}{"name": "v1", "served": true, "storage": true},
+			},
+		},
+	}}
+}
+
+func newAPIService(name, group string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiregistration.k8s.io/v1",
+		"kind":       "APIService",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       map[string]interface{}{"group": group},
+	}}
+}
+
+func TestPlanOperatorUninstall(t *testing.T) {
+	clientset := k8sfake.NewSimpleClientset(
+		&admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "widget-validator"},
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+				Name: "validate.example.com",
+				Rules: []admissionregistrationv1.RuleWithOperations{{
+					Rule: admissionregistrationv1.Rule{APIGroups: []string{"example.com"}, Resources: []string{"widgets"}},
+				}},
+			}},
+		},
+		&admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "mixed-validator"},
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{
+				{Name: "validate.example.com", Rules: []admissionregistrationv1.RuleWithOperations{{
+					Rule: admissionregistrationv1.Rule{APIGroups: []string{"example.com"}, Resources: []string{"widgets"}},
+				}}},
+				{Name: "validate.other.io", Rules: []admissionregistrationv1.RuleWithOperations{{
+					Rule: admissionregistrationv1.Rule{APIGroups: []string{"other.io"}, Resources: []string{"things"}},
+				}}},
+			},
+		},
+		&admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "per-group-validator"},
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+				Name: "validate.example.com",
+				Rules: []admissionregistrationv1.RuleWithOperations{
+					{Rule: admissionregistrationv1.Rule{APIGroups: []string{"example.com"}, Resources: []string{"widgets"}}},
+					{Rule: admissionregistrationv1.Rule{APIGroups: []string{"other.io"}, Resources: []string{"things"}}},
+				},
+			}},
+		},
+		&admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "multi-group-mutator"},
+			Webhooks: []admissionregistrationv1.MutatingWebhook{{
+				Name: "mutate.example.com",
+				Rules: []admissionregistrationv1.RuleWithOperations{{
+					Rule: admissionregistrationv1.Rule{APIGroups: []string{"example.com", "other.io"}, Resources: []string{"*"}},
+				}},
+			}},
+		},
+		&admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "unrelated-mutator"},
+			Webhooks: []admissionregistrationv1.MutatingWebhook{{
+				Name: "mutate.other.io",
+				Rules: []admissionregistrationv1.RuleWithOperations{{
+					Rule: admissionregistrationv1.Rule{APIGroups: []string{"other.io"}, Resources: []string{"things"}},
+				}},
+			}},
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "widget-operator"},
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"example.com"}, Resources: []string{"widgets"}, Verbs: []string{"*"}}},
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "widget-and-pods"},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"example.com"}, Resources: []string{"widgets"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+			},
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta:      metav1.ObjectMeta{Name: "widget-viewer"},
+			AggregationRule: &rbacv1.AggregationRule{},
+			Rules:           []rbacv1.PolicyRule{{APIGroups: []string{"example.com"}, Resources: []string{"widgets"}, Verbs: []string{"get"}}},
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "system:widgets"},
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"example.com"}, Resources: []string{"widgets"}, Verbs: []string{"get"}}},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "widget-and-pods"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "widget-and-pods"},
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"},
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "widget-operator"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "widget-operator"},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "admins"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+		},
+	)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			crdGVR:        "CustomResourceDefinitionList",
+			apiServiceGVR: "APIServiceList",
+			widgetGVR:     "WidgetList",
+		},
+		newWidgetCRD(),
+		newAPIService("v1.example.com", "example.com"),
+		newAPIService("v1.other.io", "other.io"),
+		newWidget("team-a", "one", "foo.example.com/cleanup"),
+		newWidget("team-b", "two"),
+	)
+
+	plan, err := PlanOperatorUninstall(context.TODO(), clientset, dynamicClient, "example.com")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []struct {
+		step  string
+		names []string
+	}{
+		{"Webhook configurations", []string{"widget-validator"}},
+		{"Custom resources", []string{"one", "two"}},
+		{"CustomResourceDefinitions", []string{"widgets.example.com"}},
+		{"APIServices", []string{"v1.example.com"}},
+		{"ClusterRoleBindings", []string{"widget-operator"}},
+		{"ClusterRoles", []string{"widget-operator"}},
+	}
+
+	if len(plan.Steps) != len(expected) {
+		t.Fatalf("expected %d steps, got %d", len(expected), len(plan.Steps))
+	}
+	for i, want := range expected {
+		step := plan.Steps[i]
+		if step.Name != want.step {
+			t.Errorf("step %d: expected %s, got %s", i, want.step, step.Name)
+		}
+		var names []string
+		for _, obj := range step.Objects {
+			names = append(names, obj.Name)
+		}
+		if len(names) != len(want.names) {
+			t.Errorf("%s: expected %v, got %v", want.step, want.names, names)
+			continue
+		}
+		for j := range names {
+			if names[j] != want.names[j] {
+				t.Errorf("%s: expected %v, got %v", want.step, want.names, names)
+				break
+			}
+		}
+	}
+
+	var shared []string
+	for _, obj := range plan.Shared {
+		shared = append(shared, obj.Kind+" "+obj.Name)
+	}
+	expectedShared := []string{
+		"ValidatingWebhookConfiguration mixed-validator",
+		"ValidatingWebhookConfiguration per-group-validator",
+		"MutatingWebhookConfiguration multi-group-mutator",
+		"ClusterRole widget-and-pods",
+	}
+	if strings.Join(shared, ",") != strings.Join(expectedShared, ",") {
+		t.Errorf("expected the mixed and multi-group webhook configurations and the mixed role to be reported as shared, got %v", shared)
+	}
+
+	if gvr := plan.Steps[1].Objects[0].GVR; gvr != widgetGVR {
+		t.Errorf("expected custom resources to use the storage version %v, got %v", widgetGVR, gvr)
+	}
+}
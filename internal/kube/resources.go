@@ -2,18 +2,21 @@ package kube
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+
+	"github.com/codesenju/kubectl-nuke-go/pkg/storage"
 )
 
 // DeleteNamespace attempts to delete a namespace and returns true if deleted, false if stuck in terminating, or error.
@@ -57,74 +60,267 @@ func ForceRemoveFinalizers(ctx context.Context, clientset kubernetes.Interface,
 	return true, err
 }
 
+// NukeOptions tunes the force pipeline used by NukeNamespace
+type NukeOptions struct {
+	BypassWebhooks bool
+	// Webhooks tunes how admission webhooks are checked when BypassWebhooks is set
+	Webhooks       WebhookOptions
+	ForceAPIDirect bool
+	// ContentsOnly wipes everything inside the namespace but never deletes or finalizes the namespace itself
+	ContentsOnly bool
+	// Kinds limits which resource types the pipeline touches; every stage leaves the other types alone
+	Kinds KindFilter
+	// Keep lists objects the pipeline must leave untouched
+	Keep KeepFilter
+	// FinalizerTimeout is how long deleted objects get to finalize before their finalizers are stripped
+	FinalizerTimeout time.Duration
+	// ArgoCD tunes how ArgoCD Applications and their ApplicationSets are handled
+	ArgoCD ArgoCDOptions
+	// Flux tunes how Flux Kustomizations and HelmReleases are handled
+	Flux FluxOptions
+	// DestroyStorageData applies the storage providers' destroyData patches, such as Rook's cleanupPolicy, so
+	// their operators wipe the stored data during teardown
+	DestroyStorageData bool
+	// DetachVolumes stops the workloads using a storage provider's attached volumes so it can be torn down
+	DetachVolumes bool
+	// Snapshots tunes how CSI VolumeSnapshots and their VolumeSnapshotContents are handled
+	Snapshots SnapshotOptions
+	// AllowDataLoss lets the pipeline delete PVCs whose volumes have the Delete reclaim policy
+	AllowDataLoss bool
+	// ReleasedPVs is one of the ReleasedPVAction constants; ReleasedPVActionAsk prompts through Prompt
+	ReleasedPVs string
+	// Prompt asks a yes/no question; without it, asking falls back to keeping released PVs
+	Prompt func(message string) bool
+	// volumesChecked is set once checkVolumeSafety has passed, so the force pipeline doesn't repeat it
+	volumesChecked bool
+	// directAPI sends raw REST requests when ForceAPIDirect is set
+	directAPI *DirectAPI
+	// StorageProviders declares the storage providers to detect and tear down; nil means the built-in ones
+	StorageProviders *storage.Registry
+}
+
+// storageProviders returns the storage provider registry, defaulting to the built-in providers
+func (o NukeOptions) storageProviders() *storage.Registry {
+	if o.StorageProviders == nil {
+		return storage.DefaultRegistry()
+	}
+	return o.StorageProviders
+}
+
+// finalizerTimeout returns how long deleted objects get to finalize, defaulting to DefaultFinalizerTimeout
+func (o NukeOptions) finalizerTimeout() time.Duration {
+	if o.FinalizerTimeout <= 0 {
+		return DefaultFinalizerTimeout
+	}
+	return o.FinalizerTimeout
+}
+
+// untouched returns the filter every stage of the pipeline applies: kept objects, and every resource type the
+// kind filter doesn't allow
+func (o NukeOptions) untouched() KeepFilter {
+	keep := o.Keep
+	keep.kinds = o.Kinds
+	return keep
+}
+
+// KeepFilter selects objects that must survive a namespace wipe, by kind or by label selector
+type KeepFilter struct {
+	Kinds    []string
+	Selector labels.Selector
+	// kinds leaves alone the resource types a kind filter doesn't allow
+	kinds KindFilter
+}
+
+// IsEmpty reports whether the filter keeps nothing
+func (f KeepFilter) IsEmpty() bool {
+	return len(f.Kinds) == 0 && (f.Selector == nil || f.Selector.Empty()) && f.kinds.IsEmpty()
+}
+
+// keeps reports whether an object of the given resource type with the given labels must be left alone
+func (f KeepFilter) keeps(res discoveredResource, objLabels map[string]string) bool {
+	if f.keepsKind(res) {
+		return true
+	}
+	if f.Selector != nil && !f.Selector.Empty() && f.Selector.Matches(labels.Set(objLabels)) {
+		return true
+	}
+	return false
+}
+
+// keepsKind reports whether every object of the given resource type must be left alone
+func (f KeepFilter) keepsKind(res discoveredResource) bool {
+	return (len(f.Kinds) > 0 && res.matchesKind(f.Kinds)) || !f.kinds.allows(res)
+}
+
+// keepsObject reports whether an object listed through the dynamic client must be left alone
+func (f KeepFilter) keepsObject(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) bool {
+	res := discoveredResource{GVR: gvr, APIResource: metav1.APIResource{Name: gvr.Resource, Kind: obj.GetKind()}}
+	return f.keeps(res, obj.GetLabels())
+}
+
+// builtinResource describes a built-in resource type for the keep filter when it is handled through the typed clientset
+func builtinResource(group, resource, kind string, shortNames ...string) discoveredResource {
+	return discoveredResource{
+		GVR: schema.GroupVersionResource{Group: group, Version: "v1", Resource: resource},
+		APIResource: metav1.APIResource{
+			Name:         resource,
+			SingularName: strings.ToLower(kind),
+			Kind:         kind,
+			Namespaced:   true,
+			ShortNames:   shortNames,
+		},
+	}
+}
+
+// Built-in resource types handled through the typed clientset
+var (
+	podsResource                   = builtinResource("", "pods", "Pod", "po")
+	persistentVolumeClaimsResource = builtinResource("", "persistentvolumeclaims", "PersistentVolumeClaim", "pvc")
+)
+
 // NukeNamespace aggressively deletes a namespace by force-deleting all resources first
 func NukeNamespace(ctx context.Context, clientset kubernetes.Interface, name string, bypassWebhooks bool, forceAPIDirect bool) error {
-	fmt.Printf("💥 NUKE MODE: Aggressively deleting namespace %s and all its contents...\n", name)
+	return NukeNamespaceWithOptions(ctx, clientset, name, NukeOptions{
+		BypassWebhooks: bypassWebhooks,
+		ForceAPIDirect: forceAPIDirect,
+	})
+}
 
+// NukeNamespaceWithOptions runs the force pipeline on a namespace. In contents-only mode it stops after wiping
+// the namespace's contents and never deletes or finalizes the namespace itself.
+func NukeNamespaceWithOptions(ctx context.Context, clientset kubernetes.Interface, name string, opts NukeOptions) error {
 	// Get REST config for dynamic client operations
+	var discoveryClient discovery.DiscoveryInterface
+	var dynamicClient dynamic.Interface
 	config, err := GetRESTConfig(clientset)
 	if err != nil {
 		fmt.Printf("⚠️  Warning: Failed to get REST config: %v\n", err)
 		fmt.Printf("    Some advanced operations may not be available\n")
+	} else if discoveryClient, err = discovery.NewDiscoveryClientForConfig(config); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to create discovery client: %v\n", err)
+	} else if dynamicClient, err = dynamic.NewForConfig(config); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to create dynamic client: %v\n", err)
+		discoveryClient = nil
 	}
 
-	// If bypass webhooks is enabled, check for problematic webhooks
-	if bypassWebhooks {
-		// First check for storage provider issues
-		if err := DetectStorageProviderResources(ctx, clientset); err != nil {
-			fmt.Printf("⚠️  Warning: Failed to detect storage provider issues: %v\n", err)
+	return forceNukeNamespace(ctx, clientset, discoveryClient, dynamicClient, name, opts)
+}
+
+// forceNukeNamespace checks the namespace's volumes and sets up direct API calls, then runs the force pipeline
+// with the given clients
+func forceNukeNamespace(ctx context.Context, clientset kubernetes.Interface, discoveryClient discovery.DiscoveryInterface, dynamicClient dynamic.Interface, name string, opts NukeOptions) error {
+	if opts.ContentsOnly {
+		fmt.Printf("💥 CONTENTS-ONLY MODE: Aggressively emptying namespace %s (the namespace itself is kept)...\n", name)
+	} else {
+		fmt.Printf("💥 NUKE MODE: Aggressively deleting namespace %s and all its contents...\n", name)
+	}
+	if !opts.Kinds.IsEmpty() {
+		displayKindFilter(opts.Kinds)
+	}
+	if !opts.Keep.IsEmpty() {
+		displayKeepFilter(opts.Keep)
+	}
+
+	// Refuse to delete volumes whose data would go with them before anything is touched
+	if !opts.volumesChecked {
+		if err := checkVolumeSafety(ctx, clientset, name, opts); err != nil {
+			return err
 		}
+	}
 
-		// Then check for problematic webhooks
-		if err := DetectAndHandleWebhookIssues(ctx, clientset, true); err != nil {
-			fmt.Printf("⚠️  Warning: Failed to handle webhook issues: %v\n", err)
+	if opts.ForceAPIDirect {
+		direct, err := NewDirectAPI(clientset)
+		if err != nil {
+			fmt.Printf("⚠️  Warning: Direct API calls unavailable: %v\n", err)
 		}
+		opts.directAPI = direct
+	}
+
+	return nukeNamespace(ctx, clientset, discoveryClient, dynamicClient, name, opts)
+}
 
-		// Specifically target storage provider webhooks
-		if err := DisableStorageProviderWebhooks(ctx, clientset); err != nil {
-			fmt.Printf("⚠️  Warning: Failed to disable storage provider webhooks: %v\n", err)
+// nukeNamespace runs the stages of the force pipeline. The discovery-driven stages are skipped when the
+// discovery and dynamic clients are nil. Every stage leaves alone what opts.untouched() selects.
+func nukeNamespace(ctx context.Context, clientset kubernetes.Interface, discoveryClient discovery.DiscoveryInterface, dynamicClient dynamic.Interface, name string, opts NukeOptions) error {
+	untouched := opts.untouched()
+
+	// Report the storage backing the namespace's volumes before tearing it down
+	if _, err := DetectStorageProviders(ctx, clientset, name, opts.storageProviders()); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to detect storage providers: %v\n", err)
+	}
+
+	// If bypass webhooks is enabled, check for problematic webhooks
+	if opts.BypassWebhooks {
+		// Only the webhooks intercepting the deletes and finalizer updates below can block them
+		var scope *WebhookScope
+		if dynamicClient != nil {
+			var err error
+			scope, err = NewWebhookScope(ctx, clientset, dynamicClient, name, opts)
+			if err != nil {
+				fmt.Printf("⚠️  Warning: Failed to plan webhook scope, checking every webhook: %v\n", err)
+			}
+		}
+
+		// Check for problematic webhooks, storage provider webhooks included
+		webhookOpts := opts.Webhooks
+		webhookOpts.StorageProviders = opts.storageProviders()
+		if err := DetectAndHandleWebhookIssues(ctx, clientset, scope, true, webhookOpts); err != nil {
+			fmt.Printf("⚠️  Warning: Failed to handle webhook issues: %v\n", err)
 		}
 	}
 
-	// First, force delete all pods with grace period 0
-	if err := forceDeleteAllPods(ctx, clientset, name); err != nil {
-		fmt.Printf("⚠️  Warning: Failed to force delete pods: %v\n", err)
+	// Delete CSI snapshots while their driver is still around to delete the backend snapshots
+	if dynamicClient != nil {
+		if err := handleNamespaceVolumeSnapshots(ctx, dynamicClient, name, opts); err != nil {
+			fmt.Printf("⚠️  Warning: Failed to handle volume snapshots: %v\n", err)
+		}
 	}
 
 	// Handle storage provider specific resources (like Longhorn)
-	if config != nil {
-		if err := HandleStorageProviderResources(ctx, clientset, name, config); err != nil {
+	if dynamicClient != nil {
+		fmt.Printf("🔍 Checking for storage provider resources in namespace %s...\n", name)
+		skipped, err := handleStorageProviders(ctx, clientset, discoveryClient, dynamicClient, name, opts)
+		if err != nil {
 			fmt.Printf("⚠️  Warning: Failed to handle storage provider resources: %v\n", err)
 		}
+		// Wiping the namespace now would delete the skipped providers' objects and operator pods behind their back
+		if len(skipped) > 0 {
+			return fmt.Errorf("%s teardown skipped; stopping before the pods and objects it depends on are deleted", strings.Join(skipped, ", "))
+		}
+	}
+
+	// Force delete all pods with grace period 0, once the storage operators and CSI drivers running here are done
+	if err := forceDeleteAllPods(ctx, clientset, name, untouched); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to force delete pods: %v\n", err)
 	}
 
 	// Handle PVC finalizers specifically
-	if err := HandlePVCFinalizers(ctx, clientset, name, forceAPIDirect); err != nil {
+	if err := HandlePVCFinalizers(ctx, clientset, name, opts); err != nil {
 		fmt.Printf("⚠️  Warning: Failed to handle PVC finalizers: %v\n", err)
 	}
 
-	// Force delete other common resources
-	if err := forceDeleteCommonResources(ctx, clientset, name); err != nil {
-		fmt.Printf("⚠️  Warning: Failed to delete some resources: %v\n", err)
+	// Force delete every deletable namespaced resource type, built-in or custom
+	if dynamicClient != nil {
+		if _, err := deleteNamespacedResources(ctx, dynamicClient, discoveryClient, name, opts.Kinds, opts.Keep, opts.finalizerTimeout()); err != nil {
+			fmt.Printf("⚠️  Warning: Failed to delete some resources: %v\n", err)
+		}
 	}
 
 	// Aggressively remove finalizers from all custom resources
-	if config != nil {
-		if err := RemoveAllCustomResourceFinalizers(ctx, config, name); err != nil {
+	if dynamicClient != nil {
+		if err := removeAllCustomResourceFinalizers(ctx, discoveryClient, dynamicClient, name, untouched, opts.directAPI); err != nil {
 			fmt.Printf("⚠️  Warning: Failed to remove custom resource finalizers: %v\n", err)
 		}
 	}
 
-	// Force delete all custom resources
-	if config != nil {
-		if err := ForceDeleteAllCustomResources(ctx, config, name); err != nil {
-			fmt.Printf("⚠️  Warning: Failed to delete custom resources: %v\n", err)
-		}
-	}
-
 	// Run diagnostics on the namespace
 	DiagnoseStuckNamespace(ctx, clientset, name)
 
+	if opts.ContentsOnly {
+		fmt.Printf("🧹 Namespace %s has been emptied and kept\n", name)
+		return nil
+	}
+
 	// Now try to delete the namespace
 	deleted, terminating, err := DeleteNamespace(ctx, clientset, name)
 	if err != nil {
@@ -133,31 +329,52 @@ func NukeNamespace(ctx context.Context, clientset kubernetes.Interface, name str
 
 	if terminating || !deleted {
 		fmt.Printf("🔧 Namespace stuck, attempting aggressive finalizer removal...\n")
-		return aggressiveFinalizerRemoval(ctx, clientset, name)
+		return aggressiveFinalizerRemoval(ctx, clientset, name, opts.directAPI)
 	}
 
 	return nil
 }
 
+// displayKeepFilter prints the objects a namespace wipe will leave untouched
+func displayKeepFilter(keep KeepFilter) {
+	if len(keep.Kinds) > 0 {
+		fmt.Printf("🛡️  Keeping kinds: %s\n", strings.Join(keep.Kinds, ", "))
+	}
+	if keep.Selector != nil && !keep.Selector.Empty() {
+		fmt.Printf("🛡️  Keeping objects matching selector: %s\n", keep.Selector.String())
+	}
+}
+
 // forceDeleteAllPods force deletes all pods in the namespace with grace period 0
-func forceDeleteAllPods(ctx context.Context, clientset kubernetes.Interface, name string) error {
+func forceDeleteAllPods(ctx context.Context, clientset kubernetes.Interface, name string, keep KeepFilter) error {
+	if keep.keepsKind(podsResource) {
+		return nil
+	}
+
 	pods, err := clientset.CoreV1().Pods(name).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return err
 	}
 
-	if len(pods.Items) == 0 {
+	var podsToDelete []corev1.Pod
+	for _, pod := range pods.Items {
+		if !keep.keeps(podsResource, pod.Labels) {
+			podsToDelete = append(podsToDelete, pod)
+		}
+	}
+
+	if len(podsToDelete) == 0 {
 		return nil
 	}
 
-	fmt.Printf("🚀 Force deleting %d pods...\n", len(pods.Items))
+	fmt.Printf("🚀 Force deleting %d pods...\n", len(podsToDelete))
 
 	gracePeriod := int64(0)
 	deleteOptions := metav1.DeleteOptions{
 		GracePeriodSeconds: &gracePeriod,
 	}
 
-	for _, pod := range pods.Items {
+	for _, pod := range podsToDelete {
 		err := clientset.CoreV1().Pods(name).Delete(ctx, pod.Name, deleteOptions)
 		if err != nil {
 			fmt.Printf("⚠️  Failed to delete pod %s: %v\n", pod.Name, err)
@@ -169,68 +386,9 @@ func forceDeleteAllPods(ctx context.Context, clientset kubernetes.Interface, nam
 	return nil
 }
 
-// forceDeleteCommonResources deletes common resources that might prevent namespace deletion
-func forceDeleteCommonResources(ctx context.Context, clientset kubernetes.Interface, name string) error {
-	gracePeriod := int64(0)
-	deleteOptions := metav1.DeleteOptions{
-		GracePeriodSeconds: &gracePeriod,
-	}
-
-	// Delete services
-	services, err := clientset.CoreV1().Services(name).List(ctx, metav1.ListOptions{})
-	if err == nil && len(services.Items) > 0 {
-		fmt.Printf("🗑️  Deleting %d services...\n", len(services.Items))
-		for _, svc := range services.Items {
-			clientset.CoreV1().Services(name).Delete(ctx, svc.Name, deleteOptions)
-		}
-	}
-
-	// Delete deployments
-	deployments, err := clientset.AppsV1().Deployments(name).List(ctx, metav1.ListOptions{})
-	if err == nil && len(deployments.Items) > 0 {
-		fmt.Printf("🗑️  Deleting %d deployments...\n", len(deployments.Items))
-		for _, deploy := range deployments.Items {
-			clientset.AppsV1().Deployments(name).Delete(ctx, deploy.Name, deleteOptions)
-		}
-	}
-
-	// Delete replicasets
-	replicasets, err := clientset.AppsV1().ReplicaSets(name).List(ctx, metav1.ListOptions{})
-	if err == nil && len(replicasets.Items) > 0 {
-		fmt.Printf("🗑️  Deleting %d replicasets...\n", len(replicasets.Items))
-		for _, rs := range replicasets.Items {
-			clientset.AppsV1().ReplicaSets(name).Delete(ctx, rs.Name, deleteOptions)
-		}
-	}
-
-	// Delete configmaps
-	configmaps, err := clientset.CoreV1().ConfigMaps(name).List(ctx, metav1.ListOptions{})
-	if err == nil && len(configmaps.Items) > 0 {
-		fmt.Printf("🗑️  Deleting %d configmaps...\n", len(configmaps.Items))
-		for _, cm := range configmaps.Items {
-			clientset.CoreV1().ConfigMaps(name).Delete(ctx, cm.Name, deleteOptions)
-		}
-	}
-
-	// Delete secrets
-	secrets, err := clientset.CoreV1().Secrets(name).List(ctx, metav1.ListOptions{})
-	if err == nil && len(secrets.Items) > 0 {
-		fmt.Printf("🗑️  Deleting %d secrets...\n", len(secrets.Items))
-		for _, secret := range secrets.Items {
-			clientset.CoreV1().Secrets(name).Delete(ctx, secret.Name, deleteOptions)
-		}
-	}
-
-	// Delete custom resources that might be preventing namespace deletion
-	if err := forceDeleteCustomResources(ctx, clientset, name); err != nil {
-		fmt.Printf("⚠️  Warning: Failed to delete some custom resources: %v\n", err)
-	}
-
-	return nil
-}
-
-// aggressiveFinalizerRemoval uses multiple strategies to remove finalizers
-func aggressiveFinalizerRemoval(ctx context.Context, clientset kubernetes.Interface, name string) error {
+// aggressiveFinalizerRemoval uses multiple strategies to remove finalizers, ending with raw calls to the
+// namespace finalize endpoint when direct is set
+func aggressiveFinalizerRemoval(ctx context.Context, clientset kubernetes.Interface, name string, direct *DirectAPI) error {
 	// Try the standard finalizer removal first
 	removed, err := ForceRemoveFinalizers(ctx, clientset, name)
 	if err == nil && removed {
@@ -261,7 +419,12 @@ func aggressiveFinalizerRemoval(ctx context.Context, clientset kubernetes.Interf
 	if err != nil {
 		// Last resort: try to patch the namespace spec directly
 		fmt.Printf("🔧 Patch failed, trying direct spec modification...\n")
-		return forceRemoveFinalizersDirectly(ctx, clientset, name)
+		err = forceRemoveFinalizersDirectly(ctx, clientset, name)
+		if err != nil && direct != nil {
+			fmt.Printf("🔧 Direct modification failed, finalizing through the raw API...\n")
+			err = direct.FinalizeNamespace(ctx, name)
+		}
+		return err
 	}
 
 	return nil
@@ -329,163 +492,6 @@ func ForceDeletePods(ctx context.Context, clientset kubernetes.Interface, namesp
 	return nil
 }
 
-// forceDeleteCustomResources discovers and force deletes custom resources in a namespace
-// This is specifically designed to handle complex cases like SignOz with ClickHouse installations
-func forceDeleteCustomResources(ctx context.Context, clientset kubernetes.Interface, namespace string) error {
-	// We need to get the REST config to create discovery and dynamic clients
-	config, err := GetRESTConfig(clientset)
-	if err != nil {
-		fmt.Printf("⚠️  Could not get REST config for custom resource deletion, skipping...\n")
-		return nil
-	}
-
-	// Create discovery client to find all API resources
-	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
-	if err != nil {
-		fmt.Printf("⚠️  Could not create discovery client: %v\n", err)
-		return nil
-	}
-
-	// Create dynamic client for custom resource operations
-	dynamicClient, err := dynamic.NewForConfig(config)
-	if err != nil {
-		fmt.Printf("⚠️  Could not create dynamic client: %v\n", err)
-		return nil
-	}
-
-	// Get all API resources
-	apiResourceLists, err := discoveryClient.ServerPreferredNamespacedResources()
-	if err != nil {
-		fmt.Printf("⚠️  Could not discover API resources: %v\n", err)
-		return nil
-	}
-
-	customResourcesFound := 0
-	customResourcesDeleted := 0
-
-	// Look for custom resources (non-core Kubernetes resources)
-	for _, apiResourceList := range apiResourceLists {
-		// Skip core Kubernetes APIs and common extensions
-		if strings.Contains(apiResourceList.GroupVersion, "/v1") && !strings.Contains(apiResourceList.GroupVersion, ".") {
-			continue
-		}
-
-		gv, err := schema.ParseGroupVersion(apiResourceList.GroupVersion)
-		if err != nil {
-			continue
-		}
-
-		for _, apiResource := range apiResourceList.APIResources {
-			// Skip subresources
-			if strings.Contains(apiResource.Name, "/") {
-				continue
-			}
-
-			// Only process namespaced resources that support delete
-			if !apiResource.Namespaced {
-				continue
-			}
-
-			canDelete := false
-			for _, verb := range apiResource.Verbs {
-				if verb == "delete" {
-					canDelete = true
-					break
-				}
-			}
-			if !canDelete {
-				continue
-			}
-
-			// Create GVR (GroupVersionResource)
-			gvr := schema.GroupVersionResource{
-				Group:    gv.Group,
-				Version:  gv.Version,
-				Resource: apiResource.Name,
-			}
-
-			// List resources of this type in the namespace
-			resourceList, err := dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
-			if err != nil {
-				// Skip resources we can't list (permissions, etc.)
-				continue
-			}
-
-			if len(resourceList.Items) > 0 {
-				customResourcesFound += len(resourceList.Items)
-				fmt.Printf("🔍 Found %d %s resources in namespace %s\n", len(resourceList.Items), apiResource.Name, namespace)
-
-				// Force delete each resource
-				gracePeriod := int64(0)
-				deleteOptions := metav1.DeleteOptions{
-					GracePeriodSeconds: &gracePeriod,
-				}
-
-				for _, resource := range resourceList.Items {
-					resourceName := resource.GetName()
-					fmt.Printf("💥 Force deleting %s: %s\n", apiResource.Name, resourceName)
-
-					// First, try to remove finalizers if they exist
-					if finalizers := resource.GetFinalizers(); len(finalizers) > 0 {
-						fmt.Printf("🔧 Removing finalizers from %s: %s\n", apiResource.Name, resourceName)
-						
-						// Try patch method first (most reliable)
-						patchData := map[string]interface{}{
-							"metadata": map[string]interface{}{
-								"finalizers": nil,
-							},
-						}
-						patchBytes, _ := json.Marshal(patchData)
-						
-						_, err := dynamicClient.Resource(gvr).Namespace(namespace).Patch(
-							ctx,
-							resourceName,
-							types.MergePatchType,
-							patchBytes,
-							metav1.PatchOptions{},
-						)
-						
-						if err != nil {
-							fmt.Printf("⚠️  Failed to patch finalizers from %s: %v\n", resourceName, err)
-							
-							// Try update method as fallback
-							resource.SetFinalizers([]string{})
-							_, err := dynamicClient.Resource(gvr).Namespace(namespace).Update(
-								ctx,
-								&resource,
-								metav1.UpdateOptions{},
-							)
-							
-							if err != nil {
-								fmt.Printf("⚠️  Failed to update finalizers from %s: %v\n", resourceName, err)
-							}
-						}
-					}
-
-					// Now force delete the resource
-					err := dynamicClient.Resource(gvr).Namespace(namespace).Delete(ctx, resourceName, deleteOptions)
-					if err != nil {
-						fmt.Printf("⚠️  Failed to delete %s %s: %v\n", apiResource.Name, resourceName, err)
-					} else {
-						customResourcesDeleted++
-						fmt.Printf("✅ Successfully deleted %s: %s\n", apiResource.Name, resourceName)
-					}
-				}
-			}
-		}
-	}
-
-	if customResourcesFound > 0 {
-		fmt.Printf("📊 Custom resources summary: %d found, %d deleted\n", customResourcesFound, customResourcesDeleted)
-		// Wait a bit for custom resources to be processed
-		time.Sleep(3 * time.Second)
-	} else {
-		fmt.Printf("ℹ️  No custom resources found in namespace %s\n", namespace)
-	}
-
-	return nil
-}
-
 // WaitForNamespaceDeletion waits for a namespace to be completely deleted
 func WaitForNamespaceDeletion(ctx context.Context, clientset kubernetes.Interface, name string, maxWaitSeconds int) bool {
 	fmt.Printf("⏳ Waiting for namespace %s to be completely deleted...\n", name)
@@ -0,0 +1,344 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// Finalizers the CSI snapshot controller puts on VolumeSnapshots and VolumeSnapshotContents
+const (
+	SnapshotBoundProtectionFinalizer   = "snapshot.storage.kubernetes.io/volumesnapshot-bound-protection"
+	SnapshotSourceProtectionFinalizer  = "snapshot.storage.kubernetes.io/volumesnapshot-as-source-protection"
+	SnapshotContentProtectionFinalizer = "snapshot.storage.kubernetes.io/volumesnapshotcontent-bound-protection"
+	snapshotFinalizerPrefix            = "snapshot.storage.kubernetes.io/"
+)
+
+// How the deletionPolicy of the namespace's VolumeSnapshotContents is applied
+const (
+	// SnapshotPolicyRespect keeps each content's own deletionPolicy
+	SnapshotPolicyRespect = ""
+	// SnapshotPolicyDelete sets deletionPolicy Delete, so the backend snapshots are deleted
+	SnapshotPolicyDelete = "delete"
+	// SnapshotPolicyRetain sets deletionPolicy Retain, so the contents and backend snapshots are kept
+	SnapshotPolicyRetain = "retain"
+)
+
+// VolumeSnapshotContent deletion policies
+const (
+	deletionPolicyDelete = "Delete"
+	deletionPolicyRetain = "Retain"
+)
+
+// DefaultSnapshotTimeout bounds the wait for the snapshot controller before finalizers are removed
+const DefaultSnapshotTimeout = 30 * time.Second
+
+// CSI snapshot resources
+var (
+	volumeSnapshotsResource   = builtinResource("snapshot.storage.k8s.io", "volumesnapshots", "VolumeSnapshot", "vs")
+	volumeSnapshotContentsGVR = schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshotcontents"}
+)
+
+// SnapshotOptions tunes how CSI VolumeSnapshots in the namespace are handled
+type SnapshotOptions struct {
+	// DeletionPolicy is one of the SnapshotPolicy constants
+	DeletionPolicy string
+	// Timeout bounds the wait for the snapshot controller; zero means DefaultSnapshotTimeout
+	Timeout time.Duration
+}
+
+// timeout returns the wait for the snapshot controller, defaulting to DefaultSnapshotTimeout
+func (o SnapshotOptions) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return DefaultSnapshotTimeout
+	}
+	return o.Timeout
+}
+
+// VolumeSnapshot is a namespace's VolumeSnapshot with its bound VolumeSnapshotContent. Content is nil for a
+// snapshot that was never bound; Snapshot is nil for a content whose snapshot is already gone.
+type VolumeSnapshot struct {
+	Snapshot *unstructured.Unstructured
+	Content  *unstructured.Unstructured
+}
+
+// Name returns the VolumeSnapshot's name, or the one its content refers to
+func (s VolumeSnapshot) Name() string {
+	if s.Snapshot != nil {
+		return s.Snapshot.GetName()
+	}
+	name, _, _ := unstructured.NestedString(s.Content.Object, "spec", "volumeSnapshotRef", "name")
+	return name
+}
+
+// DeletionPolicy returns the content's deletionPolicy, or an empty string without a content
+func (s VolumeSnapshot) DeletionPolicy() string {
+	if s.Content == nil {
+		return ""
+	}
+	policy, _, _ := unstructured.NestedString(s.Content.Object, "spec", "deletionPolicy")
+	return policy
+}
+
+// Driver returns the CSI driver holding the backend snapshot
+func (s VolumeSnapshot) Driver() string {
+	if s.Content == nil {
+		return ""
+	}
+	driver, _, _ := unstructured.NestedString(s.Content.Object, "spec", "driver")
+	return driver
+}
+
+// Handle returns the backend snapshot's ID, from the content's status or, when pre-provisioned, its source
+func (s VolumeSnapshot) Handle() string {
+	if s.Content == nil {
+		return ""
+	}
+	if handle, _, _ := unstructured.NestedString(s.Content.Object, "status", "snapshotHandle"); handle != "" {
+		return handle
+	}
+	handle, _, _ := unstructured.NestedString(s.Content.Object, "spec", "source", "snapshotHandle")
+	return handle
+}
+
+// effectivePolicy returns the deletionPolicy the content ends up with
+func (s VolumeSnapshot) effectivePolicy(opts SnapshotOptions) string {
+	switch opts.DeletionPolicy {
+	case SnapshotPolicyDelete:
+		return deletionPolicyDelete
+	case SnapshotPolicyRetain:
+		return deletionPolicyRetain
+	}
+	return s.DeletionPolicy()
+}
+
+// HandleVolumeSnapshots deletes the namespace's CSI VolumeSnapshots and their bound VolumeSnapshotContents in
+// the order the snapshot controller expects, and reports which backend snapshots are deleted or orphaned
+func HandleVolumeSnapshots(ctx context.Context, config *rest.Config, namespace string, opts NukeOptions) error {
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	return handleNamespaceVolumeSnapshots(ctx, dynamicClient, namespace, opts)
+}
+
+// handleNamespaceVolumeSnapshots finds the namespace's snapshots that the pipeline may touch and deletes them
+func handleNamespaceVolumeSnapshots(ctx context.Context, dynamicClient dynamic.Interface, namespace string, opts NukeOptions) error {
+	snapshots, err := findVolumeSnapshots(ctx, dynamicClient, namespace, opts.untouched())
+	if err != nil || len(snapshots) == 0 {
+		return err
+	}
+	displayVolumeSnapshots(snapshots, opts.Snapshots)
+	return handleVolumeSnapshots(ctx, dynamicClient, namespace, snapshots, opts.Snapshots)
+}
+
+// findVolumeSnapshots returns the namespace's VolumeSnapshots with their bound contents, followed by the contents
+// still referring to snapshots of the namespace that no longer exist. It returns nothing when the snapshot CRDs
+// aren't installed.
+func findVolumeSnapshots(ctx context.Context, dynamicClient dynamic.Interface, namespace string, keep KeepFilter) ([]VolumeSnapshot, error) {
+	if keep.keepsKind(volumeSnapshotsResource) {
+		return nil, nil
+	}
+
+	snapshotList, err := dynamicClient.Resource(volumeSnapshotsResource.GVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list VolumeSnapshots: %w", err)
+	}
+
+	contents := map[string]*unstructured.Unstructured{}
+	contentList, err := dynamicClient.Resource(volumeSnapshotContentsGVR).List(ctx, metav1.ListOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to list VolumeSnapshotContents: %w", err)
+	}
+	if contentList != nil {
+		for i := range contentList.Items {
+			content := &contentList.Items[i]
+			if ref, _, _ := unstructured.NestedString(content.Object, "spec", "volumeSnapshotRef", "namespace"); ref == namespace {
+				contents[content.GetName()] = content
+			}
+		}
+	}
+
+	var snapshots []VolumeSnapshot
+	claimed := map[string]bool{}
+	for i := range snapshotList.Items {
+		snapshot := &snapshotList.Items[i]
+		claimed[snapshot.GetName()] = true
+		contentName, _, _ := unstructured.NestedString(snapshot.Object, "status", "boundVolumeSnapshotContentName")
+		if contentName == "" {
+			contentName, _, _ = unstructured.NestedString(snapshot.Object, "spec", "source", "volumeSnapshotContentName")
+		}
+		content := contents[contentName]
+		delete(contents, contentName)
+		if keep.keeps(volumeSnapshotsResource, snapshot.GetLabels()) {
+			continue
+		}
+		snapshots = append(snapshots, VolumeSnapshot{Snapshot: snapshot, Content: content})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name() < snapshots[j].Name() })
+
+	var orphans []VolumeSnapshot
+	for _, content := range contents {
+		orphan := VolumeSnapshot{Content: content}
+		if !claimed[orphan.Name()] {
+			orphans = append(orphans, orphan)
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Content.GetName() < orphans[j].Content.GetName() })
+	return append(snapshots, orphans...), nil
+}
+
+// displayVolumeSnapshots prints each snapshot with its content, backend snapshot and what happens to it
+func displayVolumeSnapshots(snapshots []VolumeSnapshot, opts SnapshotOptions) {
+	fmt.Printf("\n📸 VOLUME SNAPSHOTS:\n")
+	fmt.Printf("===================\n")
+	for _, s := range snapshots {
+		switch {
+		case s.Content == nil:
+			fmt.Printf("📸 VolumeSnapshot %s: not bound to a VolumeSnapshotContent\n", s.Name())
+			continue
+		case s.Snapshot == nil:
+			fmt.Printf("📸 VolumeSnapshotContent %s: left behind by deleted VolumeSnapshot %s\n", s.Content.GetName(), s.Name())
+		default:
+			fmt.Printf("📸 VolumeSnapshot %s → VolumeSnapshotContent %s\n", s.Name(), s.Content.GetName())
+		}
+		fmt.Printf("   💿 Backend snapshot: %s (%s)\n", valueOr(s.Handle(), "unknown"), valueOr(s.Driver(), "unknown driver"))
+		policy := s.effectivePolicy(opts)
+		if policy != s.DeletionPolicy() {
+			fmt.Printf("   🔄 deletionPolicy %s overridden to %s\n", s.DeletionPolicy(), policy)
+		}
+		if policy == deletionPolicyDelete {
+			fmt.Printf("   🔥 deletionPolicy Delete: the backend snapshot will be deleted\n")
+		} else {
+			fmt.Printf("   🛡️  deletionPolicy %s: the VolumeSnapshotContent and backend snapshot will be kept\n", policy)
+		}
+	}
+}
+
+// handleVolumeSnapshots applies the deletionPolicy override, deletes the snapshots and then the contents with the
+// Delete policy, giving the snapshot controller time to delete the backend snapshots. Finalizers are removed
+// only from what is still there after the wait: the snapshots' first, then the contents' bound protection.
+func handleVolumeSnapshots(ctx context.Context, dynamicClient dynamic.Interface, namespace string, snapshots []VolumeSnapshot, opts SnapshotOptions) error {
+	gracePeriod := int64(0)
+	deleteOptions := metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod}
+	snapshotClient := dynamicClient.Resource(volumeSnapshotsResource.GVR).Namespace(namespace)
+	contentClient := dynamicClient.Resource(volumeSnapshotContentsGVR)
+
+	var deletedSnapshots, deletedContents []string
+	retained := 0
+	for _, s := range snapshots {
+		policy := s.effectivePolicy(opts)
+		if s.Content != nil && policy != s.DeletionPolicy() {
+			patch := []byte(fmt.Sprintf(`{"spec":{"deletionPolicy":%q}}`, policy))
+			if _, err := contentClient.Patch(ctx, s.Content.GetName(), types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+				fmt.Printf("⚠️  Failed to set deletionPolicy %s on VolumeSnapshotContent %s: %v\n", policy, s.Content.GetName(), err)
+				policy = s.DeletionPolicy()
+			}
+		}
+
+		if s.Snapshot != nil {
+			if err := snapshotClient.Delete(ctx, s.Name(), deleteOptions); err != nil && !errors.IsNotFound(err) {
+				fmt.Printf("⚠️  Failed to delete VolumeSnapshot %s: %v\n", s.Name(), err)
+			} else {
+				fmt.Printf("🗑️  Deleted VolumeSnapshot %s\n", s.Name())
+				deletedSnapshots = append(deletedSnapshots, s.Name())
+			}
+		}
+		if s.Content == nil {
+			continue
+		}
+		if policy != deletionPolicyDelete {
+			retained++
+			continue
+		}
+		if err := contentClient.Delete(ctx, s.Content.GetName(), metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			fmt.Printf("⚠️  Failed to delete VolumeSnapshotContent %s: %v\n", s.Content.GetName(), err)
+			continue
+		}
+		deletedContents = append(deletedContents, s.Content.GetName())
+	}
+
+	if len(deletedSnapshots)+len(deletedContents) > 0 {
+		fmt.Printf("⏳ Waiting up to %v for the snapshot controller...\n", opts.timeout())
+	}
+	for _, name := range waitForRemoval(ctx, snapshotClient, deletedSnapshots, opts.timeout()) {
+		fmt.Printf("🔧 VolumeSnapshot %s is stuck, removing its snapshot finalizers\n", name)
+		if err := removeSnapshotFinalizers(ctx, snapshotClient, name); err != nil {
+			fmt.Printf("⚠️  Failed to remove finalizers from VolumeSnapshot %s: %v\n", name, err)
+		}
+	}
+
+	orphaned := 0
+	for _, name := range waitForRemoval(ctx, contentClient, deletedContents, opts.timeout()) {
+		fmt.Printf("🔧 VolumeSnapshotContent %s is stuck, removing its snapshot finalizers\n", name)
+		if err := removeSnapshotFinalizers(ctx, contentClient, name); err != nil {
+			fmt.Printf("⚠️  Failed to remove finalizers from VolumeSnapshotContent %s: %v\n", name, err)
+			continue
+		}
+		fmt.Printf("⚠️  The backend snapshot of %s may be orphaned; check that it is gone from the storage backend\n", name)
+		orphaned++
+	}
+
+	fmt.Printf("📊 Snapshots summary: %d backend snapshot(s) deleted, %d retained, %d possibly orphaned\n",
+		len(deletedContents)-orphaned, retained, orphaned)
+	return nil
+}
+
+// waitForRemoval polls until the named objects are gone and returns those still present after the timeout
+func waitForRemoval(ctx context.Context, resourceClient dynamic.ResourceInterface, names []string, timeout time.Duration) []string {
+	remaining := names
+	_ = wait.PollImmediate(2*time.Second, timeout, func() (bool, error) {
+		var still []string
+		for _, name := range remaining {
+			if _, err := resourceClient.Get(ctx, name, metav1.GetOptions{}); !errors.IsNotFound(err) {
+				still = append(still, name)
+			}
+		}
+		remaining = still
+		return len(remaining) == 0, nil
+	})
+	return remaining
+}
+
+// removeSnapshotFinalizers removes the snapshot controller's finalizers from an object, leaving any others
+func removeSnapshotFinalizers(ctx context.Context, resourceClient dynamic.ResourceInterface, name string) error {
+	obj, err := resourceClient.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	for _, finalizer := range obj.GetFinalizers() {
+		if !strings.HasPrefix(finalizer, snapshotFinalizerPrefix) {
+			continue
+		}
+		if _, err := RemoveFinalizerFromObject(ctx, resourceClient, name, finalizer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// valueOr returns the value, or the fallback when it is empty
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,151 @@
+package kube
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func newVolumeSnapshot(namespace, name, content string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{"boundVolumeSnapshotContentName": content},
+	}}
+	u.SetAPIVersion("snapshot.storage.k8s.io/v1")
+	u.SetKind("VolumeSnapshot")
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	u.SetFinalizers([]string{SnapshotBoundProtectionFinalizer, SnapshotSourceProtectionFinalizer})
+	return u
+}
+
+func newVolumeSnapshotContent(name, namespace, snapshot, policy string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"deletionPolicy":    policy,
+			"driver":            "ebs.csi.aws.com",
+			"volumeSnapshotRef": map[string]interface{}{"namespace": namespace, "name": snapshot},
+		},
+		"status": map[string]interface{}{"snapshotHandle": "snap-" + name},
+	}}
+	u.SetAPIVersion("snapshot.storage.k8s.io/v1")
+	u.SetKind("VolumeSnapshotContent")
+	u.SetName(name)
+	u.SetFinalizers([]string{SnapshotContentProtectionFinalizer, "example.com/keep"})
+	return u
+}
+
+func newSnapshotDynamicClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			volumeSnapshotsResource.GVR: "VolumeSnapshotList",
+			volumeSnapshotContentsGVR:   "VolumeSnapshotContentList",
+		}, objects...)
+}
+
+func TestFindVolumeSnapshots(t *testing.T) {
+	dynamicClient := newSnapshotDynamicClient(
+		newVolumeSnapshot("shop", "weekly", "content-weekly"),
+		newVolumeSnapshot("shop", "daily", "content-daily"),
+		newVolumeSnapshotContent("content-weekly", "shop", "weekly", deletionPolicyRetain),
+		newVolumeSnapshotContent("content-daily", "shop", "daily", deletionPolicyDelete),
+		newVolumeSnapshotContent("content-gone", "shop", "gone", deletionPolicyDelete),
+		newVolumeSnapshotContent("content-other", "other", "daily", deletionPolicyDelete),
+	)
+
+	snapshots, err := findVolumeSnapshots(context.TODO(), dynamicClient, "shop", KeepFilter{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(snapshots) != 3 {
+		t.Fatalf("expected 2 snapshots and 1 left-behind content, got %d", len(snapshots))
+	}
+	if snapshots[0].Name() != "daily" || snapshots[0].Content.GetName() != "content-daily" || snapshots[0].Handle() != "snap-content-daily" {
+		t.Errorf("expected daily bound to content-daily, got %s → %v", snapshots[0].Name(), snapshots[0].Content)
+	}
+	if snapshots[2].Snapshot != nil || snapshots[2].Content.GetName() != "content-gone" || snapshots[2].Name() != "gone" {
+		t.Errorf("expected content-gone left behind by snapshot gone, got %+v", snapshots[2])
+	}
+	if policy := snapshots[1].effectivePolicy(SnapshotOptions{DeletionPolicy: SnapshotPolicyDelete}); policy != deletionPolicyDelete {
+		t.Errorf("expected --snapshot-policy delete to override Retain, got %s", policy)
+	}
+
+	snapshots, err = findVolumeSnapshots(context.TODO(), dynamicClient, "shop", KeepFilter{Kinds: []string{"vs"}})
+	if err != nil || len(snapshots) != 0 {
+		t.Errorf("expected kept VolumeSnapshots to be skipped, got %d, %v", len(snapshots), err)
+	}
+}
+
+func TestHandleVolumeSnapshots(t *testing.T) {
+	dynamicClient := newSnapshotDynamicClient(
+		newVolumeSnapshot("shop", "daily", "content-daily"),
+		newVolumeSnapshot("shop", "weekly", "content-weekly"),
+		newVolumeSnapshotContent("content-daily", "shop", "daily", deletionPolicyDelete),
+		newVolumeSnapshotContent("content-weekly", "shop", "weekly", deletionPolicyRetain),
+	)
+	// A stopped snapshot controller never removes its finalizers, so deletes leave the objects in place
+	dynamicClient.PrependReactor("delete", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	ctx := context.TODO()
+
+	snapshots, err := findVolumeSnapshots(ctx, dynamicClient, "shop", KeepFilter{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := handleVolumeSnapshots(ctx, dynamicClient, "shop", snapshots, SnapshotOptions{Timeout: time.Millisecond}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var deletes, patches []string
+	for _, action := range dynamicClient.Actions() {
+		switch a := action.(type) {
+		case clienttesting.DeleteAction:
+			deletes = append(deletes, a.GetResource().Resource+"/"+a.GetName())
+		case clienttesting.PatchAction:
+			if a.GetPatchType() == types.JSONPatchType {
+				patches = append(patches, a.GetResource().Resource+"/"+a.GetName())
+			}
+		}
+	}
+	expectedDeletes := []string{"volumesnapshots/daily", "volumesnapshotcontents/content-daily", "volumesnapshots/weekly"}
+	if len(deletes) != len(expectedDeletes) {
+		t.Fatalf("expected deletes %v, got %v", expectedDeletes, deletes)
+	}
+	for i := range expectedDeletes {
+		if deletes[i] != expectedDeletes[i] {
+			t.Errorf("expected deletes %v, got %v", expectedDeletes, deletes)
+			break
+		}
+	}
+
+	// Both snapshot finalizers of each snapshot go before the Delete-policy content's; the Retain content is left alone
+	expectedPatches := []string{
+		"volumesnapshots/daily", "volumesnapshots/daily", "volumesnapshots/weekly", "volumesnapshots/weekly",
+		"volumesnapshotcontents/content-daily",
+	}
+	if len(patches) != len(expectedPatches) {
+		t.Fatalf("expected finalizer patches %v, got %v", expectedPatches, patches)
+	}
+	for i := range expectedPatches {
+		if patches[i] != expectedPatches[i] {
+			t.Errorf("expected finalizer patches %v, got %v", expectedPatches, patches)
+			break
+		}
+	}
+
+	content, err := dynamicClient.Resource(volumeSnapshotContentsGVR).Get(ctx, "content-daily", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected content-daily to remain in the fake, got %v", err)
+	}
+	if finalizers := content.GetFinalizers(); len(finalizers) != 1 || finalizers[0] != "example.com/keep" {
+		t.Errorf("expected only the snapshot controller's finalizer to be removed, got %v", finalizers)
+	}
+}
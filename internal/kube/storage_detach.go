@@ -0,0 +1,415 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/codesenju/kubectl-nuke-go/pkg/storage"
+)
+
+// storageConsumer is a workload whose pods use one of a provider's volumes
+type storageConsumer struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Replicas  int32
+}
+
+// String returns the consumer as "Kind namespace/name"
+func (c storageConsumer) String() string {
+	return fmt.Sprintf("%s %s/%s", c.Kind, c.Namespace, c.Name)
+}
+
+// providerVolume is one of a storage provider's volumes, with what keeps it attached
+type providerVolume struct {
+	Name   string
+	Status string
+	PV     string
+	// PVC is the claim bound to the volume's PV, as namespace/name
+	PVC string
+	// Nodes the volume is attached to, from Kubernetes VolumeAttachments
+	Nodes []string
+	// Tickets are the provider's own attachment records, as "type on node"
+	Tickets   []string
+	Consumers []storageConsumer
+}
+
+// attached reports whether anything still holds the volume on a node
+func (v providerVolume) attached() bool {
+	return len(v.Nodes) > 0 || len(v.Tickets) > 0
+}
+
+// findProviderResource returns the served resource of the provider named by plural resource name or kind
+func findProviderResource(provider storage.Provider, resources []discoveredResource, name string) (discoveredResource, bool) {
+	for _, res := range resources {
+		if provider.OwnsGroup(res.GVR.Group) && (storage.Resource{Name: name, Kind: name}).Matches(res.APIResource) {
+			return res, true
+		}
+	}
+	return discoveredResource{}, false
+}
+
+// findProviderVolumes returns the provider's volumes in the namespace, the nodes and attachment tickets holding
+// them, and the workloads using them. It returns nothing when the namespace holds none of the provider's volumes.
+func findProviderVolumes(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, provider storage.Provider, resources []discoveredResource) ([]providerVolume, error) {
+	volumesRes, ok := findProviderResource(provider, resources, provider.Detach.Volumes)
+	if !ok {
+		return nil, nil
+	}
+	list, err := dynamicClient.Resource(volumesRes.GVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil || len(list.Items) == 0 {
+		return nil, err
+	}
+
+	volumes := make([]providerVolume, len(list.Items))
+	byName := map[string]*providerVolume{}
+	for i := range list.Items {
+		volumes[i] = providerVolume{Name: list.Items[i].GetName(), Status: describeStorageStatus(&list.Items[i])}
+		byName[volumes[i].Name] = &volumes[i]
+	}
+
+	if attachmentsRes, ok := findProviderResource(provider, resources, provider.Detach.Attachments); ok {
+		attachments, err := dynamicClient.Resource(attachmentsRes.GVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", attachmentsRes.GVR.Resource, err)
+		}
+		for _, attachment := range attachments.Items {
+			if v := byName[attachment.GetName()]; v != nil {
+				v.Tickets = attachmentTickets(attachment)
+			}
+		}
+	}
+
+	attachments, err := clientset.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VolumeAttachments: %w", err)
+	}
+	for _, va := range attachments.Items {
+		if !provider.MatchesProvisioner(va.Spec.Attacher) || va.Spec.Source.PersistentVolumeName == nil {
+			continue
+		}
+		pv, err := clientset.CoreV1().PersistentVolumes().Get(ctx, *va.Spec.Source.PersistentVolumeName, metav1.GetOptions{})
+		if err != nil || pv.Spec.CSI == nil || byName[pv.Spec.CSI.VolumeHandle] == nil {
+			continue
+		}
+		v := byName[pv.Spec.CSI.VolumeHandle]
+		v.PV = pv.Name
+		if va.Status.Attached {
+			v.Nodes = append(v.Nodes, va.Spec.NodeName)
+		}
+		if claim := pv.Spec.ClaimRef; claim != nil && v.PVC == "" {
+			v.PVC = claim.Namespace + "/" + claim.Name
+			if v.Consumers, err = findVolumeConsumers(ctx, clientset, claim.Namespace, claim.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return volumes, nil
+}
+
+// attachmentTickets returns the tickets of a provider attachment record, as "type on node"
+func attachmentTickets(attachment unstructured.Unstructured) []string {
+	tickets, _, _ := unstructured.NestedMap(attachment.Object, "spec", "attachmentTickets")
+	var described []string
+	for _, t := range tickets {
+		ticket, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		described = append(described, fmt.Sprintf("%v on %v", ticket["type"], ticket["nodeID"]))
+	}
+	sort.Strings(described)
+	return described
+}
+
+// findVolumeConsumers returns the workloads whose pods mount the PVC: the Deployment or StatefulSet owning
+// them, any other owner, or the pod itself
+func findVolumeConsumers(ctx context.Context, clientset kubernetes.Interface, namespace, claim string) ([]storageConsumer, error) {
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods in %s: %w", namespace, err)
+	}
+
+	var consumers []storageConsumer
+	seen := map[string]bool{}
+	for _, pod := range pods.Items {
+		if !podMountsClaim(pod, claim) {
+			continue
+		}
+		consumer := podConsumer(ctx, clientset, pod)
+		if !seen[consumer.String()] {
+			seen[consumer.String()] = true
+			consumers = append(consumers, consumer)
+		}
+	}
+	return consumers, nil
+}
+
+// podMountsClaim reports whether a pod mounts the PVC
+func podMountsClaim(pod corev1.Pod, claim string) bool {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == claim {
+			return true
+		}
+	}
+	return false
+}
+
+// podConsumer resolves the workload controlling a pod, following a ReplicaSet up to its Deployment
+func podConsumer(ctx context.Context, clientset kubernetes.Interface, pod corev1.Pod) storageConsumer {
+	owner := metav1.GetControllerOf(&pod)
+	if owner == nil {
+		return storageConsumer{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+	}
+	switch owner.Kind {
+	case "ReplicaSet":
+		rs, err := clientset.AppsV1().ReplicaSets(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			break
+		}
+		if deploymentRef := metav1.GetControllerOf(rs); deploymentRef != nil && deploymentRef.Kind == "Deployment" {
+			if deployment, err := clientset.AppsV1().Deployments(pod.Namespace).Get(ctx, deploymentRef.Name, metav1.GetOptions{}); err == nil {
+				return storageConsumer{Kind: "Deployment", Namespace: pod.Namespace, Name: deployment.Name, Replicas: replicasOf(deployment.Spec.Replicas)}
+			}
+		}
+	case "StatefulSet":
+		if sts, err := clientset.AppsV1().StatefulSets(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{}); err == nil {
+			return storageConsumer{Kind: "StatefulSet", Namespace: pod.Namespace, Name: sts.Name, Replicas: replicasOf(sts.Spec.Replicas)}
+		}
+	}
+	return storageConsumer{Kind: owner.Kind, Namespace: pod.Namespace, Name: owner.Name}
+}
+
+// replicasOf returns a workload's replica count, which defaults to 1
+func replicasOf(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// displayProviderVolumes prints each of the provider's volumes with its state, attachments and consumers
+func displayProviderVolumes(provider storage.Provider, volumes []providerVolume) {
+	fmt.Printf("\n💽 %s VOLUMES:\n", strings.ToUpper(provider.Name))
+	fmt.Printf("=====================\n")
+	for _, v := range volumes {
+		icon := "💤"
+		if v.attached() {
+			icon = "🔗"
+		}
+		fmt.Printf("%s %s: %s\n", icon, v.Name, v.Status)
+		if v.PV != "" {
+			fmt.Printf("   PV %s → PVC %s\n", v.PV, valueOr(v.PVC, "none"))
+		}
+		if len(v.Nodes) > 0 {
+			fmt.Printf("   📍 Attached to: %s\n", strings.Join(v.Nodes, ", "))
+		}
+		if len(v.Tickets) > 0 {
+			fmt.Printf("   🎫 Attachment tickets: %s\n", strings.Join(v.Tickets, ", "))
+		}
+		for _, c := range v.Consumers {
+			fmt.Printf("   📦 Used by %s\n", c)
+		}
+	}
+}
+
+// attachedProviderVolumes returns the volumes still attached
+func attachedProviderVolumes(volumes []providerVolume) []providerVolume {
+	var attached []providerVolume
+	for _, v := range volumes {
+		if v.attached() {
+			attached = append(attached, v)
+		}
+	}
+	return attached
+}
+
+// prepareStorageProvider reports the provider's volumes, detaches them when asked to, and checks the operator's
+// confirmation setting. It returns false when tearing the provider down now would corrupt volumes or be refused.
+func prepareStorageProvider(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, provider storage.Provider, resources []discoveredResource, opts NukeOptions) bool {
+	if provider.Detach != nil {
+		volumes, err := findProviderVolumes(ctx, clientset, dynamicClient, namespace, provider, resources)
+		if err != nil {
+			fmt.Printf("⚠️  Failed to check whether %s volumes are attached: %v\n", provider.Name, err)
+			return false
+		}
+		if len(volumes) > 0 {
+			displayProviderVolumes(provider, volumes)
+		}
+		if attached := attachedProviderVolumes(volumes); len(attached) > 0 {
+			if !opts.DetachVolumes {
+				fmt.Printf("🛑 Skipping %s teardown: %d volume(s) are attached, and deleting them now corrupts their state\n", provider.Name, len(attached))
+				fmt.Printf("💡 Rerun with --detach-volumes to scale down the workloads using them first\n")
+				return false
+			}
+			if !detachProviderVolumes(ctx, clientset, dynamicClient, namespace, provider, resources, attached) {
+				return false
+			}
+		}
+	}
+	return checkStorageConfirmation(ctx, dynamicClient, namespace, provider, resources, opts.DestroyStorageData)
+}
+
+// detachProviderVolumes stops the workloads using the volumes, then waits for the volumes to be detached
+func detachProviderVolumes(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, provider storage.Provider, resources []discoveredResource, attached []providerVolume) bool {
+	fmt.Printf("⏬ Detaching %d %s volume(s) by stopping the workloads using them...\n", len(attached), provider.Name)
+	stopped := map[string]bool{}
+	for _, v := range attached {
+		for _, c := range v.Consumers {
+			if !stopped[c.String()] {
+				stopped[c.String()] = true
+				stopStorageConsumer(ctx, clientset, c)
+			}
+		}
+	}
+
+	timeout := provider.WaitTimeout()
+	fmt.Printf("⏳ Waiting up to %v for the volumes to detach...\n", timeout)
+	var remaining []providerVolume
+	err := wait.PollImmediate(2*time.Second, timeout, func() (bool, error) {
+		volumes, err := findProviderVolumes(ctx, clientset, dynamicClient, namespace, provider, resources)
+		if err != nil {
+			return false, err
+		}
+		remaining = attachedProviderVolumes(volumes)
+		return len(remaining) == 0, nil
+	})
+	if err == nil {
+		fmt.Printf("✅ All %s volumes are detached\n", provider.Name)
+		return true
+	}
+
+	fmt.Printf("🛑 Skipping %s teardown: volumes still attached after %v:\n", provider.Name, timeout)
+	for _, v := range remaining {
+		fmt.Printf("   - %s (%s)\n", v.Name, strings.Join(append(v.Nodes, v.Tickets...), ", "))
+	}
+	return false
+}
+
+// stopStorageConsumer scales a Deployment or StatefulSet to zero, or deletes a bare pod, so it releases its volume
+func stopStorageConsumer(ctx context.Context, clientset kubernetes.Interface, c storageConsumer) {
+	patch := []byte(`{"spec":{"replicas":0}}`)
+	var err error
+	switch c.Kind {
+	case "Deployment":
+		_, err = clientset.AppsV1().Deployments(c.Namespace).Patch(ctx, c.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "StatefulSet":
+		_, err = clientset.AppsV1().StatefulSets(c.Namespace).Patch(ctx, c.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "Pod":
+		if err := clientset.CoreV1().Pods(c.Namespace).Delete(ctx, c.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			fmt.Printf("⚠️  Failed to delete pod %s/%s: %v\n", c.Namespace, c.Name, err)
+		} else {
+			fmt.Printf("🗑️  Deleted pod %s/%s\n", c.Namespace, c.Name)
+		}
+		return
+	default:
+		fmt.Printf("⚠️  Can't scale down %s; stop it by hand to release its volume\n", c)
+		return
+	}
+	if err != nil {
+		fmt.Printf("⚠️  Failed to scale down %s: %v\n", c, err)
+		return
+	}
+	fmt.Printf("⏬ Scaled down %s (restore with: kubectl scale %s/%s -n %s --replicas=%d)\n",
+		c, strings.ToLower(c.Kind), c.Name, c.Namespace, c.Replicas)
+}
+
+// checkStorageConfirmation checks the setting the operator requires before deletion, setting it when asked to.
+// It returns false when the setting is present in the namespace and left unset.
+func checkStorageConfirmation(ctx context.Context, dynamicClient dynamic.Interface, namespace string, provider storage.Provider, resources []discoveredResource, set bool) bool {
+	c := provider.Confirmation
+	if c == nil {
+		return true
+	}
+	res, ok := findProviderResource(provider, resources, c.Resource)
+	if !ok {
+		return true
+	}
+	resourceClient := dynamicClient.Resource(res.GVR).Namespace(namespace)
+	obj, err := resourceClient.Get(ctx, c.Name, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			fmt.Printf("⚠️  Failed to read %s setting %s: %v\n", provider.Name, c.Name, err)
+		}
+		return true
+	}
+
+	path := strings.Split(c.Field, ".")
+	value, found, _ := unstructured.NestedFieldNoCopy(obj.Object, path...)
+	if found && fmt.Sprint(value) == c.Value {
+		fmt.Printf("✅ %s setting %s is %s\n", provider.Name, c.Name, c.Value)
+		return true
+	}
+	if !set {
+		fmt.Printf("🛑 Skipping %s teardown: it refuses deletion until setting %s is %s (now %v)\n", provider.Name, c.Name, c.Value, value)
+		fmt.Printf("💡 Rerun with --destroy-storage-data to set it\n")
+		return false
+	}
+
+	patch := map[string]interface{}{}
+	if err := unstructured.SetNestedField(patch, c.Value, path...); err != nil {
+		fmt.Printf("⚠️  Failed to build the %s patch: %v\n", c.Name, err)
+		return false
+	}
+	data, _ := (&unstructured.Unstructured{Object: patch}).MarshalJSON()
+	if _, err := resourceClient.Patch(ctx, c.Name, types.MergePatchType, data, metav1.PatchOptions{}); err != nil {
+		fmt.Printf("⚠️  Failed to set %s setting %s: %v\n", provider.Name, c.Name, err)
+		return false
+	}
+	fmt.Printf("🔓 Set %s setting %s to %s\n", provider.Name, c.Name, c.Value)
+	return true
+}
+
+// planStorageProviderPreparation prints what prepareStorageProvider would do
+func planStorageProviderPreparation(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, provider storage.Provider, resources []discoveredResource, opts NukeOptions) {
+	if provider.Detach != nil {
+		volumes, err := findProviderVolumes(ctx, clientset, dynamicClient, namespace, provider, resources)
+		if err != nil {
+			fmt.Printf("⚠️  Failed to check whether %s volumes are attached: %v\n", provider.Name, err)
+		}
+		if len(volumes) > 0 {
+			displayProviderVolumes(provider, volumes)
+		}
+		if attached := attachedProviderVolumes(volumes); len(attached) > 0 {
+			if !opts.DetachVolumes {
+				fmt.Printf("🛑 WOULD SKIP the teardown and stop before the pod wipe: %d volume(s) are attached (use --detach-volumes)\n", len(attached))
+				return
+			}
+			for _, v := range attached {
+				for _, c := range v.Consumers {
+					fmt.Printf("⏬ WOULD STOP %s to detach %s\n", c, v.Name)
+				}
+			}
+			fmt.Printf("⏳ WOULD WAIT up to %v for the volumes to detach, and skip the teardown if they don't\n", provider.WaitTimeout())
+		}
+	}
+
+	if c := provider.Confirmation; c != nil {
+		if res, ok := findProviderResource(provider, resources, c.Resource); ok {
+			obj, err := dynamicClient.Resource(res.GVR).Namespace(namespace).Get(ctx, c.Name, metav1.GetOptions{})
+			if err == nil {
+				value, found, _ := unstructured.NestedFieldNoCopy(obj.Object, strings.Split(c.Field, ".")...)
+				switch {
+				case found && fmt.Sprint(value) == c.Value:
+					fmt.Printf("✅ Setting %s is %s\n", c.Name, c.Value)
+				case opts.DestroyStorageData:
+					fmt.Printf("🔓 WOULD SET setting %s to %s (now %v)\n", c.Name, c.Value, value)
+				default:
+					fmt.Printf("🛑 WOULD SKIP the teardown and stop before the pod wipe: setting %s is %v, not %s (use --destroy-storage-data)\n", c.Name, value, c.Value)
+				}
+			}
+		}
+	}
+}
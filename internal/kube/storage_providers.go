@@ -6,21 +6,26 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/codesenju/kubectl-nuke-go/pkg/storage"
 )
 
-// HandleStorageProviderResources handles specific storage provider resources
-func HandleStorageProviderResources(ctx context.Context, clientset kubernetes.Interface, namespace string, config *rest.Config) error {
+// HandleStorageProviderResources tears down the custom resources of the registry's storage providers in a namespace
+func HandleStorageProviderResources(ctx context.Context, clientset kubernetes.Interface, namespace string, config *rest.Config, opts NukeOptions) error {
 	fmt.Printf("🔍 Checking for storage provider resources in namespace %s...\n", namespace)
 
 	// Create discovery client
@@ -35,497 +40,216 @@ func HandleStorageProviderResources(ctx context.Context, clientset kubernetes.In
 		return fmt.Errorf("failed to create dynamic client: %w", err)
 	}
 
-	// Check for Longhorn resources
-	if err := handleLonghornResources(ctx, discoveryClient, dynamicClient, namespace); err != nil {
-		fmt.Printf("⚠️  Error handling Longhorn resources: %v\n", err)
-	}
-
-	// Check for Rook-Ceph resources
-	if err := handleRookCephResources(ctx, discoveryClient, dynamicClient, namespace); err != nil {
-		fmt.Printf("⚠️  Error handling Rook-Ceph resources: %v\n", err)
+	skipped, err := handleStorageProviders(ctx, clientset, discoveryClient, dynamicClient, namespace, opts)
+	if err == nil && len(skipped) > 0 {
+		err = fmt.Errorf("%s teardown skipped", strings.Join(skipped, ", "))
 	}
-
-	// Check for OpenEBS resources
-	if err := handleOpenEBSResources(ctx, discoveryClient, dynamicClient, namespace); err != nil {
-		fmt.Printf("⚠️  Error handling OpenEBS resources: %v\n", err)
-	}
-
-	return nil
+	return err
 }
 
-// handleLonghornResources specifically handles Longhorn resources
-func handleLonghornResources(ctx context.Context, discoveryClient discovery.DiscoveryInterface, dynamicClient dynamic.Interface, namespace string) error {
-	// Define Longhorn resource types to check
-	longhornResources := []struct {
-		group    string
-		version  string
-		resource string
-	}{
-		{"longhorn.io", "v1beta2", "volumes"},
-		{"longhorn.io", "v1beta2", "replicas"},
-		{"longhorn.io", "v1beta2", "engines"},
-		{"longhorn.io", "v1beta2", "instancemanagers"},
-		{"longhorn.io", "v1beta2", "nodes"},
-		{"longhorn.io", "v1beta2", "volumeattachments"},
-		{"longhorn.io", "v1beta2", "snapshots"},
-		{"longhorn.io", "v1beta1", "volumes"},
-		{"longhorn.io", "v1beta1", "replicas"},
-		{"longhorn.io", "v1beta1", "engines"},
-		{"longhorn.io", "v1beta1", "instancemanagers"},
-		{"longhorn.io", "v1beta1", "nodes"},
-		{"longhorn.io", "v1beta1", "volumeattachments"},
-		{"longhorn.io", "v1beta1", "snapshots"},
+// handleStorageProviders tears down each provider whose resources the cluster serves, in registry order.
+// Providers with an operator get the ordered teardown; the others are stripped and force deleted. A provider
+// with attached volumes or an unconfirmed deletion setting is skipped, and its name returned.
+func handleStorageProviders(ctx context.Context, clientset kubernetes.Interface, discoveryClient discovery.DiscoveryInterface, dynamicClient dynamic.Interface, namespace string, opts NukeOptions) ([]string, error) {
+	resources, err := discoverResources(discoveryClient, false, "list", "delete")
+	if len(resources) == 0 {
+		return nil, err
 	}
 
-	longhornFound := false
-	resourcesProcessed := 0
-
-	// Check each Longhorn resource type
-	for _, res := range longhornResources {
-		gvr := schema.GroupVersionResource{
-			Group:    res.group,
-			Version:  res.version,
-			Resource: res.resource,
+	var skipped []string
+	for _, provider := range opts.storageProviders().Providers {
+		steps := collectStorageTeardown(ctx, clientset, dynamicClient, namespace, provider, storageProviderResources(provider, resources), opts)
+		if len(steps) == 0 {
+			continue
 		}
-
-		// Try to list resources of this type
-		list, err := dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
-		if err != nil {
-			// Skip if resource not found or other error
+		if !prepareStorageProvider(ctx, clientset, dynamicClient, namespace, provider, resources, opts) {
+			skipped = append(skipped, provider.Name)
 			continue
 		}
-
-		if len(list.Items) > 0 {
-			longhornFound = true
-			fmt.Printf("🔍 Found %d Longhorn %s resources\n", len(list.Items), res.resource)
-
-			// Process each resource
-			for _, item := range list.Items {
-				resourcesProcessed++
-				fmt.Printf("🔧 Processing Longhorn %s: %s\n", res.resource, item.GetName())
-
-				// First, try to remove finalizers
-				if finalizers := item.GetFinalizers(); len(finalizers) > 0 {
-					fmt.Printf("🔧 Removing finalizers from %s: %s\n", res.resource, item.GetName())
-					
-					// Try patch method first (most reliable)
-					patchData := map[string]interface{}{
-						"metadata": map[string]interface{}{
-							"finalizers": nil,
-						},
-					}
-					patchBytes, _ := json.Marshal(patchData)
-					
-					_, err := dynamicClient.Resource(gvr).Namespace(namespace).Patch(
-						ctx,
-						item.GetName(),
-						types.MergePatchType,
-						patchBytes,
-						metav1.PatchOptions{},
-					)
-					
-					if err != nil {
-						fmt.Printf("⚠️  Failed to patch finalizers for %s: %v\n", item.GetName(), err)
-						
-						// Try update method as fallback
-						item.SetFinalizers([]string{})
-						_, err = dynamicClient.Resource(gvr).Namespace(namespace).Update(
-							ctx,
-							&item,
-							metav1.UpdateOptions{},
-						)
-						
-						if err != nil {
-							fmt.Printf("⚠️  Failed to update finalizers for %s: %v\n", item.GetName(), err)
-						}
-					}
-				}
-
-				// Then force delete the resource
-				gracePeriod := int64(0)
-				err = dynamicClient.Resource(gvr).Namespace(namespace).Delete(
-					ctx,
-					item.GetName(),
-					metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod},
-				)
-				
-				if err != nil {
-					fmt.Printf("⚠️  Failed to delete %s %s: %v\n", res.resource, item.GetName(), err)
-				} else {
-					fmt.Printf("✅ Successfully deleted %s: %s\n", res.resource, item.GetName())
-				}
-			}
+		if provider.Operator != nil {
+			err = tearDownWithOperator(ctx, dynamicClient, namespace, provider, steps, opts.DestroyStorageData)
+		} else {
+			err = tearDownStorageProvider(ctx, dynamicClient, namespace, provider, steps)
+		}
+		if err != nil {
+			fmt.Printf("⚠️  Error handling %s resources: %v\n", provider.Name, err)
 		}
 	}
-
-	if longhornFound {
-		fmt.Printf("📊 Processed %d Longhorn resources\n", resourcesProcessed)
-		fmt.Printf("💡 Tip: Longhorn resources often have finalizers that prevent deletion\n")
-		fmt.Printf("⏳ Waiting for Longhorn resources to be processed...\n")
-		time.Sleep(5 * time.Second)
-	}
-
-	return nil
+	return skipped, nil
 }
 
-// handleRookCephResources specifically handles Rook-Ceph resources
-func handleRookCephResources(ctx context.Context, discoveryClient discovery.DiscoveryInterface, dynamicClient dynamic.Interface, namespace string) error {
-	// Define Rook-Ceph resource types to check
-	rookResources := []struct {
-		group    string
-		version  string
-		resource string
-	}{
-		{"ceph.rook.io", "v1", "cephclusters"},
-		{"ceph.rook.io", "v1", "cephblockpools"},
-		{"ceph.rook.io", "v1", "cephfilesystems"},
-		{"ceph.rook.io", "v1", "cephobjectstores"},
-		{"ceph.rook.io", "v1", "cephobjectstoreusers"},
-	}
-
-	rookFound := false
-	resourcesProcessed := 0
-
-	// Check each Rook-Ceph resource type
-	for _, res := range rookResources {
-		gvr := schema.GroupVersionResource{
-			Group:    res.group,
-			Version:  res.version,
-			Resource: res.resource,
-		}
-
-		// Try to list resources of this type
-		list, err := dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
-		if err != nil {
-			// Skip if resource not found or other error
+// storageProviderResources returns the served resource types a provider tears down, in teardown order.
+// Discovery yields the preferred version of each, so only versions the cluster actually serves are used.
+// Cluster-scoped resources are only torn down when the provider lists them.
+func storageProviderResources(provider storage.Provider, resources []discoveredResource) []discoveredResource {
+	var served []discoveredResource
+	for _, res := range resources {
+		if !res.APIResource.Namespaced && provider.Resource(res.GVR.Group, res.APIResource) == nil {
 			continue
 		}
-
-		if len(list.Items) > 0 {
-			rookFound = true
-			fmt.Printf("🔍 Found %d Rook-Ceph %s resources\n", len(list.Items), res.resource)
-
-			// Process each resource
-			for _, item := range list.Items {
-				resourcesProcessed++
-				fmt.Printf("🔧 Processing Rook-Ceph %s: %s\n", res.resource, item.GetName())
-
-				// Remove finalizers
-				if finalizers := item.GetFinalizers(); len(finalizers) > 0 {
-					fmt.Printf("🔧 Removing finalizers from %s: %s\n", res.resource, item.GetName())
-					
-					// Try patch method
-					patchData := map[string]interface{}{
-						"metadata": map[string]interface{}{
-							"finalizers": nil,
-						},
-					}
-					patchBytes, _ := json.Marshal(patchData)
-					
-					_, err := dynamicClient.Resource(gvr).Namespace(namespace).Patch(
-						ctx,
-						item.GetName(),
-						types.MergePatchType,
-						patchBytes,
-						metav1.PatchOptions{},
-					)
-					
-					if err != nil {
-						fmt.Printf("⚠️  Failed to patch finalizers for %s: %v\n", item.GetName(), err)
-						
-						// Try update method as fallback
-						item.SetFinalizers([]string{})
-						_, err = dynamicClient.Resource(gvr).Namespace(namespace).Update(
-							ctx,
-							&item,
-							metav1.UpdateOptions{},
-						)
-						
-						if err != nil {
-							fmt.Printf("⚠️  Failed to update finalizers for %s: %v\n", item.GetName(), err)
-						}
-					}
-				}
-
-				// Force delete the resource
-				gracePeriod := int64(0)
-				err = dynamicClient.Resource(gvr).Namespace(namespace).Delete(
-					ctx,
-					item.GetName(),
-					metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod},
-				)
-				
-				if err != nil {
-					fmt.Printf("⚠️  Failed to delete %s %s: %v\n", res.resource, item.GetName(), err)
-				} else {
-					fmt.Printf("✅ Successfully deleted %s: %s\n", res.resource, item.GetName())
-				}
-			}
+		if provider.Order(res.GVR.Group, res.APIResource) >= 0 {
+			served = append(served, res)
 		}
 	}
-
-	if rookFound {
-		fmt.Printf("📊 Processed %d Rook-Ceph resources\n", resourcesProcessed)
-		fmt.Printf("⏳ Waiting for Rook-Ceph resources to be processed...\n")
-		time.Sleep(3 * time.Second)
-	}
-
-	return nil
+	sort.SliceStable(served, func(i, j int) bool {
+		return provider.Order(served[i].GVR.Group, served[i].APIResource) < provider.Order(served[j].GVR.Group, served[j].APIResource)
+	})
+	return served
 }
 
-// handleOpenEBSResources specifically handles OpenEBS resources
-func handleOpenEBSResources(ctx context.Context, discoveryClient discovery.DiscoveryInterface, dynamicClient dynamic.Interface, namespace string) error {
-	// Define OpenEBS resource types to check
-	openebsResources := []struct {
-		group    string
-		version  string
-		resource string
-	}{
-		{"openebs.io", "v1alpha1", "blockdevices"},
-		{"openebs.io", "v1alpha1", "blockdeviceclaims"},
-		{"openebs.io", "v1alpha1", "cstorvolumes"},
-		{"openebs.io", "v1alpha1", "cstorvolumeclaims"},
-		{"openebs.io", "v1alpha1", "cstorvolumereplicas"},
-	}
-
-	openebsFound := false
-	resourcesProcessed := 0
-
-	// Check each OpenEBS resource type
-	for _, res := range openebsResources {
-		gvr := schema.GroupVersionResource{
-			Group:    res.group,
-			Version:  res.version,
-			Resource: res.resource,
-		}
-
-		// Try to list resources of this type
-		list, err := dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
-		if err != nil {
-			// Skip if resource not found or other error
-			continue
-		}
-
-		if len(list.Items) > 0 {
-			openebsFound = true
-			fmt.Printf("🔍 Found %d OpenEBS %s resources\n", len(list.Items), res.resource)
-
-			// Process each resource
-			for _, item := range list.Items {
-				resourcesProcessed++
-				fmt.Printf("🔧 Processing OpenEBS %s: %s\n", res.resource, item.GetName())
+// storageObject is a provider object deleted during teardown
+type storageObject struct {
+	Client dynamic.ResourceInterface
+	GVR    schema.GroupVersionResource
+	Name   string
+}
 
-				// Remove finalizers
-				if finalizers := item.GetFinalizers(); len(finalizers) > 0 {
-					fmt.Printf("🔧 Removing finalizers from %s: %s\n", res.resource, item.GetName())
-					
-					// Try patch method
-					patchData := map[string]interface{}{
-						"metadata": map[string]interface{}{
-							"finalizers": nil,
-						},
-					}
-					patchBytes, _ := json.Marshal(patchData)
-					
-					_, err := dynamicClient.Resource(gvr).Namespace(namespace).Patch(
-						ctx,
-						item.GetName(),
-						types.MergePatchType,
-						patchBytes,
-						metav1.PatchOptions{},
-					)
-					
-					if err != nil {
-						fmt.Printf("⚠️  Failed to patch finalizers for %s: %v\n", item.GetName(), err)
-						
-						// Try update method as fallback
-						item.SetFinalizers([]string{})
-						_, err = dynamicClient.Resource(gvr).Namespace(namespace).Update(
-							ctx,
-							&item,
-							metav1.UpdateOptions{},
-						)
-						
-						if err != nil {
-							fmt.Printf("⚠️  Failed to update finalizers for %s: %v\n", item.GetName(), err)
-						}
-					}
+// tearDownStorageProvider strips finalizers from and force deletes a provider's objects resource by resource,
+// then waits for the provider's post-teardown condition
+func tearDownStorageProvider(ctx context.Context, dynamicClient dynamic.Interface, namespace string, provider storage.Provider, steps []storageTeardownStep) error {
+	var deleted []storageObject
+	for _, step := range steps {
+		res := step.Resource
+		resourceClient := step.client(dynamicClient, namespace)
+		fmt.Printf("🔍 Found %d %s %s resources (%s)\n", len(step.Objects), provider.Name, res.GVR.Resource, res.GVR.GroupVersion())
+
+		for _, item := range step.Objects {
+			fmt.Printf("🔧 Processing %s %s: %s\n", provider.Name, res.GVR.Resource, item.GetName())
+
+			if finalizers := item.GetFinalizers(); len(finalizers) > 0 {
+				fmt.Printf("🔧 Removing finalizers from %s: %s\n", res.GVR.Resource, item.GetName())
+				if err := stripAllFinalizers(ctx, resourceClient, item.GetName()); err != nil {
+					fmt.Printf("⚠️  Failed to remove finalizers from %s: %v\n", item.GetName(), err)
 				}
+			}
 
-				// Force delete the resource
-				gracePeriod := int64(0)
-				err = dynamicClient.Resource(gvr).Namespace(namespace).Delete(
-					ctx,
-					item.GetName(),
-					metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod},
-				)
-				
-				if err != nil {
-					fmt.Printf("⚠️  Failed to delete %s %s: %v\n", res.resource, item.GetName(), err)
-				} else {
-					fmt.Printf("✅ Successfully deleted %s: %s\n", res.resource, item.GetName())
-				}
+			gracePeriod := int64(0)
+			err := resourceClient.Delete(ctx, item.GetName(), metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod})
+			if err != nil && !errors.IsNotFound(err) {
+				fmt.Printf("⚠️  Failed to delete %s %s: %v\n", res.GVR.Resource, item.GetName(), err)
+				continue
 			}
+			fmt.Printf("✅ Successfully deleted %s: %s\n", res.GVR.Resource, item.GetName())
+			deleted = append(deleted, storageObject{Client: resourceClient, GVR: res.GVR, Name: item.GetName()})
 		}
 	}
 
-	if openebsFound {
-		fmt.Printf("📊 Processed %d OpenEBS resources\n", resourcesProcessed)
-		fmt.Printf("⏳ Waiting for OpenEBS resources to be processed...\n")
-		time.Sleep(3 * time.Second)
+	if len(deleted) == 0 {
+		return nil
 	}
-
-	return nil
+	fmt.Printf("📊 Processed %d %s resources\n", len(deleted), provider.Name)
+	return waitForStorageProvider(ctx, provider, deleted)
 }
 
-// DetectStorageProviderResources detects common storage provider issues
-func DetectStorageProviderResources(ctx context.Context, clientset kubernetes.Interface) error {
-	// Check for storage classes that might be causing issues
-	storageClasses, err := clientset.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to list storage classes: %w", err)
+// waitForStorageProvider waits for the provider's post-teardown condition
+func waitForStorageProvider(ctx context.Context, provider storage.Provider, deleted []storageObject) error {
+	if provider.WaitCondition() == storage.WaitForNone {
+		return nil
 	}
 
-	// Check for known problematic storage providers
-	for _, sc := range storageClasses.Items {
-		provisioner := sc.Provisioner
-		
-		if strings.Contains(provisioner, "longhorn.io") {
-			fmt.Printf("🔍 Detected Longhorn storage class: %s\n", sc.Name)
-			fmt.Printf("💡 Tip: Longhorn resources often have finalizers that prevent deletion\n")
-		} else if strings.Contains(provisioner, "rook.io") || strings.Contains(provisioner, "ceph.rook.io") {
-			fmt.Printf("🔍 Detected Rook-Ceph storage class: %s\n", sc.Name)
-			fmt.Printf("💡 Tip: Rook-Ceph resources often have finalizers that prevent deletion\n")
-		} else if strings.Contains(provisioner, "openebs.io") {
-			fmt.Printf("🔍 Detected OpenEBS storage class: %s\n", sc.Name)
-			fmt.Printf("💡 Tip: OpenEBS resources often have finalizers that prevent deletion\n")
+	timeout := provider.WaitTimeout()
+	fmt.Printf("⏳ Waiting up to %v for %s resources to be removed...\n", timeout, provider.Name)
+	remaining := deleted
+	err := wait.PollImmediate(2*time.Second, timeout, func() (bool, error) {
+		var still []storageObject
+		for _, obj := range remaining {
+			_, err := obj.Client.Get(ctx, obj.Name, metav1.GetOptions{})
+			if err == nil {
+				still = append(still, obj)
+			} else if !errors.IsNotFound(err) {
+				return false, err
+			}
 		}
+		remaining = still
+		return len(remaining) == 0, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("%d %s resource(s) still present after %v", len(remaining), provider.Name, timeout)
 	}
-
-	return nil
+	return err
 }
 
-// RemoveAllCustomResourceFinalizers aggressively removes finalizers from all custom resources in a namespace
-func RemoveAllCustomResourceFinalizers(ctx context.Context, config *rest.Config, namespace string) error {
-	fmt.Printf("💥 Aggressively removing finalizers from all custom resources in namespace %s...\n", namespace)
-
-	// Create discovery client
-	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+// DetectStorageProviders reports the storage driver behind each PVC in the namespace, found from its bound PV,
+// provisioner annotation or StorageClass, and where each driver's pods run and whether they are healthy
+func DetectStorageProviders(ctx context.Context, clientset kubernetes.Interface, namespace string, registry *storage.Registry) (*storage.Report, error) {
+	report, err := storage.NewDetector(clientset, registry).DetectForNamespace(ctx, namespace)
 	if err != nil {
-		return fmt.Errorf("failed to create discovery client: %w", err)
+		return nil, err
 	}
+	displayStorageReport(report)
+	return report, nil
+}
 
-	// Create dynamic client
-	dynamicClient, err := dynamic.NewForConfig(config)
-	if err != nil {
-		return fmt.Errorf("failed to create dynamic client: %w", err)
+// displayStorageReport prints the volumes in the namespace, the drivers backing them, and known providers
+// installed in the cluster
+func displayStorageReport(report *storage.Report) {
+	fmt.Printf("\n💾 STORAGE PROVIDERS:\n")
+	fmt.Printf("====================\n")
+	if len(report.Volumes) == 0 {
+		fmt.Printf("ℹ️  No PVCs in namespace %s\n", report.Namespace)
 	}
-
-	// Get all API resources
-	apiResourceLists, err := discoveryClient.ServerPreferredNamespacedResources()
-	if err != nil {
-		return fmt.Errorf("failed to discover API resources: %w", err)
+	for _, v := range report.Volumes {
+		backing := "unknown driver"
+		if v.Driver != "" {
+			backing = fmt.Sprintf("%s (from %s)", v.Driver, v.Source)
+			if v.Provider != "" {
+				backing = fmt.Sprintf("%s via %s", v.Provider, backing)
+			}
+		}
+		fmt.Printf("📦 PVC %s [%s] → %s\n", v.PVC, v.Phase, backing)
+		if v.Volume != "" {
+			fmt.Printf("   Volume: %s, StorageClass: %s\n", v.Volume, v.StorageClass)
+		}
 	}
 
-	resourcesProcessed := 0
-	finalizersRemoved := 0
+	for _, d := range report.DriversInUse() {
+		displayStorageDriver(d)
+	}
 
-	// Process all resource types
-	for _, apiResourceList := range apiResourceLists {
-		gv, err := schema.ParseGroupVersion(apiResourceList.GroupVersion)
-		if err != nil {
-			continue
+	inUse := map[string]bool{}
+	for _, d := range report.DriversInUse() {
+		inUse[d.Name] = true
+	}
+	for _, d := range report.Drivers {
+		if !inUse[d.Name] && d.Provider != "" {
+			fmt.Printf("🔍 Detected %s in the cluster (not backing this namespace)\n", d.DisplayName())
 		}
+	}
+}
 
-		for _, apiResource := range apiResourceList.APIResources {
-			// Skip subresources and non-namespaced resources
-			if strings.Contains(apiResource.Name, "/") || !apiResource.Namespaced {
-				continue
-			}
-
-			// Check if resource supports get and update operations
-			canGet := false
-			canUpdate := false
-			for _, verb := range apiResource.Verbs {
-				if verb == "get" {
-					canGet = true
-				}
-				if verb == "update" || verb == "patch" {
-					canUpdate = true
-				}
-			}
-			if !canGet || !canUpdate {
-				continue
-			}
-
-			// Create GVR
-			gvr := schema.GroupVersionResource{
-				Group:    gv.Group,
-				Version:  gv.Version,
-				Resource: apiResource.Name,
-			}
-
-			// List resources of this type
-			list, err := dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
-			if err != nil {
-				continue
-			}
+// displayStorageDriver prints a driver's pods and health
+func displayStorageDriver(d storage.Driver) {
+	fmt.Printf("\n🔌 Driver %s", d.DisplayName())
+	if d.CSIDriver {
+		fmt.Printf(" [CSIDriver]")
+	}
+	fmt.Printf("\n")
+	if len(d.Pods) == 0 {
+		fmt.Printf("   ⚠️  No pods found running this driver; its volumes can't be detached or deleted, so their finalizers will hang\n")
+		return
+	}
 
-			// Process each resource
-			for _, item := range list.Items {
-				resourcesProcessed++
-				
-				// Check for finalizers
-				finalizers := item.GetFinalizers()
-				if len(finalizers) > 0 {
-					fmt.Printf("🔧 Removing finalizers from %s/%s: %v\n", apiResource.Name, item.GetName(), finalizers)
-					
-					// Try patch method first
-					patchData := map[string]interface{}{
-						"metadata": map[string]interface{}{
-							"finalizers": nil,
-						},
-					}
-					patchBytes, _ := json.Marshal(patchData)
-					
-					_, err := dynamicClient.Resource(gvr).Namespace(namespace).Patch(
-						ctx,
-						item.GetName(),
-						types.MergePatchType,
-						patchBytes,
-						metav1.PatchOptions{},
-					)
-					
-					if err != nil {
-						// Try update method as fallback
-						item.SetFinalizers([]string{})
-						_, err = dynamicClient.Resource(gvr).Namespace(namespace).Update(
-							ctx,
-							&item,
-							metav1.UpdateOptions{},
-						)
-						
-						if err != nil {
-							fmt.Printf("⚠️  Failed to remove finalizers from %s/%s: %v\n", apiResource.Name, item.GetName(), err)
-						} else {
-							finalizersRemoved++
-						}
-					} else {
-						finalizersRemoved++
-					}
-				}
-			}
+	for _, pod := range d.Pods {
+		status := "✅"
+		if !pod.Ready {
+			status = "❌"
 		}
+		fmt.Printf("   %s %s/%s on %s (%s, %d restarts)\n", status, pod.Namespace, pod.Name, pod.Node, pod.Phase, pod.Restarts)
+	}
+	if d.Healthy() {
+		fmt.Printf("   💓 Healthy\n")
+	} else {
+		fmt.Printf("   ⚠️  Unhealthy: volume cleanup may hang until its pods are ready\n")
+	}
+	for _, ns := range d.TerminatingNamespaces {
+		fmt.Printf("   ⚠️  Namespace %s running the driver is Terminating, which may cause PVC deletion issues\n", ns)
+		fmt.Printf("   💡 Tip: You can disable storage provider webhooks with --bypass-webhooks flag\n")
 	}
-
-	fmt.Printf("📊 Processed %d resources, removed finalizers from %d resources\n", resourcesProcessed, finalizersRemoved)
-	return nil
 }
 
-// ForceDeleteAllCustomResources aggressively deletes all custom resources in a namespace
-func ForceDeleteAllCustomResources(ctx context.Context, config *rest.Config, namespace string) error {
-	fmt.Printf("💥 Aggressively deleting all custom resources in namespace %s...\n", namespace)
-
+// RemoveAllCustomResourceFinalizers aggressively removes finalizers from all custom resources in a namespace,
+// falling back to raw API calls when direct is set
+func RemoveAllCustomResourceFinalizers(ctx context.Context, config *rest.Config, namespace string, keep KeepFilter, direct *DirectAPI) error {
 	// Create discovery client
 	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
 	if err != nil {
@@ -538,124 +262,92 @@ func ForceDeleteAllCustomResources(ctx context.Context, config *rest.Config, nam
 		return fmt.Errorf("failed to create dynamic client: %w", err)
 	}
 
-	// Get all API resources
-	apiResourceLists, err := discoveryClient.ServerPreferredNamespacedResources()
+	return removeAllCustomResourceFinalizers(ctx, discoveryClient, dynamicClient, namespace, keep, direct)
+}
+
+// removeAllCustomResourceFinalizers strips the finalizers of every object in the namespace the keep filter doesn't keep
+// or the kind filter leaves out, across the namespaced resource types discovery finds can be listed and patched
+func removeAllCustomResourceFinalizers(ctx context.Context, discoveryClient discovery.DiscoveryInterface, dynamicClient dynamic.Interface, namespace string, keep KeepFilter, direct *DirectAPI) error {
+	fmt.Printf("💥 Aggressively removing finalizers from all custom resources in namespace %s...\n", namespace)
+
+	resources, err := discoverResources(discoveryClient, true, "list", "patch")
 	if err != nil {
-		return fmt.Errorf("failed to discover API resources: %w", err)
+		if len(resources) == 0 {
+			return err
+		}
+		// Continue with partial results if some APIs are unavailable
+		fmt.Printf("⚠️  Warning: Some API resources may not be accessible: %v\n", err)
 	}
 
 	resourcesProcessed := 0
-	resourcesDeleted := 0
+	finalizersRemoved := 0
 
 	// Process all resource types
-	for _, apiResourceList := range apiResourceLists {
-		// Skip core Kubernetes APIs
-		if strings.Contains(apiResourceList.GroupVersion, "/v1") && 
-		   !strings.Contains(apiResourceList.GroupVersion, ".") {
+	for _, res := range resources {
+		if keep.keepsKind(res) {
 			continue
 		}
 
-		gv, err := schema.ParseGroupVersion(apiResourceList.GroupVersion)
+		// List resources of this type
+		list, err := dynamicClient.Resource(res.GVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
 		if err != nil {
 			continue
 		}
 
-		for _, apiResource := range apiResourceList.APIResources {
-			// Skip subresources and non-namespaced resources
-			if strings.Contains(apiResource.Name, "/") || !apiResource.Namespaced {
+		// Process each resource
+		for _, item := range list.Items {
+			if keep.keeps(res, item.GetLabels()) {
 				continue
 			}
-
-			// Check if resource supports delete operation
-			canDelete := false
-			for _, verb := range apiResource.Verbs {
-				if verb == "delete" {
-					canDelete = true
-					break
+			resourcesProcessed++
+			
+			// Check for finalizers
+			finalizers := item.GetFinalizers()
+			if len(finalizers) > 0 {
+				fmt.Printf("🔧 Removing finalizers from %s/%s: %v\n", res.displayName(), item.GetName(), finalizers)
+				
+				// Try patch method first
+				patchData := map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"finalizers": nil,
+					},
 				}
-			}
-			if !canDelete {
-				continue
-			}
-
-			// Create GVR
-			gvr := schema.GroupVersionResource{
-				Group:    gv.Group,
-				Version:  gv.Version,
-				Resource: apiResource.Name,
-			}
-
-			// List resources of this type
-			list, err := dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
-			if err != nil {
-				continue
-			}
-
-			if len(list.Items) > 0 {
-				fmt.Printf("🔍 Found %d %s resources\n", len(list.Items), apiResource.Name)
+				patchBytes, _ := json.Marshal(patchData)
 				
-				// Process each resource
-				for _, item := range list.Items {
-					resourcesProcessed++
-					fmt.Printf("🔧 Processing %s: %s\n", apiResource.Name, item.GetName())
-					
-					// First remove finalizers
-					if finalizers := item.GetFinalizers(); len(finalizers) > 0 {
-						fmt.Printf("🔧 Removing finalizers from %s: %s\n", apiResource.Name, item.GetName())
-						
-						// Try patch method
-						patchData := map[string]interface{}{
-							"metadata": map[string]interface{}{
-								"finalizers": nil,
-							},
-						}
-						patchBytes, _ := json.Marshal(patchData)
-						
-						_, err := dynamicClient.Resource(gvr).Namespace(namespace).Patch(
-							ctx,
-							item.GetName(),
-							types.MergePatchType,
-							patchBytes,
-							metav1.PatchOptions{},
-						)
-						
-						if err != nil {
-							fmt.Printf("⚠️  Failed to patch finalizers for %s: %v\n", item.GetName(), err)
-							
-							// Try update method as fallback
-							item.SetFinalizers([]string{})
-							_, err = dynamicClient.Resource(gvr).Namespace(namespace).Update(
-								ctx,
-								&item,
-								metav1.UpdateOptions{},
-							)
-							
-							if err != nil {
-								fmt.Printf("⚠️  Failed to update finalizers for %s: %v\n", item.GetName(), err)
-							}
-						}
-					}
-					
-					// Then force delete
-					gracePeriod := int64(0)
-					err = dynamicClient.Resource(gvr).Namespace(namespace).Delete(
+				_, err := dynamicClient.Resource(res.GVR).Namespace(namespace).Patch(
+					ctx,
+					item.GetName(),
+					types.MergePatchType,
+					patchBytes,
+					metav1.PatchOptions{},
+				)
+				
+				if err != nil {
+					// Try update method as fallback
+					item.SetFinalizers([]string{})
+					_, err = dynamicClient.Resource(res.GVR).Namespace(namespace).Update(
 						ctx,
-						item.GetName(),
-						metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod},
+						&item,
+						metav1.UpdateOptions{},
 					)
 					
+					if err != nil && direct != nil {
+						_, err = direct.RemoveFinalizers(ctx, res.GVR, namespace, item.GetName())
+					}
+
 					if err != nil {
-						fmt.Printf("⚠️  Failed to delete %s %s: %v\n", apiResource.Name, item.GetName(), err)
+						fmt.Printf("⚠️  Failed to remove finalizers from %s/%s: %v\n", res.displayName(), item.GetName(), err)
 					} else {
-						resourcesDeleted++
-						fmt.Printf("✅ Successfully deleted %s: %s\n", apiResource.Name, item.GetName())
+						finalizersRemoved++
 					}
+				} else {
+					finalizersRemoved++
 				}
 			}
 		}
 	}
 
-	fmt.Printf("📊 Processed %d resources, deleted %d resources\n", resourcesProcessed, resourcesDeleted)
+	fmt.Printf("📊 Processed %d resources, removed finalizers from %d resources\n", resourcesProcessed, finalizersRemoved)
 	return nil
 }
 
@@ -0,0 +1,641 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	discoveryfake "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/codesenju/kubectl-nuke-go/pkg/storage"
+)
+
+func newLonghornObject(kind, name string, finalizers ...string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("longhorn.io/v1beta2")
+	u.SetKind(kind)
+	u.SetNamespace("shop")
+	u.SetName(name)
+	u.SetFinalizers(finalizers)
+	return u
+}
+
+func TestHandleStorageProviders(t *testing.T) {
+	volumesGVR := schema.GroupVersionResource{Group: "longhorn.io", Version: "v1beta2", Resource: "volumes"}
+	replicasGVR := schema.GroupVersionResource{Group: "longhorn.io", Version: "v1beta2", Resource: "replicas"}
+
+	kept := newWidget("shop", "kept", "foo.example.com/cleanup")
+	kept.SetLabels(map[string]string{"keep": "true"})
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{volumesGVR: "VolumeList", replicasGVR: "ReplicaList", widgetGVR: "WidgetList", podsGVR: "PodList"},
+		newLonghornObject("Volume", "data", "longhorn.io"),
+		newLonghornObject("Replica", "data-r-1", "longhorn.io"),
+		newWidget("shop", "gadget", "foo.example.com/cleanup"),
+		kept,
+	)
+	// Only v1beta2 is served, so v1beta1 must never be tried
+	discoveryClient := newFakeDiscovery(widgetResourceList(), &metav1.APIResourceList{
+		GroupVersion: "longhorn.io/v1beta2",
+		APIResources: []metav1.APIResource{
+			{Name: "volumes", Kind: "Volume", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "patch", "delete"}},
+			{Name: "replicas", Kind: "Replica", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "patch", "delete"}},
+			{Name: "settings", Kind: "Setting", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "patch", "delete"}},
+		},
+	})
+
+	registry := storage.DefaultRegistry()
+	registry.Merge(&storage.Registry{Providers: []storage.Provider{{Name: "Widgets", Groups: []string{"example.com"}}}})
+	keep := KeepFilter{Selector: labels.SelectorFromSet(labels.Set{"keep": "true"})}
+	ctx := context.TODO()
+
+	if _, err := handleStorageProviders(ctx, k8sfake.NewSimpleClientset(), discoveryClient, dynamicClient, "shop", NukeOptions{StorageProviders: registry, Keep: keep}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var deletes []string
+	for _, action := range dynamicClient.Actions() {
+		if action.GetVerb() != "delete" {
+			continue
+		}
+		if action.GetResource().Version != "v1beta2" && action.GetResource().Group == "longhorn.io" {
+			t.Errorf("expected only the served Longhorn version to be used, got %v", action.GetResource())
+		}
+		deletes = append(deletes, action.(clienttesting.DeleteAction).GetName())
+	}
+	// longhorn-manager deletes a volume's replicas with it, so volumes go first
+	expected := []string{"data", "data-r-1", "gadget"}
+	if len(deletes) != len(expected) {
+		t.Fatalf("expected deletes %v, got %v", expected, deletes)
+	}
+	for i := range expected {
+		if deletes[i] != expected[i] {
+			t.Errorf("expected deletes in teardown order %v, got %v", expected, deletes)
+			break
+		}
+	}
+
+	if _, err := dynamicClient.Resource(widgetGVR).Namespace("shop").Get(ctx, "kept", metav1.GetOptions{}); errors.IsNotFound(err) {
+		t.Errorf("expected the kept widget to survive")
+	}
+}
+
+func newRookObject(kind, name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("ceph.rook.io/v1")
+	u.SetKind(kind)
+	u.SetNamespace("rook-ceph")
+	u.SetName(name)
+	u.SetFinalizers([]string{"ceph.rook.io/disaster-protection"})
+	return u
+}
+
+func newOperatorPod(ready string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": ready}},
+		},
+	}}
+	u.SetAPIVersion("v1")
+	u.SetKind("Pod")
+	u.SetNamespace("rook-ceph")
+	u.SetName("rook-ceph-operator-0")
+	u.SetLabels(map[string]string{"app": "rook-ceph-operator"})
+	return u
+}
+
+func TestHandleStorageProvidersWithOperator(t *testing.T) {
+	poolsGVR := schema.GroupVersionResource{Group: "ceph.rook.io", Version: "v1", Resource: "cephblockpools"}
+	clustersGVR := schema.GroupVersionResource{Group: "ceph.rook.io", Version: "v1", Resource: "cephclusters"}
+	discoveryClient := newFakeDiscovery(&metav1.APIResourceList{
+		GroupVersion: "ceph.rook.io/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "cephclusters", Kind: "CephCluster", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "patch", "delete"}},
+			{Name: "cephblockpools", Kind: "CephBlockPool", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "patch", "delete"}},
+		},
+	})
+	newClient := func(operatorReady string) *dynamicfake.FakeDynamicClient {
+		return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{poolsGVR: "CephBlockPoolList", clustersGVR: "CephClusterList", podsGVR: "PodList"},
+			newRookObject("CephCluster", "rook-ceph"),
+			newRookObject("CephBlockPool", "replicapool"),
+			newOperatorPod(operatorReady),
+		)
+	}
+	actions := func(dynamicClient *dynamicfake.FakeDynamicClient) []string {
+		var got []string
+		for _, action := range dynamicClient.Actions() {
+			switch a := action.(type) {
+			case clienttesting.DeleteAction:
+				got = append(got, "delete "+a.GetResource().Resource)
+			case clienttesting.PatchAction:
+				got = append(got, string(a.GetPatchType())+" "+a.GetResource().Resource)
+			}
+		}
+		return got
+	}
+	ctx := context.TODO()
+
+	// A running operator removes each step's objects, so nothing has its finalizers stripped
+	dynamicClient := newClient("True")
+	opts := NukeOptions{StorageProviders: storage.DefaultRegistry(), DestroyStorageData: true}
+	if _, err := handleStorageProviders(ctx, k8sfake.NewSimpleClientset(), discoveryClient, dynamicClient, "rook-ceph", opts); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	expected := []string{
+		"application/merge-patch+json cephclusters",
+		"delete cephblockpools",
+		"delete cephclusters",
+	}
+	if got := actions(dynamicClient); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected the cleanup policy, then dependents before the cluster %v, got %v", expected, got)
+	}
+
+	// Without an operator, deletes hang on the finalizers until they are removed
+	dynamicClient = newClient("False")
+	dynamicClient.PrependReactor("delete", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	opts.DestroyStorageData = false
+	if _, err := handleStorageProviders(ctx, k8sfake.NewSimpleClientset(), discoveryClient, dynamicClient, "rook-ceph", opts); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	expected = []string{
+		"delete cephblockpools",
+		"application/json-patch+json cephblockpools",
+		"delete cephclusters",
+		"application/json-patch+json cephclusters",
+	}
+	if got := actions(dynamicClient); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected finalizers removed after each delete %v, got %v", expected, got)
+	}
+}
+
+func TestHandleStorageProvidersDetachesVolumes(t *testing.T) {
+	volumesGVR := schema.GroupVersionResource{Group: "longhorn.io", Version: "v1beta2", Resource: "volumes"}
+	attachmentsGVR := schema.GroupVersionResource{Group: "longhorn.io", Version: "v1beta2", Resource: "volumeattachments"}
+	discoveryClient := newFakeDiscovery(&metav1.APIResourceList{
+		GroupVersion: "longhorn.io/v1beta2",
+		APIResources: []metav1.APIResource{
+			{Name: "volumes", Kind: "Volume", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "patch", "delete"}},
+			{Name: "volumeattachments", Kind: "VolumeAttachment", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "patch", "delete"}},
+			{Name: "settings", Kind: "Setting", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "patch", "delete"}},
+		},
+	})
+
+	newClients := func() (*k8sfake.Clientset, *dynamicfake.FakeDynamicClient) {
+		volume := newLonghornObject("Volume", "pvc-123")
+		volume.SetNamespace("longhorn-system")
+		unstructured.SetNestedField(volume.Object, "attached", "status", "state")
+		attachment := newLonghornObject("VolumeAttachment", "pvc-123")
+		attachment.SetNamespace("longhorn-system")
+		unstructured.SetNestedMap(attachment.Object, map[string]interface{}{
+			"csi-abc": map[string]interface{}{"type": "csi-attacher", "nodeID": "node-1"},
+		}, "spec", "attachmentTickets")
+		setting := newLonghornObject("Setting", "deleting-confirmation-flag")
+		setting.SetNamespace("longhorn-system")
+		setting.Object["value"] = "false"
+		manager := newOperatorPod("True")
+		manager.SetNamespace("longhorn-system")
+		manager.SetName("longhorn-manager-0")
+		manager.SetLabels(map[string]string{"app": "longhorn-manager"})
+		dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{volumesGVR: "VolumeList", attachmentsGVR: "VolumeAttachmentList", podsGVR: "PodList"},
+			volume, attachment, setting, manager,
+		)
+
+		replicas := int32(2)
+		pvName := "pv-data"
+		isController := true
+		clientset := k8sfake.NewSimpleClientset(
+			&storagev1.VolumeAttachment{
+				ObjectMeta: metav1.ObjectMeta{Name: "csi-abc"},
+				Spec: storagev1.VolumeAttachmentSpec{
+					Attacher: "driver.longhorn.io",
+					NodeName: "node-1",
+					Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName},
+				},
+				Status: storagev1.VolumeAttachmentStatus{Attached: true},
+			},
+			&corev1.PersistentVolume{
+				ObjectMeta: metav1.ObjectMeta{Name: pvName},
+				Spec: corev1.PersistentVolumeSpec{
+					PersistentVolumeSource: corev1.PersistentVolumeSource{CSI: &corev1.CSIPersistentVolumeSource{Driver: "driver.longhorn.io", VolumeHandle: "pvc-123"}},
+					ClaimRef:               &corev1.ObjectReference{Namespace: "shop", Name: "data"},
+				},
+			},
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"}, Spec: appsv1.DeploymentSpec{Replicas: &replicas}},
+			&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-7d9",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "web", Controller: &isController}}}},
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-7d9-x",
+					OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-7d9", Controller: &isController}}},
+				Spec: corev1.PodSpec{Volumes: []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}}}}},
+			},
+		)
+		return clientset, dynamicClient
+	}
+	ctx := context.TODO()
+
+	// Attached volumes keep the provider from being torn down
+	clientset, dynamicClient := newClients()
+	volumes, err := findProviderVolumes(ctx, clientset, dynamicClient, "longhorn-system", *storage.DefaultRegistry().Find("Longhorn"), mustDiscover(t, discoveryClient))
+	if err != nil || len(volumes) != 1 {
+		t.Fatalf("expected one Longhorn volume, got %v, %v", volumes, err)
+	}
+	v := volumes[0]
+	if v.PVC != "shop/data" || len(v.Nodes) != 1 || len(v.Tickets) != 1 || len(v.Consumers) != 1 || v.Consumers[0].String() != "Deployment shop/web" {
+		t.Errorf("expected pvc-123 attached to node-1 for Deployment shop/web, got %+v", v)
+	}
+	scope := &WebhookScope{}
+	resources := append(mustDiscover(t, discoveryClient), builtinResource("apps", "deployments", "Deployment"))
+	scope.addDetachCalls(ctx, clientset, dynamicClient, "longhorn-system", resources, NukeOptions{StorageProviders: storage.DefaultRegistry(), DetachVolumes: true})
+	if len(scope.calls) != 1 || scope.calls[0].String() != "UPDATE deployments.apps" || scope.calls[0].Namespace != "shop" {
+		t.Errorf("expected scaling down Deployment shop/web to be in the webhook scope, got %+v", scope.calls)
+	}
+	opts := NukeOptions{StorageProviders: storage.DefaultRegistry()}
+	skipped, err := handleStorageProviders(ctx, clientset, discoveryClient, dynamicClient, "longhorn-system", opts)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(skipped) != 1 || skipped[0] != "Longhorn" {
+		t.Errorf("expected the Longhorn teardown to be reported as skipped, got %v", skipped)
+	}
+	for _, action := range dynamicClient.Actions() {
+		if action.GetVerb() == "delete" || action.GetVerb() == "patch" {
+			t.Errorf("expected nothing to be changed while volumes are attached, got %s %s", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+
+	// Scaling the Deployment down releases the volume, which longhorn-manager then detaches
+	clientset, dynamicClient = newClients()
+	clientset.PrependReactor("patch", "deployments", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		clientset.Tracker().Delete(schema.GroupVersionResource{Group: "storage.k8s.io", Version: "v1", Resource: "volumeattachments"}, "", "csi-abc")
+		dynamicClient.Tracker().Delete(attachmentsGVR, "longhorn-system", "pvc-123")
+		return false, nil, nil
+	})
+	opts.DetachVolumes, opts.DestroyStorageData = true, true
+	if _, err := handleStorageProviders(ctx, clientset, discoveryClient, dynamicClient, "longhorn-system", opts); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	deployment, _ := clientset.AppsV1().Deployments("shop").Get(ctx, "web", metav1.GetOptions{})
+	if replicasOf(deployment.Spec.Replicas) != 0 {
+		t.Errorf("expected Deployment web to be scaled down, got %d replicas", replicasOf(deployment.Spec.Replicas))
+	}
+	setting, _ := dynamicClient.Resource(schema.GroupVersionResource{Group: "longhorn.io", Version: "v1beta2", Resource: "settings"}).
+		Namespace("longhorn-system").Get(ctx, "deleting-confirmation-flag", metav1.GetOptions{})
+	if setting.Object["value"] != "true" {
+		t.Errorf("expected deleting-confirmation-flag to be set, got %v", setting.Object["value"])
+	}
+	if _, err := dynamicClient.Resource(volumesGVR).Namespace("longhorn-system").Get(ctx, "pvc-123", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expected the detached volume to be deleted, got %v", err)
+	}
+}
+
+// mustDiscover returns the served namespaced resources of a fake discovery client
+func mustDiscover(t *testing.T, discoveryClient *discoveryfake.FakeDiscovery) []discoveredResource {
+	resources, err := discoverResources(discoveryClient, true, "list", "delete")
+	if err != nil {
+		t.Fatalf("failed to discover resources: %v", err)
+	}
+	return resources
+}
+
+func TestCollectStorageTeardownClusterScoped(t *testing.T) {
+	logicalVolumesGVR := schema.GroupVersionResource{Group: "topolvm.io", Version: "v1", Resource: "logicalvolumes"}
+	strategiesGVR := schema.GroupVersionResource{Group: "portworx.io", Version: "v1beta2", Resource: "volumeplacementstrategies"}
+	clustersGVR := schema.GroupVersionResource{Group: "core.libopenstorage.org", Version: "v1", Resource: "storageclusters"}
+
+	newObject := func(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		return u
+	}
+	newLogicalVolume := func(name, volumeID string) *unstructured.Unstructured {
+		u := newObject("topolvm.io/v1", "LogicalVolume", "", name)
+		unstructured.SetNestedField(u.Object, volumeID, "status", "volumeID")
+		return u
+	}
+	newVolume := func(namespace, claim, name, handle string, policy corev1.PersistentVolumeReclaimPolicy, labels map[string]string) []runtime.Object {
+		return []runtime.Object{
+			&corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: claim, Labels: labels},
+				Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: name},
+			},
+			&corev1.PersistentVolume{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Spec: corev1.PersistentVolumeSpec{
+					ClaimRef:                      &corev1.ObjectReference{Namespace: namespace, Name: claim},
+					PersistentVolumeReclaimPolicy: policy,
+					PersistentVolumeSource:        corev1.PersistentVolumeSource{CSI: &corev1.CSIPersistentVolumeSource{Driver: "topolvm.io", VolumeHandle: handle}},
+				},
+			},
+		}
+	}
+
+	var volumes []runtime.Object
+	volumes = append(volumes, newVolume("shop", "data", "pvc-1", "vol-1", corev1.PersistentVolumeReclaimDelete, nil)...)
+	volumes = append(volumes, newVolume("other", "data", "pvc-2", "vol-2", corev1.PersistentVolumeReclaimDelete, nil)...)
+	volumes = append(volumes, newVolume("shop", "logs", "pvc-3", "vol-3", corev1.PersistentVolumeReclaimRetain, nil)...)
+	volumes = append(volumes, newVolume("shop", "cache", "pvc-4", "vol-4", corev1.PersistentVolumeReclaimDelete, map[string]string{"keep": "true"})...)
+	clientset := k8sfake.NewSimpleClientset(volumes...)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{logicalVolumesGVR: "LogicalVolumeList", strategiesGVR: "VolumePlacementStrategyList", clustersGVR: "StorageClusterList"},
+		newLogicalVolume("pvc-1", "vol-1"),
+		newLogicalVolume("pvc-2", "vol-2"),
+		newLogicalVolume("pvc-3", "vol-3"),
+		newLogicalVolume("pvc-4", "vol-4"),
+		newObject("portworx.io/v1beta2", "VolumePlacementStrategy", "", "fast"),
+		newObject("core.libopenstorage.org/v1", "StorageCluster", "portworx", "px"),
+	)
+	verbs := metav1.Verbs{"get", "list", "patch", "delete"}
+	resources, err := discoverResources(newFakeDiscovery(
+		&metav1.APIResourceList{GroupVersion: "topolvm.io/v1", APIResources: []metav1.APIResource{{Name: "logicalvolumes", Kind: "LogicalVolume", Verbs: verbs}}},
+		&metav1.APIResourceList{GroupVersion: "portworx.io/v1beta2", APIResources: []metav1.APIResource{{Name: "volumeplacementstrategies", Kind: "VolumePlacementStrategy", Verbs: verbs}}},
+		&metav1.APIResourceList{GroupVersion: "core.libopenstorage.org/v1", APIResources: []metav1.APIResource{{Name: "storageclusters", Kind: "StorageCluster", Namespaced: true, Verbs: verbs}}},
+	), false, "list", "delete")
+	if err != nil {
+		t.Fatalf("failed to discover resources: %v", err)
+	}
+
+	registry := storage.DefaultRegistry()
+	keep := KeepFilter{Selector: labels.SelectorFromSet(labels.Set{"keep": "true"})}
+	collect := func(providerName, namespace string, allowDataLoss bool) []string {
+		provider := *registry.Find(providerName)
+		opts := NukeOptions{ContentsOnly: true, Keep: keep, AllowDataLoss: allowDataLoss}
+		var names []string
+		for _, step := range collectStorageTeardown(context.TODO(), clientset, dynamicClient, namespace, provider, storageProviderResources(provider, resources), opts) {
+			for _, name := range step.names() {
+				names = append(names, step.Resource.GVR.Resource+"/"+name)
+			}
+		}
+		return names
+	}
+
+	tests := []struct {
+		provider, namespace string
+		allowDataLoss       bool
+		want                []string
+	}{
+		// LogicalVolumes go with the namespace's deleted PVCs whose data is lost anyway, not with retained or kept ones
+		{"TopoLVM", "shop", true, []string{"logicalvolumes/pvc-1"}},
+		{"TopoLVM", "shop", false, nil},
+		{"TopoLVM", "portworx", true, nil},
+		// Placement strategies go with the namespace running the StorageCluster
+		{"Portworx", "portworx", false, []string{"volumeplacementstrategies/fast", "storageclusters/px"}},
+		{"Portworx", "shop", false, nil},
+	}
+	for _, tt := range tests {
+		if got := collect(tt.provider, tt.namespace, tt.allowDataLoss); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s in %s (allow data loss %v): expected %v, got %v", tt.provider, tt.namespace, tt.allowDataLoss, tt.want, got)
+		}
+	}
+}
+
+func TestOperatorStalled(t *testing.T) {
+	clustersGVR := schema.GroupVersionResource{Group: "ceph.rook.io", Version: "v1", Resource: "cephclusters"}
+	cluster := newRookObject("CephCluster", "rook-ceph")
+	unstructured.SetNestedField(cluster.Object, "Deleting", "status", "phase")
+	newClient := func(operatorReady string, objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+		return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{clustersGVR: "CephClusterList", podsGVR: "PodList"},
+			append(objects, newOperatorPod(operatorReady))...,
+		)
+	}
+	operator := *storage.DefaultRegistry().Find("Rook-Ceph").Operator
+	ctx := context.TODO()
+	names := []string{"rook-ceph"}
+
+	dynamicClient := newClient("True", cluster)
+	resourceClient := dynamicClient.Resource(clustersGVR).Namespace("rook-ceph")
+	statuses := map[string]string{}
+	if reason := operatorStalled(ctx, dynamicClient, resourceClient, operator, names, statuses); reason != "" {
+		t.Errorf("expected a first status to count as progress, got %q", reason)
+	}
+	if reason := operatorStalled(ctx, dynamicClient, resourceClient, operator, names, statuses); reason != "making no progress" {
+		t.Errorf("expected an unchanged status to stall the operator, got %q", reason)
+	}
+
+	unstructured.SetNestedField(cluster.Object, "Failure", "status", "phase")
+	unstructured.SetNestedSlice(cluster.Object, []interface{}{
+		map[string]interface{}{"type": "DeletionFailed", "status": "True", "message": "pools still in use"},
+	}, "status", "conditions")
+	dynamicClient = newClient("True", cluster)
+	resourceClient = dynamicClient.Resource(clustersGVR).Namespace("rook-ceph")
+	if reason := operatorStalled(ctx, dynamicClient, resourceClient, operator, names, map[string]string{}); !strings.Contains(reason, "failing") {
+		t.Errorf("expected a failed condition to stop the wait, got %q", reason)
+	}
+
+	dynamicClient = newClient("False", cluster)
+	resourceClient = dynamicClient.Resource(clustersGVR).Namespace("rook-ceph")
+	if reason := operatorStalled(ctx, dynamicClient, resourceClient, operator, names, map[string]string{}); !strings.Contains(reason, "gone") {
+		t.Errorf("expected an operator without ready pods to stop the wait, got %q", reason)
+	}
+}
+
+func TestTearDownWithOperatorGivesUp(t *testing.T) {
+	clustersGVR := schema.GroupVersionResource{Group: "ceph.rook.io", Version: "v1", Resource: "cephclusters"}
+	cluster := newRookObject("CephCluster", "rook-ceph")
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{clustersGVR: "CephClusterList", podsGVR: "PodList"},
+		cluster, newOperatorPod("True"),
+	)
+	// The operator never finishes, but its status keeps changing, so it always looks busy
+	dynamicClient.PrependReactor("delete", "cephclusters", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	polls := 0
+	dynamicClient.PrependReactor("get", "cephclusters", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		polls++
+		obj := cluster.DeepCopy()
+		unstructured.SetNestedField(obj.Object, fmt.Sprintf("Deleting (%d)", polls), "status", "phase")
+		return true, obj, nil
+	})
+
+	provider := *storage.DefaultRegistry().Find("Rook-Ceph")
+	provider.Wait.Timeout = metav1.Duration{Duration: time.Millisecond}
+	steps := []storageTeardownStep{{
+		Resource: discoveredResource{GVR: clustersGVR, APIResource: metav1.APIResource{Name: "cephclusters", Kind: "CephCluster", Namespaced: true}},
+		Objects:  []unstructured.Unstructured{*cluster},
+	}}
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	if err := tearDownWithOperator(ctx, dynamicClient, "rook-ceph", provider, steps, false); err != nil {
+		t.Fatalf("expected the wait to end on its own, got %v", err)
+	}
+	stripped := false
+	for _, action := range dynamicClient.Actions() {
+		stripped = stripped || action.GetVerb() == "patch"
+	}
+	if !stripped {
+		t.Errorf("expected the finalizers to be removed once the operator is treated as unresponsive")
+	}
+}
+
+func TestNukeNamespaceTearsDownStorageBeforePods(t *testing.T) {
+	clustersGVR := schema.GroupVersionResource{Group: "ceph.rook.io", Version: "v1", Resource: "cephclusters"}
+	clientset := k8sfake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "rook-ceph-operator-0", Namespace: "rook-ceph", Labels: map[string]string{"app": "rook-ceph-operator"}}},
+	)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			clustersGVR:                 "CephClusterList",
+			podsGVR:                     "PodList",
+			volumeSnapshotsResource.GVR: "VolumeSnapshotList",
+			volumeSnapshotContentsGVR:   "VolumeSnapshotContentList",
+		},
+		newRookObject("CephCluster", "rook-ceph"),
+		newOperatorPod("True"),
+	)
+	verbs := metav1.Verbs{"get", "list", "delete", "patch", "update"}
+	discoveryClient := newFakeDiscovery(
+		&metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: verbs},
+		}},
+		&metav1.APIResourceList{GroupVersion: "ceph.rook.io/v1", APIResources: []metav1.APIResource{
+			{Name: "cephclusters", Kind: "CephCluster", Namespaced: true, Verbs: verbs},
+		}},
+	)
+
+	var deletes []string
+	record := func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetVerb() == "delete" {
+			deletes = append(deletes, action.GetResource().Resource)
+		}
+		return false, nil, nil
+	}
+	clientset.PrependReactor("delete", "*", record)
+	dynamicClient.PrependReactor("delete", "*", record)
+
+	opts := NukeOptions{ContentsOnly: true, StorageProviders: storage.DefaultRegistry(), FinalizerTimeout: time.Millisecond}
+	if err := nukeNamespace(context.TODO(), clientset, discoveryClient, dynamicClient, "rook-ceph", opts); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(deletes) < 2 || deletes[0] != "cephclusters" || deletes[1] != "pods" {
+		t.Errorf("expected the CephCluster to be left to its operator before the pods are deleted, got %v", deletes)
+	}
+}
+
+func TestNukeNamespaceDetachesLonghornBeforePods(t *testing.T) {
+	volumesGVR := schema.GroupVersionResource{Group: "longhorn.io", Version: "v1beta2", Resource: "volumes"}
+	settingsGVR := schema.GroupVersionResource{Group: "longhorn.io", Version: "v1beta2", Resource: "settings"}
+	volume := newLonghornObject("Volume", "pvc-123")
+	volume.SetNamespace("longhorn-system")
+	setting := newLonghornObject("Setting", "deleting-confirmation-flag")
+	setting.SetNamespace("longhorn-system")
+	setting.Object["value"] = "false"
+	manager := newOperatorPod("True")
+	manager.SetNamespace("longhorn-system")
+	manager.SetName("longhorn-manager-0")
+	manager.SetLabels(map[string]string{"app": "longhorn-manager"})
+
+	clientset := k8sfake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "longhorn-manager-0", Namespace: "longhorn-system", Labels: map[string]string{"app": "longhorn-manager"}}},
+	)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			volumesGVR:                  "VolumeList",
+			settingsGVR:                 "SettingList",
+			podsGVR:                     "PodList",
+			volumeSnapshotsResource.GVR: "VolumeSnapshotList",
+			volumeSnapshotContentsGVR:   "VolumeSnapshotContentList",
+		},
+		volume, setting, manager,
+	)
+	verbs := metav1.Verbs{"get", "list", "delete", "patch", "update"}
+	discoveryClient := newFakeDiscovery(
+		&metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: verbs},
+		}},
+		&metav1.APIResourceList{GroupVersion: "longhorn.io/v1beta2", APIResources: []metav1.APIResource{
+			{Name: "volumes", Kind: "Volume", Namespaced: true, Verbs: verbs},
+			{Name: "settings", Kind: "Setting", Namespaced: true, Verbs: verbs},
+		}},
+	)
+
+	var writes []string
+	record := func(action clienttesting.Action) (bool, runtime.Object, error) {
+		writes = append(writes, action.GetVerb()+" "+action.GetResource().Resource)
+		return false, nil, nil
+	}
+	for _, verb := range []string{"delete", "patch", "update"} {
+		clientset.PrependReactor(verb, "*", record)
+		dynamicClient.PrependReactor(verb, "*", record)
+	}
+
+	// Without the confirmation, the pipeline stops before longhorn-manager loses its pods
+	opts := NukeOptions{ContentsOnly: true, StorageProviders: storage.DefaultRegistry(), DetachVolumes: true, FinalizerTimeout: time.Millisecond}
+	if err := nukeNamespace(context.TODO(), clientset, discoveryClient, dynamicClient, "longhorn-system", opts); err == nil || len(writes) > 0 {
+		t.Fatalf("expected the skipped Longhorn teardown to stop the pipeline untouched, got %v after %v", err, writes)
+	}
+
+	opts.DestroyStorageData = true
+	if err := nukeNamespace(context.TODO(), clientset, discoveryClient, dynamicClient, "longhorn-system", opts); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	pods := -1
+	for i, write := range writes {
+		if write == "delete pods" {
+			pods = i
+			break
+		}
+	}
+	expected := []string{"patch settings", "delete volumes"}
+	if pods < len(expected) || strings.Join(writes[:len(expected)], ",") != strings.Join(expected, ",") {
+		t.Errorf("expected longhorn-manager to confirm and remove the volumes before its pod is deleted, got %v", writes)
+	}
+}
+
+func TestRemoveAllCustomResourceFinalizers(t *testing.T) {
+	configMapGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	configMap := newWidget("shop", "settings", "example.com/cleanup")
+	configMap.SetAPIVersion("v1")
+	configMap.SetKind("ConfigMap")
+	kept := newWidget("shop", "kept", "example.com/cleanup")
+	kept.SetLabels(map[string]string{"keep": "true"})
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{widgetGVR: "WidgetList", configMapGVR: "ConfigMapList"},
+		newWidget("shop", "stuck", "example.com/cleanup"), kept, configMap)
+	discoveryClient := newFakeDiscovery(widgetResourceList(), &metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{
+		{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "patch"}},
+	}})
+	keep := KeepFilter{Kinds: []string{"configmaps"}, Selector: labels.SelectorFromSet(labels.Set{"keep": "true"})}
+	ctx := context.TODO()
+
+	if err := removeAllCustomResourceFinalizers(ctx, discoveryClient, dynamicClient, "shop", keep, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := map[schema.GroupVersionResource]map[string]int{
+		widgetGVR:    {"stuck": 0, "kept": 1},
+		configMapGVR: {"settings": 1},
+	}
+	for gvr, objects := range expected {
+		for name, finalizers := range objects {
+			obj, err := dynamicClient.Resource(gvr).Namespace("shop").Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get %s %s: %v", gvr.Resource, name, err)
+			}
+			if got := len(obj.GetFinalizers()); got != finalizers {
+				t.Errorf("expected %s %s to have %d finalizer(s), got %v", gvr.Resource, name, finalizers, obj.GetFinalizers())
+			}
+		}
+	}
+}
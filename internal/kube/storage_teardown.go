@@ -0,0 +1,480 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/codesenju/kubectl-nuke-go/pkg/storage"
+)
+
+// podsGVR is the core pods resource, listed through the dynamic client to find storage operators
+var podsGVR = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+// operatorWaitRounds caps how many wait timeouts one step of an operator teardown may take while the operator is
+// making progress; after that it is treated as unresponsive
+const operatorWaitRounds = 5
+
+// storageTeardownStep is one resource of a provider's teardown with the objects to delete
+type storageTeardownStep struct {
+	Resource discoveredResource
+	Objects  []unstructured.Unstructured
+}
+
+// names returns the names of the step's objects
+func (s storageTeardownStep) names() []string {
+	names := make([]string, 0, len(s.Objects))
+	for _, obj := range s.Objects {
+		names = append(names, obj.GetName())
+	}
+	return names
+}
+
+// client returns the dynamic client of the step's resource, scoped to the namespace unless it is cluster-scoped
+func (s storageTeardownStep) client(dynamicClient dynamic.Interface, namespace string) dynamic.ResourceInterface {
+	if !s.Resource.APIResource.Namespaced {
+		return dynamicClient.Resource(s.Resource.GVR)
+	}
+	return dynamicClient.Resource(s.Resource.GVR).Namespace(namespace)
+}
+
+// collectStorageTeardown lists the objects of each served resource, in teardown order, leaving out untouched
+// objects and resources without any. Cluster-scoped objects are included when their volume field names one of
+// the namespace's disposable volumes or, for resources without one, when the namespace holds the provider's
+// namespaced objects.
+func collectStorageTeardown(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, provider storage.Provider, served []discoveredResource, opts NukeOptions) []storageTeardownStep {
+	keep := opts.untouched()
+	var steps []storageTeardownStep
+	var volumes map[string]bool
+	namespaced := false
+	for _, res := range served {
+		if keep.keepsKind(res) {
+			continue
+		}
+		step := storageTeardownStep{Resource: res}
+		list, err := step.client(dynamicClient, namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			continue
+		}
+
+		var volumeField []string
+		if r := provider.Resource(res.GVR.Group, res.APIResource); !res.APIResource.Namespaced && r != nil && r.VolumeField != "" {
+			volumeField = strings.Split(r.VolumeField, ".")
+			if volumes == nil {
+				if volumes, err = disposableVolumeIDs(ctx, clientset, namespace, opts); err != nil {
+					fmt.Printf("⚠️  Warning: %v\n", err)
+				}
+			}
+		}
+		for _, item := range list.Items {
+			if keep.keeps(res, item.GetLabels()) {
+				continue
+			}
+			if volumeField != nil {
+				if id, _, _ := unstructured.NestedString(item.Object, volumeField...); !volumes[id] && !volumes[item.GetName()] {
+					continue
+				}
+			}
+			step.Objects = append(step.Objects, item)
+		}
+		if len(step.Objects) > 0 {
+			steps = append(steps, step)
+			namespaced = namespaced || res.APIResource.Namespaced
+		}
+	}
+
+	if namespaced {
+		return steps
+	}
+	var owned []storageTeardownStep
+	for _, step := range steps {
+		if r := provider.Resource(step.Resource.GVR.Group, step.Resource.APIResource); r != nil && r.VolumeField != "" {
+			owned = append(owned, step)
+		}
+	}
+	return owned
+}
+
+// disposableVolumeIDs returns the names and CSI volume handles of the PVs whose data goes with the namespace's
+// PVCs: the Delete reclaim policy volumes of the PVCs the pipeline deletes, once data loss is allowed. Retained
+// volumes and those of kept PVCs keep their backing objects.
+func disposableVolumeIDs(ctx context.Context, clientset kubernetes.Interface, namespace string, opts NukeOptions) (map[string]bool, error) {
+	ids := map[string]bool{}
+	if !opts.AllowDataLoss {
+		return ids, nil
+	}
+	volumes, err := analyzePVCVolumes(ctx, clientset, namespace, opts.untouched())
+	if err != nil {
+		return ids, err
+	}
+	for _, v := range volumes {
+		if !v.LosesData() {
+			continue
+		}
+		ids[v.PV.Name] = true
+		if v.PV.Spec.CSI != nil && v.PV.Spec.CSI.VolumeHandle != "" {
+			ids[v.PV.Spec.CSI.VolumeHandle] = true
+		}
+	}
+	return ids, nil
+}
+
+// readyOperatorPods returns the operator's ready pods, as namespace/name, from every namespace
+func readyOperatorPods(ctx context.Context, dynamicClient dynamic.Interface, operator storage.Operator) ([]string, error) {
+	pods, err := dynamicClient.Resource(podsGVR).List(ctx, metav1.ListOptions{LabelSelector: operator.Selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list operator pods: %w", err)
+	}
+	var ready []string
+	for _, pod := range pods.Items {
+		if pod.GetDeletionTimestamp() == nil && conditionStatus(&pod, "Ready") == "True" {
+			ready = append(ready, pod.GetNamespace()+"/"+pod.GetName())
+		}
+	}
+	return ready, nil
+}
+
+// conditionStatus returns the status of an object's condition, or an empty string when it has none of the type
+func conditionStatus(obj *unstructured.Unstructured, conditionType string) string {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == conditionType {
+			status, _ := condition["status"].(string)
+			return status
+		}
+	}
+	return ""
+}
+
+// describeStorageStatus summarises an object's phase, state, robustness and true conditions, to show what an
+// operator is doing
+func describeStorageStatus(obj *unstructured.Unstructured) string {
+	var parts []string
+	for _, field := range []string{"phase", "state", "robustness"} {
+		if value, _, _ := unstructured.NestedString(obj.Object, "status", field); value != "" {
+			parts = append(parts, field+" "+value)
+		}
+	}
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["status"] != "True" {
+			continue
+		}
+		part := fmt.Sprint(condition["type"])
+		if message, _ := condition["message"].(string); message != "" {
+			part += ": " + message
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "no status reported"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// dataCleanupPatches returns the provider's destroyData patches for a resource, encoded as merge patches
+func dataCleanupPatches(provider storage.Provider, res discoveredResource) [][]byte {
+	var patches [][]byte
+	for _, cleanup := range provider.DestroyData {
+		if !cleanup.Matches(res.APIResource) {
+			continue
+		}
+		if patch, err := json.Marshal(cleanup.Patch); err == nil {
+			patches = append(patches, patch)
+		}
+	}
+	return patches
+}
+
+// storageFailureStates are phase, state or robustness values meaning the operator has given up on an object
+var storageFailureStates = map[string]bool{"failed": true, "error": true, "faulted": true}
+
+// reportsStorageFailure reports whether an object's status shows its operator failed to tear it down: a failure
+// phase, state or robustness, or a true condition such as Failed, Error or Degraded
+func reportsStorageFailure(obj *unstructured.Unstructured) bool {
+	for _, field := range []string{"phase", "state", "robustness"} {
+		if value, _, _ := unstructured.NestedString(obj.Object, "status", field); storageFailureStates[strings.ToLower(value)] {
+			return true
+		}
+	}
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["status"] != "True" {
+			continue
+		}
+		conditionType := fmt.Sprint(condition["type"])
+		if strings.Contains(conditionType, "Fail") || strings.Contains(conditionType, "Error") || conditionType == "Degraded" {
+			return true
+		}
+	}
+	return false
+}
+
+// operatorStalled returns why the operator can't be left to finish removing the objects: its pods aren't ready
+// anymore, an object reports a failure, or none of them changed status since the last check. It returns an empty
+// string while the operator is making progress, and records the objects' status for the next check.
+func operatorStalled(ctx context.Context, dynamicClient dynamic.Interface, resourceClient dynamic.ResourceInterface, operator storage.Operator, names []string, statuses map[string]string) string {
+	pods, err := readyOperatorPods(ctx, dynamicClient, operator)
+	if err != nil {
+		return fmt.Sprintf("not confirmed ready (%v)", err)
+	}
+	if len(pods) == 0 {
+		return fmt.Sprintf("gone: no ready pod matches %s", operator.Selector)
+	}
+
+	progressed := false
+	for _, name := range names {
+		obj, err := resourceClient.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			progressed = progressed || errors.IsNotFound(err)
+			continue
+		}
+		if reportsStorageFailure(obj) {
+			return fmt.Sprintf("failing on %s: %s", name, describeStorageStatus(obj))
+		}
+		status := describeStorageStatus(obj)
+		progressed = progressed || statuses[name] != status
+		statuses[name] = status
+	}
+	if !progressed {
+		return "making no progress"
+	}
+	return ""
+}
+
+// tearDownWithOperator runs a provider's documented teardown: the destroyData patches on request, then each
+// resource in order, deleted and left to the operator. Finalizers are removed only when the operator is gone,
+// or from the objects it has stopped working on: while its pods are ready and the objects' status keeps
+// changing without reporting a failure, the wait is extended, up to operatorWaitRounds times the provider's
+// wait timeout per step.
+func tearDownWithOperator(ctx context.Context, dynamicClient dynamic.Interface, namespace string, provider storage.Provider, steps []storageTeardownStep, destroyData bool) error {
+	fmt.Printf("🪜 Tearing down %s in order, leaving each step to its operator...\n", provider.Name)
+	pods, err := readyOperatorPods(ctx, dynamicClient, *provider.Operator)
+	operatorUp := err != nil || len(pods) > 0
+	switch {
+	case err != nil:
+		fmt.Printf("⚠️  Warning: %v; assuming the %s operator is running\n", err, provider.Name)
+	case operatorUp:
+		fmt.Printf("⚙️  %s operator running: %s\n", provider.Name, strings.Join(pods, ", "))
+	default:
+		fmt.Printf("⚠️  No ready %s operator pod (%s); finalizers are removed right after each delete\n", provider.Name, provider.Operator.Selector)
+	}
+
+	if destroyData {
+		applyDataCleanup(ctx, dynamicClient, namespace, provider, steps)
+	} else if len(provider.DestroyData) > 0 {
+		fmt.Printf("💡 %s keeps its data on the storage hosts; rerun with --destroy-storage-data to have the operator wipe it\n", provider.Name)
+	}
+
+	for i, step := range steps {
+		res := step.Resource
+		resourceClient := step.client(dynamicClient, namespace)
+		fmt.Printf("🪜 Step %d/%d: deleting %s %s: %s\n", i+1, len(steps), provider.Name, res.GVR.Resource, strings.Join(step.names(), ", "))
+
+		var deleted []string
+		for _, item := range step.Objects {
+			if err := resourceClient.Delete(ctx, item.GetName(), metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				fmt.Printf("⚠️  Failed to delete %s %s: %v\n", res.GVR.Resource, item.GetName(), err)
+				continue
+			}
+			deleted = append(deleted, item.GetName())
+		}
+
+		remaining := deleted
+		if operatorUp {
+			if provider.WaitCondition() == storage.WaitForNone {
+				continue
+			}
+			fmt.Printf("⏳ Waiting up to %v for the %s operator to remove them...\n", provider.WaitTimeout(), provider.Name)
+			statuses := map[string]string{}
+			operatorStalled(ctx, dynamicClient, resourceClient, *provider.Operator, deleted, statuses)
+			reason := ""
+			for round := 1; reason == "" && ctx.Err() == nil; round++ {
+				remaining = waitForRemoval(ctx, resourceClient, remaining, provider.WaitTimeout())
+				if len(remaining) == 0 {
+					break
+				}
+				if round == operatorWaitRounds {
+					reason = fmt.Sprintf("unresponsive: still not done after %v", operatorWaitRounds*provider.WaitTimeout())
+					break
+				}
+				if reason = operatorStalled(ctx, dynamicClient, resourceClient, *provider.Operator, remaining, statuses); reason == "" {
+					fmt.Printf("⏳ The %s operator is still working on %d %s; waiting another %v...\n", provider.Name, len(remaining), res.GVR.Resource, provider.WaitTimeout())
+				}
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if len(remaining) == 0 {
+				fmt.Printf("✅ %s removed the %s\n", provider.Name, res.GVR.Resource)
+				continue
+			}
+			fmt.Printf("⚠️  The %s operator is %s:\n", provider.Name, reason)
+			for _, name := range remaining {
+				if obj, err := resourceClient.Get(ctx, name, metav1.GetOptions{}); err == nil {
+					fmt.Printf("   - %s %s: %s\n", res.GVR.Resource, name, describeStorageStatus(obj))
+				}
+			}
+		}
+
+		for _, name := range remaining {
+			fmt.Printf("🔧 Removing finalizers from %s: %s\n", res.GVR.Resource, name)
+			if err := stripAllFinalizers(ctx, resourceClient, name); err != nil {
+				fmt.Printf("⚠️  Failed to remove finalizers from %s: %v\n", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyDataCleanup applies the provider's destroyData patches to the objects about to be torn down
+func applyDataCleanup(ctx context.Context, dynamicClient dynamic.Interface, namespace string, provider storage.Provider, steps []storageTeardownStep) {
+	for _, step := range steps {
+		resourceClient := step.client(dynamicClient, namespace)
+		for _, patch := range dataCleanupPatches(provider, step.Resource) {
+			for _, name := range step.names() {
+				if _, err := resourceClient.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+					fmt.Printf("⚠️  Failed to patch %s %s for data cleanup: %v\n", step.Resource.GVR.Resource, name, err)
+					continue
+				}
+				fmt.Printf("🔥 Set %s on %s %s: the operator wipes its data\n", patch, step.Resource.GVR.Resource, name)
+			}
+		}
+	}
+}
+
+// planStorageProviders prints the teardown force mode would run for each provider with objects in the namespace
+func planStorageProviders(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, opts NukeOptions) {
+	resources, err := discoverResources(clientset.Discovery(), false, "list", "delete")
+	if len(resources) == 0 {
+		if err != nil {
+			fmt.Printf("⚠️  Warning: Failed to discover storage provider resources: %v\n", err)
+		}
+		return
+	}
+
+	for _, provider := range opts.storageProviders().Providers {
+		steps := collectStorageTeardown(ctx, clientset, dynamicClient, namespace, provider, storageProviderResources(provider, resources), opts)
+		if len(steps) == 0 {
+			continue
+		}
+		fmt.Printf("\n🪜 %s TEARDOWN PLAN:\n", strings.ToUpper(provider.Name))
+		fmt.Printf("=========================\n")
+		planStorageProviderPreparation(ctx, clientset, dynamicClient, namespace, provider, resources, opts)
+		if provider.Operator == nil {
+			for i, step := range steps {
+				fmt.Printf("%d. WOULD REMOVE finalizers and force delete %s: %s\n", i+1, step.Resource.GVR.Resource, strings.Join(step.names(), ", "))
+			}
+			continue
+		}
+
+		operatorUp := true
+		if pods, err := readyOperatorPods(ctx, dynamicClient, *provider.Operator); err == nil {
+			operatorUp = len(pods) > 0
+			if operatorUp {
+				fmt.Printf("⚙️  Operator running: %s\n", strings.Join(pods, ", "))
+			} else {
+				fmt.Printf("⚠️  No ready operator pod (%s): finalizers would be removed right after each delete\n", provider.Operator.Selector)
+			}
+		}
+		for _, step := range steps {
+			for _, patch := range dataCleanupPatches(provider, step.Resource) {
+				if opts.DestroyStorageData {
+					fmt.Printf("🔥 WOULD SET %s on %s: %s\n", patch, step.Resource.GVR.Resource, strings.Join(step.names(), ", "))
+				} else {
+					fmt.Printf("💡 --destroy-storage-data would set %s on %s first\n", patch, step.Resource.GVR.Resource)
+				}
+			}
+		}
+		for i, step := range steps {
+			fmt.Printf("%d. WOULD DELETE %s: %s\n", i+1, step.Resource.GVR.Resource, strings.Join(step.names(), ", "))
+			if operatorUp && provider.WaitCondition() != storage.WaitForNone {
+				fmt.Printf("   ⏳ WOULD WAIT for the operator in rounds of %v while it makes progress, up to %v, then remove finalizers from what it stopped working on\n", provider.WaitTimeout(), operatorWaitRounds*provider.WaitTimeout())
+			} else if !operatorUp {
+				fmt.Printf("   🔧 WOULD REMOVE their finalizers\n")
+			}
+		}
+	}
+}
+
+// splitProviderFinalizers splits an object's finalizers into those the provider's controllers set and the others
+func splitProviderFinalizers(provider storage.Provider, obj *unstructured.Unstructured) (owned, other []string) {
+	for _, finalizer := range obj.GetFinalizers() {
+		if provider.OwnsFinalizer(finalizer) {
+			owned = append(owned, finalizer)
+		} else {
+			other = append(other, finalizer)
+		}
+	}
+	return owned, other
+}
+
+// diagnoseStorageProviders prints a diagnostics section for each provider with objects in the namespace: the
+// objects in teardown order, the status their controllers report, and the finalizers holding them
+func diagnoseStorageProviders(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, opts NukeOptions) {
+	resources, err := discoverResources(clientset.Discovery(), false, "list", "delete")
+	if len(resources) == 0 {
+		if err != nil {
+			fmt.Printf("⚠️  Warning: Failed to discover storage provider resources: %v\n", err)
+		}
+		return
+	}
+
+	for _, provider := range opts.storageProviders().Providers {
+		steps := collectStorageTeardown(ctx, clientset, dynamicClient, namespace, provider, storageProviderResources(provider, resources), opts)
+		if len(steps) == 0 {
+			continue
+		}
+		fmt.Printf("\n🔍 %s DIAGNOSTICS:\n", strings.ToUpper(provider.Name))
+		fmt.Printf("=========================\n")
+
+		var held []string
+		for _, step := range steps {
+			scope := step.Resource.GVR.GroupVersion().String()
+			if !step.Resource.APIResource.Namespaced {
+				scope += ", cluster-scoped"
+			}
+			fmt.Printf("📦 %s (%s): %d\n", step.Resource.GVR.Resource, scope, len(step.Objects))
+			for i := range step.Objects {
+				obj := &step.Objects[i]
+				terminating := ""
+				if ts := obj.GetDeletionTimestamp(); ts != nil {
+					terminating = fmt.Sprintf(" [terminating since %s]", ts.Format(time.RFC3339))
+				}
+				fmt.Printf("   - %s%s: %s\n", obj.GetName(), terminating, describeStorageStatus(obj))
+
+				owned, other := splitProviderFinalizers(provider, obj)
+				if len(owned) > 0 {
+					fmt.Printf("     🔒 %s finalizers: %s\n", provider.Name, strings.Join(owned, ", "))
+				}
+				if len(other) > 0 {
+					fmt.Printf("     🔒 Other finalizers: %s\n", strings.Join(other, ", "))
+				}
+				if terminating != "" && len(owned) > 0 {
+					held = append(held, step.Resource.GVR.Resource+"/"+obj.GetName())
+				}
+			}
+		}
+
+		if len(held) == 0 {
+			continue
+		}
+		fmt.Printf("⚠️  %d object(s) are terminating but still held by %s finalizers: %s\n", len(held), provider.Name, strings.Join(held, ", "))
+		if provider.Operator != nil {
+			fmt.Printf("💡 Its controllers remove them; check the pods matching %s\n", provider.Operator.Selector)
+		}
+	}
+}
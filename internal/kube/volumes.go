@@ -0,0 +1,265 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/codesenju/kubectl-nuke-go/pkg/storage"
+)
+
+// What to do with PersistentVolumes left behind by the namespace's deleted PVCs
+const (
+	ReleasedPVActionAsk    = ""
+	ReleasedPVActionDelete = "delete"
+	ReleasedPVActionRebind = "rebind"
+	ReleasedPVActionKeep   = "keep"
+)
+
+// PVCVolume is a PVC and the PersistentVolume bound to it, if any
+type PVCVolume struct {
+	PVC corev1.PersistentVolumeClaim
+	PV  *corev1.PersistentVolume
+}
+
+// ReclaimPolicy returns the bound PV's reclaim policy, or an empty string when the PVC isn't bound
+func (v PVCVolume) ReclaimPolicy() corev1.PersistentVolumeReclaimPolicy {
+	if v.PV == nil {
+		return ""
+	}
+	return v.PV.Spec.PersistentVolumeReclaimPolicy
+}
+
+// LosesData reports whether deleting the PVC deletes its volume's data
+func (v PVCVolume) LosesData() bool {
+	return v.ReclaimPolicy() == corev1.PersistentVolumeReclaimDelete
+}
+
+// StorageClass returns the PVC's StorageClass, falling back to the PV's
+func (v PVCVolume) StorageClass() string {
+	if v.PVC.Spec.StorageClassName != nil && *v.PVC.Spec.StorageClassName != "" {
+		return *v.PVC.Spec.StorageClassName
+	}
+	if v.PV != nil {
+		return v.PV.Spec.StorageClassName
+	}
+	return ""
+}
+
+// Capacity returns the bound PV's capacity, or the PVC's request when it isn't bound
+func (v PVCVolume) Capacity() string {
+	if v.PV != nil {
+		if capacity, ok := v.PV.Spec.Capacity[corev1.ResourceStorage]; ok {
+			return capacity.String()
+		}
+	}
+	if request, ok := v.PVC.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+		return request.String()
+	}
+	return "unknown"
+}
+
+// analyzePVCVolumes returns the namespace's PVCs that the pipeline would delete, with their bound PVs
+func analyzePVCVolumes(ctx context.Context, clientset kubernetes.Interface, namespace string, keep KeepFilter) ([]PVCVolume, error) {
+	if keep.keepsKind(persistentVolumeClaimsResource) {
+		return nil, nil
+	}
+
+	pvcs, err := clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PVCs: %w", err)
+	}
+
+	var volumes []PVCVolume
+	for _, pvc := range pvcs.Items {
+		if keep.keeps(persistentVolumeClaimsResource, pvc.Labels) {
+			continue
+		}
+		volume := PVCVolume{PVC: pvc}
+		if pvc.Spec.VolumeName != "" {
+			pv, err := clientset.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				return nil, fmt.Errorf("failed to get PersistentVolume %s: %w", pvc.Spec.VolumeName, err)
+			}
+			if err == nil {
+				volume.PV = pv
+			}
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes, nil
+}
+
+// displayPVCVolumes prints each PVC with its bound PV, reclaim policy, StorageClass and capacity
+func displayPVCVolumes(volumes []PVCVolume) {
+	fmt.Printf("\n💾 PERSISTENT VOLUMES:\n")
+	fmt.Printf("=====================\n")
+	for _, v := range volumes {
+		if v.PV == nil {
+			fmt.Printf("📦 PVC %s: not bound (StorageClass: %s, request: %s)\n", v.PVC.Name, v.StorageClass(), v.Capacity())
+			continue
+		}
+		icon := "🛡️ "
+		if v.LosesData() {
+			icon = "🔥"
+		}
+		fmt.Printf("%s PVC %s → PV %s: reclaim %s, StorageClass: %s, capacity: %s\n",
+			icon, v.PVC.Name, v.PV.Name, v.ReclaimPolicy(), v.StorageClass(), v.Capacity())
+	}
+}
+
+// checkVolumeSafety shows the volumes the pipeline would delete and refuses to go on when some of them have the
+// Delete reclaim policy, unless data loss is allowed. Kept PVCs only survive when the namespace does, so the keep
+// and kind filters are ignored unless only its contents are deleted.
+func checkVolumeSafety(ctx context.Context, clientset kubernetes.Interface, namespace string, opts NukeOptions) error {
+	var untouched KeepFilter
+	if opts.ContentsOnly {
+		untouched = opts.untouched()
+	}
+	volumes, err := analyzePVCVolumes(ctx, clientset, namespace, untouched)
+	if err != nil {
+		return err
+	}
+	if len(volumes) == 0 {
+		return nil
+	}
+	displayPVCVolumes(volumes)
+
+	var losing []string
+	for _, v := range volumes {
+		if v.LosesData() {
+			losing = append(losing, v.PVC.Name)
+		}
+	}
+	if len(losing) == 0 {
+		fmt.Printf("✅ No volume has the Delete reclaim policy; their data is retained\n")
+		return nil
+	}
+	if opts.AllowDataLoss {
+		fmt.Printf("🔥 --allow-data-loss: the data of %d volume(s) will be deleted: %s\n", len(losing), strings.Join(losing, ", "))
+		return nil
+	}
+
+	if opts.ContentsOnly {
+		fmt.Printf("💡 Rerun with --allow-data-loss, keep them with --keep-kinds pvc, or set the PVs' reclaim policy to Retain:\n")
+	} else {
+		fmt.Printf("💡 Rerun with --allow-data-loss, or set the PVs' reclaim policy to Retain:\n")
+	}
+	for _, v := range volumes {
+		if v.LosesData() {
+			fmt.Printf("   kubectl patch pv %s -p '{\"spec\":{\"persistentVolumeReclaimPolicy\":\"Retain\"}}'\n", v.PV.Name)
+		}
+	}
+	return fmt.Errorf("refusing to delete %d PVC(s) whose volumes have the Delete reclaim policy: %s", len(losing), strings.Join(losing, ", "))
+}
+
+// findReleasedPVs returns the PersistentVolumes still claimed by PVCs of the namespace that no longer exist. Volumes
+// with the Delete reclaim policy are left to their provisioner unless it has failed to delete them.
+func findReleasedPVs(ctx context.Context, clientset kubernetes.Interface, namespace string) ([]corev1.PersistentVolume, error) {
+	pvs, err := clientset.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PersistentVolumes: %w", err)
+	}
+
+	var released []corev1.PersistentVolume
+	for _, pv := range pvs.Items {
+		claim := pv.Spec.ClaimRef
+		if claim == nil || claim.Namespace != namespace || claim.UID == "" {
+			continue
+		}
+		if pv.Spec.PersistentVolumeReclaimPolicy == corev1.PersistentVolumeReclaimDelete && pv.Status.Phase != corev1.VolumeFailed {
+			continue
+		}
+		pvc, err := clientset.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, claim.Name, metav1.GetOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get PVC %s: %w", claim.Name, err)
+		}
+		if err == nil && pvc.UID == claim.UID {
+			continue // Still claimed
+		}
+		released = append(released, pv)
+	}
+	return released, nil
+}
+
+// handleReleasedPVs offers to clean up or re-bind the PersistentVolumes left behind by the namespace's deleted
+// PVCs, so Retain volumes don't leak their disks unnoticed
+func handleReleasedPVs(ctx context.Context, clientset kubernetes.Interface, namespace string, opts NukeOptions) error {
+	released, err := findReleasedPVs(ctx, clientset, namespace)
+	if err != nil || len(released) == 0 {
+		return err
+	}
+
+	fmt.Printf("\n🔍 Found %d PersistentVolume(s) released by deleted PVCs of namespace %s:\n", len(released), namespace)
+	for _, pv := range released {
+		fmt.Printf("  - %s [%s] reclaim %s, was %s/%s\n", pv.Name, pv.Status.Phase, pv.Spec.PersistentVolumeReclaimPolicy, namespace, pv.Spec.ClaimRef.Name)
+	}
+
+	for _, pv := range released {
+		switch chooseReleasedPVAction(pv, opts) {
+		case ReleasedPVActionDelete:
+			if err := deleteReleasedPV(ctx, clientset, pv); err != nil {
+				fmt.Printf("⚠️  Failed to clean up PersistentVolume %s: %v\n", pv.Name, err)
+			}
+		case ReleasedPVActionRebind:
+			patch := []byte(`{"spec":{"claimRef":{"uid":null,"resourceVersion":null}}}`)
+			if _, err := clientset.CoreV1().PersistentVolumes().Patch(ctx, pv.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+				fmt.Printf("⚠️  Failed to release the claim on PersistentVolume %s: %v\n", pv.Name, err)
+				continue
+			}
+			fmt.Printf("🔗 PersistentVolume %s is available again; recreate PVC %s/%s to bind it\n", pv.Name, namespace, pv.Spec.ClaimRef.Name)
+		default:
+			fmt.Printf("🛡️  Keeping PersistentVolume %s\n", pv.Name)
+		}
+	}
+	return nil
+}
+
+// chooseReleasedPVAction resolves the action for one released PV, prompting when asked to
+func chooseReleasedPVAction(pv corev1.PersistentVolume, opts NukeOptions) string {
+	if opts.ReleasedPVs != ReleasedPVActionAsk {
+		return opts.ReleasedPVs
+	}
+	if opts.Prompt == nil {
+		return ReleasedPVActionKeep
+	}
+	if opts.Prompt(fmt.Sprintf("Delete PersistentVolume %s and its backing storage? (y/N): ", pv.Name)) {
+		return ReleasedPVActionDelete
+	}
+	if opts.Prompt(fmt.Sprintf("Make PersistentVolume %s available to re-bind to a recreated PVC %s? (y/N): ", pv.Name, pv.Spec.ClaimRef.Name)) {
+		return ReleasedPVActionRebind
+	}
+	return ReleasedPVActionKeep
+}
+
+// deleteReleasedPV removes a released PV with its backing storage. A dynamically provisioned PV is switched to the
+// Delete reclaim policy so its provisioner deletes the disk along with the PV; a static PV is deleted, and its
+// disk has to be removed by hand.
+func deleteReleasedPV(ctx context.Context, clientset kubernetes.Interface, pv corev1.PersistentVolume) error {
+	provisioner := pv.Annotations[storage.AnnotationProvisionedBy]
+	if provisioner != "" && pv.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimDelete {
+		patch := []byte(`{"spec":{"persistentVolumeReclaimPolicy":"Delete"}}`)
+		if _, err := clientset.CoreV1().PersistentVolumes().Patch(ctx, pv.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return err
+		}
+		fmt.Printf("🗑️  PersistentVolume %s set to reclaim Delete; %s deletes it and its disk\n", pv.Name, provisioner)
+		return nil
+	}
+
+	if err := clientset.CoreV1().PersistentVolumes().Delete(ctx, pv.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	fmt.Printf("🗑️  Deleted PersistentVolume %s\n", pv.Name)
+	if provisioner == "" {
+		fmt.Printf("⚠️  %s was statically provisioned; delete its backing disk by hand\n", pv.Name)
+	} else {
+		fmt.Printf("⚠️  %s failed to delete %s; check that its backing disk is gone\n", provisioner, pv.Name)
+	}
+	return nil
+}
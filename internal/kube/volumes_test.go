@@ -0,0 +1,111 @@
+package kube
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/codesenju/kubectl-nuke-go/pkg/storage"
+)
+
+func newBoundVolume(namespace, pvcName, pvName string, policy corev1.PersistentVolumeReclaimPolicy) (*corev1.PersistentVolumeClaim, *corev1.PersistentVolume) {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: pvcName, UID: types.UID(pvcName + "-uid")},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: pvName},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: pvName},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeReclaimPolicy: policy,
+			StorageClassName:              "standard",
+			Capacity:                      corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
+			ClaimRef:                      &corev1.ObjectReference{Namespace: namespace, Name: pvcName, UID: pvc.UID},
+		},
+	}
+	return pvc, pv
+}
+
+func TestCheckVolumeSafety(t *testing.T) {
+	dataPVC, dataPV := newBoundVolume("shop", "data", "pv-data", corev1.PersistentVolumeReclaimDelete)
+	logsPVC, logsPV := newBoundVolume("shop", "logs", "pv-logs", corev1.PersistentVolumeReclaimRetain)
+	clientset := k8sfake.NewSimpleClientset(dataPVC, dataPV, logsPVC, logsPV)
+	ctx := context.TODO()
+
+	volumes, err := analyzePVCVolumes(ctx, clientset, "shop", KeepFilter{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(volumes) != 2 || volumes[0].Capacity() != "10Gi" || volumes[0].StorageClass() != "standard" {
+		t.Fatalf("expected 2 volumes of 10Gi in standard, got %+v", volumes)
+	}
+
+	err = checkVolumeSafety(ctx, clientset, "shop", NukeOptions{})
+	if err == nil || !strings.Contains(err.Error(), "data") || strings.Contains(err.Error(), "logs") {
+		t.Errorf("expected only the Delete-policy PVC data to be refused, got %v", err)
+	}
+	if err := checkVolumeSafety(ctx, clientset, "shop", NukeOptions{AllowDataLoss: true}); err != nil {
+		t.Errorf("expected --allow-data-loss to permit the deletion, got %v", err)
+	}
+
+	dataPVC.Labels = map[string]string{"keep": "true"}
+	clientset = k8sfake.NewSimpleClientset(dataPVC, dataPV, logsPVC, logsPV)
+	keep := KeepFilter{Selector: labels.SelectorFromSet(labels.Set{"keep": "true"})}
+	if err := checkVolumeSafety(ctx, clientset, "shop", NukeOptions{Keep: keep, ContentsOnly: true}); err != nil {
+		t.Errorf("expected a kept PVC not to need --allow-data-loss, got %v", err)
+	}
+	if err := checkVolumeSafety(ctx, clientset, "shop", NukeOptions{Keep: keep}); err == nil {
+		t.Errorf("expected a kept PVC to be refused when the namespace itself is deleted")
+	}
+}
+
+func TestHandleReleasedPVs(t *testing.T) {
+	_, provisioned := newBoundVolume("shop", "data", "pv-data", corev1.PersistentVolumeReclaimRetain)
+	provisioned.Annotations = map[string]string{storage.AnnotationProvisionedBy: "ebs.csi.aws.com"}
+	_, static := newBoundVolume("shop", "logs", "pv-logs", corev1.PersistentVolumeReclaimRetain)
+	_, deleting := newBoundVolume("shop", "tmp", "pv-tmp", corev1.PersistentVolumeReclaimDelete)
+	livePVC, live := newBoundVolume("shop", "live", "pv-live", corev1.PersistentVolumeReclaimRetain)
+	_, other := newBoundVolume("other", "data", "pv-other", corev1.PersistentVolumeReclaimRetain)
+	clientset := k8sfake.NewSimpleClientset(provisioned, static, deleting, livePVC, live, other)
+	ctx := context.TODO()
+
+	released, err := findReleasedPVs(ctx, clientset, "shop")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(released) != 2 || released[0].Name != "pv-data" || released[1].Name != "pv-logs" {
+		t.Fatalf("expected pv-data and pv-logs to be released, got %v", released)
+	}
+
+	if err := handleReleasedPVs(ctx, clientset, "shop", NukeOptions{ReleasedPVs: ReleasedPVActionDelete}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	pv, err := clientset.CoreV1().PersistentVolumes().Get(ctx, "pv-data", metav1.GetOptions{})
+	if err != nil || pv.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimDelete {
+		t.Errorf("expected the provisioned PV to be handed to its provisioner for deletion, got %v, %v", pv, err)
+	}
+	if _, err := clientset.CoreV1().PersistentVolumes().Get(ctx, "pv-logs", metav1.GetOptions{}); err == nil {
+		t.Errorf("expected the static PV to be deleted")
+	}
+
+	prompts := 0
+	answers := NukeOptions{Prompt: func(message string) bool {
+		prompts++
+		return strings.HasPrefix(message, "Make")
+	}}
+	clientset = k8sfake.NewSimpleClientset(static)
+	if err := handleReleasedPVs(ctx, clientset, "shop", answers); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	pv, _ = clientset.CoreV1().PersistentVolumes().Get(ctx, "pv-logs", metav1.GetOptions{})
+	if prompts != 2 || pv.Spec.ClaimRef == nil || pv.Spec.ClaimRef.UID != "" || pv.Spec.ClaimRef.Name != "logs" {
+		t.Errorf("expected the PV to be re-bindable to logs after 2 prompts, got %d prompts and claimRef %+v", prompts, pv.Spec.ClaimRef)
+	}
+}
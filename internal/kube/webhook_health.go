@@ -0,0 +1,304 @@
+package kube
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/codesenju/kubectl-nuke-go/pkg/storage"
+)
+
+// Webhook configuration kinds
+const (
+	validatingWebhook = "validating"
+	mutatingWebhook   = "mutating"
+)
+
+// DefaultWebhookProbeTimeout bounds a TLS probe when the webhook's own timeout is longer
+const DefaultWebhookProbeTimeout = 3 * time.Second
+
+// defaultWebhookTimeoutSeconds is the API server's timeout for a webhook without timeoutSeconds
+const defaultWebhookTimeoutSeconds = 10
+
+// WebhookOptions controls how admission webhooks are checked before deletion
+type WebhookOptions struct {
+	// Probe sends a TLS handshake to each webhook's ready endpoints or URL, verified against its caBundle
+	Probe bool
+	// ProbeTimeout bounds each handshake; the webhook's timeoutSeconds caps it further
+	ProbeTimeout time.Duration
+	// StorageProviders names the storage provider behind a webhook in the report
+	StorageProviders *storage.Registry
+}
+
+// probeTimeout returns the handshake timeout for a webhook
+func (o WebhookOptions) probeTimeout(w admissionWebhook) time.Duration {
+	timeout := o.ProbeTimeout
+	if timeout <= 0 {
+		timeout = DefaultWebhookProbeTimeout
+	}
+	if w.timeout() < timeout {
+		return w.timeout()
+	}
+	return timeout
+}
+
+// admissionWebhook is a validating or mutating webhook with the configuration it belongs to
+type admissionWebhook struct {
+	Kind              string
+	Config            string
+	Name              string
+	ClientConfig      admissionregistrationv1.WebhookClientConfig
+	Rules             []admissionregistrationv1.RuleWithOperations
+	MatchPolicy       *admissionregistrationv1.MatchPolicyType
+	FailurePolicy     *admissionregistrationv1.FailurePolicyType
+	TimeoutSeconds    *int32
+	NamespaceSelector *metav1.LabelSelector
+	ObjectSelector    *metav1.LabelSelector
+	MatchConditions   []admissionregistrationv1.MatchCondition
+}
+
+// failurePolicy returns the webhook's failure policy, which defaults to Fail
+func (w admissionWebhook) failurePolicy() admissionregistrationv1.FailurePolicyType {
+	if w.FailurePolicy == nil {
+		return admissionregistrationv1.Fail
+	}
+	return *w.FailurePolicy
+}
+
+// failsClosed reports whether the API server rejects requests when the webhook can't be reached
+func (w admissionWebhook) failsClosed() bool {
+	return w.failurePolicy() == admissionregistrationv1.Fail
+}
+
+// timeout returns how long the API server waits for the webhook
+func (w admissionWebhook) timeout() time.Duration {
+	if w.TimeoutSeconds == nil {
+		return defaultWebhookTimeoutSeconds * time.Second
+	}
+	return time.Duration(*w.TimeoutSeconds) * time.Second
+}
+
+// target describes where the API server sends the webhook's requests
+func (w admissionWebhook) target() string {
+	if s := w.ClientConfig.Service; s != nil {
+		port := int32(443)
+		if s.Port != nil {
+			port = *s.Port
+		}
+		return fmt.Sprintf("service %s/%s:%d", s.Namespace, s.Name, port)
+	}
+	if w.ClientConfig.URL != nil {
+		return *w.ClientConfig.URL
+	}
+	return "no client config"
+}
+
+// listAdmissionWebhooks returns every validating and mutating webhook in the cluster
+func listAdmissionWebhooks(ctx context.Context, clientset kubernetes.Interface) ([]admissionWebhook, error) {
+	validating, err := clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook configurations: %w", err)
+	}
+	mutating, err := clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mutating webhook configurations: %w", err)
+	}
+
+	var webhooks []admissionWebhook
+	for _, config := range validating.Items {
+		for _, w := range config.Webhooks {
+			webhooks = append(webhooks, admissionWebhook{
+				Kind: validatingWebhook, Config: config.Name, Name: w.Name, ClientConfig: w.ClientConfig, Rules: w.Rules,
+				MatchPolicy: w.MatchPolicy, FailurePolicy: w.FailurePolicy, TimeoutSeconds: w.TimeoutSeconds, NamespaceSelector: w.NamespaceSelector,
+				ObjectSelector: w.ObjectSelector, MatchConditions: w.MatchConditions,
+			})
+		}
+	}
+	for _, config := range mutating.Items {
+		for _, w := range config.Webhooks {
+			webhooks = append(webhooks, admissionWebhook{
+				Kind: mutatingWebhook, Config: config.Name, Name: w.Name, ClientConfig: w.ClientConfig, Rules: w.Rules,
+				MatchPolicy: w.MatchPolicy, FailurePolicy: w.FailurePolicy, TimeoutSeconds: w.TimeoutSeconds, NamespaceSelector: w.NamespaceSelector,
+				ObjectSelector: w.ObjectSelector, MatchConditions: w.MatchConditions,
+			})
+		}
+	}
+	return webhooks, nil
+}
+
+// webhookHealth is what checking a webhook's backend found
+type webhookHealth struct {
+	// Endpoints are the ready addresses of a service-backed webhook, as host:port
+	Endpoints []string
+	// Problem is why calls to the webhook would fail; empty when it looks reachable
+	Problem string
+	// Unreachable is why the TLS probe couldn't connect from here. The API server may still reach the webhook
+	// from inside the cluster, so it isn't a Problem.
+	Unreachable string
+}
+
+// checkWebhookHealth checks that a service-backed webhook's Service exists outside a terminating namespace and
+// has ready endpoints, then optionally probes the endpoints or URL with a TLS handshake
+func checkWebhookHealth(ctx context.Context, clientset kubernetes.Interface, w admissionWebhook, opts WebhookOptions) webhookHealth {
+	var health webhookHealth
+	var addresses []string
+	serverName := ""
+
+	if s := w.ClientConfig.Service; s != nil {
+		serverName = fmt.Sprintf("%s.%s.svc", s.Name, s.Namespace)
+		svc, err := clientset.CoreV1().Services(s.Namespace).Get(ctx, s.Name, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				health.Problem = fmt.Sprintf("service %s/%s not found", s.Namespace, s.Name)
+			} else {
+				health.Problem = fmt.Sprintf("failed to get service %s/%s: %v", s.Namespace, s.Name, err)
+			}
+			return health
+		}
+		if ns, err := clientset.CoreV1().Namespaces().Get(ctx, s.Namespace, metav1.GetOptions{}); err == nil && ns.Status.Phase == corev1.NamespaceTerminating {
+			health.Problem = fmt.Sprintf("namespace %s is terminating", s.Namespace)
+			return health
+		}
+		if svc.Spec.Type == corev1.ServiceTypeExternalName {
+			addresses = []string{net.JoinHostPort(svc.Spec.ExternalName, strconv.Itoa(int(servicePort(s))))}
+		} else {
+			health.Endpoints, err = readyServiceEndpoints(ctx, clientset, svc, servicePort(s))
+			if err != nil {
+				health.Problem = fmt.Sprintf("failed to list endpoints of service %s/%s: %v", s.Namespace, s.Name, err)
+				return health
+			}
+			if len(health.Endpoints) == 0 {
+				health.Problem = fmt.Sprintf("service %s/%s has no ready endpoints", s.Namespace, s.Name)
+				return health
+			}
+			addresses = health.Endpoints
+		}
+	} else if w.ClientConfig.URL != nil {
+		u, err := url.Parse(*w.ClientConfig.URL)
+		if err != nil {
+			health.Problem = fmt.Sprintf("invalid URL %s: %v", *w.ClientConfig.URL, err)
+			return health
+		}
+		serverName = u.Hostname()
+		port := u.Port()
+		if port == "" {
+			port = "443"
+		}
+		addresses = []string{net.JoinHostPort(u.Hostname(), port)}
+	}
+
+	if opts.Probe && len(addresses) > 0 {
+		connected, err := probeWebhookTLS(addresses, serverName, w.ClientConfig.CABundle, opts.probeTimeout(w))
+		if err != nil && connected {
+			health.Problem = fmt.Sprintf("TLS probe failed: %v", err)
+		} else if err != nil {
+			health.Unreachable = err.Error()
+		}
+	}
+	return health
+}
+
+// servicePort returns the Service port a webhook calls, which defaults to 443
+func servicePort(s *admissionregistrationv1.ServiceReference) int32 {
+	if s.Port == nil {
+		return 443
+	}
+	return *s.Port
+}
+
+// readyServiceEndpoints returns the ready addresses, as host:port, that the Service's EndpointSlices route its
+// port to
+func readyServiceEndpoints(ctx context.Context, clientset kubernetes.Interface, svc *corev1.Service, port int32) ([]string, error) {
+	portName := ""
+	for _, p := range svc.Spec.Ports {
+		if p.Port == port {
+			portName = p.Name
+		}
+	}
+
+	slices, err := clientset.DiscoveryV1().EndpointSlices(svc.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: discoveryv1.LabelServiceName + "=" + svc.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ready []string
+	for _, slice := range slices.Items {
+		var target *int32
+		for _, p := range slice.Ports {
+			if p.Name != nil && *p.Name == portName {
+				target = p.Port
+			}
+		}
+		if target == nil {
+			continue
+		}
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			for _, address := range endpoint.Addresses {
+				ready = append(ready, net.JoinHostPort(address, strconv.Itoa(int(*target))))
+			}
+		}
+	}
+	return ready, nil
+}
+
+// probeWebhookTLS completes a TLS handshake with the first reachable address, verifying its certificate for
+// serverName against the caBundle, or the system roots when the bundle is empty. It reports whether any address
+// accepted a connection, since a webhook that can't be dialed from here may still be reachable in-cluster; an
+// invalid caBundle counts as connected, as it fails every handshake.
+func probeWebhookTLS(addresses []string, serverName string, caBundle []byte, timeout time.Duration) (bool, error) {
+	config := &tls.Config{ServerName: serverName}
+	if len(caBundle) > 0 {
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(caBundle) {
+			return true, fmt.Errorf("caBundle holds no PEM certificates")
+		}
+	}
+
+	var handshakeFailures, dialFailures []string
+	for _, address := range addresses {
+		conn, err := net.DialTimeout("tcp", address, timeout)
+		if err != nil {
+			dialFailures = append(dialFailures, err.Error())
+			continue
+		}
+		tlsConn := tls.Client(conn, config)
+		tlsConn.SetDeadline(time.Now().Add(timeout))
+		err = tlsConn.Handshake()
+		tlsConn.Close()
+		if err == nil {
+			return true, nil
+		}
+		handshakeFailures = append(handshakeFailures, err.Error())
+	}
+	if len(handshakeFailures) > 0 {
+		return true, fmt.Errorf("%s", strings.Join(handshakeFailures, "; "))
+	}
+	return false, fmt.Errorf("%s", strings.Join(dialFailures, "; "))
+}
+
+// describeWebhook summarises a webhook's target, health, failure policy and timeout
+func describeWebhook(w admissionWebhook, health webhookHealth) string {
+	backend := w.target()
+	if w.ClientConfig.Service != nil && health.Problem == "" {
+		backend += fmt.Sprintf(" (%d ready endpoint(s))", len(health.Endpoints))
+	}
+	return fmt.Sprintf("%s webhook %s/%s → %s, failurePolicy %s, timeout %v", w.Kind, w.Config, w.Name, backend, w.failurePolicy(), w.timeout())
+}
@@ -0,0 +1,408 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// namespacesResource is the Namespace type, as deleted and finalized at the end of the force pipeline
+var namespacesResource = discoveredResource{
+	GVR:         namespacesGVR,
+	APIResource: metav1.APIResource{Name: "namespaces", Kind: "Namespace", Verbs: metav1.Verbs{"delete", "update"}},
+}
+
+// webhookCall is one kind of API request the force pipeline makes
+type webhookCall struct {
+	Operation   admissionregistrationv1.OperationType
+	Resource    discoveredResource
+	Subresource string
+	// Labels are those of the objects the call is made on, for objectSelectors
+	Labels []labels.Set
+	// Namespace is the namespace of the objects, or of the Namespace itself; empty for other cluster-scoped objects,
+	// which namespaceSelectors don't apply to
+	Namespace string
+	// NamespaceLabels are the labels of that namespace, for namespaceSelectors
+	NamespaceLabels labels.Set
+}
+
+// String describes the call as "OPERATION resource.group[/subresource]"
+func (c webhookCall) String() string {
+	resource := c.Resource.GVR.Resource
+	if c.Resource.GVR.Group != "" {
+		resource += "." + c.Resource.GVR.Group
+	}
+	if c.Subresource != "" {
+		resource += "/" + c.Subresource
+	}
+	return fmt.Sprintf("%s %s", c.Operation, resource)
+}
+
+// WebhookScope is the set of requests the force pipeline is about to make: deletes of the namespace's objects
+// and the updates that strip their finalizers, the cluster-scoped snapshot contents, PersistentVolumes and storage
+// provider objects that go with them, the workloads scaled down elsewhere to detach volumes, and deleting and
+// finalizing the namespace itself. Only webhooks intercepting one of them can block the pipeline.
+type WebhookScope struct {
+	calls []webhookCall
+}
+
+// add plans calls of the operations on a resource's objects with the labels, in the namespace, or cluster-scoped
+// when it is nil
+func (s *WebhookScope) add(res discoveredResource, ns *corev1.Namespace, objectLabels []labels.Set, operations ...admissionregistrationv1.OperationType) {
+	if len(objectLabels) == 0 {
+		return
+	}
+	for _, operation := range operations {
+		call := webhookCall{Operation: operation, Resource: res, Labels: objectLabels}
+		if ns != nil {
+			call.Namespace, call.NamespaceLabels = ns.Name, labels.Set(ns.Labels)
+		}
+		s.calls = append(s.calls, call)
+	}
+}
+
+// findDiscoveredResource returns the discovered resource of a group, if served
+func findDiscoveredResource(resources []discoveredResource, group, resource string) (discoveredResource, bool) {
+	for _, res := range resources {
+		if res.GVR.Group == group && res.GVR.Resource == resource {
+			return res, true
+		}
+	}
+	return discoveredResource{}, false
+}
+
+// NewWebhookScope lists the namespace's objects by resource type, leaving out kept ones, and plans a DELETE and
+// an UPDATE of each type that has any. It adds the same for the VolumeSnapshotContents, PersistentVolumes and
+// cluster-scoped storage provider objects going with them, and, with --detach-volumes, the scale-downs of the
+// workloads using the providers' attached volumes in any namespace. The namespace's own DELETE, UPDATE and
+// finalize are added unless only its contents are deleted.
+func NewWebhookScope(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, opts NukeOptions) (*WebhookScope, error) {
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+	resources, err := discoverResources(clientset.Discovery(), false, "list", "delete")
+	if len(resources) == 0 {
+		return nil, err
+	}
+
+	scope := &WebhookScope{}
+	untouched := opts.untouched()
+	for _, res := range resources {
+		if !res.APIResource.Namespaced || untouched.keepsKind(res) {
+			continue
+		}
+		list, err := dynamicClient.Resource(res.GVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			continue
+		}
+		var objectLabels []labels.Set
+		for _, item := range list.Items {
+			if !untouched.keeps(res, item.GetLabels()) {
+				objectLabels = append(objectLabels, labels.Set(item.GetLabels()))
+			}
+		}
+		scope.add(res, ns, objectLabels, admissionregistrationv1.Delete, admissionregistrationv1.Update)
+	}
+
+	scope.addClusterScopedCalls(ctx, clientset, dynamicClient, namespace, resources, opts)
+	if opts.DetachVolumes {
+		scope.addDetachCalls(ctx, clientset, dynamicClient, namespace, resources, opts)
+	}
+
+	if !opts.ContentsOnly {
+		namespaceLabels := []labels.Set{labels.Set(ns.Labels)}
+		scope.add(namespacesResource, ns, namespaceLabels, admissionregistrationv1.Delete, admissionregistrationv1.Update)
+		scope.calls = append(scope.calls, webhookCall{Operation: admissionregistrationv1.Update, Resource: namespacesResource, Subresource: "finalize",
+			Labels: namespaceLabels, Namespace: ns.Name, NamespaceLabels: labels.Set(ns.Labels)})
+	}
+	return scope, nil
+}
+
+// addClusterScopedCalls plans the deletes and updates of the cluster-scoped objects the pipeline changes for the
+// namespace: the VolumeSnapshotContents of its snapshots, the PVs of its PVCs, and the storage providers' objects
+func (s *WebhookScope) addClusterScopedCalls(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, resources []discoveredResource, opts NukeOptions) {
+	untouched := opts.untouched()
+	if contentsRes, ok := findDiscoveredResource(resources, volumeSnapshotContentsGVR.Group, volumeSnapshotContentsGVR.Resource); ok {
+		if _, served := findDiscoveredResource(resources, volumeSnapshotsResource.GVR.Group, volumeSnapshotsResource.GVR.Resource); served {
+			snapshots, _ := findVolumeSnapshots(ctx, dynamicClient, namespace, untouched)
+			var contentLabels []labels.Set
+			for _, snapshot := range snapshots {
+				if snapshot.Content != nil {
+					contentLabels = append(contentLabels, labels.Set(snapshot.Content.GetLabels()))
+				}
+			}
+			s.add(contentsRes, nil, contentLabels, admissionregistrationv1.Delete, admissionregistrationv1.Update)
+		}
+	}
+
+	if pvRes, ok := findDiscoveredResource(resources, "", "persistentvolumes"); ok {
+		volumes, _ := analyzePVCVolumes(ctx, clientset, namespace, untouched)
+		var pvLabels []labels.Set
+		for _, v := range volumes {
+			if v.PV != nil {
+				pvLabels = append(pvLabels, labels.Set(v.PV.Labels))
+			}
+		}
+		s.add(pvRes, nil, pvLabels, admissionregistrationv1.Delete, admissionregistrationv1.Update)
+	}
+
+	for _, provider := range opts.storageProviders().Providers {
+		for _, step := range collectStorageTeardown(ctx, clientset, dynamicClient, namespace, provider, storageProviderResources(provider, resources), opts) {
+			if step.Resource.APIResource.Namespaced {
+				continue
+			}
+			var objectLabels []labels.Set
+			for _, obj := range step.Objects {
+				objectLabels = append(objectLabels, labels.Set(obj.GetLabels()))
+			}
+			s.add(step.Resource, nil, objectLabels, admissionregistrationv1.Delete, admissionregistrationv1.Update)
+		}
+	}
+}
+
+// addDetachCalls plans the scale-downs of the Deployments and StatefulSets, and the deletes of the bare pods,
+// using the storage providers' attached volumes, in whichever namespace they run
+func (s *WebhookScope) addDetachCalls(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, resources []discoveredResource, opts NukeOptions) {
+	namespaces := map[string]*corev1.Namespace{}
+	for _, provider := range opts.storageProviders().Providers {
+		if provider.Detach == nil {
+			continue
+		}
+		volumes, err := findProviderVolumes(ctx, clientset, dynamicClient, namespace, provider, resources)
+		if err != nil {
+			continue
+		}
+		for _, v := range attachedProviderVolumes(volumes) {
+			for _, c := range v.Consumers {
+				ns, ok := namespaces[c.Namespace]
+				if !ok {
+					if ns, err = clientset.CoreV1().Namespaces().Get(ctx, c.Namespace, metav1.GetOptions{}); err != nil {
+						ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: c.Namespace}}
+					}
+					namespaces[c.Namespace] = ns
+				}
+
+				var objectLabels map[string]string
+				var res discoveredResource
+				operation := admissionregistrationv1.Update
+				switch c.Kind {
+				case "Deployment":
+					res, ok = findDiscoveredResource(resources, "apps", "deployments")
+					if obj, err := clientset.AppsV1().Deployments(c.Namespace).Get(ctx, c.Name, metav1.GetOptions{}); err == nil {
+						objectLabels = obj.Labels
+					}
+				case "StatefulSet":
+					res, ok = findDiscoveredResource(resources, "apps", "statefulsets")
+					if obj, err := clientset.AppsV1().StatefulSets(c.Namespace).Get(ctx, c.Name, metav1.GetOptions{}); err == nil {
+						objectLabels = obj.Labels
+					}
+				case "Pod":
+					res, ok = findDiscoveredResource(resources, "", "pods")
+					operation = admissionregistrationv1.Delete
+					if obj, err := clientset.CoreV1().Pods(c.Namespace).Get(ctx, c.Name, metav1.GetOptions{}); err == nil {
+						objectLabels = obj.Labels
+					}
+				default:
+					ok = false
+				}
+				if ok {
+					s.add(res, ns, []labels.Set{labels.Set(objectLabels)}, operation)
+				}
+			}
+		}
+	}
+}
+
+// intercepts returns the first planned call the webhook would be sent, following the API server's matching: a
+// rule must match the call, then the namespaceSelector, objectSelector and matchConditions must all admit it.
+// The namespaceSelector doesn't apply to cluster-scoped objects other than Namespaces.
+func (s *WebhookScope) intercepts(w admissionWebhook) (webhookCall, bool) {
+	for _, call := range s.calls {
+		if !webhookRulesMatch(w, call) {
+			continue
+		}
+		if call.Namespace != "" && !selectorMatches(w.NamespaceSelector, []labels.Set{call.NamespaceLabels}) {
+			continue
+		}
+		if !selectorMatches(w.ObjectSelector, call.Labels) {
+			continue
+		}
+		if matchConditionsExclude(w.MatchConditions, call) {
+			continue
+		}
+		return call, true
+	}
+	return webhookCall{}, false
+}
+
+// webhookRulesMatch reports whether any of the webhook's rules matches the call. Unless matchPolicy is Exact,
+// requests for any version of a resource are sent, so versions are only compared for Exact webhooks.
+func webhookRulesMatch(w admissionWebhook, call webhookCall) bool {
+	exact := w.MatchPolicy != nil && *w.MatchPolicy == admissionregistrationv1.Exact
+	for _, rule := range w.Rules {
+		if !containsOperation(rule.Operations, call.Operation) || !matchesWildcard(rule.APIGroups, call.Resource.GVR.Group) {
+			continue
+		}
+		if exact && !matchesWildcard(rule.APIVersions, call.Resource.GVR.Version) {
+			continue
+		}
+		if !ruleScopeMatches(rule.Scope, call.Resource.APIResource.Namespaced) {
+			continue
+		}
+		for _, pattern := range rule.Resources {
+			if ruleResourceMatches(pattern, call.Resource.GVR.Resource, call.Subresource) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsOperation reports whether the rule's operations include the operation or "*"
+func containsOperation(operations []admissionregistrationv1.OperationType, operation admissionregistrationv1.OperationType) bool {
+	for _, op := range operations {
+		if op == admissionregistrationv1.OperationAll || op == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWildcard reports whether the values include the value or "*"
+func matchesWildcard(values []string, value string) bool {
+	for _, v := range values {
+		if v == "*" || v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleScopeMatches reports whether a rule's scope covers a namespaced or cluster-scoped resource
+func ruleScopeMatches(scope *admissionregistrationv1.ScopeType, namespaced bool) bool {
+	if scope == nil || *scope == admissionregistrationv1.AllScopes {
+		return true
+	}
+	return (*scope == admissionregistrationv1.NamespacedScope) == namespaced
+}
+
+// ruleResourceMatches reports whether a rule resource matches a resource and subresource. "pods" names the
+// resource alone, "pods/*" its subresources, "*/status" a subresource of any resource, and "*/*" everything.
+func ruleResourceMatches(pattern, resource, subresource string) bool {
+	if pattern == "*/*" {
+		return true
+	}
+	patternResource, patternSubresource, hasSubresource := strings.Cut(pattern, "/")
+	if patternResource != "*" && patternResource != resource {
+		return false
+	}
+	if !hasSubresource {
+		return subresource == ""
+	}
+	return subresource != "" && (patternSubresource == "*" || patternSubresource == subresource)
+}
+
+// selectorMatches reports whether a webhook's label selector admits any of the label sets. A missing or empty
+// selector admits everything; an invalid one is treated as admitting everything, to stay on the safe side.
+func selectorMatches(selector *metav1.LabelSelector, sets []labels.Set) bool {
+	if selector == nil {
+		return true
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil || s.Empty() {
+		return true
+	}
+	for _, set := range sets {
+		if s.Matches(set) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchConditionPattern matches the simple CEL comparisons matchConditionsExclude can evaluate, such as
+// request.operation != 'DELETE' or request.resource.group in ['apps', ”]
+var matchConditionPattern = regexp.MustCompile(`^\s*(request\.[A-Za-z.]+)\s*(==|!=|in)\s*(.+?)\s*$`)
+
+// matchConditionsExclude reports whether one of the webhook's matchConditions is known to be false for the call.
+// Conditions comparing a request field with a string or list of strings are evaluated; any other CEL expression
+// is assumed to be true, so the webhook stays in scope.
+func matchConditionsExclude(conditions []admissionregistrationv1.MatchCondition, call webhookCall) bool {
+	fields := map[string]string{
+		"request.operation":         string(call.Operation),
+		"request.resource.group":    call.Resource.GVR.Group,
+		"request.resource.version":  call.Resource.GVR.Version,
+		"request.resource.resource": call.Resource.GVR.Resource,
+		"request.subResource":       call.Subresource,
+		"request.namespace":         call.Namespace,
+	}
+	if !call.Resource.APIResource.Namespaced {
+		// The namespace of a request for the Namespace itself isn't documented, so it isn't assumed
+		delete(fields, "request.namespace")
+	}
+
+	for _, condition := range conditions {
+		m := matchConditionPattern.FindStringSubmatch(condition.Expression)
+		if m == nil {
+			continue
+		}
+		value, known := fields[m[1]]
+		values, ok := parseCELStrings(m[3], m[2] == "in")
+		if !known || !ok {
+			continue
+		}
+		matched := false
+		for _, v := range values {
+			matched = matched || v == value
+		}
+		if matched == (m[2] == "!=") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCELStrings parses a quoted CEL string, or a list of them when list is set
+func parseCELStrings(expr string, list bool) ([]string, bool) {
+	if list {
+		if !strings.HasPrefix(expr, "[") || !strings.HasSuffix(expr, "]") {
+			return nil, false
+		}
+		var values []string
+		for _, item := range strings.Split(strings.Trim(expr, "[]"), ",") {
+			if strings.TrimSpace(item) == "" {
+				continue
+			}
+			value, ok := parseCELString(item)
+			if !ok {
+				return nil, false
+			}
+			values = append(values, value)
+		}
+		return values, true
+	}
+	value, ok := parseCELString(expr)
+	return []string{value}, ok
+}
+
+// parseCELString parses a single- or double-quoted CEL string without escapes
+func parseCELString(expr string) (string, bool) {
+	expr = strings.TrimSpace(expr)
+	if len(expr) < 2 || (expr[0] != '\'' && expr[0] != '"') || expr[len(expr)-1] != expr[0] {
+		return "", false
+	}
+	value := expr[1 : len(expr)-1]
+	if strings.ContainsAny(value, `\'"`) {
+		return "", false
+	}
+	return value, true
+}
@@ -5,137 +5,94 @@ import (
 	"fmt"
 	"strings"
 
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
-// DetectAndHandleWebhookIssues detects and handles webhook validation issues
-// that might be blocking namespace or resource deletion
-func DetectAndHandleWebhookIssues(ctx context.Context, clientset kubernetes.Interface, autoDisable bool) error {
-	// Check for ValidatingWebhookConfiguration resources
-	webhookConfigs, err := clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+// DetectAndHandleWebhookIssues checks the backend of the admission webhooks intercepting the scope's requests, or
+// of every webhook when scope is nil, and flags the configurations with a fail-closed webhook that can't be
+// reached, since the API server rejects the requests it intercepts. Webhooks with failurePolicy Ignore are
+// reported but left alone. Storage provider webhooks go through the same checks and are named after their
+// provider.
+func DetectAndHandleWebhookIssues(ctx context.Context, clientset kubernetes.Interface, scope *WebhookScope, autoDisable bool, opts WebhookOptions) error {
+	webhooks, err := listAdmissionWebhooks(ctx, clientset)
 	if err != nil {
-		return fmt.Errorf("failed to list webhook configurations: %w", err)
+		return err
 	}
 
 	fmt.Printf("🔍 Checking for problematic webhook configurations...\n")
-	problematicWebhooks := 0
-	disabledWebhooks := 0
-
-	for _, webhookConfig := range webhookConfigs.Items {
-		isProblematic := false
-		reason := ""
-
-		// Check each webhook in the configuration
-		for _, webhook := range webhookConfig.Webhooks {
-			if webhook.ClientConfig.Service != nil {
-				service := webhook.ClientConfig.Service
-
-				// Check if the service exists
-				_, err := clientset.CoreV1().Services(service.Namespace).Get(ctx, service.Name, metav1.GetOptions{})
-				if err != nil {
-					isProblematic = true
-					reason = fmt.Sprintf("service %s/%s not found", service.Namespace, service.Name)
-					break
-				}
-
-				// Check if the namespace is terminating
-				ns, err := clientset.CoreV1().Namespaces().Get(ctx, service.Namespace, metav1.GetOptions{})
-				if err == nil && ns.Status.Phase == "Terminating" {
-					isProblematic = true
-					reason = fmt.Sprintf("namespace %s is terminating", service.Namespace)
-					break
-				}
+	if opts.Probe {
+		fmt.Printf("🔌 Probing webhook endpoints with a TLS handshake...\n")
+	}
+	var problematic []admissionWebhook
+	reasons := map[string][]string{}
+	outOfScope := 0
+	for _, w := range webhooks {
+		intercepted := ""
+		if scope != nil {
+			call, ok := scope.intercepts(w)
+			if !ok {
+				outOfScope++
+				continue
 			}
+			intercepted = fmt.Sprintf(" (intercepts %s)", call)
 		}
-
-		if isProblematic {
-			problematicWebhooks++
-			fmt.Printf("⚠️  Found potentially problematic webhook: %s (%s)\n", webhookConfig.Name, reason)
-
-			shouldDisable := autoDisable
-			if !autoDisable {
-				// Ask for confirmation before removing
-				fmt.Printf("❓ Would you like to temporarily disable this webhook to proceed with deletion? (y/n): ")
-				var response string
-				fmt.Scanln(&response)
-				shouldDisable = strings.ToLower(response) == "y" || strings.ToLower(response) == "yes"
+		if opts.StorageProviders != nil {
+			if provider := opts.StorageProviders.ForWebhook(w.Config); provider != nil {
+				intercepted = fmt.Sprintf(" [%s]%s", provider.Name, intercepted)
 			}
+		}
 
-			if shouldDisable {
-				fmt.Printf("🔧 Temporarily removing webhook configuration: %s\n", webhookConfig.Name)
-				err := clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().Delete(ctx, webhookConfig.Name, metav1.DeleteOptions{})
-				if err != nil {
-					fmt.Printf("⚠️  Failed to remove webhook configuration: %v\n", err)
-				} else {
-					fmt.Printf("✅ Successfully removed webhook configuration: %s\n", webhookConfig.Name)
-					disabledWebhooks++
-				}
+		health := checkWebhookHealth(ctx, clientset, w, opts)
+		switch {
+		case health.Problem == "" && health.Unreachable != "":
+			fmt.Printf("⚠️  %s%s: the TLS probe couldn't connect from here (%s); the API server may still reach it, so it is left in place\n",
+				describeWebhook(w, health), intercepted, health.Unreachable)
+		case health.Problem == "":
+			fmt.Printf("✅ %s%s\n", describeWebhook(w, health), intercepted)
+		case !w.failsClosed():
+			fmt.Printf("ℹ️  %s%s: %s; it fails open, so requests still go through\n", describeWebhook(w, health), intercepted, health.Problem)
+		default:
+			fmt.Printf("❌ %s%s: %s\n", describeWebhook(w, health), intercepted, health.Problem)
+			key := w.Kind + "/" + w.Config
+			if len(reasons[key]) == 0 {
+				problematic = append(problematic, w)
 			}
+			reasons[key] = append(reasons[key], health.Problem)
 		}
 	}
 
-	// Also check MutatingWebhookConfigurations
-	mutatingWebhookConfigs, err := clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to list mutating webhook configurations: %w", err)
-	}
-
-	for _, webhookConfig := range mutatingWebhookConfigs.Items {
-		isProblematic := false
-		reason := ""
-
-		// Check each webhook in the configuration
-		for _, webhook := range webhookConfig.Webhooks {
-			if webhook.ClientConfig.Service != nil {
-				service := webhook.ClientConfig.Service
-
-				// Check if the service exists
-				_, err := clientset.CoreV1().Services(service.Namespace).Get(ctx, service.Name, metav1.GetOptions{})
-				if err != nil {
-					isProblematic = true
-					reason = fmt.Sprintf("service %s/%s not found", service.Namespace, service.Name)
-					break
-				}
-
-				// Check if the namespace is terminating
-				ns, err := clientset.CoreV1().Namespaces().Get(ctx, service.Namespace, metav1.GetOptions{})
-				if err == nil && ns.Status.Phase == "Terminating" {
-					isProblematic = true
-					reason = fmt.Sprintf("namespace %s is terminating", service.Namespace)
-					break
-				}
-			}
+	disabledWebhooks := 0
+	for _, w := range problematic {
+		fmt.Printf("⚠️  Found problematic %s webhook configuration: %s (%s)\n", w.Kind, w.Config, strings.Join(reasons[w.Kind+"/"+w.Config], "; "))
+
+		shouldDisable := autoDisable
+		if !autoDisable {
+			// Ask for confirmation before removing
+			fmt.Printf("❓ Would you like to temporarily disable this webhook to proceed with deletion? (y/n): ")
+			var response string
+			fmt.Scanln(&response)
+			shouldDisable = strings.ToLower(response) == "y" || strings.ToLower(response) == "yes"
+		}
+		if !shouldDisable {
+			continue
 		}
 
-		if isProblematic {
-			problematicWebhooks++
-			fmt.Printf("⚠️  Found potentially problematic mutating webhook: %s (%s)\n", webhookConfig.Name, reason)
-
-			shouldDisable := autoDisable
-			if !autoDisable {
-				// Ask for confirmation before removing
-				fmt.Printf("❓ Would you like to temporarily disable this webhook to proceed with deletion? (y/n): ")
-				var response string
-				fmt.Scanln(&response)
-				shouldDisable = strings.ToLower(response) == "y" || strings.ToLower(response) == "yes"
-			}
-
-			if shouldDisable {
-				fmt.Printf("🔧 Temporarily removing mutating webhook configuration: %s\n", webhookConfig.Name)
-				err := clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Delete(ctx, webhookConfig.Name, metav1.DeleteOptions{})
-				if err != nil {
-					fmt.Printf("⚠️  Failed to remove webhook configuration: %v\n", err)
-				} else {
-					fmt.Printf("✅ Successfully removed webhook configuration: %s\n", webhookConfig.Name)
-					disabledWebhooks++
-				}
-			}
+		fmt.Printf("🔧 Temporarily removing %s webhook configuration: %s\n", w.Kind, w.Config)
+		if err := deleteWebhookConfiguration(ctx, clientset, w.Kind, w.Config); err != nil {
+			fmt.Printf("⚠️  Failed to remove webhook configuration: %v\n", err)
+			continue
 		}
+		fmt.Printf("✅ Successfully removed webhook configuration: %s\n", w.Config)
+		disabledWebhooks++
 	}
 
-	if problematicWebhooks > 0 {
-		fmt.Printf("📊 Webhook summary: %d problematic webhooks found, %d disabled\n", problematicWebhooks, disabledWebhooks)
+	if outOfScope > 0 {
+		fmt.Printf("ℹ️  Skipped %d webhook(s) that don't intercept the planned deletes and finalizer updates\n", outOfScope)
+	}
+	if len(problematic) > 0 {
+		fmt.Printf("📊 Webhook summary: %d problematic webhooks found, %d disabled\n", len(problematic), disabledWebhooks)
 	} else {
 		fmt.Printf("✅ No problematic webhooks detected\n")
 	}
@@ -143,70 +100,36 @@ func DetectAndHandleWebhookIssues(ctx context.Context, clientset kubernetes.Inte
 	return nil
 }
 
-// DisableStorageProviderWebhooks specifically targets webhooks from common storage providers
-// that might be causing issues with namespace deletion
-func DisableStorageProviderWebhooks(ctx context.Context, clientset kubernetes.Interface) error {
-	// Check for common storage provider webhooks
-	storageProviders := []string{
-		"longhorn",
-		"rook-ceph",
-		"openebs",
-		"portworx",
-		"storageos",
-	}
-
-	fmt.Printf("🔍 Checking for storage provider webhooks...\n")
-	disabledCount := 0
-
-	// Check ValidatingWebhookConfigurations
-	webhookConfigs, err := clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to list webhook configurations: %w", err)
+// deleteWebhookConfiguration deletes a validating or mutating webhook configuration
+func deleteWebhookConfiguration(ctx context.Context, clientset kubernetes.Interface, kind, name string) error {
+	if kind == mutatingWebhook {
+		return clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Delete(ctx, name, metav1.DeleteOptions{})
 	}
+	return clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().Delete(ctx, name, metav1.DeleteOptions{})
+}
 
-	for _, webhookConfig := range webhookConfigs.Items {
-		for _, provider := range storageProviders {
-			if strings.Contains(strings.ToLower(webhookConfig.Name), provider) {
-				fmt.Printf("🔧 Found %s webhook: %s. Attempting to remove...\n", provider, webhookConfig.Name)
-				err := clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().Delete(ctx, webhookConfig.Name, metav1.DeleteOptions{})
-				if err != nil {
-					fmt.Printf("⚠️  Failed to remove webhook: %v\n", err)
-				} else {
-					fmt.Printf("✅ Successfully removed webhook: %s\n", webhookConfig.Name)
-					disabledCount++
-				}
-				break
-			}
+// rulesTargetGroup checks if any webhook rule names the API group explicitly
+func rulesTargetGroup(rules []admissionregistrationv1.RuleWithOperations, group string) bool {
+	for _, rule := range rules {
+		if containsString(rule.APIGroups, group) {
+			return true
 		}
 	}
+	return false
+}
 
-	// Check MutatingWebhookConfigurations
-	mutatingWebhookConfigs, err := clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to list mutating webhook configurations: %w", err)
-	}
-
-	for _, webhookConfig := range mutatingWebhookConfigs.Items {
-		for _, provider := range storageProviders {
-			if strings.Contains(strings.ToLower(webhookConfig.Name), provider) {
-				fmt.Printf("🔧 Found %s mutating webhook: %s. Attempting to remove...\n", provider, webhookConfig.Name)
-				err := clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Delete(ctx, webhookConfig.Name, metav1.DeleteOptions{})
-				if err != nil {
-					fmt.Printf("⚠️  Failed to remove webhook: %v\n", err)
-				} else {
-					fmt.Printf("✅ Successfully removed webhook: %s\n", webhookConfig.Name)
-					disabledCount++
-				}
-				break
+// rulesTargetOnlyGroup checks that every webhook rule names the API group and nothing else: no other group and
+// no wildcard
+func rulesTargetOnlyGroup(rules []admissionregistrationv1.RuleWithOperations, group string) bool {
+	for _, rule := range rules {
+		if len(rule.APIGroups) == 0 {
+			return false
+		}
+		for _, g := range rule.APIGroups {
+			if g != group {
+				return false
 			}
 		}
 	}
-
-	if disabledCount > 0 {
-		fmt.Printf("📊 Disabled %d storage provider webhooks\n", disabledCount)
-	} else {
-		fmt.Printf("ℹ️  No storage provider webhooks found\n")
-	}
-
-	return nil
+	return len(rules) > 0
 }
@@ -0,0 +1,286 @@
+package kube
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/codesenju/kubectl-nuke-go/pkg/storage"
+)
+
+func newWebhookService(namespace, name string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "https", Port: 443}}},
+	}
+}
+
+func newEndpointSlice(namespace, service string, ready bool, addresses ...string) *discoveryv1.EndpointSlice {
+	portName, port := "https", int32(8443)
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: service + "-abcde", Labels: map[string]string{discoveryv1.LabelServiceName: service}},
+		Ports:      []discoveryv1.EndpointPort{{Name: &portName, Port: &port}},
+	}
+	for _, address := range addresses {
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{Addresses: []string{address}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}})
+	}
+	return slice
+}
+
+func newServiceWebhook(config, service string, policy admissionregistrationv1.FailurePolicyType) *admissionregistrationv1.ValidatingWebhookConfiguration {
+	return &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: config},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name:          config + ".example.com",
+			ClientConfig:  admissionregistrationv1.WebhookClientConfig{Service: &admissionregistrationv1.ServiceReference{Namespace: "hooks", Name: service}},
+			FailurePolicy: &policy,
+		}},
+	}
+}
+
+func TestCheckWebhookHealth(t *testing.T) {
+	clientset := k8sfake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "hooks"}},
+		newWebhookService("hooks", "healthy"),
+		newEndpointSlice("hooks", "healthy", true, "10.0.0.1"),
+		newWebhookService("hooks", "down"),
+		newEndpointSlice("hooks", "down", false, "10.0.0.2"),
+	)
+
+	tests := []struct {
+		service   string
+		endpoints []string
+		problem   string
+	}{
+		{"healthy", []string{"10.0.0.1:8443"}, ""},
+		{"down", nil, "service hooks/down has no ready endpoints"},
+		{"missing", nil, "service hooks/missing not found"},
+	}
+	for _, tt := range tests {
+		config := newServiceWebhook(tt.service, tt.service, admissionregistrationv1.Fail)
+		w := admissionWebhook{Kind: validatingWebhook, Config: config.Name, ClientConfig: config.Webhooks[0].ClientConfig}
+		health := checkWebhookHealth(context.TODO(), clientset, w, WebhookOptions{})
+		if health.Problem != tt.problem || strings.Join(health.Endpoints, ",") != strings.Join(tt.endpoints, ",") {
+			t.Errorf("%s: expected endpoints %v and problem %q, got %+v", tt.service, tt.endpoints, tt.problem, health)
+		}
+	}
+}
+
+func TestCheckWebhookHealthProbe(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	closed := httptest.NewTLSServer(http.NotFoundHandler())
+	closedURL := closed.URL
+	closed.Close()
+
+	tests := []struct {
+		name        string
+		url         string
+		caBundle    []byte
+		healthy     bool
+		unreachable bool
+	}{
+		{"trusted", server.URL + "/validate", caBundle, true, false},
+		{"untrusted", server.URL + "/validate", nil, false, false},
+		// Not connecting from here says nothing about the API server's view, so it is reported but not a problem
+		{"unreachable", closedURL + "/validate", caBundle, true, true},
+	}
+	for _, tt := range tests {
+		url := tt.url
+		w := admissionWebhook{ClientConfig: admissionregistrationv1.WebhookClientConfig{URL: &url, CABundle: tt.caBundle}}
+		health := checkWebhookHealth(context.TODO(), k8sfake.NewSimpleClientset(), w, WebhookOptions{Probe: true, ProbeTimeout: time.Second})
+		if (health.Problem == "") != tt.healthy || (health.Unreachable != "") != tt.unreachable {
+			t.Errorf("%s: expected healthy %v and unreachable %v, got %+v", tt.name, tt.healthy, tt.unreachable, health)
+		}
+	}
+}
+
+func TestDetectAndHandleWebhookIssues(t *testing.T) {
+	clientset := k8sfake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "hooks"}},
+		newWebhookService("hooks", "healthy"),
+		newEndpointSlice("hooks", "healthy", true, "10.0.0.1"),
+		newWebhookService("hooks", "down"),
+		newServiceWebhook("healthy", "healthy", admissionregistrationv1.Fail),
+		newServiceWebhook("fail-closed", "down", admissionregistrationv1.Fail),
+		newServiceWebhook("fail-open", "down", admissionregistrationv1.Ignore),
+	)
+	ctx := context.TODO()
+
+	if err := DetectAndHandleWebhookIssues(ctx, clientset, nil, true, WebhookOptions{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	configs := clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	if _, err := configs.Get(ctx, "fail-closed", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expected the fail-closed webhook without endpoints to be removed, got %v", err)
+	}
+	for _, name := range []string{"healthy", "fail-open"} {
+		if _, err := configs.Get(ctx, name, metav1.GetOptions{}); err != nil {
+			t.Errorf("expected webhook %s to be left alone, got %v", name, err)
+		}
+	}
+}
+
+func TestDetectAndHandleWebhookIssuesChecksStorageProviderWebhooks(t *testing.T) {
+	clientset := k8sfake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "hooks"}},
+		newWebhookService("hooks", "healthy"),
+		newEndpointSlice("hooks", "healthy", true, "10.0.0.1"),
+		newWebhookService("hooks", "down"),
+		newServiceWebhook("longhorn-webhook-validator", "healthy", admissionregistrationv1.Fail),
+		newServiceWebhook("longhorn-webhook-mutator", "down", admissionregistrationv1.Ignore),
+		newServiceWebhook("rook-ceph-webhook", "down", admissionregistrationv1.Fail),
+	)
+	ctx := context.TODO()
+
+	opts := WebhookOptions{StorageProviders: storage.DefaultRegistry()}
+	if err := DetectAndHandleWebhookIssues(ctx, clientset, nil, true, opts); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	configs := clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	if _, err := configs.Get(ctx, "rook-ceph-webhook", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expected the fail-closed provider webhook without endpoints to be removed, got %v", err)
+	}
+	for _, name := range []string{"longhorn-webhook-validator", "longhorn-webhook-mutator"} {
+		if _, err := configs.Get(ctx, name, metav1.GetOptions{}); err != nil {
+			t.Errorf("expected provider webhook %s to be left alone, got %v", name, err)
+		}
+	}
+}
+
+// newScopedClients returns clients for namespace shop, labelled team=shop, holding a widget labelled app=gadget
+func newScopedClients(objects ...runtime.Object) (*k8sfake.Clientset, *dynamicfake.FakeDynamicClient) {
+	objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop", Labels: map[string]string{"team": "shop"}}})
+	clientset := k8sfake.NewSimpleClientset(objects...)
+	clientset.Resources = []*metav1.APIResourceList{widgetResourceList()}
+	gadget := newWidget("shop", "gadget")
+	gadget.SetLabels(map[string]string{"app": "gadget"})
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{widgetGVR: "WidgetList"}, gadget)
+	return clientset, dynamicClient
+}
+
+func TestWebhookScopeIntercepts(t *testing.T) {
+	clientset, dynamicClient := newScopedClients()
+	ctx := context.TODO()
+	scope, err := NewWebhookScope(ctx, clientset, dynamicClient, "shop", NukeOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	contentsOnly, err := NewWebhookScope(ctx, clientset, dynamicClient, "shop", NukeOptions{ContentsOnly: true})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	pvc, pv := newBoundVolume("shop", "data", "pv-data", corev1.PersistentVolumeReclaimDelete)
+	pv.Labels = map[string]string{"tier": "fast"}
+	clientset, dynamicClient = newScopedClients(pvc, pv)
+	clientset.Resources = append(clientset.Resources, &metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{
+		{Name: "persistentvolumes", Kind: "PersistentVolume", Verbs: metav1.Verbs{"list", "delete"}},
+	}})
+	withVolumes, err := NewWebhookScope(ctx, clientset, dynamicClient, "shop", NukeOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	rule := func(group, resource string, scope admissionregistrationv1.ScopeType, ops ...admissionregistrationv1.OperationType) []admissionregistrationv1.RuleWithOperations {
+		return []admissionregistrationv1.RuleWithOperations{{
+			Operations: ops,
+			Rule:       admissionregistrationv1.Rule{APIGroups: []string{group}, APIVersions: []string{"*"}, Resources: []string{resource}, Scope: &scope},
+		}}
+	}
+	deleteWidgets := rule("example.com", "widgets", admissionregistrationv1.AllScopes, admissionregistrationv1.Delete)
+	selector := func(key, value string) *metav1.LabelSelector {
+		return &metav1.LabelSelector{MatchLabels: map[string]string{key: value}}
+	}
+	conditions := func(expression string) []admissionregistrationv1.MatchCondition {
+		return []admissionregistrationv1.MatchCondition{{Name: "only", Expression: expression}}
+	}
+
+	tests := []struct {
+		name    string
+		webhook admissionWebhook
+		scope   *WebhookScope
+		want    string
+	}{
+		{"other resource", admissionWebhook{Rules: rule("networking.k8s.io", "ingresses", admissionregistrationv1.AllScopes, admissionregistrationv1.Create)}, scope, ""},
+		{"create only", admissionWebhook{Rules: rule("example.com", "widgets", admissionregistrationv1.AllScopes, admissionregistrationv1.Create)}, scope, ""},
+		{"delete", admissionWebhook{Rules: deleteWidgets}, scope, "DELETE widgets.example.com"},
+		{"cluster scope", admissionWebhook{Rules: rule("example.com", "widgets", admissionregistrationv1.ClusterScope, admissionregistrationv1.Delete)}, scope, ""},
+		{"other namespaces", admissionWebhook{Rules: deleteWidgets, NamespaceSelector: selector("team", "other")}, scope, ""},
+		{"this namespace", admissionWebhook{Rules: deleteWidgets, NamespaceSelector: selector("team", "shop")}, scope, "DELETE widgets.example.com"},
+		{"other objects", admissionWebhook{Rules: deleteWidgets, ObjectSelector: selector("app", "other")}, scope, ""},
+		{"matching objects", admissionWebhook{Rules: deleteWidgets, ObjectSelector: selector("app", "gadget")}, scope, "DELETE widgets.example.com"},
+		{"condition excludes", admissionWebhook{Rules: deleteWidgets, MatchConditions: conditions("request.operation != 'DELETE'")}, scope, ""},
+		{"condition admits", admissionWebhook{Rules: deleteWidgets, MatchConditions: conditions(`request.resource.group in ["example.com", "apps"]`)}, scope, "DELETE widgets.example.com"},
+		{"condition unknown", admissionWebhook{Rules: deleteWidgets, MatchConditions: conditions("object.metadata.name.startsWith('g')")}, scope, "DELETE widgets.example.com"},
+		{"everything", admissionWebhook{Rules: rule("*", "*/*", admissionregistrationv1.AllScopes, admissionregistrationv1.OperationAll)}, scope, "DELETE widgets.example.com"},
+		{"finalize", admissionWebhook{Rules: rule("", "namespaces/finalize", admissionregistrationv1.ClusterScope, admissionregistrationv1.Update)}, scope, "UPDATE namespaces/finalize"},
+		{"volumes outside the namespaceSelector", admissionWebhook{Rules: rule("", "persistentvolumes", admissionregistrationv1.ClusterScope, admissionregistrationv1.Delete), NamespaceSelector: selector("team", "other")}, withVolumes, "DELETE persistentvolumes"},
+		{"other volumes", admissionWebhook{Rules: rule("", "persistentvolumes", admissionregistrationv1.ClusterScope, admissionregistrationv1.Delete), ObjectSelector: selector("tier", "slow")}, withVolumes, ""},
+		{"finalize, contents only", admissionWebhook{Rules: rule("", "namespaces/*", admissionregistrationv1.ClusterScope, admissionregistrationv1.Update)}, contentsOnly, ""},
+	}
+	for _, tt := range tests {
+		call, ok := tt.scope.intercepts(tt.webhook)
+		got := ""
+		if ok {
+			got = call.String()
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %q to be intercepted, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestDetectAndHandleWebhookIssuesScoped(t *testing.T) {
+	deletesWidgets := newServiceWebhook("widgets", "down", admissionregistrationv1.Fail)
+	deletesWidgets.Webhooks[0].Rules = []admissionregistrationv1.RuleWithOperations{{
+		Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Delete},
+		Rule:       admissionregistrationv1.Rule{APIGroups: []string{"example.com"}, APIVersions: []string{"v1"}, Resources: []string{"widgets"}},
+	}}
+	createsIngresses := newServiceWebhook("ingresses", "down", admissionregistrationv1.Fail)
+	createsIngresses.Webhooks[0].Rules = []admissionregistrationv1.RuleWithOperations{{
+		Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+		Rule:       admissionregistrationv1.Rule{APIGroups: []string{"networking.k8s.io"}, APIVersions: []string{"v1"}, Resources: []string{"ingresses"}},
+	}}
+	clientset, dynamicClient := newScopedClients(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "hooks"}},
+		newWebhookService("hooks", "down"),
+		deletesWidgets,
+		createsIngresses,
+	)
+	ctx := context.TODO()
+
+	scope, err := NewWebhookScope(ctx, clientset, dynamicClient, "shop", NukeOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := DetectAndHandleWebhookIssues(ctx, clientset, scope, true, WebhookOptions{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	configs := clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	if _, err := configs.Get(ctx, "widgets", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expected the broken webhook intercepting widget deletes to be removed, got %v", err)
+	}
+	if _, err := configs.Get(ctx, "ingresses", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the broken webhook only intercepting ingress creates to be left alone, got %v", err)
+	}
+}
@@ -0,0 +1,261 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// KindFilter scopes the discovery-driven deletion pass to selected resource types
+type KindFilter struct {
+	Only    []string
+	Exclude []string
+}
+
+// IsEmpty reports whether the filter allows every resource type
+func (f KindFilter) IsEmpty() bool {
+	return len(f.Only) == 0 && len(f.Exclude) == 0
+}
+
+// allows reports whether the deletion pass may delete objects of the given resource type
+func (f KindFilter) allows(res discoveredResource) bool {
+	if len(f.Only) > 0 && !res.matchesKind(f.Only) {
+		return false
+	}
+	return !res.matchesKind(f.Exclude)
+}
+
+// NamespaceWipeResult summarizes a discovery-driven deletion pass over a namespace
+type NamespaceWipeResult struct {
+	ResourceTypesScanned int
+	ObjectsFound         int
+	CollectionsDeleted   int
+	ObjectsDeleted       int
+	ObjectsUnstuck       int
+	Failed               int
+}
+
+// DefaultFinalizerTimeout bounds the wait for deleted objects to finalize before their finalizers are stripped
+const DefaultFinalizerTimeout = 30 * time.Second
+
+// ForceDeleteAllNamespacedResources deletes every object of every deletable namespaced resource type in a namespace,
+// built-in or custom, then strips finalizers from anything still hanging after timeout
+func ForceDeleteAllNamespacedResources(ctx context.Context, config *rest.Config, namespace string, kinds KindFilter, keep KeepFilter, timeout time.Duration) error {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create discovery client: %w", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	_, err = deleteNamespacedResources(ctx, dynamicClient, discoveryClient, namespace, kinds, keep, timeout)
+	return err
+}
+
+// Deletion tiers of the force pass, in order
+const (
+	// customResourcesTier deletes custom resources while the workloads, config and RBAC their controllers use remain
+	customResourcesTier = iota
+	// workloadsTier deletes pods and the controllers managing them
+	workloadsTier
+	// otherResourcesTier deletes everything not in another tier, such as Services and Ingresses
+	otherResourcesTier
+	// configTier deletes the core config and RBAC the objects above may still need while finalizing
+	configTier
+)
+
+// deletionTier returns the tier a resource type is deleted in
+func deletionTier(res discoveredResource) int {
+	group := res.GVR.Group
+	switch {
+	case group == "" && (res.GVR.Resource == "pods" || res.GVR.Resource == "replicationcontrollers"):
+		return workloadsTier
+	case group == "" && (res.GVR.Resource == "configmaps" || res.GVR.Resource == "secrets" || res.GVR.Resource == "serviceaccounts" ||
+		res.GVR.Resource == "resourcequotas" || res.GVR.Resource == "limitranges"):
+		return configTier
+	case group == "apps" || group == "batch":
+		return workloadsTier
+	case group == "rbac.authorization.k8s.io":
+		return configTier
+	case group != "" && strings.Contains(group, ".") && !strings.HasSuffix(group, ".k8s.io"):
+		return customResourcesTier
+	}
+	return otherResourcesTier
+}
+
+// deletedObjects are the objects of one resource type a deletion pass deleted
+type deletedObjects struct {
+	res    discoveredResource
+	client dynamic.ResourceInterface
+	names  []string
+}
+
+// deleteNamespacedResources runs the deletion pass, tier by tier: custom resources, workloads, everything else,
+// then core config and RBAC. Resource types supporting deletecollection are removed in one call unless a keep
+// selector forces per-object deletes. Each tier's objects get up to timeout to finalize before those still
+// present have their finalizers stripped, so controllers such as the load balancer cleanup get to finish first.
+// PVCs are left to HandlePVCFinalizers, which owns their data-safety checks.
+func deleteNamespacedResources(ctx context.Context, dynamicClient dynamic.Interface, discoveryClient discovery.DiscoveryInterface, namespace string, kinds KindFilter, keep KeepFilter, timeout time.Duration) (*NamespaceWipeResult, error) {
+	resources, err := discoverResources(discoveryClient, true, "list", "delete")
+	if err != nil {
+		if len(resources) == 0 {
+			return nil, err
+		}
+		// Continue with partial results if some APIs are unavailable
+		fmt.Printf("⚠️  Warning: Some API resources may not be accessible: %v\n", err)
+	}
+	sort.SliceStable(resources, func(i, j int) bool {
+		return deletionTier(resources[i]) < deletionTier(resources[j])
+	})
+
+	fmt.Printf("💥 Deleting all namespaced resources in namespace %s...\n", namespace)
+
+	gracePeriod := int64(0)
+	propagation := metav1.DeletePropagationBackground
+	deleteOptions := metav1.DeleteOptions{
+		GracePeriodSeconds: &gracePeriod,
+		PropagationPolicy:  &propagation,
+	}
+
+	result := &NamespaceWipeResult{}
+
+	var tier []deletedObjects
+	for i, res := range resources {
+		if deleted := deleteResourceObjects(ctx, dynamicClient, namespace, res, kinds, keep, deleteOptions, result); deleted != nil {
+			tier = append(tier, *deleted)
+		}
+		if i == len(resources)-1 || deletionTier(resources[i+1]) != deletionTier(res) {
+			unstickDeletedObjects(ctx, tier, keep, timeout, result)
+			tier = nil
+		}
+	}
+
+	fmt.Printf("📊 Scanned %d resource types: %d objects found, %d deleted (%d via deletecollection), %d unstuck, %d failed\n",
+		result.ResourceTypesScanned, result.ObjectsFound, result.ObjectsDeleted, result.CollectionsDeleted, result.ObjectsUnstuck, result.Failed)
+	return result, nil
+}
+
+// deleteResourceObjects deletes the namespace's objects of one resource type and returns what it deleted, or nil
+// when the type is filtered out or has nothing to delete
+func deleteResourceObjects(ctx context.Context, dynamicClient dynamic.Interface, namespace string, res discoveredResource, kinds KindFilter, keep KeepFilter, deleteOptions metav1.DeleteOptions, result *NamespaceWipeResult) *deletedObjects {
+	if res.GVR.Group == "" && res.GVR.Resource == persistentVolumeClaimsResource.GVR.Resource {
+		return nil
+	}
+	if !kinds.allows(res) || keep.keepsKind(res) {
+		return nil
+	}
+	result.ResourceTypesScanned++
+
+	resourceClient := dynamicClient.Resource(res.GVR).Namespace(namespace)
+	list, err := resourceClient.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		// Skip resources we can't list (permissions, etc.)
+		return nil
+	}
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	result.ObjectsFound += len(list.Items)
+	fmt.Printf("🔍 Found %d %s resources\n", len(list.Items), res.displayName())
+
+	deleted := &deletedObjects{res: res, client: resourceClient}
+	canDeleteCollection := supportsVerb(res.APIResource.Verbs, "deletecollection") &&
+		(keep.Selector == nil || keep.Selector.Empty())
+
+	if canDeleteCollection {
+		if err := resourceClient.DeleteCollection(ctx, deleteOptions, metav1.ListOptions{}); err != nil {
+			fmt.Printf("⚠️  Failed to delete collection of %s, falling back to per-object deletes: %v\n", res.displayName(), err)
+			canDeleteCollection = false
+		} else {
+			result.CollectionsDeleted++
+			result.ObjectsDeleted += len(list.Items)
+			for _, item := range list.Items {
+				deleted.names = append(deleted.names, item.GetName())
+			}
+			fmt.Printf("✅ Deleted all %s\n", res.displayName())
+		}
+	}
+
+	if !canDeleteCollection {
+		for _, item := range list.Items {
+			if keep.keeps(res, item.GetLabels()) {
+				continue
+			}
+			err := resourceClient.Delete(ctx, item.GetName(), deleteOptions)
+			if err != nil && !errors.IsNotFound(err) {
+				result.Failed++
+				fmt.Printf("⚠️  Failed to delete %s %s: %v\n", res.displayName(), item.GetName(), err)
+				continue
+			}
+			result.ObjectsDeleted++
+			deleted.names = append(deleted.names, item.GetName())
+			fmt.Printf("✅ Successfully deleted %s: %s\n", res.displayName(), item.GetName())
+		}
+	}
+	return deleted
+}
+
+// unstickDeletedObjects waits up to timeout for a tier's deleted objects to go, then strips the finalizers of
+// those still terminating
+func unstickDeletedObjects(ctx context.Context, tier []deletedObjects, keep KeepFilter, timeout time.Duration, result *NamespaceWipeResult) {
+	pending := func() int {
+		count := 0
+		for i := range tier {
+			var still []string
+			for _, name := range tier[i].names {
+				if _, err := tier[i].client.Get(ctx, name, metav1.GetOptions{}); !errors.IsNotFound(err) {
+					still = append(still, name)
+				}
+			}
+			tier[i].names = still
+			count += len(still)
+		}
+		return count
+	}
+	if remaining := pending(); remaining > 0 {
+		fmt.Printf("⏳ Waiting up to %s for %d deleted object(s) to finalize...\n", timeout, remaining)
+		_ = wait.PollImmediate(2*time.Second, timeout, func() (bool, error) {
+			return pending() == 0, nil
+		})
+	}
+
+	// Strip finalizers from anything the delete left hanging
+	for _, deleted := range tier {
+		for _, name := range deleted.names {
+			item, err := deleted.client.Get(ctx, name, metav1.GetOptions{})
+			if err != nil || item.GetDeletionTimestamp() == nil || len(item.GetFinalizers()) == 0 || keep.keeps(deleted.res, item.GetLabels()) {
+				continue
+			}
+			fmt.Printf("🔧 Removing finalizers from %s %s: %v\n", deleted.res.displayName(), item.GetName(), item.GetFinalizers())
+			if err := stripAllFinalizers(ctx, deleted.client, item.GetName()); err != nil {
+				result.Failed++
+				fmt.Printf("⚠️  Failed to remove finalizers from %s %s: %v\n", deleted.res.displayName(), item.GetName(), err)
+				continue
+			}
+			result.ObjectsUnstuck++
+		}
+	}
+}
+
+// displayKindFilter prints which resource types a deletion pass is limited to
+func displayKindFilter(kinds KindFilter) {
+	if len(kinds.Only) > 0 {
+		fmt.Printf("🎯 Only deleting kinds: %s\n", strings.Join(kinds.Only, ", "))
+	}
+	if len(kinds.Exclude) > 0 {
+		fmt.Printf("🚫 Not deleting kinds: %s\n", strings.Join(kinds.Exclude, ", "))
+	}
+}
@@ -0,0 +1,294 @@
+package kube
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+var (
+	statefulSetGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}
+	jobGVR         = schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}
+)
+
+func newNamespacedObject(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	return u
+}
+
+func builtinResourceLists() []*metav1.APIResourceList {
+	return []*metav1.APIResourceList{
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "statefulsets", SingularName: "statefulset", Kind: "StatefulSet", Namespaced: true, ShortNames: []string{"sts"},
+					Verbs: metav1.Verbs{"get", "list", "delete", "deletecollection"}},
+			},
+		},
+		{
+			GroupVersion: "batch/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "jobs", SingularName: "job", Kind: "Job", Namespaced: true,
+					Verbs: metav1.Verbs{"get", "list", "delete"}},
+			},
+		},
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "persistentvolumeclaims", SingularName: "persistentvolumeclaim", Kind: "PersistentVolumeClaim", Namespaced: true,
+					Verbs: metav1.Verbs{"get", "list", "delete", "deletecollection"}},
+			},
+		},
+	}
+}
+
+func TestDeleteNamespacedResources(t *testing.T) {
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			statefulSetGVR:                     "StatefulSetList",
+			jobGVR:                             "JobList",
+			widgetGVR:                          "WidgetList",
+			persistentVolumeClaimsResource.GVR: "PersistentVolumeClaimList",
+		},
+		newNamespacedObject("apps/v1", "StatefulSet", "wipe-ns", "db"),
+		newNamespacedObject("batch/v1", "Job", "wipe-ns", "migrate"),
+		newNamespacedObject("batch/v1", "Job", "other-ns", "untouched"),
+		newNamespacedObject("v1", "PersistentVolumeClaim", "wipe-ns", "data"),
+		newWidget("wipe-ns", "gadget"),
+	)
+	ctx := context.TODO()
+
+	resources := append(builtinResourceLists(), widgetResourceList())
+	result, err := deleteNamespacedResources(ctx, dynamicClient, newFakeDiscovery(resources...), "wipe-ns", KindFilter{}, KeepFilter{}, time.Millisecond)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.ResourceTypesScanned != 3 {
+		t.Errorf("expected statefulsets, jobs and widgets to be scanned, got %d types", result.ResourceTypesScanned)
+	}
+
+	var collections []string
+	for _, action := range dynamicClient.Actions() {
+		if action.GetVerb() == "delete-collection" {
+			collections = append(collections, action.GetResource().Resource)
+			if action.GetNamespace() != "wipe-ns" {
+				t.Errorf("expected delete-collection scoped to wipe-ns, got %q", action.GetNamespace())
+			}
+		}
+		if action.GetResource().Resource == "persistentvolumeclaims" && action.GetVerb() != "list" {
+			t.Errorf("expected PVCs to be left to the PVC handler, got %s", action.GetVerb())
+		}
+	}
+	if len(collections) != 2 || collections[0] != "widgets" || collections[1] != "statefulsets" {
+		t.Errorf("expected deletecollection for widgets, then statefulsets, got %v", collections)
+	}
+
+	if _, err := dynamicClient.Resource(jobGVR).Namespace("wipe-ns").Get(ctx, "migrate", metav1.GetOptions{}); err == nil {
+		t.Errorf("expected job without deletecollection support to be deleted individually")
+	}
+	if _, err := dynamicClient.Resource(jobGVR).Namespace("other-ns").Get(ctx, "untouched", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected job in another namespace to survive, got %v", err)
+	}
+}
+
+func TestDeleteNamespacedResources_KindFilter(t *testing.T) {
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			statefulSetGVR: "StatefulSetList",
+			jobGVR:         "JobList",
+			widgetGVR:      "WidgetList",
+		},
+		newNamespacedObject("batch/v1", "Job", "wipe-ns", "migrate"),
+	)
+	ctx := context.TODO()
+
+	resources := append(builtinResourceLists(), widgetResourceList())
+	result, err := deleteNamespacedResources(ctx, dynamicClient, newFakeDiscovery(resources...), "wipe-ns", KindFilter{Only: []string{"sts", "jobs", "widgets"}, Exclude: []string{"Job"}}, KeepFilter{}, time.Millisecond)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.ResourceTypesScanned != 2 {
+		t.Errorf("expected only statefulsets and widgets to be scanned, got %d types", result.ResourceTypesScanned)
+	}
+	for _, action := range dynamicClient.Actions() {
+		if action.GetResource() == jobGVR {
+			t.Errorf("expected excluded jobs to be untouched, got %s", action.GetVerb())
+		}
+	}
+	if _, err := dynamicClient.Resource(jobGVR).Namespace("wipe-ns").Get(ctx, "migrate", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected excluded job to survive, got %v", err)
+	}
+}
+
+func TestDeleteNamespacedResources_UnsticksTerminatingObjects(t *testing.T) {
+	stuck := newWidget("wipe-ns", "stuck", "example.com/cleanup")
+	now := metav1.Now()
+	stuck.SetDeletionTimestamp(&now)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{widgetGVR: "WidgetList"},
+		stuck,
+	)
+	// The fake tracker does not honour finalizers, so make deletecollection a no-op like a blocked delete
+	dynamicClient.PrependReactor("delete-collection", "widgets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	ctx := context.TODO()
+
+	result, err := deleteNamespacedResources(ctx, dynamicClient, newFakeDiscovery(widgetResourceList()), "wipe-ns", KindFilter{}, KeepFilter{}, time.Millisecond)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.ObjectsUnstuck != 1 {
+		t.Errorf("expected 1 object unstuck, got %d", result.ObjectsUnstuck)
+	}
+
+	obj, err := dynamicClient.Resource(widgetGVR).Namespace("wipe-ns").Get(ctx, "stuck", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get widget: %v", err)
+	}
+	if len(obj.GetFinalizers()) != 0 {
+		t.Errorf("expected finalizers to be stripped, got %v", obj.GetFinalizers())
+	}
+}
+
+func TestDeleteNamespacedResources_TierOrder(t *testing.T) {
+	secretGVR := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	roleBindingGVR := schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "rolebindings"}
+	serviceGVR := schema.GroupVersionResource{Version: "v1", Resource: "services"}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			secretGVR:      "SecretList",
+			roleBindingGVR: "RoleBindingList",
+			serviceGVR:     "ServiceList",
+			statefulSetGVR: "StatefulSetList",
+			widgetGVR:      "WidgetList",
+		},
+		newNamespacedObject("v1", "Secret", "wipe-ns", "credentials"),
+		newNamespacedObject("rbac.authorization.k8s.io/v1", "RoleBinding", "wipe-ns", "operator"),
+		newNamespacedObject("v1", "Service", "wipe-ns", "frontend"),
+		newNamespacedObject("apps/v1", "StatefulSet", "wipe-ns", "db"),
+		newWidget("wipe-ns", "gadget"),
+	)
+	verbs := metav1.Verbs{"get", "list", "delete"}
+	resources := []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "secrets", Kind: "Secret", Namespaced: true, Verbs: verbs},
+			{Name: "services", Kind: "Service", Namespaced: true, Verbs: verbs},
+		}},
+		{GroupVersion: "rbac.authorization.k8s.io/v1", APIResources: []metav1.APIResource{
+			{Name: "rolebindings", Kind: "RoleBinding", Namespaced: true, Verbs: verbs},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "statefulsets", Kind: "StatefulSet", Namespaced: true, Verbs: verbs},
+		}},
+		widgetResourceList(),
+	}
+
+	if _, err := deleteNamespacedResources(context.TODO(), dynamicClient, newFakeDiscovery(resources...), "wipe-ns", KindFilter{}, KeepFilter{}, time.Millisecond); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var deleted []string
+	for _, action := range dynamicClient.Actions() {
+		if action.GetVerb() == "delete" || action.GetVerb() == "delete-collection" {
+			deleted = append(deleted, action.GetResource().Resource)
+		}
+	}
+	want := []string{"widgets", "statefulsets", "services", "secrets", "rolebindings"}
+	if len(deleted) != len(want) {
+		t.Fatalf("expected deletes %v, got %v", want, deleted)
+	}
+	for i := range want {
+		if deleted[i] != want[i] {
+			t.Fatalf("expected custom resources, workloads, the rest, then config and RBAC %v, got %v", want, deleted)
+		}
+	}
+}
+
+func TestNukeNamespace_KindFilterAppliesToEveryStage(t *testing.T) {
+	retain := corev1.PersistentVolumeReclaimRetain
+	clientset := k8sfake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: "app"}},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "app"},
+			Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "pv-data"},
+		},
+		&corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-data"},
+			Spec:       corev1.PersistentVolumeSpec{PersistentVolumeReclaimPolicy: retain},
+		},
+	)
+
+	deploymentGVR := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	snapshot := newNamespacedObject("snapshot.storage.k8s.io/v1", "VolumeSnapshot", "app", "nightly")
+	snapshot.SetFinalizers([]string{"snapshot.storage.kubernetes.io/volumesnapshot-as-source-protection"})
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			deploymentGVR:                      "DeploymentList",
+			widgetGVR:                          "WidgetList",
+			volumeSnapshotsResource.GVR:        "VolumeSnapshotList",
+			volumeSnapshotContentsGVR:          "VolumeSnapshotContentList",
+			podsResource.GVR:                   "PodList",
+			persistentVolumeClaimsResource.GVR: "PersistentVolumeClaimList",
+		},
+		newNamespacedObject("apps/v1", "Deployment", "app", "web"),
+		newWidget("app", "gadget", "example.com/cleanup"),
+		snapshot,
+	)
+	verbs := metav1.Verbs{"get", "list", "delete", "patch", "update"}
+	discoveryClient := newFakeDiscovery(
+		&metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: verbs},
+			{Name: "persistentvolumeclaims", Kind: "PersistentVolumeClaim", Namespaced: true, Verbs: verbs},
+		}},
+		&metav1.APIResourceList{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments", SingularName: "deployment", Kind: "Deployment", Namespaced: true, Verbs: verbs},
+		}},
+		&metav1.APIResourceList{GroupVersion: "snapshot.storage.k8s.io/v1", APIResources: []metav1.APIResource{
+			{Name: "volumesnapshots", Kind: "VolumeSnapshot", Namespaced: true, Verbs: verbs},
+		}},
+		widgetResourceList(),
+	)
+
+	opts := NukeOptions{ContentsOnly: true, Kinds: KindFilter{Only: []string{"deployments"}}, FinalizerTimeout: time.Millisecond}
+	ctx := context.TODO()
+	if err := nukeNamespace(ctx, clientset, discoveryClient, dynamicClient, "app", opts); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := dynamicClient.Resource(deploymentGVR).Namespace("app").Get(ctx, "web", metav1.GetOptions{}); err == nil {
+		t.Errorf("expected the deployment to be deleted")
+	}
+	if _, err := clientset.CoreV1().Pods("app").Get(ctx, "web-0", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the pod to be left alone, got %v", err)
+	}
+	if _, err := clientset.CoreV1().PersistentVolumeClaims("app").Get(ctx, "data", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the PVC to be left alone, got %v", err)
+	}
+	if obj, err := dynamicClient.Resource(volumeSnapshotsResource.GVR).Namespace("app").Get(ctx, "nightly", metav1.GetOptions{}); err != nil || len(obj.GetFinalizers()) != 1 {
+		t.Errorf("expected the snapshot to be left alone with its finalizer, got %v", err)
+	}
+	if obj, err := dynamicClient.Resource(widgetGVR).Namespace("app").Get(ctx, "gadget", metav1.GetOptions{}); err != nil || len(obj.GetFinalizers()) != 1 {
+		t.Errorf("expected the widget to keep its finalizer, got %v", err)
+	}
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "delete" || action.GetVerb() == "patch" || action.GetVerb() == "update" {
+			t.Errorf("expected no writes outside deployments, got %s %s", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+}
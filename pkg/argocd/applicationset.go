@@ -0,0 +1,227 @@
+package argocd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ArgoCD ApplicationSet CRD
+const (
+	ApplicationSetResource = "applicationsets"
+	ApplicationSetKind     = "ApplicationSet"
+)
+
+// ApplicationSetOwnership links an ApplicationSet to the detected Applications it generated
+type ApplicationSetOwnership struct {
+	ApplicationSet unstructured.Unstructured
+	Applications   []unstructured.Unstructured
+}
+
+// ApplicationNames returns the names of the generated Applications
+func (o ApplicationSetOwnership) ApplicationNames() []string {
+	names := make([]string, 0, len(o.Applications))
+	for _, app := range o.Applications {
+		names = append(names, app.GetName())
+	}
+	return names
+}
+
+// ListElementRef points at a list generator element that produced an Application
+type ListElementRef struct {
+	Generator   int
+	Element     int
+	Application string
+	Value       interface{}
+}
+
+// ApplicationSetExclusion describes the list generator elements to remove so an ApplicationSet stops generating
+// the given Applications. Unmatched lists the Applications no list element could be found for.
+type ApplicationSetExclusion struct {
+	Elements  []ListElementRef
+	Unmatched []string
+}
+
+// FindOwningApplicationSets groups Applications by the ApplicationSet named in their ownerReferences.
+// Applications that were not generated by an ApplicationSet are skipped.
+func (d *Detector) FindOwningApplicationSets(ctx context.Context, apps []unstructured.Unstructured) ([]ApplicationSetOwnership, error) {
+	var ownerships []ApplicationSetOwnership
+	index := map[string]int{}
+
+	appSetGVR, served, err := d.resource(ApplicationSetResource)
+	if err != nil || !served {
+		return nil, err // Without the ApplicationSet CRD, nothing can own the applications
+	}
+
+	for _, app := range apps {
+		ownerName, ok := owningApplicationSetName(app)
+		if !ok {
+			continue
+		}
+
+		key := app.GetNamespace() + "/" + ownerName
+		if i, seen := index[key]; seen {
+			ownerships[i].Applications = append(ownerships[i].Applications, app)
+			continue
+		}
+
+		appSet, err := d.dynamicClient.Resource(appSetGVR).Namespace(app.GetNamespace()).Get(ctx, ownerName, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue // Owner already gone, nothing will regenerate the application
+			}
+			return ownerships, fmt.Errorf("failed to get ArgoCD ApplicationSet %s: %w", key, err)
+		}
+
+		index[key] = len(ownerships)
+		ownerships = append(ownerships, ApplicationSetOwnership{
+			ApplicationSet: *appSet,
+			Applications:   []unstructured.Unstructured{app},
+		})
+	}
+
+	return ownerships, nil
+}
+
+// owningApplicationSetName returns the name of the ApplicationSet owning an Application, if any
+func owningApplicationSetName(app unstructured.Unstructured) (string, bool) {
+	for _, ref := range app.GetOwnerReferences() {
+		if ref.Kind != ApplicationSetKind {
+			continue
+		}
+		if gv, err := schema.ParseGroupVersion(ref.APIVersion); err == nil && gv.Group == ArgoCDGroup {
+			return ref.Name, true
+		}
+	}
+	return "", false
+}
+
+// DeleteApplicationSet deletes an ApplicationSet while orphaning its Applications, so only the Applications
+// that are deleted explicitly go away
+func (h *Handler) DeleteApplicationSet(ctx context.Context, appSet unstructured.Unstructured) error {
+	propagation := metav1.DeletePropagationOrphan
+	err := h.dynamicClient.Resource(h.resource(ApplicationSetResource)).Namespace(appSet.GetNamespace()).Delete(ctx, appSet.GetName(), metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete ArgoCD ApplicationSet %s/%s: %w", appSet.GetNamespace(), appSet.GetName(), err)
+	}
+	return nil
+}
+
+// PlanApplicationSetExclusion finds the list generator elements that render to the names of the generated
+// Applications. Other generators (git, cluster, matrix, ...) can't be edited safely and leave Applications unmatched.
+func PlanApplicationSetExclusion(ownership ApplicationSetOwnership) ApplicationSetExclusion {
+	exclusion := ApplicationSetExclusion{}
+
+	nameTemplate, _, _ := unstructured.NestedString(ownership.ApplicationSet.Object, "spec", "template", "metadata", "name")
+	generators, _, _ := unstructured.NestedSlice(ownership.ApplicationSet.Object, "spec", "generators")
+
+	matched := map[string]bool{}
+	for gi, generator := range generators {
+		generatorMap, ok := generator.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		elements, found, _ := unstructured.NestedSlice(generatorMap, "list", "elements")
+		if !found {
+			continue
+		}
+		for ei, element := range elements {
+			params, ok := element.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			rendered := renderApplicationSetTemplate(nameTemplate, params)
+			for _, app := range ownership.Applications {
+				if app.GetName() == rendered {
+					exclusion.Elements = append(exclusion.Elements, ListElementRef{
+						Generator:   gi,
+						Element:     ei,
+						Application: rendered,
+						Value:       element,
+					})
+					matched[rendered] = true
+				}
+			}
+		}
+	}
+
+	for _, app := range ownership.Applications {
+		if !matched[app.GetName()] {
+			exclusion.Unmatched = append(exclusion.Unmatched, app.GetName())
+		}
+	}
+	return exclusion
+}
+
+// ExcludeGeneratedApplications removes the list generator elements that generated the Applications, so the
+// ApplicationSet keeps managing everything else. It returns the exclusion that was applied.
+func (h *Handler) ExcludeGeneratedApplications(ctx context.Context, ownership ApplicationSetOwnership) (ApplicationSetExclusion, error) {
+	exclusion := PlanApplicationSetExclusion(ownership)
+	if len(exclusion.Elements) == 0 {
+		return exclusion, nil
+	}
+
+	patch, err := buildElementRemovalPatch(exclusion.Elements)
+	if err != nil {
+		return exclusion, err
+	}
+
+	appSet := ownership.ApplicationSet
+	_, err = h.dynamicClient.Resource(h.resource(ApplicationSetResource)).Namespace(appSet.GetNamespace()).Patch(
+		ctx, appSet.GetName(), types.JSONPatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return exclusion, fmt.Errorf("failed to exclude elements from ArgoCD ApplicationSet %s/%s: %w", appSet.GetNamespace(), appSet.GetName(), err)
+	}
+	return exclusion, nil
+}
+
+// buildElementRemovalPatch builds a JSON patch that removes list elements from the highest index down, guarding
+// each removal with a test so a concurrent edit of the ApplicationSet makes the patch fail instead of dropping the
+// wrong element
+func buildElementRemovalPatch(elements []ListElementRef) ([]byte, error) {
+	sorted := append([]ListElementRef(nil), elements...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Generator != sorted[j].Generator {
+			return sorted[i].Generator > sorted[j].Generator
+		}
+		return sorted[i].Element > sorted[j].Element
+	})
+
+	var ops []map[string]interface{}
+	for _, ref := range sorted {
+		path := fmt.Sprintf("/spec/generators/%d/list/elements/%d", ref.Generator, ref.Element)
+		ops = append(ops,
+			map[string]interface{}{"op": "test", "path": path, "value": ref.Value},
+			map[string]interface{}{"op": "remove", "path": path},
+		)
+	}
+	return json.Marshal(ops)
+}
+
+// templateParamPattern matches ApplicationSet parameters in both fasttemplate ({{cluster}}) and Go template
+// ({{ .cluster }}) syntax
+var templateParamPattern = regexp.MustCompile(`\{\{\s*\.?([A-Za-z0-9_.\-]+)\s*\}\}`)
+
+// renderApplicationSetTemplate substitutes list element parameters into a template string. Nested values are
+// addressed with dots, as in {{values.suffix}}. Unknown parameters are left untouched.
+func renderApplicationSetTemplate(template string, params map[string]interface{}) string {
+	return templateParamPattern.ReplaceAllStringFunc(template, func(match string) string {
+		key := templateParamPattern.FindStringSubmatch(match)[1]
+		value, found, err := unstructured.NestedFieldNoCopy(params, strings.Split(key, ".")...)
+		if err != nil || !found {
+			return match
+		}
+		return fmt.Sprintf("%v", value)
+	})
+}
@@ -0,0 +1,116 @@
+package argocd
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newApplication(name, ownerAppSet string) unstructured.Unstructured {
+	app := unstructured.Unstructured{}
+	app.SetAPIVersion(ArgoCDGroup + "/" + ArgoCDVersion)
+	app.SetKind(ArgoCDKind)
+	app.SetNamespace("argocd")
+	app.SetName(name)
+	if ownerAppSet != "" {
+		app.SetOwnerReferences([]metav1.OwnerReference{{
+			APIVersion: ArgoCDGroup + "/" + ArgoCDVersion,
+			Kind:       ApplicationSetKind,
+			Name:       ownerAppSet,
+		}})
+	}
+	return app
+}
+
+func newListApplicationSet(name, nameTemplate string, elements ...interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": ArgoCDGroup + "/" + ArgoCDVersion,
+		"kind":       ApplicationSetKind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": "argocd"},
+		"spec": map[string]interface{}{
+			"generators": []interface{}{
+				map[string]interface{}{"git": map[string]interface{}{"repoURL": "https://example.com/repo.git"}},
+				map[string]interface{}{"list": map[string]interface{}{"elements": elements}},
+			},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"name": nameTemplate},
+			},
+		},
+	}}
+}
+
+func newFakeArgoCDClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			applicationGVR:    "ApplicationList",
+			applicationSetGVR: "ApplicationSetList",
+		},
+		objects...,
+	)
+}
+
+func TestFindOwningApplicationSets(t *testing.T) {
+	dynamicClient := newFakeArgoCDClient(newListApplicationSet("teams", "{{team}}-app"))
+	detector := NewDetector(newFakeKubeClient(), dynamicClient)
+
+	apps := []unstructured.Unstructured{
+		newApplication("a-app", "teams"),
+		newApplication("standalone", ""),
+		newApplication("b-app", "teams"),
+		newApplication("orphan", "deleted-appset"),
+	}
+	ownerships, err := detector.FindOwningApplicationSets(context.TODO(), apps)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(ownerships) != 1 {
+		t.Fatalf("expected 1 owning ApplicationSet, got %d", len(ownerships))
+	}
+	if ownerships[0].ApplicationSet.GetName() != "teams" {
+		t.Errorf("expected ApplicationSet teams, got %s", ownerships[0].ApplicationSet.GetName())
+	}
+	if names := ownerships[0].ApplicationNames(); len(names) != 2 || names[0] != "a-app" || names[1] != "b-app" {
+		t.Errorf("expected a-app and b-app to be grouped under teams, got %v", names)
+	}
+}
+
+func TestExcludeGeneratedApplications(t *testing.T) {
+	appSet := newListApplicationSet("teams", "{{ .team }}-{{values.env}}",
+		map[string]interface{}{"team": "a", "values": map[string]interface{}{"env": "dev"}},
+		map[string]interface{}{"team": "b", "values": map[string]interface{}{"env": "dev"}},
+		map[string]interface{}{"team": "c", "values": map[string]interface{}{"env": "dev"}},
+	)
+	dynamicClient := newFakeArgoCDClient(appSet)
+	handler := NewHandler(dynamicClient)
+	ctx := context.TODO()
+
+	ownership := ApplicationSetOwnership{
+		ApplicationSet: *appSet,
+		Applications:   []unstructured.Unstructured{newApplication("a-dev", "teams"), newApplication("c-dev", "teams"), newApplication("git-app", "teams")},
+	}
+	exclusion, err := handler.ExcludeGeneratedApplications(ctx, ownership)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(exclusion.Elements) != 2 {
+		t.Errorf("expected 2 list elements to be excluded, got %d", len(exclusion.Elements))
+	}
+	if len(exclusion.Unmatched) != 1 || exclusion.Unmatched[0] != "git-app" {
+		t.Errorf("expected git-app to be unmatched, got %v", exclusion.Unmatched)
+	}
+
+	updated, err := dynamicClient.Resource(applicationSetGVR).Namespace("argocd").Get(ctx, "teams", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get ApplicationSet: %v", err)
+	}
+	generators, _, _ := unstructured.NestedSlice(updated.Object, "spec", "generators")
+	elements, _, _ := unstructured.NestedSlice(generators[1].(map[string]interface{}), "list", "elements")
+	if len(elements) != 1 || elements[0].(map[string]interface{})["team"] != "b" {
+		t.Errorf("expected only team b to remain, got %v", elements)
+	}
+}
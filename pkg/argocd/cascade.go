@@ -0,0 +1,165 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Workload resources the ArgoCD controllers run as
+var (
+	deploymentGVR  = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	statefulSetGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}
+)
+
+// cascadeComponents are the ArgoCD workloads a cascading delete depends on: the application controller deletes
+// the resources, and it can't work out what to delete for an Application whose manifests the repo server can't render
+var cascadeComponents = []string{"argocd-application-controller", "argocd-repo-server"}
+
+// applicationResources returns the entries of an Application's status.resources, the live resources ArgoCD
+// still tracks for it
+func applicationResources(app unstructured.Unstructured) []interface{} {
+	resources, _, _ := unstructured.NestedSlice(app.Object, "status", "resources")
+	return resources
+}
+
+// CascadesOnDelete reports whether deleting the Application makes ArgoCD delete its resources
+func CascadesOnDelete(app unstructured.Unstructured) bool {
+	for _, finalizer := range app.GetFinalizers() {
+		if isResourcesFinalizer(finalizer) {
+			return true
+		}
+	}
+	return false
+}
+
+// DiagnoseStuckCascade explains why ArgoCD hasn't finished deleting an Application: its controller or repo server
+// isn't running, or the Application reports an error condition. It returns nothing when no cause is found.
+func (h *Handler) DiagnoseStuckCascade(ctx context.Context, app unstructured.Unstructured) []string {
+	var issues []string
+
+	for _, component := range cascadeComponents {
+		ready, found, err := h.componentReady(ctx, component)
+		switch {
+		case err != nil:
+			issues = append(issues, fmt.Sprintf("could not check %s: %v", component, err))
+		case !found:
+			issues = append(issues, fmt.Sprintf("%s is not running on this cluster; nothing will process the deletion", component))
+		case !ready:
+			issues = append(issues, fmt.Sprintf("%s has no ready replicas", component))
+		}
+	}
+
+	current, err := h.dynamicClient.Resource(h.resource(ArgoCDResource)).Namespace(app.GetNamespace()).Get(ctx, app.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return issues
+	}
+	conditions, _, _ := unstructured.NestedSlice(current.Object, "status", "conditions")
+	for _, condition := range conditions {
+		conditionMap, ok := condition.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		conditionType, _, _ := unstructured.NestedString(conditionMap, "type")
+		if !strings.HasSuffix(conditionType, "Error") {
+			continue
+		}
+		message, _, _ := unstructured.NestedString(conditionMap, "message")
+		issues = append(issues, fmt.Sprintf("%s: %s", conditionType, message))
+	}
+	if count := len(applicationResources(*current)); count > 0 {
+		issues = append(issues, fmt.Sprintf("%d managed resource(s) still listed in status.resources", count))
+	}
+	return issues
+}
+
+// componentReady looks for an ArgoCD workload by its app.kubernetes.io/name label in any namespace and reports
+// whether any instance has a ready replica
+func (h *Handler) componentReady(ctx context.Context, component string) (bool, bool, error) {
+	found := false
+	for _, gvr := range []schema.GroupVersionResource{deploymentGVR, statefulSetGVR} {
+		list, err := h.dynamicClient.Resource(gvr).List(ctx, metav1.ListOptions{LabelSelector: LabelArgoCDName + "=" + component})
+		if err != nil {
+			return false, false, err
+		}
+		for _, workload := range list.Items {
+			found = true
+			if ready, _, _ := unstructured.NestedInt64(workload.Object, "status", "readyReplicas"); ready > 0 {
+				return true, true, nil
+			}
+		}
+	}
+	return false, found, nil
+}
+
+// countForApplications counts the tracked resources that belong to the referenced Applications
+func countForApplications(refs map[ApplicationRef]unstructured.Unstructured, annotated map[ApplicationRef]int, labelled map[ApplicationRef][]objectRef) int {
+	count := 0
+	for ref, n := range annotated {
+		if _, ok := refs[ref]; ok {
+			count += n
+		}
+	}
+	for ref, objects := range labelled {
+		app, ok := refs[ref]
+		if !ok {
+			continue
+		}
+		for _, obj := range objects {
+			if listsResource(app, obj) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// WaitForTrackedResources waits until no live resource in the namespace is tracked to one of the Applications.
+// An Application with a background cascade is gone before its resources are, so its deletion alone doesn't mean
+// ArgoCD is done. It returns the number of tracked resources left when the timeout expires.
+func (d *Detector) WaitForTrackedResources(ctx context.Context, namespace string, apps []unstructured.Unstructured, timeout time.Duration) (int, error) {
+	refs := map[ApplicationRef]unstructured.Unstructured{}
+	for _, app := range apps {
+		refs[ApplicationRef{Name: app.GetName()}] = app
+		refs[ApplicationRef{Namespace: app.GetNamespace(), Name: app.GetName()}] = app
+	}
+	if len(refs) == 0 {
+		return 0, nil
+	}
+
+	// Discover once: later rounds only list the types that still hold tracked resources, since ArgoCD doesn't
+	// create new ones for Applications being deleted
+	types, err := d.listableResourceTypes()
+	if err != nil {
+		return 0, err
+	}
+
+	remaining := 0
+	err = wait.PollImmediate(2*time.Second, timeout, func() (bool, error) {
+		remaining = 0
+		var tracked []resourceType
+		for _, rt := range types {
+			annotated, labelled := map[ApplicationRef]int{}, map[ApplicationRef][]objectRef{}
+			if err := d.collectTrackedResources(ctx, namespace, rt, annotated, labelled); err != nil {
+				tracked = append(tracked, rt) // Try again next round
+				continue
+			}
+			if count := countForApplications(refs, annotated, labelled); count > 0 {
+				tracked = append(tracked, rt)
+				remaining += count
+			}
+		}
+		types = tracked
+		return remaining == 0, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return remaining, nil
+	}
+	return remaining, err
+}
@@ -0,0 +1,149 @@
+package argocd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func newWorkload(kind, name string, readyReplicas int64) *unstructured.Unstructured {
+	workload := &unstructured.Unstructured{}
+	workload.SetAPIVersion("apps/v1")
+	workload.SetKind(kind)
+	workload.SetNamespace("argocd")
+	workload.SetName(name)
+	workload.SetLabels(map[string]string{LabelArgoCDName: name})
+	unstructured.SetNestedField(workload.Object, readyReplicas, "status", "readyReplicas")
+	return workload
+}
+
+func TestDiagnoseStuckCascade(t *testing.T) {
+	app := newApplication("shop", "")
+	app.SetFinalizers([]string{ResourcesFinalizer})
+	unstructured.SetNestedSlice(app.Object, []interface{}{
+		map[string]interface{}{"type": "ComparisonError", "message": "rpc error: repository not accessible"},
+		map[string]interface{}{"type": "SyncWarning", "message": "ignored"},
+	}, "status", "conditions")
+	unstructured.SetNestedSlice(app.Object, []interface{}{
+		map[string]interface{}{"kind": "ConfigMap", "name": "settings", "namespace": "shop"},
+	}, "status", "resources")
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			applicationGVR: "ApplicationList",
+			deploymentGVR:  "DeploymentList",
+			statefulSetGVR: "StatefulSetList",
+		},
+		&app,
+		newWorkload("Deployment", "argocd-repo-server", 0),
+	)
+
+	issues := NewHandler(dynamicClient).DiagnoseStuckCascade(context.TODO(), app)
+	expected := []string{
+		"argocd-application-controller is not running",
+		"argocd-repo-server has no ready replicas",
+		"ComparisonError: rpc error: repository not accessible",
+		"1 managed resource(s) still listed",
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %v", len(expected), issues)
+	}
+	for i, prefix := range expected {
+		if !strings.HasPrefix(issues[i], prefix) {
+			t.Errorf("expected issue %d to start with %q, got %q", i, prefix, issues[i])
+		}
+	}
+}
+
+func TestWaitForTrackedResources(t *testing.T) {
+	kubeClient := k8sfake.NewSimpleClientset()
+	kubeClient.Fake.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+		},
+	}}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{configMapGVR: "ConfigMapList"},
+		newTrackedConfigMap("a", nil, map[string]string{AnnotationTrackingID: "shop:/ConfigMap:shop/a"}),
+		newTrackedConfigMap("b", nil, map[string]string{AnnotationTrackingID: "other:/ConfigMap:shop/b"}),
+	)
+	detector := NewDetector(kubeClient, dynamicClient)
+	ctx := context.TODO()
+
+	remaining, err := detector.WaitForTrackedResources(ctx, "shop", []unstructured.Unstructured{newApplication("shop", "")}, time.Millisecond)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if remaining != 1 {
+		t.Errorf("expected 1 resource still tracked to shop, got %d", remaining)
+	}
+
+	if err := dynamicClient.Resource(configMapGVR).Namespace("shop").Delete(ctx, "a", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("failed to delete config map: %v", err)
+	}
+	remaining, err = detector.WaitForTrackedResources(ctx, "shop", []unstructured.Unstructured{newApplication("shop", "")}, time.Second)
+	if err != nil || remaining != 0 {
+		t.Errorf("expected the cascade to be finished, got %d remaining and error %v", remaining, err)
+	}
+}
+
+func TestWaitForTrackedResourcesPollsOnlyTrackedTypes(t *testing.T) {
+	kubeClient := k8sfake.NewSimpleClientset()
+	kubeClient.Fake.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+			{Name: "secrets", Kind: "Secret", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+		},
+	}}
+	secretGVR := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{configMapGVR: "ConfigMapList", secretGVR: "SecretList"},
+		newTrackedConfigMap("a", nil, map[string]string{AnnotationTrackingID: "shop:/ConfigMap:shop/a"}),
+	)
+	// ArgoCD deletes the config map between the first and second round
+	configMapLists := 0
+	dynamicClient.PrependReactor("list", "configmaps", func(clienttesting.Action) (bool, runtime.Object, error) {
+		configMapLists++
+		if configMapLists == 2 {
+			if err := dynamicClient.Tracker().Delete(configMapGVR, "shop", "a"); err != nil {
+				t.Errorf("failed to delete config map: %v", err)
+			}
+		}
+		return false, nil, nil
+	})
+	detector := NewDetector(kubeClient, dynamicClient)
+
+	remaining, err := detector.WaitForTrackedResources(context.TODO(), "shop", []unstructured.Unstructured{newApplication("shop", "")}, 10*time.Second)
+	if err != nil || remaining != 0 {
+		t.Fatalf("expected the cascade to be finished, got %d remaining and error %v", remaining, err)
+	}
+
+	discoveries, secretLists := 0, 0
+	for _, action := range kubeClient.Actions() {
+		if action.GetResource().Resource == "resource" {
+			discoveries++
+		}
+	}
+	for _, action := range dynamicClient.Actions() {
+		if action.GetVerb() == "list" && action.GetResource() == secretGVR {
+			secretLists++
+		}
+	}
+	if configMapLists != 2 || secretLists != 1 {
+		t.Errorf("expected config maps listed twice and secrets once, got %d and %d", configMapLists, secretLists)
+	}
+	if discoveries != 1 {
+		t.Errorf("expected API resources to be discovered once, got %d", discoveries)
+	}
+}
@@ -0,0 +1,131 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ArgoCD cluster registration
+const (
+	InClusterServer = "https://kubernetes.default.svc"
+	InClusterName   = "in-cluster"
+
+	// LabelArgoCDSecretType marks the Secrets ArgoCD stores cluster credentials in
+	LabelArgoCDSecretType = "argocd.argoproj.io/secret-type"
+	SecretTypeCluster     = "cluster"
+)
+
+// ClusterIdentity lists the server URLs and cluster names ArgoCD uses for the cluster kubectl-nuke is talking to
+type ClusterIdentity struct {
+	Servers []string `json:"servers"`
+	Names   []string `json:"names"`
+}
+
+// Matches reports whether an Application deploys to this cluster, by spec.destination.server or spec.destination.name
+func (c *ClusterIdentity) Matches(app unstructured.Unstructured) bool {
+	server, _, _ := unstructured.NestedString(app.Object, "spec", "destination", "server")
+	if server != "" {
+		return containsString(c.Servers, normalizeServer(server))
+	}
+	name, _, _ := unstructured.NestedString(app.Object, "spec", "destination", "name")
+	if name != "" {
+		return containsString(c.Names, name)
+	}
+	return false
+}
+
+// String returns the cluster names and servers for display
+func (c *ClusterIdentity) String() string {
+	return fmt.Sprintf("%s (%s)", strings.Join(c.Names, ", "), strings.Join(c.Servers, ", "))
+}
+
+// ResolveCurrentCluster works out which ArgoCD destinations refer to the cluster behind apiServerHost. Applications
+// live in the cluster being queried, so the in-cluster destination always counts; cluster Secrets registering the
+// same API server under another name or URL are added. If the Secrets can't be read, only the in-cluster
+// destination and apiServerHost are used and the error is returned alongside.
+func (d *Detector) ResolveCurrentCluster(ctx context.Context, apiServerHost string) (*ClusterIdentity, error) {
+	servers := []string{InClusterServer}
+	if apiServerHost != "" && !containsString(servers, normalizeServer(apiServerHost)) {
+		servers = append(servers, normalizeServer(apiServerHost))
+	}
+	names := map[string]bool{InClusterName: true}
+
+	secrets, err := d.kubeClient.CoreV1().Secrets("").List(ctx, metav1.ListOptions{
+		LabelSelector: LabelArgoCDSecretType + "=" + SecretTypeCluster,
+	})
+	if err != nil {
+		return &ClusterIdentity{Servers: servers, Names: []string{InClusterName}}, fmt.Errorf("failed to list ArgoCD cluster secrets: %w", err)
+	}
+
+	for _, secret := range secrets.Items {
+		server := normalizeServer(string(secret.Data["server"]))
+		name := string(secret.Data["name"])
+		if server == "" {
+			continue
+		}
+		if containsString(servers, server) {
+			if name != "" {
+				names[name] = true
+			}
+		} else if name == InClusterName {
+			// The in-cluster name has been reassigned to another cluster
+			delete(names, InClusterName)
+		}
+	}
+
+	identity := &ClusterIdentity{Servers: servers}
+	for name := range names {
+		identity.Names = append(identity.Names, name)
+	}
+	sort.Strings(identity.Names)
+	return identity, nil
+}
+
+// ManagedNamespaces returns the namespaces an Application deploys to: its destination namespace plus every
+// namespace listed in status.resources
+func ManagedNamespaces(app unstructured.Unstructured) []string {
+	seen := map[string]bool{}
+	var namespaces []string
+	add := func(ns string) {
+		if ns != "" && !seen[ns] {
+			seen[ns] = true
+			namespaces = append(namespaces, ns)
+		}
+	}
+
+	destination, _, _ := unstructured.NestedString(app.Object, "spec", "destination", "namespace")
+	add(destination)
+
+	resources, _, _ := unstructured.NestedSlice(app.Object, "status", "resources")
+	for _, resource := range resources {
+		if resourceMap, ok := resource.(map[string]interface{}); ok {
+			ns, _, _ := unstructured.NestedString(resourceMap, "namespace")
+			add(ns)
+		}
+	}
+	return namespaces
+}
+
+// normalizeServer makes API server URLs comparable
+func normalizeServer(server string) string {
+	server = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(server)), "/")
+	if server != "" && !strings.Contains(server, "://") {
+		server = "https://" + server
+	}
+	return server
+}
+
+// containsString checks if a string slice contains a specific string
+func containsString(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package argocd
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newClusterSecret(name, clusterName, server string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "argocd",
+			Labels:    map[string]string{LabelArgoCDSecretType: SecretTypeCluster},
+		},
+		Data: map[string][]byte{"name": []byte(clusterName), "server": []byte(server)},
+	}
+}
+
+func newDestinationApplication(name string, destination map[string]interface{}, resourceNamespaces ...string) *unstructured.Unstructured {
+	app := newApplication(name, "")
+	unstructured.SetNestedMap(app.Object, destination, "spec", "destination")
+	var resources []interface{}
+	for _, ns := range resourceNamespaces {
+		resources = append(resources, map[string]interface{}{"kind": "ConfigMap", "name": "cm", "namespace": ns})
+	}
+	if resources != nil {
+		unstructured.SetNestedSlice(app.Object, resources, "status", "resources")
+	}
+	return &app
+}
+
+func TestResolveCurrentCluster(t *testing.T) {
+	kubeClient := k8sfake.NewSimpleClientset(
+		newClusterSecret("mgmt", "management", "https://10.0.0.1:6443/"),
+		newClusterSecret("prod", "prod", "https://prod.example.com"),
+	)
+	detector := NewDetector(kubeClient, nil)
+
+	cluster, err := detector.ResolveCurrentCluster(context.TODO(), "https://10.0.0.1:6443")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		destination map[string]interface{}
+		expected    bool
+	}{
+		{map[string]interface{}{"server": "https://kubernetes.default.svc"}, true},
+		{map[string]interface{}{"server": "https://10.0.0.1:6443"}, true},
+		{map[string]interface{}{"name": "in-cluster"}, true},
+		{map[string]interface{}{"name": "management"}, true},
+		{map[string]interface{}{"name": "prod"}, false},
+		{map[string]interface{}{"server": "https://prod.example.com"}, false},
+		{map[string]interface{}{}, false},
+	}
+	for _, tt := range tests {
+		app := newDestinationApplication("app", tt.destination)
+		if got := cluster.Matches(*app); got != tt.expected {
+			t.Errorf("Matches(%v) = %v, expected %v", tt.destination, got, tt.expected)
+		}
+	}
+}
+
+func TestDetectArgoCDAppsForCluster(t *testing.T) {
+	dynamicClient := newFakeArgoCDClient(
+		newDestinationApplication("local", map[string]interface{}{"server": InClusterServer, "namespace": "shop"}),
+		newDestinationApplication("remote", map[string]interface{}{"name": "prod", "namespace": "shop"}),
+		newDestinationApplication("spanning", map[string]interface{}{"server": InClusterServer, "namespace": "platform"}, "platform", "shop"),
+		newDestinationApplication("unrelated", map[string]interface{}{"server": InClusterServer, "namespace": "other"}),
+	)
+	detector := NewDetector(newFakeKubeClient(), dynamicClient)
+	cluster, err := detector.ResolveCurrentCluster(context.TODO(), "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	matching, otherClusters, err := detector.DetectArgoCDAppsForCluster(context.TODO(), "shop", cluster)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var names []string
+	for _, app := range matching {
+		names = append(names, app.GetName())
+	}
+	if len(names) != 2 || names[0] != "local" || names[1] != "spanning" {
+		t.Errorf("expected local and spanning to match, got %v", names)
+	}
+	if len(otherClusters) != 1 || otherClusters[0].GetName() != "remote" {
+		t.Errorf("expected remote to be reported as another cluster, got %v", otherClusters)
+	}
+}
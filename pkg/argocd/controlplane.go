@@ -0,0 +1,215 @@
+package argocd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// ArgoCD AppProject CRD
+const (
+	AppProjectResource = "appprojects"
+	AppProjectKind     = "AppProject"
+)
+
+// controlPlaneComponents are the app.kubernetes.io/component values and name suffixes of ArgoCD's workloads
+var controlPlaneComponents = []string{"application-controller", "applicationset-controller", "repo-server", "server"}
+
+// ControlPlaneInfo describes the ArgoCD objects that lose their controller when a namespace is deleted
+type ControlPlaneInfo struct {
+	Namespace string
+	// Components lists the ArgoCD control plane workloads running in the namespace
+	Components []string
+	// Applications lists the Applications stored in the namespace and, when the control plane runs there,
+	// every other Application carrying an ArgoCD finalizer
+	Applications []unstructured.Unstructured
+	// AppProjects lists the AppProjects stored in the namespace
+	AppProjects []unstructured.Unstructured
+}
+
+// HostsControlPlane reports whether ArgoCD itself runs in the namespace
+func (c *ControlPlaneInfo) HostsControlPlane() bool {
+	return len(c.Components) > 0
+}
+
+// IsEmpty reports whether deleting the namespace leaves no ArgoCD objects without a controller
+func (c *ControlPlaneInfo) IsEmpty() bool {
+	return !c.HostsControlPlane() && len(c.Applications) == 0 && len(c.AppProjects) == 0
+}
+
+// DetectControlPlane checks whether the namespace hosts the ArgoCD control plane or stores Application and
+// AppProject objects (apps-in-any-namespace). Once the application controller is gone, nothing removes the
+// resources finalizer from those objects, so the namespace would hang, or, while the controller is still
+// shutting down, deleting them would cascade to everything they deployed.
+func (d *Detector) DetectControlPlane(ctx context.Context, namespace string) (*ControlPlaneInfo, error) {
+	info := &ControlPlaneInfo{Namespace: namespace}
+
+	deployments, err := d.kubeClient.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	for _, deployment := range deployments.Items {
+		if isControlPlaneComponent(deployment.Name, deployment.Labels) {
+			info.Components = append(info.Components, "deployment/"+deployment.Name)
+		}
+	}
+
+	statefulSets, err := d.kubeClient.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list statefulsets: %w", err)
+	}
+	for _, statefulSet := range statefulSets.Items {
+		if isControlPlaneComponent(statefulSet.Name, statefulSet.Labels) {
+			info.Components = append(info.Components, "statefulset/"+statefulSet.Name)
+		}
+	}
+
+	apps, err := d.listApplications(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, app := range apps {
+		if app.GetNamespace() == namespace || (info.HostsControlPlane() && hasArgoCDFinalizer(app)) {
+			info.Applications = append(info.Applications, app)
+		}
+	}
+
+	projectGVR, served, err := d.resource(AppProjectResource)
+	if err != nil {
+		return nil, err
+	}
+	if served {
+		projects, err := d.dynamicClient.Resource(projectGVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list ArgoCD AppProjects: %w", err)
+		}
+		info.AppProjects = projects.Items
+	}
+
+	return info, nil
+}
+
+// isControlPlaneComponent matches ArgoCD workloads by their standard labels or name suffix
+func isControlPlaneComponent(name string, labels map[string]string) bool {
+	if labels[LabelArgoCDPartOf] == "argocd" && containsString(controlPlaneComponents, labels["app.kubernetes.io/component"]) {
+		return true
+	}
+	for _, component := range controlPlaneComponents {
+		if strings.HasSuffix(name, "argocd-"+component) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsArgoCDFinalizer matches the finalizers ArgoCD's controllers remove, such as
+// resources-finalizer.argocd.argoproj.io/background or post-delete-finalizer.argocd.argoproj.io
+func IsArgoCDFinalizer(finalizer string) bool {
+	name := strings.SplitN(finalizer, "/", 2)[0]
+	return strings.HasSuffix(name, ".argocd.argoproj.io")
+}
+
+// hasArgoCDFinalizer checks if an object carries any ArgoCD finalizer
+func hasArgoCDFinalizer(obj unstructured.Unstructured) bool {
+	for _, finalizer := range obj.GetFinalizers() {
+		if IsArgoCDFinalizer(finalizer) {
+			return true
+		}
+	}
+	return false
+}
+
+// StripControlPlaneFinalizers removes the ArgoCD finalizers from the Applications and AppProjects in info, so
+// they can be deleted without a running controller and without cascading to what they deployed. It returns the
+// number of objects patched.
+func (h *Handler) StripControlPlaneFinalizers(ctx context.Context, info *ControlPlaneInfo) (int, error) {
+	stripped := 0
+	var failed []string
+
+	objects := append(append([]unstructured.Unstructured(nil), info.Applications...), info.AppProjects...)
+	for _, obj := range objects {
+		gvr := h.resource(ArgoCDResource)
+		if obj.GetKind() == AppProjectKind {
+			gvr = h.resource(AppProjectResource)
+		}
+		removed, err := removeFinalizers(ctx, h.dynamicClient.Resource(gvr).Namespace(obj.GetNamespace()), obj.GetName(), IsArgoCDFinalizer)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s %s/%s: %v", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err))
+			continue
+		}
+		if removed {
+			stripped++
+		}
+	}
+
+	if len(failed) > 0 {
+		return stripped, fmt.Errorf("failed to strip finalizers from %d object(s): %s", len(failed), strings.Join(failed, "; "))
+	}
+	return stripped, nil
+}
+
+// removeFinalizers removes the finalizers selected by match from an object with a guarded JSON patch, removing from
+// the highest index down and testing each value first so a concurrent change never drops the wrong entry
+func removeFinalizers(ctx context.Context, resourceClient dynamic.ResourceInterface, name string, match func(string) bool) (bool, error) {
+	current, err := resourceClient.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	var ops []map[string]interface{}
+	finalizers := current.GetFinalizers()
+	for i := len(finalizers) - 1; i >= 0; i-- {
+		if !match(finalizers[i]) {
+			continue
+		}
+		path := fmt.Sprintf("/metadata/finalizers/%d", i)
+		ops = append(ops,
+			map[string]interface{}{"op": "test", "path": path, "value": finalizers[i]},
+			map[string]interface{}{"op": "remove", "path": path},
+		)
+	}
+	if len(ops) == 0 {
+		return false, nil
+	}
+
+	patch, err := json.Marshal(ops)
+	if err != nil {
+		return false, err
+	}
+	if _, err := resourceClient.Patch(ctx, name, types.JSONPatchType, patch, metav1.PatchOptions{}); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ApplicationDestinations returns the distinct destination clusters of the Applications, sorted
+func ApplicationDestinations(apps []unstructured.Unstructured) []string {
+	seen := map[string]bool{}
+	var destinations []string
+	for _, app := range apps {
+		destination, _, _ := unstructured.NestedString(app.Object, "spec", "destination", "server")
+		if destination == "" {
+			destination, _, _ = unstructured.NestedString(app.Object, "spec", "destination", "name")
+		}
+		if destination != "" && !seen[destination] {
+			seen[destination] = true
+			destinations = append(destinations, destination)
+		}
+	}
+	sort.Strings(destinations)
+	return destinations
+}
@@ -0,0 +1,87 @@
+package argocd
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestIsArgoCDFinalizer(t *testing.T) {
+	tests := map[string]bool{
+		ResourcesFinalizer:                            true,
+		ResourcesFinalizer + "/background":            true,
+		"post-delete-finalizer.argocd.argoproj.io":    true,
+		"kubernetes.io/pvc-protection":                false,
+		"example.com/argocd.argoproj.io-lookalike":    false,
+		"resources-finalizer.notargocd.argoproj.io.x": false,
+	}
+	for finalizer, expected := range tests {
+		if got := IsArgoCDFinalizer(finalizer); got != expected {
+			t.Errorf("IsArgoCDFinalizer(%q) = %v, expected %v", finalizer, got, expected)
+		}
+	}
+}
+
+func TestDetectAndStripControlPlane(t *testing.T) {
+	kubeClient := newFakeKubeClient(
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "argocd-application-controller", Namespace: "argocd"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "argocd"}},
+	)
+
+	local := newApplication("local", "")
+	local.SetFinalizers([]string{ResourcesFinalizer})
+	elsewhere := newApplication("elsewhere", "")
+	elsewhere.SetNamespace("team-a")
+	elsewhere.SetFinalizers([]string{"example.com/keep", ResourcesFinalizer + "/background"})
+	plain := newApplication("plain", "")
+	plain.SetNamespace("team-a")
+
+	project := &unstructured.Unstructured{}
+	project.SetAPIVersion(ArgoCDGroup + "/" + ArgoCDVersion)
+	project.SetKind(AppProjectKind)
+	project.SetNamespace("argocd")
+	project.SetName("default")
+	project.SetFinalizers([]string{ResourcesFinalizer})
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			applicationGVR: "ApplicationList",
+			appProjectGVR:  "AppProjectList",
+		},
+		&local, &elsewhere, &plain, project,
+	)
+	ctx := context.TODO()
+
+	info, err := NewDetector(kubeClient, dynamicClient).DetectControlPlane(ctx, "argocd")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !info.HostsControlPlane() || len(info.Components) != 1 {
+		t.Errorf("expected the application controller to be detected, got %v", info.Components)
+	}
+	if len(info.Applications) != 2 || len(info.AppProjects) != 1 {
+		t.Fatalf("expected 2 applications and 1 AppProject, got %d and %d", len(info.Applications), len(info.AppProjects))
+	}
+
+	stripped, err := NewHandler(dynamicClient).StripControlPlaneFinalizers(ctx, info)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if stripped != 3 {
+		t.Errorf("expected 3 objects to be patched, got %d", stripped)
+	}
+
+	updated, err := dynamicClient.Resource(applicationGVR).Namespace("team-a").Get(ctx, "elsewhere", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get application: %v", err)
+	}
+	if finalizers := updated.GetFinalizers(); len(finalizers) != 1 || finalizers[0] != "example.com/keep" {
+		t.Errorf("expected only the ArgoCD finalizer to be removed, got %v", finalizers)
+	}
+}
@@ -3,12 +3,10 @@ package argocd
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
@@ -38,6 +36,8 @@ const (
 type Detector struct {
 	kubeClient    kubernetes.Interface
 	dynamicClient dynamic.Interface
+	apiServerHost string
+	installation  *Installation
 }
 
 // NewDetector creates a new ArgoCD detector
@@ -48,44 +48,69 @@ func NewDetector(kubeClient kubernetes.Interface, dynamicClient dynamic.Interfac
 	}
 }
 
+// WithAPIServerHost records the API server URL from the kubeconfig, so ArgoCD cluster Secrets registering this
+// cluster under its external URL are recognised
+func (d *Detector) WithAPIServerHost(host string) *Detector {
+	d.apiServerHost = host
+	return d
+}
+
 // DetectArgoCDAppsForNamespace finds all ArgoCD Applications that manage resources in the given namespace
+// on the current cluster
 func (d *Detector) DetectArgoCDAppsForNamespace(ctx context.Context, namespace string) ([]unstructured.Unstructured, error) {
-	// Get ArgoCD Application GVR
-	appGVR := schema.GroupVersionResource{
-		Group:    ArgoCDGroup,
-		Version:  ArgoCDVersion,
-		Resource: ArgoCDResource,
-	}
-	
-	// List all ArgoCD applications in all namespaces
-	// ArgoCD apps can be in any namespace but typically in argocd namespace
-	allApps, err := d.dynamicClient.Resource(appGVR).List(ctx, metav1.ListOptions{})
+	// A partial identity is still usable when cluster secrets can't be read
+	cluster, _ := d.ResolveCurrentCluster(ctx, d.apiServerHost)
+
+	matchingApps, _, err := d.DetectArgoCDAppsForCluster(ctx, namespace, cluster)
+	return matchingApps, err
+}
+
+// DetectArgoCDAppsForCluster finds the ArgoCD Applications that deploy into the given namespace, either as their
+// destination namespace or through the resources they manage. Applications deploying into a namespace of the same
+// name on another cluster are returned separately so they can be reported, never deleted.
+func (d *Detector) DetectArgoCDAppsForCluster(ctx context.Context, namespace string, cluster *ClusterIdentity) ([]unstructured.Unstructured, []unstructured.Unstructured, error) {
+	allApps, err := d.listApplications(ctx)
 	if err != nil {
-		// If CRD doesn't exist, return empty list (ArgoCD might not be installed)
-		if strings.Contains(err.Error(), "the server could not find the requested resource") {
-			return []unstructured.Unstructured{}, nil
-		}
-		return nil, fmt.Errorf("failed to list ArgoCD applications: %w", err)
+		return nil, nil, err
 	}
 	
-	// Filter apps that target the namespace
-	var matchingApps []unstructured.Unstructured
-	for _, app := range allApps.Items {
-		// Check if app targets our namespace
-		targetNs, found, err := unstructured.NestedString(app.Object, "spec", "destination", "namespace")
-		if err != nil {
-			continue // Skip if error extracting namespace
+	// Filter apps that deploy into the namespace
+	var matchingApps, otherClusterApps []unstructured.Unstructured
+	for _, app := range allApps {
+		if !containsString(ManagedNamespaces(app), namespace) {
+			continue
 		}
 		
-		if found && targetNs == namespace {
+		if cluster.Matches(app) {
 			matchingApps = append(matchingApps, app)
+		} else {
+			otherClusterApps = append(otherClusterApps, app)
 		}
 	}
 	
-	return matchingApps, nil
+	return matchingApps, otherClusterApps, nil
+}
+
+// listApplications lists ArgoCD Applications in all namespaces, returning none if ArgoCD isn't installed
+func (d *Detector) listApplications(ctx context.Context) ([]unstructured.Unstructured, error) {
+	appGVR, served, err := d.resource(ArgoCDResource)
+	if err != nil {
+		return nil, err
+	}
+	if !served {
+		return []unstructured.Unstructured{}, nil // ArgoCD is not installed
+	}
+
+	// ArgoCD apps can be in any namespace but typically in argocd namespace
+	allApps, err := d.dynamicClient.Resource(appGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ArgoCD applications: %w", err)
+	}
+	return allApps.Items, nil
 }
 
-// IsArgoCDManagedResource checks if a resource is managed by ArgoCD
+// IsArgoCDManagedResource checks if a resource is managed by ArgoCD. Use TrackedApplicationRef or
+// DetectArgoCDAppsFromTracking to find which Application manages it.
 func (d *Detector) IsArgoCDManagedResource(resource *unstructured.Unstructured) bool {
 	// Check for ArgoCD labels
 	labels := resource.GetLabels()
@@ -100,9 +125,7 @@ func (d *Detector) IsArgoCDManagedResource(resource *unstructured.Unstructured)
 		if val, ok := labels[LabelArgoCDName]; ok && (val == "argocd-application" || val == "argocd") {
 			return true
 		}
-		if _, ok := labels[LabelArgoCDInstance]; ok {
-			return true
-		}
+		// app.kubernetes.io/instance alone is not enough: Helm sets it to the release name
 	}
 	
 	// Check for ArgoCD annotations
@@ -111,6 +134,9 @@ func (d *Detector) IsArgoCDManagedResource(resource *unstructured.Unstructured)
 		if _, ok := annotations[AnnotationArgoCDInstance]; ok {
 			return true
 		}
+		if _, ok := annotations[AnnotationTrackingID]; ok {
+			return true
+		}
 	}
 	
 	return false
@@ -41,7 +41,7 @@ func TestIsArgoCDManagedResource(t *testing.T) {
 			expected: true,
 		},
 		{
-			name: "Resource with ArgoCD instance label",
+			name: "Resource with only the instance label, which Helm sets too",
 			resource: &unstructured.Unstructured{
 				Object: map[string]interface{}{
 					"metadata": map[string]interface{}{
@@ -51,6 +51,19 @@ func TestIsArgoCDManagedResource(t *testing.T) {
 					},
 				},
 			},
+			expected: false,
+		},
+		{
+			name: "Resource with ArgoCD tracking-id annotation",
+			resource: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"annotations": map[string]interface{}{
+							AnnotationTrackingID: "my-app:apps/Deployment:shop/web",
+						},
+					},
+				},
+			},
 			expected: true,
 		},
 		{
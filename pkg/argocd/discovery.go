@@ -0,0 +1,86 @@
+package argocd
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Installation describes the Argo CD API the cluster serves, as found through API discovery
+type Installation struct {
+	Installed bool   `json:"installed"`
+	Group     string `json:"group"`
+	// Version is the preferred version of the argoproj.io group
+	Version string `json:"version,omitempty"`
+	// Versions lists every served version of the group
+	Versions []string `json:"versions,omitempty"`
+	// Resources lists the Argo CD resources served in the preferred version, such as applications and appprojects
+	Resources []string `json:"resources,omitempty"`
+}
+
+// Serves reports whether the preferred version serves the resource
+func (i *Installation) Serves(resource string) bool {
+	return i.Installed && containsString(i.Resources, resource)
+}
+
+// GroupVersionResource returns the resource in the preferred version
+func (i *Installation) GroupVersionResource(resource string) schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: i.Group, Version: i.Version, Resource: resource}
+}
+
+// DetectInstallation asks API discovery whether the argoproj.io group is served, in which preferred version and
+// with which resources. The result is cached for the life of the Detector. argoproj.io is shared with Argo
+// Workflows and Rollouts, so Installed requires the applications resource.
+func (d *Detector) DetectInstallation() (*Installation, error) {
+	if d.installation != nil {
+		return d.installation, nil
+	}
+
+	installation := &Installation{Group: ArgoCDGroup}
+	groups, err := d.kubeClient.Discovery().ServerGroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover API groups: %w", err)
+	}
+	for _, group := range groups.Groups {
+		if group.Name != ArgoCDGroup {
+			continue
+		}
+		installation.Version = group.PreferredVersion.Version
+		for _, version := range group.Versions {
+			installation.Versions = append(installation.Versions, version.Version)
+		}
+	}
+
+	if installation.Version != "" {
+		resourceList, err := d.kubeClient.Discovery().ServerResourcesForGroupVersion(installation.GroupVersionResource("").GroupVersion().String())
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to discover %s resources: %w", ArgoCDGroup, err)
+		}
+		if resourceList != nil {
+			for _, apiResource := range resourceList.APIResources {
+				if apiResource.Name == ArgoCDResource || apiResource.Name == ApplicationSetResource || apiResource.Name == AppProjectResource {
+					installation.Resources = append(installation.Resources, apiResource.Name)
+				}
+			}
+		}
+		sort.Strings(installation.Resources)
+		installation.Installed = containsString(installation.Resources, ArgoCDResource)
+	}
+
+	d.installation = installation
+	return installation, nil
+}
+
+// resource returns the preferred version of an Argo CD resource, and false when the cluster doesn't serve it
+func (d *Detector) resource(name string) (schema.GroupVersionResource, bool, error) {
+	installation, err := d.DetectInstallation()
+	if err != nil {
+		return schema.GroupVersionResource{}, false, err
+	}
+	if !installation.Serves(name) {
+		return schema.GroupVersionResource{}, false, nil
+	}
+	return installation.GroupVersionResource(name), true, nil
+}
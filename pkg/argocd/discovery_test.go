@@ -0,0 +1,68 @@
+package argocd
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+var (
+	applicationGVR    = schema.GroupVersionResource{Group: ArgoCDGroup, Version: ArgoCDVersion, Resource: ArgoCDResource}
+	applicationSetGVR = schema.GroupVersionResource{Group: ArgoCDGroup, Version: ArgoCDVersion, Resource: ApplicationSetResource}
+	appProjectGVR     = schema.GroupVersionResource{Group: ArgoCDGroup, Version: ArgoCDVersion, Resource: AppProjectResource}
+)
+
+// argoCDResources is the discovery document of an Argo CD installation
+func argoCDResources() *metav1.APIResourceList {
+	return &metav1.APIResourceList{
+		GroupVersion: ArgoCDGroup + "/" + ArgoCDVersion,
+		APIResources: []metav1.APIResource{
+			{Name: ArgoCDResource, Kind: ArgoCDKind, Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+			{Name: ApplicationSetResource, Kind: ApplicationSetKind, Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+			{Name: AppProjectResource, Kind: AppProjectKind, Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+		},
+	}
+}
+
+// newFakeKubeClient returns a clientset whose discovery serves Argo CD
+func newFakeKubeClient(objects ...runtime.Object) *k8sfake.Clientset {
+	kubeClient := k8sfake.NewSimpleClientset(objects...)
+	kubeClient.Fake.Resources = []*metav1.APIResourceList{argoCDResources()}
+	return kubeClient
+}
+
+func TestDetectInstallation(t *testing.T) {
+	kubeClient := k8sfake.NewSimpleClientset()
+	kubeClient.Fake.Resources = []*metav1.APIResourceList{{
+		// Argo Workflows shares the group
+		GroupVersion: ArgoCDGroup + "/" + ArgoCDVersion,
+		APIResources: []metav1.APIResource{{Name: "workflows", Kind: "Workflow", Namespaced: true}},
+	}}
+	installation, err := NewDetector(kubeClient, nil).DetectInstallation()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if installation.Installed || installation.Version != ArgoCDVersion {
+		t.Errorf("expected the group without Argo CD resources not to count as installed, got %+v", installation)
+	}
+
+	installation, err = NewDetector(newFakeKubeClient(), nil).DetectInstallation()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !installation.Installed || !installation.Serves(AppProjectResource) {
+		t.Errorf("expected Argo CD to be installed with AppProjects, got %+v", installation)
+	}
+	if gvr := installation.GroupVersionResource(ArgoCDResource); gvr != applicationGVR {
+		t.Errorf("expected %v, got %v", applicationGVR, gvr)
+	}
+
+	apps, err := NewDetector(k8sfake.NewSimpleClientset(), nil).listApplications(context.TODO())
+	if err != nil || len(apps) != 0 {
+		t.Errorf("expected no applications and no error without Argo CD, got %v, %v", apps, err)
+	}
+}
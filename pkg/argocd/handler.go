@@ -2,7 +2,9 @@ package argocd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -17,22 +19,66 @@ import (
 // Handler handles ArgoCD application deletion and cleanup
 type Handler struct {
 	dynamicClient dynamic.Interface
+	timeout       time.Duration
+	deadline      time.Time
+	version       string
 }
 
 // NewHandler creates a new ArgoCD handler
 func NewHandler(dynamicClient dynamic.Interface) *Handler {
 	return &Handler{
 		dynamicClient: dynamicClient,
+		timeout:       ArgoCDTimeout,
+		version:       ArgoCDVersion,
 	}
 }
 
+// WithAPIVersion sets the argoproj.io version to use, normally Installation.Version from the Detector
+func (h *Handler) WithAPIVersion(version string) *Handler {
+	if version != "" {
+		h.version = version
+	}
+	return h
+}
+
+// resource returns an Argo CD resource in the Handler's API version
+func (h *Handler) resource(name string) schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: ArgoCDGroup, Version: h.version, Resource: name}
+}
+
+// WithTimeout sets how long to wait for an Application's cascade before removing its finalizers
+func (h *Handler) WithTimeout(timeout time.Duration) *Handler {
+	if timeout > 0 {
+		h.timeout = timeout
+	}
+	return h
+}
+
+// WithDeadline cuts every wait for an Application's cascade short at deadline, so several Applications and the
+// caller's own wait can share one timeout. A zero deadline removes it.
+func (h *Handler) WithDeadline(deadline time.Time) *Handler {
+	h.deadline = deadline
+	return h
+}
+
+// waitTimeout returns how long DeleteApplication waits: the timeout, cut short by the deadline
+func (h *Handler) waitTimeout() time.Duration {
+	timeout := h.timeout
+	if !h.deadline.IsZero() {
+		if left := time.Until(h.deadline); left < timeout {
+			timeout = left
+		}
+	}
+	if timeout <= 0 {
+		// PollImmediate never gives up on a zero timeout; check once instead
+		timeout = time.Millisecond
+	}
+	return timeout
+}
+
 // DeleteApplication deletes an ArgoCD application and waits for it to be deleted
 func (h *Handler) DeleteApplication(ctx context.Context, app unstructured.Unstructured) error {
-	appGVR := schema.GroupVersionResource{
-		Group:    ArgoCDGroup,
-		Version:  ArgoCDVersion,
-		Resource: ArgoCDResource,
-	}
+	appGVR := h.resource(ArgoCDResource)
 
 	appName := app.GetName()
 	appNamespace := app.GetNamespace()
@@ -46,20 +92,30 @@ func (h *Handler) DeleteApplication(ctx context.Context, app unstructured.Unstru
 		return fmt.Errorf("failed to delete ArgoCD application %s/%s: %w", appNamespace, appName, err)
 	}
 
-	// Wait for application to be deleted with timeout
-	err = wait.PollImmediate(2*time.Second, ArgoCDTimeout, func() (bool, error) {
-		_, err := h.dynamicClient.Resource(appGVR).Namespace(appNamespace).Get(ctx, appName, metav1.GetOptions{})
+	// Wait for the cascade to finish, reporting the resources ArgoCD still has to delete
+	remaining := -1
+	timeout := h.waitTimeout()
+	err = wait.PollImmediate(2*time.Second, timeout, func() (bool, error) {
+		current, err := h.dynamicClient.Resource(appGVR).Namespace(appNamespace).Get(ctx, appName, metav1.GetOptions{})
 		if errors.IsNotFound(err) {
 			return true, nil // Successfully deleted
 		}
 		if err != nil {
 			return false, err // Unexpected error
 		}
+		if count := len(applicationResources(*current)); count != remaining && len(current.GetFinalizers()) > 0 {
+			remaining = count
+			fmt.Printf("⏳ ArgoCD is deleting %s/%s: %d managed resource(s) remaining\n", appNamespace, appName, count)
+		}
 		return false, nil // Still exists
 	})
 
 	if err != nil {
-		// If timeout or other error, try to remove finalizers
+		// The cascade is stuck: say why before removing finalizers
+		fmt.Printf("⚠️  ArgoCD Application %s/%s still present after %v\n", appNamespace, appName, timeout)
+		for _, issue := range h.DiagnoseStuckCascade(ctx, app) {
+			fmt.Printf("   🔎 %s\n", issue)
+		}
 		return h.RemoveApplicationFinalizers(ctx, app)
 	}
 
@@ -68,11 +124,7 @@ func (h *Handler) DeleteApplication(ctx context.Context, app unstructured.Unstru
 
 // RemoveApplicationFinalizers removes finalizers from an ArgoCD application
 func (h *Handler) RemoveApplicationFinalizers(ctx context.Context, app unstructured.Unstructured) error {
-	appGVR := schema.GroupVersionResource{
-		Group:    ArgoCDGroup,
-		Version:  ArgoCDVersion,
-		Resource: ArgoCDResource,
-	}
+	appGVR := h.resource(ArgoCDResource)
 
 	appName := app.GetName()
 	appNamespace := app.GetNamespace()
@@ -128,3 +180,142 @@ func (h *Handler) DeleteApplications(ctx context.Context, apps []unstructured.Un
 	
 	return nil
 }
+
+// ArgoCD handling modes
+const (
+	// ModeDelete deletes Applications, letting ArgoCD cascade-delete their resources
+	ModeDelete = "delete"
+	// ModeDisableSync keeps Applications but turns off automated sync and self-heal
+	ModeDisableSync = "disable-sync"
+	// ModeOrphan deletes Applications without the cascade by removing the resources finalizer first
+	ModeOrphan = "orphan"
+)
+
+const (
+	// ResourcesFinalizer makes ArgoCD delete an Application's resources before the Application itself
+	ResourcesFinalizer = "resources-finalizer.argocd.argoproj.io"
+	// AnnotationOriginalAutomatedSync records spec.syncPolicy.automated from before auto-sync was disabled
+	AnnotationOriginalAutomatedSync = "kubectl-nuke.io/original-automated-sync"
+)
+
+// HandleApplications deletes, orphans or disables auto-sync on Applications according to mode
+func (h *Handler) HandleApplications(ctx context.Context, apps []unstructured.Unstructured, mode string) error {
+	switch mode {
+	case ModeDelete, "":
+		return h.DeleteApplications(ctx, apps)
+	case ModeDisableSync:
+		for _, app := range apps {
+			fmt.Printf("⏸️  Disabling auto-sync on ArgoCD Application: %s/%s\n", app.GetNamespace(), app.GetName())
+			if err := h.DisableAutoSync(ctx, app); err != nil {
+				fmt.Printf("⚠️ Warning: %v\n", err)
+				continue
+			}
+			fmt.Printf("✅ Auto-sync disabled on ArgoCD Application: %s/%s (restore with: kubectl-nuke argocd restore-sync %s -n %s)\n",
+				app.GetNamespace(), app.GetName(), app.GetName(), app.GetNamespace())
+		}
+		return nil
+	case ModeOrphan:
+		for _, app := range apps {
+			fmt.Printf("🔄 Orphaning ArgoCD Application: %s/%s\n", app.GetNamespace(), app.GetName())
+			if err := h.OrphanApplication(ctx, app); err != nil {
+				fmt.Printf("⚠️ Warning: Failed to orphan ArgoCD Application %s/%s: %v\n", app.GetNamespace(), app.GetName(), err)
+				continue
+			}
+			fmt.Printf("✅ Deleted ArgoCD Application %s/%s and left its resources in place\n", app.GetNamespace(), app.GetName())
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown ArgoCD mode %q", mode)
+	}
+}
+
+// DisableAutoSync removes spec.syncPolicy.automated (which carries selfHeal and prune) from an Application and
+// records the original in an annotation so RestoreAutoSync can put it back. An existing record is kept, so
+// running twice doesn't lose the original.
+func (h *Handler) DisableAutoSync(ctx context.Context, app unstructured.Unstructured) error {
+	resourceClient := h.dynamicClient.Resource(h.resource(ArgoCDResource)).Namespace(app.GetNamespace())
+	current, err := resourceClient.Get(ctx, app.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get ArgoCD application %s/%s: %w", app.GetNamespace(), app.GetName(), err)
+	}
+
+	automated, found, _ := unstructured.NestedMap(current.Object, "spec", "syncPolicy", "automated")
+	if !found {
+		return nil // Auto-sync already off
+	}
+
+	metadata := map[string]interface{}{"resourceVersion": current.GetResourceVersion()}
+	if _, recorded := current.GetAnnotations()[AnnotationOriginalAutomatedSync]; !recorded {
+		original, err := json.Marshal(automated)
+		if err != nil {
+			return fmt.Errorf("failed to record sync policy of ArgoCD application %s/%s: %w", app.GetNamespace(), app.GetName(), err)
+		}
+		metadata["annotations"] = map[string]interface{}{AnnotationOriginalAutomatedSync: string(original)}
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": metadata,
+		"spec":     map[string]interface{}{"syncPolicy": map[string]interface{}{"automated": nil}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = resourceClient.Patch(ctx, app.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to disable auto-sync on ArgoCD application %s/%s: %w", app.GetNamespace(), app.GetName(), err)
+	}
+	return nil
+}
+
+// RestoreAutoSync puts back the spec.syncPolicy.automated recorded by DisableAutoSync and drops the record
+func (h *Handler) RestoreAutoSync(ctx context.Context, namespace, name string) error {
+	resourceClient := h.dynamicClient.Resource(h.resource(ArgoCDResource)).Namespace(namespace)
+	current, err := resourceClient.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get ArgoCD application %s/%s: %w", namespace, name, err)
+	}
+
+	recorded, found := current.GetAnnotations()[AnnotationOriginalAutomatedSync]
+	if !found {
+		return fmt.Errorf("ArgoCD application %s/%s has no recorded sync policy to restore", namespace, name)
+	}
+
+	var automated map[string]interface{}
+	if err := json.Unmarshal([]byte(recorded), &automated); err != nil {
+		return fmt.Errorf("invalid recorded sync policy on ArgoCD application %s/%s: %w", namespace, name, err)
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": current.GetResourceVersion(),
+			"annotations":     map[string]interface{}{AnnotationOriginalAutomatedSync: nil},
+		},
+		"spec": map[string]interface{}{"syncPolicy": map[string]interface{}{"automated": automated}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = resourceClient.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to restore auto-sync on ArgoCD application %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// OrphanApplication removes the resources finalizer (including its background/foreground variants) from an
+// Application and deletes it, so ArgoCD doesn't cascade-delete the resources the nuke is already handling
+func (h *Handler) OrphanApplication(ctx context.Context, app unstructured.Unstructured) error {
+	resourceClient := h.dynamicClient.Resource(h.resource(ArgoCDResource)).Namespace(app.GetNamespace())
+	if _, err := removeFinalizers(ctx, resourceClient, app.GetName(), isResourcesFinalizer); err != nil {
+		return fmt.Errorf("failed to remove resources finalizer from ArgoCD application %s/%s: %w", app.GetNamespace(), app.GetName(), err)
+	}
+
+	return h.DeleteApplication(ctx, app)
+}
+
+// isResourcesFinalizer matches the ArgoCD resources finalizer and its "/background" and "/foreground" variants
+func isResourcesFinalizer(finalizer string) bool {
+	return finalizer == ResourcesFinalizer || strings.HasPrefix(finalizer, ResourcesFinalizer+"/")
+}
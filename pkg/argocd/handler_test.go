@@ -0,0 +1,125 @@
+package argocd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestDisableAndRestoreAutoSync(t *testing.T) {
+	app := newApplication("shop", "")
+	automated := map[string]interface{}{"prune": true, "selfHeal": true}
+	unstructured.SetNestedMap(app.Object, automated, "spec", "syncPolicy", "automated")
+	unstructured.SetNestedStringSlice(app.Object, []string{"CreateNamespace=true"}, "spec", "syncPolicy", "syncOptions")
+
+	dynamicClient := newFakeArgoCDClient(&app)
+	handler := NewHandler(dynamicClient)
+	ctx := context.TODO()
+
+	if err := handler.DisableAutoSync(ctx, app); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	disabled, err := dynamicClient.Resource(applicationGVR).Namespace("argocd").Get(ctx, "shop", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get application: %v", err)
+	}
+	if _, found, _ := unstructured.NestedMap(disabled.Object, "spec", "syncPolicy", "automated"); found {
+		t.Errorf("expected spec.syncPolicy.automated to be removed")
+	}
+	if options, _, _ := unstructured.NestedStringSlice(disabled.Object, "spec", "syncPolicy", "syncOptions"); len(options) != 1 {
+		t.Errorf("expected sync options to be kept, got %v", options)
+	}
+	if disabled.GetAnnotations()[AnnotationOriginalAutomatedSync] == "" {
+		t.Fatalf("expected the original sync policy to be recorded")
+	}
+
+	// Disabling again must not overwrite the recorded original
+	if err := handler.DisableAutoSync(ctx, *disabled); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := handler.RestoreAutoSync(ctx, "argocd", "shop"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	restored, err := dynamicClient.Resource(applicationGVR).Namespace("argocd").Get(ctx, "shop", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get application: %v", err)
+	}
+	got, _, _ := unstructured.NestedMap(restored.Object, "spec", "syncPolicy", "automated")
+	if got["prune"] != true || got["selfHeal"] != true {
+		t.Errorf("expected automated sync policy to be restored, got %v", got)
+	}
+	if _, recorded := restored.GetAnnotations()[AnnotationOriginalAutomatedSync]; recorded {
+		t.Errorf("expected the recorded sync policy annotation to be removed")
+	}
+
+	if err := handler.RestoreAutoSync(ctx, "argocd", "shop"); err == nil {
+		t.Errorf("expected an error when nothing is recorded")
+	}
+}
+
+func TestOrphanApplication(t *testing.T) {
+	app := newApplication("shop", "")
+	app.SetFinalizers([]string{"example.com/keep", ResourcesFinalizer + "/background"})
+
+	dynamicClient := newFakeArgoCDClient(&app)
+	handler := NewHandler(dynamicClient)
+	ctx := context.TODO()
+
+	if err := handler.OrphanApplication(ctx, app); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var patched bool
+	for _, action := range dynamicClient.Actions() {
+		if action.GetVerb() == "patch" {
+			patched = true
+		}
+	}
+	if !patched {
+		t.Errorf("expected the resources finalizer to be patched out before deletion")
+	}
+	if _, err := dynamicClient.Resource(applicationGVR).Namespace("argocd").Get(ctx, "shop", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expected the application to be deleted, got %v", err)
+	}
+}
+
+func TestDeleteApplicationStopsAtDeadline(t *testing.T) {
+	app := newApplication("shop", "")
+	app.SetFinalizers([]string{ResourcesFinalizer})
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			applicationGVR: "ApplicationList",
+			deploymentGVR:  "DeploymentList",
+			statefulSetGVR: "StatefulSetList",
+		},
+		&app,
+	)
+	// The first delete leaves the Application in place, as while ArgoCD is still cascading
+	deletes := 0
+	dynamicClient.PrependReactor("delete", "applications", func(clienttesting.Action) (bool, runtime.Object, error) {
+		deletes++
+		return deletes == 1, nil, nil
+	})
+	handler := NewHandler(dynamicClient).WithTimeout(time.Minute).WithDeadline(time.Now())
+	ctx := context.TODO()
+
+	start := time.Now()
+	if err := handler.DeleteApplication(ctx, app); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected the passed deadline to cut the wait short, waited %v", elapsed)
+	}
+	if _, err := dynamicClient.Resource(applicationGVR).Namespace("argocd").Get(ctx, "shop", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expected the application to be deleted after removing its finalizers, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package argocd
+
+import (
+	"context"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ApplicationLineage is an Application with the app-of-apps parents managing it, nearest parent first
+type ApplicationLineage struct {
+	Application unstructured.Unstructured
+	Parents     []unstructured.Unstructured
+}
+
+// ApplicationNode is an Application in an app-of-apps tree
+type ApplicationNode struct {
+	Application unstructured.Unstructured
+	Children    []*ApplicationNode
+}
+
+// FindApplicationLineages walks up the app-of-apps hierarchy from each Application. A parent is an Application on
+// this cluster whose status.resources include the child Application; deleting only the child would make the parent
+// recreate it on its next sync.
+func (d *Detector) FindApplicationLineages(ctx context.Context, apps []unstructured.Unstructured) ([]ApplicationLineage, error) {
+	allApps, err := d.listApplications(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// A partial identity is still usable when cluster secrets can't be read
+	cluster, _ := d.ResolveCurrentCluster(ctx, d.apiServerHost)
+
+	byKey := map[string]unstructured.Unstructured{}
+	parentOf := map[string]string{}
+	for _, app := range allApps {
+		key := app.GetNamespace() + "/" + app.GetName()
+		byKey[key] = app
+		if !cluster.Matches(app) {
+			continue // Its Application resources live on another cluster
+		}
+		for _, childKey := range managedApplicationKeys(app) {
+			if _, claimed := parentOf[childKey]; !claimed && childKey != key {
+				parentOf[childKey] = key
+			}
+		}
+	}
+
+	lineages := make([]ApplicationLineage, 0, len(apps))
+	for _, app := range apps {
+		lineage := ApplicationLineage{Application: app}
+		visited := map[string]bool{app.GetNamespace() + "/" + app.GetName(): true}
+		for key := parentOf[app.GetNamespace()+"/"+app.GetName()]; key != "" && !visited[key]; key = parentOf[key] {
+			visited[key] = true
+			lineage.Parents = append(lineage.Parents, byKey[key])
+		}
+		lineages = append(lineages, lineage)
+	}
+	return lineages, nil
+}
+
+// managedApplicationKeys returns namespace/name keys of the Applications listed in an Application's status.resources
+func managedApplicationKeys(app unstructured.Unstructured) []string {
+	destination, _, _ := unstructured.NestedString(app.Object, "spec", "destination", "namespace")
+	resources, _, _ := unstructured.NestedSlice(app.Object, "status", "resources")
+
+	var keys []string
+	for _, resource := range resources {
+		resourceMap, ok := resource.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(resourceMap, "group")
+		kind, _, _ := unstructured.NestedString(resourceMap, "kind")
+		if group != ArgoCDGroup || kind != ArgoCDKind {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(resourceMap, "name")
+		namespace, _, _ := unstructured.NestedString(resourceMap, "namespace")
+		if namespace == "" {
+			namespace = destination
+		}
+		keys = append(keys, namespace+"/"+name)
+	}
+	return keys
+}
+
+// Ancestors returns every parent across the lineages once, topmost first, so they can be handled top-down
+func Ancestors(lineages []ApplicationLineage) []unstructured.Unstructured {
+	depth := map[string]int{}
+	byKey := map[string]unstructured.Unstructured{}
+	for _, lineage := range lineages {
+		for i, parent := range lineage.Parents {
+			key := parent.GetNamespace() + "/" + parent.GetName()
+			byKey[key] = parent
+			depth[key] = len(lineage.Parents) - 1 - i
+		}
+	}
+
+	keys := make([]string, 0, len(byKey))
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if depth[keys[i]] != depth[keys[j]] {
+			return depth[keys[i]] < depth[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	ancestors := make([]unstructured.Unstructured, 0, len(keys))
+	for _, key := range keys {
+		ancestors = append(ancestors, byKey[key])
+	}
+	return ancestors
+}
+
+// BuildApplicationTree merges the lineages into app-of-apps trees, returning their roots
+func BuildApplicationTree(lineages []ApplicationLineage) []*ApplicationNode {
+	nodes := map[string]*ApplicationNode{}
+	node := func(app unstructured.Unstructured) *ApplicationNode {
+		key := app.GetNamespace() + "/" + app.GetName()
+		if n, ok := nodes[key]; ok {
+			return n
+		}
+		n := &ApplicationNode{Application: app}
+		nodes[key] = n
+		return n
+	}
+
+	var roots []*ApplicationNode
+	linked := map[*ApplicationNode]bool{}
+	for _, lineage := range lineages {
+		child := node(lineage.Application)
+		for _, parentApp := range lineage.Parents {
+			parent := node(parentApp)
+			if !linked[child] {
+				parent.Children = append(parent.Children, child)
+				linked[child] = true
+			}
+			child = parent
+		}
+		if !linked[child] && !containsNode(roots, child) {
+			roots = append(roots, child)
+		}
+	}
+	return roots
+}
+
+// containsNode checks if a node is in a slice
+func containsNode(nodes []*ApplicationNode, node *ApplicationNode) bool {
+	for _, n := range nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package argocd
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// newParentApplication returns an in-cluster Application whose live resources are the named child Applications
+func newParentApplication(name string, children ...string) *unstructured.Unstructured {
+	app := newDestinationApplication(name, map[string]interface{}{"server": InClusterServer, "namespace": "argocd"})
+	var resources []interface{}
+	for _, child := range children {
+		resources = append(resources, map[string]interface{}{"group": ArgoCDGroup, "kind": ArgoCDKind, "name": child})
+	}
+	unstructured.SetNestedSlice(app.Object, resources, "status", "resources")
+	return app
+}
+
+func TestFindApplicationLineages(t *testing.T) {
+	shop := newDestinationApplication("shop", map[string]interface{}{"server": InClusterServer, "namespace": "shop"})
+	remoteParent := newParentApplication("remote-root", "shop")
+	unstructured.SetNestedField(remoteParent.Object, "https://prod.example.com", "spec", "destination", "server")
+
+	dynamicClient := newFakeArgoCDClient(
+		newParentApplication("root", "platform", "other"),
+		newParentApplication("platform", "shop"),
+		newParentApplication("other"),
+		remoteParent,
+		shop,
+	)
+	detector := NewDetector(newFakeKubeClient(), dynamicClient)
+
+	lineages, err := detector.FindApplicationLineages(context.TODO(), []unstructured.Unstructured{*shop})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(lineages) != 1 || len(lineages[0].Parents) != 2 {
+		t.Fatalf("expected shop to have 2 parents, got %v", lineages)
+	}
+	if lineages[0].Parents[0].GetName() != "platform" || lineages[0].Parents[1].GetName() != "root" {
+		t.Errorf("expected parents platform then root, got %s then %s", lineages[0].Parents[0].GetName(), lineages[0].Parents[1].GetName())
+	}
+
+	ancestors := Ancestors(lineages)
+	if len(ancestors) != 2 || ancestors[0].GetName() != "root" || ancestors[1].GetName() != "platform" {
+		t.Errorf("expected ancestors root then platform, got %v", ancestors)
+	}
+
+	roots := BuildApplicationTree(lineages)
+	if len(roots) != 1 || roots[0].Application.GetName() != "root" {
+		t.Fatalf("expected a single root, got %v", roots)
+	}
+	platform := roots[0].Children
+	if len(platform) != 1 || platform[0].Application.GetName() != "platform" ||
+		len(platform[0].Children) != 1 || platform[0].Children[0].Application.GetName() != "shop" {
+		t.Errorf("expected root → platform → shop, got %v", roots[0])
+	}
+}
@@ -0,0 +1,209 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// OrphanedResourceKey is an entry of an AppProject's spec.orphanedResources.ignore
+type OrphanedResourceKey struct {
+	Group string `json:"group,omitempty"`
+	Kind  string `json:"kind,omitempty"`
+	Name  string `json:"name,omitempty"`
+}
+
+// OrphanedResourcesPolicy is an AppProject's spec.orphanedResources
+type OrphanedResourcesPolicy struct {
+	Warn   bool                  `json:"warn"`
+	Ignore []OrphanedResourceKey `json:"ignore,omitempty"`
+}
+
+// AppProject is the typed view of the AppProject fields that decide what its Applications may do in a namespace
+type AppProject struct {
+	Name         string        `json:"name"`
+	Namespace    string        `json:"namespace"`
+	Destinations []Destination `json:"destinations,omitempty"`
+	// OrphanedResources is nil when the project doesn't monitor orphaned resources
+	OrphanedResources *OrphanedResourcesPolicy `json:"orphanedResources,omitempty"`
+}
+
+// NewAppProject reads the typed view from an AppProject object
+func NewAppProject(obj unstructured.Unstructured) AppProject {
+	project := AppProject{Name: obj.GetName(), Namespace: obj.GetNamespace()}
+
+	destinations, _, _ := unstructured.NestedSlice(obj.Object, "spec", "destinations")
+	for _, destination := range destinations {
+		destinationMap, ok := destination.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var d Destination
+		d.Server, _, _ = unstructured.NestedString(destinationMap, "server")
+		d.Name, _, _ = unstructured.NestedString(destinationMap, "name")
+		d.Namespace, _, _ = unstructured.NestedString(destinationMap, "namespace")
+		project.Destinations = append(project.Destinations, d)
+	}
+
+	if orphaned, found, _ := unstructured.NestedMap(obj.Object, "spec", "orphanedResources"); found {
+		policy := &OrphanedResourcesPolicy{}
+		policy.Warn, _, _ = unstructured.NestedBool(orphaned, "warn")
+		ignores, _, _ := unstructured.NestedSlice(orphaned, "ignore")
+		for _, ignore := range ignores {
+			ignoreMap, ok := ignore.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			var key OrphanedResourceKey
+			key.Group, _, _ = unstructured.NestedString(ignoreMap, "group")
+			key.Kind, _, _ = unstructured.NestedString(ignoreMap, "kind")
+			key.Name, _, _ = unstructured.NestedString(ignoreMap, "name")
+			policy.Ignore = append(policy.Ignore, key)
+		}
+		project.OrphanedResources = policy
+	}
+	return project
+}
+
+// PermittedDestinations returns the project destinations permitting the namespace on the cluster. Patterns are
+// globs; a namespace pattern starting with "!" denies matching namespaces and wins over any permitting entry,
+// as in Argo CD. A nil cluster matches every destination cluster.
+func (p AppProject) PermittedDestinations(namespace string, cluster *ClusterIdentity) []Destination {
+	var permitted []Destination
+	for _, destination := range p.Destinations {
+		if !destinationMatchesCluster(destination, cluster) {
+			continue
+		}
+		if strings.HasPrefix(destination.Namespace, "!") {
+			if globMatch(destination.Namespace[1:], namespace) {
+				return nil
+			}
+			continue
+		}
+		if globMatch(destination.Namespace, namespace) {
+			permitted = append(permitted, destination)
+		}
+	}
+	return permitted
+}
+
+// destinationMatchesCluster reports whether a project destination's server or name pattern matches the cluster
+func destinationMatchesCluster(destination Destination, cluster *ClusterIdentity) bool {
+	if cluster == nil {
+		return true
+	}
+	if destination.Server != "" {
+		for _, server := range cluster.Servers {
+			if globMatch(destination.Server, server) || globMatch(normalizeServer(destination.Server), server) {
+				return true
+			}
+		}
+	}
+	if destination.Name != "" {
+		for _, name := range cluster.Names {
+			if globMatch(destination.Name, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// globMatch matches a destination pattern; an invalid pattern only matches itself
+func globMatch(pattern, value string) bool {
+	if pattern == "*" || pattern == value {
+		return true
+	}
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
+
+// ProjectRestrictions reports how an AppProject constrains its Applications in a namespace
+type ProjectRestrictions struct {
+	Project AppProject `json:"project"`
+	// Applications lists namespace/name of the namespace's Applications in the project
+	Applications []string `json:"applications"`
+	// Missing is set when the AppProject doesn't exist; Argo CD refuses to sync or delete for its Applications
+	Missing bool `json:"missing,omitempty"`
+	// PermittedDestinations lists the destinations that permit the namespace on this cluster. When there are
+	// none, Argo CD reports InvalidSpecError and won't act on the namespace, so a cascading delete can't finish.
+	PermittedDestinations []Destination `json:"permittedDestinations"`
+	// WarnsOnOrphans is set when orphanedResources.warn is on: resources left behind by --argocd-mode orphan
+	// raise OrphanedResourceWarning conditions until the namespace is gone
+	WarnsOnOrphans bool `json:"warnsOnOrphans"`
+}
+
+// DestinationPermitted reports whether the project lets Argo CD act on the namespace
+func (r ProjectRestrictions) DestinationPermitted() bool {
+	return !r.Missing && len(r.PermittedDestinations) > 0
+}
+
+// DetectProjectRestrictions looks up the AppProject of each Application and checks its destinations and
+// orphaned resource monitoring against the namespace. A project is looked up in its Application's namespace
+// first, then anywhere, since Applications outside the control plane namespace use projects stored there.
+func (d *Detector) DetectProjectRestrictions(ctx context.Context, namespace string, apps []unstructured.Unstructured) ([]ProjectRestrictions, error) {
+	projectGVR, served, err := d.resource(AppProjectResource)
+	if err != nil || !served || len(apps) == 0 {
+		return nil, err
+	}
+	projects, err := d.dynamicClient.Resource(projectGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ArgoCD AppProjects: %w", err)
+	}
+	// A partial identity is still usable when cluster secrets can't be read
+	cluster, _ := d.ResolveCurrentCluster(ctx, d.apiServerHost)
+
+	byKey := map[string]*ProjectRestrictions{}
+	for _, obj := range apps {
+		app := NewApplication(obj)
+		project, found := findProject(projects.Items, app.Project, app.Namespace)
+
+		key := project.Namespace + "/" + app.Project
+		restrictions, seen := byKey[key]
+		if !seen {
+			restrictions = &ProjectRestrictions{Project: project, Missing: !found}
+			if found {
+				restrictions.PermittedDestinations = project.PermittedDestinations(namespace, cluster)
+				restrictions.WarnsOnOrphans = project.OrphanedResources != nil && project.OrphanedResources.Warn
+			} else {
+				restrictions.Project.Name = app.Project
+			}
+			byKey[key] = restrictions
+		}
+		restrictions.Applications = append(restrictions.Applications, app.Namespace+"/"+app.Name)
+	}
+
+	result := make([]ProjectRestrictions, 0, len(byKey))
+	for _, restrictions := range byKey {
+		result = append(result, *restrictions)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Project.Namespace+"/"+result[i].Project.Name < result[j].Project.Namespace+"/"+result[j].Project.Name
+	})
+	return result, nil
+}
+
+// findProject returns the named AppProject, preferring one in the given namespace
+func findProject(projects []unstructured.Unstructured, name, namespace string) (AppProject, bool) {
+	var fallback *unstructured.Unstructured
+	for i := range projects {
+		if projects[i].GetName() != name {
+			continue
+		}
+		if projects[i].GetNamespace() == namespace {
+			return NewAppProject(projects[i]), true
+		}
+		if fallback == nil {
+			fallback = &projects[i]
+		}
+	}
+	if fallback == nil {
+		return AppProject{}, false
+	}
+	return NewAppProject(*fallback), true
+}
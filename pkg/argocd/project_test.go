@@ -0,0 +1,84 @@
+package argocd
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newAppProject(name string, destinations []interface{}, orphanedResources map[string]interface{}) *unstructured.Unstructured {
+	project := &unstructured.Unstructured{}
+	project.SetAPIVersion(ArgoCDGroup + "/" + ArgoCDVersion)
+	project.SetKind(AppProjectKind)
+	project.SetNamespace("argocd")
+	project.SetName(name)
+	unstructured.SetNestedSlice(project.Object, destinations, "spec", "destinations")
+	if orphanedResources != nil {
+		unstructured.SetNestedMap(project.Object, orphanedResources, "spec", "orphanedResources")
+	}
+	return project
+}
+
+func TestPermittedDestinations(t *testing.T) {
+	cluster := &ClusterIdentity{Servers: []string{InClusterServer}, Names: []string{InClusterName}}
+	project := AppProject{Destinations: []Destination{
+		{Server: "*", Namespace: "team-*"},
+		{Server: "*", Namespace: "!team-secret"},
+		{Name: "prod", Namespace: "shop"},
+	}}
+
+	tests := map[string]int{
+		"team-a":      1,
+		"team-secret": 0, // Denied even though team-* permits it
+		"shop":        0, // Only permitted on another cluster
+	}
+	for namespace, expected := range tests {
+		if got := project.PermittedDestinations(namespace, cluster); len(got) != expected {
+			t.Errorf("PermittedDestinations(%q) = %v, expected %d destination(s)", namespace, got, expected)
+		}
+	}
+}
+
+func TestDetectProjectRestrictions(t *testing.T) {
+	shop := newDestinationApplication("shop", map[string]interface{}{"server": InClusterServer, "namespace": "shop"})
+	unstructured.SetNestedField(shop.Object, "retail", "spec", "project")
+	legacy := newDestinationApplication("legacy", map[string]interface{}{"server": InClusterServer, "namespace": "shop"})
+	unstructured.SetNestedField(legacy.Object, "gone", "spec", "project")
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			applicationGVR: "ApplicationList",
+			appProjectGVR:  "AppProjectList",
+		},
+		newAppProject("retail",
+			[]interface{}{map[string]interface{}{"server": InClusterServer, "namespace": "retail-*"}},
+			map[string]interface{}{"warn": true, "ignore": []interface{}{map[string]interface{}{"kind": "ConfigMap"}}}),
+	)
+	detector := NewDetector(newFakeKubeClient(), dynamicClient)
+
+	restrictions, err := detector.DetectProjectRestrictions(context.TODO(), "shop", []unstructured.Unstructured{*shop, *legacy})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(restrictions) != 2 {
+		t.Fatalf("expected 2 projects, got %d", len(restrictions))
+	}
+
+	missing, retail := restrictions[0], restrictions[1]
+	if !missing.Missing || missing.Project.Name != "gone" || missing.DestinationPermitted() {
+		t.Errorf("expected project gone to be reported missing, got %+v", missing)
+	}
+	if retail.Project.Name != "retail" || retail.DestinationPermitted() || !retail.WarnsOnOrphans {
+		t.Errorf("expected retail to forbid namespace shop and warn on orphans, got %+v", retail)
+	}
+	if len(retail.Project.OrphanedResources.Ignore) != 1 || retail.Project.OrphanedResources.Ignore[0].Kind != "ConfigMap" {
+		t.Errorf("expected the ignored orphan kinds to be read, got %+v", retail.Project.OrphanedResources)
+	}
+	if len(retail.Applications) != 1 || retail.Applications[0] != "argocd/shop" {
+		t.Errorf("expected shop to be in retail, got %v", retail.Applications)
+	}
+}
@@ -0,0 +1,67 @@
+package argocd
+
+import (
+	"context"
+	"sort"
+)
+
+// NamespaceReport describes how Argo CD relates to a namespace, with stable fields for JSON output
+type NamespaceReport struct {
+	Namespace    string           `json:"namespace"`
+	Installation *Installation    `json:"installation"`
+	Cluster      *ClusterIdentity `json:"cluster,omitempty"`
+	// Applications deploy into the namespace on this cluster, by destination or by resource tracking
+	Applications []Application         `json:"applications"`
+	Projects     []ProjectRestrictions `json:"projects,omitempty"`
+	// Unresolved counts resources tracked to Applications that aren't on this cluster
+	Unresolved map[string]int `json:"unresolved,omitempty"`
+}
+
+// BuildNamespaceReport detects the Argo CD installation and the Applications and AppProjects affecting the
+// namespace, without printing anything
+func (d *Detector) BuildNamespaceReport(ctx context.Context, namespace string) (*NamespaceReport, error) {
+	installation, err := d.DetectInstallation()
+	if err != nil {
+		return nil, err
+	}
+	report := &NamespaceReport{Namespace: namespace, Installation: installation, Applications: []Application{}}
+	if !installation.Installed {
+		return report, nil
+	}
+
+	// A partial identity is still usable when cluster secrets can't be read
+	report.Cluster, _ = d.ResolveCurrentCluster(ctx, d.apiServerHost)
+	apps, _, err := d.DetectArgoCDAppsForCluster(ctx, namespace, report.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	tracking, err := d.DetectArgoCDAppsFromTracking(ctx, namespace, report.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, app := range apps {
+		seen[app.GetNamespace()+"/"+app.GetName()] = true
+	}
+	for _, tracked := range tracking.Applications {
+		if key := tracked.Application.GetNamespace() + "/" + tracked.Application.GetName(); !seen[key] {
+			seen[key] = true
+			apps = append(apps, tracked.Application)
+		}
+	}
+	if len(tracking.Unresolved) > 0 {
+		report.Unresolved = tracking.Unresolved
+	}
+
+	sort.Slice(apps, func(i, j int) bool {
+		return apps[i].GetNamespace()+"/"+apps[i].GetName() < apps[j].GetNamespace()+"/"+apps[j].GetName()
+	})
+	report.Applications = NewApplications(apps)
+
+	report.Projects, err = d.DetectProjectRestrictions(ctx, namespace, apps)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,269 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// AnnotationTrackingID is set on live resources by ArgoCD's annotation-based resource tracking, in the form
+// <app>:<group>/<kind>:<namespace>/<name>
+const AnnotationTrackingID = "argocd.argoproj.io/tracking-id"
+
+// ApplicationRef names an Application as recorded in tracking metadata. Namespace is empty when the
+// Application lives in the ArgoCD control plane namespace.
+type ApplicationRef struct {
+	Namespace string
+	Name      string
+}
+
+// String returns the reference as ArgoCD writes it
+func (r ApplicationRef) String() string {
+	if r.Namespace == "" {
+		return r.Name
+	}
+	return r.Namespace + "_" + r.Name
+}
+
+// TrackedApplication is an Application resolved from the tracking metadata of live resources in a namespace
+type TrackedApplication struct {
+	Application unstructured.Unstructured
+	// Resources counts the live resources tracked to the Application
+	Resources int
+	// LabelOnly is set when only app.kubernetes.io/instance labels pointed at the Application
+	LabelOnly bool
+}
+
+// TrackingResult holds the Applications owning a namespace's live resources
+type TrackingResult struct {
+	Applications []TrackedApplication
+	// Unresolved counts resources whose tracking annotations name no Application on this cluster
+	Unresolved map[string]int
+	// UnlistedLabels counts resources whose app.kubernetes.io/instance label names an Application that doesn't
+	// list them in its status.resources; they aren't attributed to it
+	UnlistedLabels map[string]int
+}
+
+// objectRef identifies a live resource the way an Application's status.resources lists it
+type objectRef struct {
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// listsResource reports whether the Application's status.resources includes the object
+func listsResource(app unstructured.Unstructured, obj objectRef) bool {
+	resources, _, _ := unstructured.NestedSlice(app.Object, "status", "resources")
+	for _, resource := range resources {
+		resourceMap, ok := resource.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(resourceMap, "group")
+		kind, _, _ := unstructured.NestedString(resourceMap, "kind")
+		namespace, _, _ := unstructured.NestedString(resourceMap, "namespace")
+		name, _, _ := unstructured.NestedString(resourceMap, "name")
+		if (objectRef{Group: group, Kind: kind, Namespace: namespace, Name: name}) == obj {
+			return true
+		}
+	}
+	return false
+}
+
+// parseApplicationRef splits an ArgoCD app instance value of the form <namespace>_<name> or <name>.
+// Application names can't contain underscores, so the split is unambiguous.
+func parseApplicationRef(value string) ApplicationRef {
+	if i := strings.Index(value, "_"); i >= 0 {
+		return ApplicationRef{Namespace: value[:i], Name: value[i+1:]}
+	}
+	return ApplicationRef{Name: value}
+}
+
+// ParseTrackingID extracts the owning Application from a tracking-id annotation value
+func ParseTrackingID(trackingID string) (ApplicationRef, bool) {
+	parts := strings.SplitN(trackingID, ":", 3)
+	if len(parts) != 3 || parts[0] == "" {
+		return ApplicationRef{}, false
+	}
+	return parseApplicationRef(parts[0]), true
+}
+
+// TrackedApplicationRef returns the Application a resource belongs to according to ArgoCD's tracking
+// annotations. The app.kubernetes.io/instance label is not used since Helm sets it too.
+func TrackedApplicationRef(resource *unstructured.Unstructured) (ApplicationRef, bool) {
+	annotations := resource.GetAnnotations()
+	if trackingID, ok := annotations[AnnotationTrackingID]; ok {
+		return ParseTrackingID(trackingID)
+	}
+	if instance, ok := annotations[AnnotationArgoCDInstance]; ok && instance != "" {
+		return parseApplicationRef(instance), true
+	}
+	return ApplicationRef{}, false
+}
+
+// instanceLabelRef returns the app.kubernetes.io/instance label as a candidate Application reference. It only
+// counts once it resolves to an existing Application listing the resource in its status.resources, since Helm
+// sets the same label to the release name.
+func instanceLabelRef(resource *unstructured.Unstructured) (ApplicationRef, bool) {
+	instance, ok := resource.GetLabels()[LabelArgoCDInstance]
+	if !ok || instance == "" {
+		return ApplicationRef{}, false
+	}
+	return parseApplicationRef(instance), true
+}
+
+// DetectArgoCDAppsFromTracking scans every listable resource type in the namespace for ArgoCD tracking metadata
+// and resolves it to Applications deploying to this cluster. This finds apps that create the namespace or
+// deploy into it without it being their destination namespace.
+func (d *Detector) DetectArgoCDAppsFromTracking(ctx context.Context, namespace string, cluster *ClusterIdentity) (*TrackingResult, error) {
+	apps, err := d.listApplications(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &TrackingResult{Unresolved: map[string]int{}, UnlistedLabels: map[string]int{}}
+	if len(apps) == 0 {
+		return result, nil // Nothing to resolve to
+	}
+
+	annotated, labelled, err := d.countTrackedResources(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[string]int{}
+	for ref, count := range annotated {
+		app, ok := resolveApplicationRef(apps, ref, cluster)
+		if !ok {
+			result.Unresolved[ref.String()] += count
+			continue
+		}
+		key := app.GetNamespace() + "/" + app.GetName()
+		if i, seen := found[key]; seen {
+			result.Applications[i].Resources += count
+			continue
+		}
+		found[key] = len(result.Applications)
+		result.Applications = append(result.Applications, TrackedApplication{Application: app, Resources: count})
+	}
+
+	for ref, objects := range labelled {
+		app, ok := resolveApplicationRef(apps, ref, cluster)
+		if !ok {
+			continue // Most likely a Helm release name
+		}
+		count := 0
+		for _, obj := range objects {
+			if listsResource(app, obj) {
+				count++
+			} else {
+				result.UnlistedLabels[ref.String()]++
+			}
+		}
+		if count == 0 {
+			continue
+		}
+		key := app.GetNamespace() + "/" + app.GetName()
+		if i, seen := found[key]; seen {
+			result.Applications[i].Resources += count
+			continue
+		}
+		found[key] = len(result.Applications)
+		result.Applications = append(result.Applications, TrackedApplication{Application: app, Resources: count, LabelOnly: true})
+	}
+
+	sort.Slice(result.Applications, func(i, j int) bool {
+		a, b := result.Applications[i].Application, result.Applications[j].Application
+		return a.GetNamespace()+"/"+a.GetName() < b.GetNamespace()+"/"+b.GetName()
+	})
+	return result, nil
+}
+
+// resourceType is a namespaced resource type that can be listed
+type resourceType struct {
+	GVR  schema.GroupVersionResource
+	Kind string
+}
+
+// listableResourceTypes discovers the namespaced resource types that can be listed
+func (d *Detector) listableResourceTypes() ([]resourceType, error) {
+	resourceLists, err := discovery.ServerPreferredNamespacedResources(d.kubeClient.Discovery())
+	if err != nil && len(resourceLists) == 0 {
+		return nil, fmt.Errorf("failed to discover API resources: %w", err)
+	}
+
+	var types []resourceType
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, apiResource := range resourceList.APIResources {
+			if strings.Contains(apiResource.Name, "/") || !containsString(apiResource.Verbs, "list") {
+				continue
+			}
+			types = append(types, resourceType{GVR: gv.WithResource(apiResource.Name), Kind: apiResource.Kind})
+		}
+	}
+	return types, nil
+}
+
+// countTrackedResources counts the namespace's live resources per Application reference for tracking annotations,
+// and collects the resources per reference for instance labels on resources without tracking annotations
+func (d *Detector) countTrackedResources(ctx context.Context, namespace string) (map[ApplicationRef]int, map[ApplicationRef][]objectRef, error) {
+	types, err := d.listableResourceTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	annotated := map[ApplicationRef]int{}
+	labelled := map[ApplicationRef][]objectRef{}
+	for _, rt := range types {
+		if err := d.collectTrackedResources(ctx, namespace, rt, annotated, labelled); err != nil {
+			continue // Skip resources we can't list (permissions, etc.)
+		}
+	}
+	return annotated, labelled, nil
+}
+
+// collectTrackedResources adds the namespace's live resources of one type to the tracking annotation counts
+// and the instance label references
+func (d *Detector) collectTrackedResources(ctx context.Context, namespace string, rt resourceType, annotated map[ApplicationRef]int, labelled map[ApplicationRef][]objectRef) error {
+	list, err := d.dynamicClient.Resource(rt.GVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range list.Items {
+		if ref, ok := TrackedApplicationRef(&list.Items[i]); ok {
+			annotated[ref]++
+		} else if ref, ok := instanceLabelRef(&list.Items[i]); ok {
+			labelled[ref] = append(labelled[ref], objectRef{Group: rt.GVR.Group, Kind: rt.Kind, Namespace: namespace, Name: list.Items[i].GetName()})
+		}
+	}
+	return nil
+}
+
+// resolveApplicationRef finds the Application on this cluster a reference points at. A reference without a
+// namespace must match exactly one Application by name.
+func resolveApplicationRef(apps []unstructured.Unstructured, ref ApplicationRef, cluster *ClusterIdentity) (unstructured.Unstructured, bool) {
+	var matches []unstructured.Unstructured
+	for _, app := range apps {
+		if app.GetName() != ref.Name || (ref.Namespace != "" && app.GetNamespace() != ref.Namespace) {
+			continue
+		}
+		if cluster != nil && !cluster.Matches(app) {
+			continue
+		}
+		matches = append(matches, app)
+	}
+	if len(matches) != 1 {
+		return unstructured.Unstructured{}, false
+	}
+	return matches[0], true
+}
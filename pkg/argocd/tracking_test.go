@@ -0,0 +1,103 @@
+package argocd
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+var configMapGVR = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+func newTrackedConfigMap(name string, labels, annotations map[string]string) *unstructured.Unstructured {
+	cm := &unstructured.Unstructured{}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	cm.SetNamespace("shop")
+	cm.SetName(name)
+	cm.SetLabels(labels)
+	cm.SetAnnotations(annotations)
+	return cm
+}
+
+func TestParseTrackingID(t *testing.T) {
+	tests := []struct {
+		trackingID string
+		expected   ApplicationRef
+		ok         bool
+	}{
+		{"shop:apps/Deployment:shop/web", ApplicationRef{Name: "shop"}, true},
+		{"team-a_shop:/ConfigMap:shop/settings", ApplicationRef{Namespace: "team-a", Name: "shop"}, true},
+		{"not-a-tracking-id", ApplicationRef{}, false},
+	}
+	for _, tt := range tests {
+		ref, ok := ParseTrackingID(tt.trackingID)
+		if ok != tt.ok || ref != tt.expected {
+			t.Errorf("ParseTrackingID(%q) = %v, %v, expected %v, %v", tt.trackingID, ref, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestDetectArgoCDAppsFromTracking(t *testing.T) {
+	kubeClient := k8sfake.NewSimpleClientset()
+	kubeClient.Fake.Resources = []*metav1.APIResourceList{argoCDResources(), {
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+		},
+	}}
+
+	platform := newDestinationApplication("platform", map[string]interface{}{"server": InClusterServer, "namespace": "platform"})
+	labelled := newDestinationApplication("legacy", map[string]interface{}{"server": InClusterServer, "namespace": "legacy"})
+	unstructured.SetNestedSlice(labelled.Object, []interface{}{
+		map[string]interface{}{"version": "v1", "kind": "ConfigMap", "namespace": "shop", "name": "c"},
+	}, "status", "resources")
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			applicationGVR:    "ApplicationList",
+			applicationSetGVR: "ApplicationSetList",
+			appProjectGVR:     "AppProjectList",
+			configMapGVR:      "ConfigMapList",
+		},
+		platform,
+		labelled,
+		newTrackedConfigMap("a", nil, map[string]string{AnnotationTrackingID: "platform:/ConfigMap:shop/a"}),
+		newTrackedConfigMap("b", map[string]string{LabelArgoCDInstance: "helm-release"},
+			map[string]string{AnnotationTrackingID: "argocd_platform:/ConfigMap:shop/b"}),
+		newTrackedConfigMap("c", map[string]string{LabelArgoCDInstance: "legacy"}, nil),
+		newTrackedConfigMap("d", map[string]string{LabelArgoCDInstance: "helm-release"}, nil),
+		newTrackedConfigMap("f", map[string]string{LabelArgoCDInstance: "legacy"}, nil),
+		newTrackedConfigMap("e", nil, map[string]string{AnnotationTrackingID: "deleted-app:/ConfigMap:shop/e"}),
+	)
+
+	detector := NewDetector(kubeClient, dynamicClient)
+	cluster, _ := detector.ResolveCurrentCluster(context.TODO(), "")
+	result, err := detector.DetectArgoCDAppsFromTracking(context.TODO(), "shop", cluster)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(result.Applications) != 2 {
+		t.Fatalf("expected 2 tracked applications, got %d", len(result.Applications))
+	}
+	legacy, platformApp := result.Applications[0], result.Applications[1]
+	if platformApp.Application.GetName() != "platform" || platformApp.Resources != 2 || platformApp.LabelOnly {
+		t.Errorf("expected platform to own 2 resources through tracking annotations, got %s %d %v",
+			platformApp.Application.GetName(), platformApp.Resources, platformApp.LabelOnly)
+	}
+	if legacy.Application.GetName() != "legacy" || legacy.Resources != 1 || !legacy.LabelOnly {
+		t.Errorf("expected legacy to own 1 resource through its instance label, got %s %d %v",
+			legacy.Application.GetName(), legacy.Resources, legacy.LabelOnly)
+	}
+	if len(result.Unresolved) != 1 || result.Unresolved["deleted-app"] != 1 {
+		t.Errorf("expected deleted-app to be unresolved and the Helm release ignored, got %v", result.Unresolved)
+	}
+	if len(result.UnlistedLabels) != 1 || result.UnlistedLabels["legacy"] != 1 {
+		t.Errorf("expected the resource legacy doesn't list to be reported, got %v", result.UnlistedLabels)
+	}
+}
@@ -0,0 +1,81 @@
+package argocd
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Destination is an Application's spec.destination or an entry of an AppProject's spec.destinations
+type Destination struct {
+	Server    string `json:"server,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ResourceStatus is an entry of an Application's status.resources
+type ResourceStatus struct {
+	Group     string `json:"group,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Status    string `json:"status,omitempty"`
+}
+
+// Application is the typed view of the Application fields kubectl-nuke reads, with stable JSON names
+type Application struct {
+	Name          string           `json:"name"`
+	Namespace     string           `json:"namespace"`
+	Project       string           `json:"project"`
+	Destination   Destination      `json:"destination"`
+	SyncStatus    string           `json:"syncStatus,omitempty"`
+	HealthStatus  string           `json:"healthStatus,omitempty"`
+	HealthMessage string           `json:"healthMessage,omitempty"`
+	AutoSync      bool             `json:"autoSync"`
+	Finalizers    []string         `json:"finalizers,omitempty"`
+	Resources     []ResourceStatus `json:"resources,omitempty"`
+}
+
+// NewApplication reads the typed view from an Application object. An empty spec.project means the default project.
+func NewApplication(obj unstructured.Unstructured) Application {
+	app := Application{
+		Name:       obj.GetName(),
+		Namespace:  obj.GetNamespace(),
+		Finalizers: obj.GetFinalizers(),
+	}
+	app.Project, _, _ = unstructured.NestedString(obj.Object, "spec", "project")
+	if app.Project == "" {
+		app.Project = "default"
+	}
+	app.Destination.Server, _, _ = unstructured.NestedString(obj.Object, "spec", "destination", "server")
+	app.Destination.Name, _, _ = unstructured.NestedString(obj.Object, "spec", "destination", "name")
+	app.Destination.Namespace, _, _ = unstructured.NestedString(obj.Object, "spec", "destination", "namespace")
+	app.SyncStatus, _, _ = unstructured.NestedString(obj.Object, "status", "sync", "status")
+	app.HealthStatus, _, _ = unstructured.NestedString(obj.Object, "status", "health", "status")
+	app.HealthMessage, _, _ = unstructured.NestedString(obj.Object, "status", "health", "message")
+	_, app.AutoSync, _ = unstructured.NestedMap(obj.Object, "spec", "syncPolicy", "automated")
+
+	for _, resource := range applicationResources(obj) {
+		resourceMap, ok := resource.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var status ResourceStatus
+		status.Group, _, _ = unstructured.NestedString(resourceMap, "group")
+		status.Version, _, _ = unstructured.NestedString(resourceMap, "version")
+		status.Kind, _, _ = unstructured.NestedString(resourceMap, "kind")
+		status.Namespace, _, _ = unstructured.NestedString(resourceMap, "namespace")
+		status.Name, _, _ = unstructured.NestedString(resourceMap, "name")
+		status.Status, _, _ = unstructured.NestedString(resourceMap, "status")
+		app.Resources = append(app.Resources, status)
+	}
+	return app
+}
+
+// NewApplications reads the typed view of each Application
+func NewApplications(objs []unstructured.Unstructured) []Application {
+	apps := make([]Application, 0, len(objs))
+	for _, obj := range objs {
+		apps = append(apps, NewApplication(obj))
+	}
+	return apps
+}
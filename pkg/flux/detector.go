@@ -0,0 +1,332 @@
+package flux
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Flux toolkit API groups and kinds
+const (
+	KustomizeGroup        = "kustomize.toolkit.fluxcd.io"
+	KustomizationResource = "kustomizations"
+	KustomizationKind     = "Kustomization"
+
+	HelmGroup           = "helm.toolkit.fluxcd.io"
+	HelmReleaseResource = "helmreleases"
+	HelmReleaseKind     = "HelmRelease"
+
+	// Finalizer is set by the Flux controllers to garbage-collect or uninstall what a reconciler applied
+	Finalizer = "finalizers.fluxcd.io"
+)
+
+// Reconciler is a Kustomization or HelmRelease that applies resources to a namespace
+type Reconciler struct {
+	Object unstructured.Unstructured
+	GVR    schema.GroupVersionResource
+	// Reasons explains why the reconciler matches the namespace, such as "targetNamespace" or "inventory"
+	Reasons []string
+	// Inventory counts the inventory entries in the namespace
+	Inventory int
+	// ManagesNamespace is set when the namespace object itself is in the inventory, so deleting the reconciler
+	// with pruning deletes the namespace
+	ManagesNamespace bool
+	// Outside counts the inventory entries in other namespaces or cluster-scoped, besides the namespace object
+	Outside int
+	// OtherNamespaces lists the other namespaces the inventory has entries in
+	OtherNamespaces []string
+}
+
+// Kind returns Kustomization or HelmRelease
+func (r Reconciler) Kind() string {
+	return r.Object.GetKind()
+}
+
+// String returns kind namespace/name
+func (r Reconciler) String() string {
+	return fmt.Sprintf("%s %s/%s", r.Kind(), r.Object.GetNamespace(), r.Object.GetName())
+}
+
+// Suspended reports whether spec.suspend is set
+func (r Reconciler) Suspended() bool {
+	suspended, _, _ := unstructured.NestedBool(r.Object.Object, "spec", "suspend")
+	return suspended
+}
+
+// Prunes reports whether deleting the reconciler removes what it applied: Kustomizations with spec.prune and
+// every HelmRelease, which is uninstalled, unless suspended
+func (r Reconciler) Prunes() bool {
+	if r.Suspended() {
+		return false
+	}
+	if r.Kind() == KustomizationKind {
+		prune, _, _ := unstructured.NestedBool(r.Object.Object, "spec", "prune")
+		return prune
+	}
+	return true
+}
+
+// PrunesOutside reports whether deleting the reconciler also removes objects it applied outside the namespace
+func (r Reconciler) PrunesOutside() bool {
+	return r.Outside > 0 && r.Prunes()
+}
+
+// Ready returns the status and message of the Ready condition, or Unknown when it isn't reported
+func (r Reconciler) Ready() (string, string) {
+	conditions, _, _ := unstructured.NestedSlice(r.Object.Object, "status", "conditions")
+	for _, condition := range conditions {
+		conditionMap, ok := condition.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if conditionType, _, _ := unstructured.NestedString(conditionMap, "type"); conditionType != "Ready" {
+			continue
+		}
+		status, _, _ := unstructured.NestedString(conditionMap, "status")
+		message, _, _ := unstructured.NestedString(conditionMap, "message")
+		return status, message
+	}
+	return "Unknown", ""
+}
+
+// Detector handles detection of Flux reconcilers
+type Detector struct {
+	kubeClient    kubernetes.Interface
+	dynamicClient dynamic.Interface
+	resources     map[string]*schema.GroupVersionResource
+}
+
+// NewDetector creates a new Flux detector
+func NewDetector(kubeClient kubernetes.Interface, dynamicClient dynamic.Interface) *Detector {
+	return &Detector{
+		kubeClient:    kubeClient,
+		dynamicClient: dynamicClient,
+		resources:     map[string]*schema.GroupVersionResource{},
+	}
+}
+
+// IsInstalled reports whether the cluster serves Kustomizations or HelmReleases
+func (d *Detector) IsInstalled() (bool, error) {
+	for _, group := range []string{KustomizeGroup, HelmGroup} {
+		_, served, err := d.resource(group)
+		if err != nil || served {
+			return served, err
+		}
+	}
+	return false, nil
+}
+
+// resource returns the preferred version of the Flux resource in the API group, found through discovery, and
+// false when the group isn't served
+func (d *Detector) resource(group string) (schema.GroupVersionResource, bool, error) {
+	if gvr, cached := d.resources[group]; cached {
+		return derefGVR(gvr), gvr != nil, nil
+	}
+
+	groups, err := d.kubeClient.Discovery().ServerGroups()
+	if err != nil {
+		return schema.GroupVersionResource{}, false, fmt.Errorf("failed to discover API groups: %w", err)
+	}
+	for _, apiGroup := range groups.Groups {
+		if apiGroup.Name == KustomizeGroup || apiGroup.Name == HelmGroup {
+			gvr := schema.GroupVersionResource{Group: apiGroup.Name, Version: apiGroup.PreferredVersion.Version, Resource: resourceForGroup(apiGroup.Name)}
+			d.resources[apiGroup.Name] = &gvr
+		}
+	}
+	for _, fluxGroup := range []string{KustomizeGroup, HelmGroup} {
+		if _, found := d.resources[fluxGroup]; !found {
+			d.resources[fluxGroup] = nil // Not served; remembered so discovery runs once
+		}
+	}
+	gvr := d.resources[group]
+	return derefGVR(gvr), gvr != nil, nil
+}
+
+// derefGVR returns the GVR or the zero value
+func derefGVR(gvr *schema.GroupVersionResource) schema.GroupVersionResource {
+	if gvr == nil {
+		return schema.GroupVersionResource{}
+	}
+	return *gvr
+}
+
+// resourceForGroup returns the reconciler resource of a Flux API group
+func resourceForGroup(group string) string {
+	if group == HelmGroup {
+		return HelmReleaseResource
+	}
+	return KustomizationResource
+}
+
+// DetectReconcilersForNamespace finds the Kustomizations and HelmReleases applying resources to the namespace:
+// those with spec.targetNamespace set to it, those whose status.inventory lists objects in it, and HelmReleases
+// stored in it without a targetNamespace. Flux is reported as not installed by returning no reconcilers.
+func (d *Detector) DetectReconcilersForNamespace(ctx context.Context, namespace string) ([]Reconciler, error) {
+	var reconcilers []Reconciler
+	for _, group := range []string{KustomizeGroup, HelmGroup} {
+		gvr, served, err := d.resource(group)
+		if err != nil {
+			return nil, err
+		}
+		if !served {
+			continue
+		}
+
+		list, err := d.dynamicClient.Resource(gvr).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to list %s: %w", gvr.Resource, err)
+		}
+		for _, obj := range list.Items {
+			if reconciler, ok := matchReconciler(obj, gvr, namespace); ok {
+				reconcilers = append(reconcilers, reconciler)
+			}
+		}
+	}
+
+	sortReconcilers(reconcilers)
+	return reconcilers, nil
+}
+
+// matchReconciler checks whether a Kustomization or HelmRelease applies resources to the namespace
+func matchReconciler(obj unstructured.Unstructured, gvr schema.GroupVersionResource, namespace string) (Reconciler, bool) {
+	reconciler := Reconciler{Object: obj, GVR: gvr}
+
+	targetNamespace, _, _ := unstructured.NestedString(obj.Object, "spec", "targetNamespace")
+	if targetNamespace == namespace {
+		reconciler.Reasons = append(reconciler.Reasons, "targetNamespace")
+	}
+	if gvr.Resource == HelmReleaseResource && targetNamespace == "" && obj.GetNamespace() == namespace {
+		reconciler.Reasons = append(reconciler.Reasons, "release namespace")
+	}
+
+	others := map[string]bool{}
+	for _, entry := range InventoryEntries(obj) {
+		switch {
+		case entry.Namespace == namespace:
+			reconciler.Inventory++
+		case entry.Namespace == "" && entry.Group == "" && entry.Kind == "Namespace" && entry.Name == namespace:
+			reconciler.ManagesNamespace = true
+		default:
+			reconciler.Outside++
+			if entry.Namespace != "" && !others[entry.Namespace] {
+				others[entry.Namespace] = true
+				reconciler.OtherNamespaces = append(reconciler.OtherNamespaces, entry.Namespace)
+			}
+		}
+	}
+	sort.Strings(reconciler.OtherNamespaces)
+	if reconciler.Inventory > 0 {
+		reconciler.Reasons = append(reconciler.Reasons, fmt.Sprintf("inventory (%d objects)", reconciler.Inventory))
+	}
+	if reconciler.ManagesNamespace {
+		reconciler.Reasons = append(reconciler.Reasons, "inventory (namespace object)")
+	}
+
+	return reconciler, len(reconciler.Reasons) > 0
+}
+
+// InventoryEntry is an object applied by a reconciler, parsed from a status.inventory entry id of the form
+// <namespace>_<name>_<group>_<kind>
+type InventoryEntry struct {
+	Namespace string
+	Name      string
+	Group     string
+	Kind      string
+}
+
+// InventoryEntries parses a reconciler's status.inventory.entries, skipping malformed ids
+func InventoryEntries(obj unstructured.Unstructured) []InventoryEntry {
+	entries, _, _ := unstructured.NestedSlice(obj.Object, "status", "inventory", "entries")
+
+	var parsed []InventoryEntry
+	for _, entry := range entries {
+		entryMap, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _, _ := unstructured.NestedString(entryMap, "id")
+		if inventoryEntry, ok := ParseInventoryID(id); ok {
+			parsed = append(parsed, inventoryEntry)
+		}
+	}
+	return parsed
+}
+
+// ParseInventoryID splits an inventory entry id. Kubernetes names can't contain underscores, so splitting from
+// both ends is unambiguous: namespace first, then kind and group from the end.
+func ParseInventoryID(id string) (InventoryEntry, bool) {
+	first := strings.Index(id, "_")
+	last := strings.LastIndex(id, "_")
+	if first < 0 || last <= first {
+		return InventoryEntry{}, false
+	}
+	rest := id[first+1 : last]
+	groupSep := strings.LastIndex(rest, "_")
+	if groupSep < 0 {
+		return InventoryEntry{}, false
+	}
+	return InventoryEntry{
+		Namespace: id[:first],
+		Name:      rest[:groupSep],
+		Group:     rest[groupSep+1:],
+		Kind:      id[last+1:],
+	}, true
+}
+
+// FindParents returns the Kustomizations whose inventory lists any of the reconcilers. A parent reapplies a
+// deleted or resumed child on its next reconciliation, so it has to be suspended first.
+func (d *Detector) FindParents(ctx context.Context, reconcilers []Reconciler) ([]Reconciler, error) {
+	gvr, served, err := d.resource(KustomizeGroup)
+	if err != nil || !served || len(reconcilers) == 0 {
+		return nil, err
+	}
+
+	children := map[InventoryEntry]bool{}
+	own := map[string]bool{}
+	for _, r := range reconcilers {
+		children[InventoryEntry{Namespace: r.Object.GetNamespace(), Name: r.Object.GetName(), Group: r.GVR.Group, Kind: r.Kind()}] = true
+		own[r.String()] = true
+	}
+
+	list, err := d.dynamicClient.Resource(gvr).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", gvr.Resource, err)
+	}
+
+	var parents []Reconciler
+	for _, obj := range list.Items {
+		parent := Reconciler{Object: obj, GVR: gvr}
+		if own[parent.String()] {
+			continue
+		}
+		for _, entry := range InventoryEntries(obj) {
+			if children[entry] {
+				parent.Reasons = append(parent.Reasons, fmt.Sprintf("applies %s %s/%s", entry.Kind, entry.Namespace, entry.Name))
+			}
+		}
+		if len(parent.Reasons) > 0 {
+			parents = append(parents, parent)
+		}
+	}
+
+	sortReconcilers(parents)
+	return parents, nil
+}
+
+// sortReconcilers orders reconcilers by kind, namespace and name
+func sortReconcilers(reconcilers []Reconciler) {
+	sort.Slice(reconcilers, func(i, j int) bool {
+		return reconcilers[i].String() < reconcilers[j].String()
+	})
+}
@@ -0,0 +1,139 @@
+package flux
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+var (
+	kustomizationGVR = schema.GroupVersionResource{Group: KustomizeGroup, Version: "v1", Resource: KustomizationResource}
+	helmReleaseGVR   = schema.GroupVersionResource{Group: HelmGroup, Version: "v2", Resource: HelmReleaseResource}
+)
+
+// newFakeKubeClient returns a clientset whose discovery serves Kustomizations and HelmReleases
+func newFakeKubeClient() *k8sfake.Clientset {
+	kubeClient := k8sfake.NewSimpleClientset()
+	kubeClient.Fake.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: KustomizeGroup + "/v1",
+			APIResources: []metav1.APIResource{{Name: KustomizationResource, Kind: KustomizationKind, Namespaced: true}},
+		},
+		{
+			GroupVersion: HelmGroup + "/v2",
+			APIResources: []metav1.APIResource{{Name: HelmReleaseResource, Kind: HelmReleaseKind, Namespaced: true}},
+		},
+	}
+	return kubeClient
+}
+
+// newFakeDynamicClient returns a dynamic client that can list Kustomizations and HelmReleases
+func newFakeDynamicClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			kustomizationGVR: "KustomizationList",
+			helmReleaseGVR:   "HelmReleaseList",
+		},
+		objects...,
+	)
+}
+
+func newReconciler(gvr schema.GroupVersionResource, kind, namespace, name string, spec map[string]interface{}, inventory ...string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(gvr.Group + "/" + gvr.Version)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	if spec != nil {
+		unstructured.SetNestedMap(obj.Object, spec, "spec")
+	}
+	var entries []interface{}
+	for _, id := range inventory {
+		entries = append(entries, map[string]interface{}{"id": id, "v": "v1"})
+	}
+	if entries != nil {
+		unstructured.SetNestedSlice(obj.Object, entries, "status", "inventory", "entries")
+	}
+	return obj
+}
+
+func TestParseInventoryID(t *testing.T) {
+	tests := map[string]struct {
+		expected InventoryEntry
+		ok       bool
+	}{
+		"shop_web_apps_Deployment": {InventoryEntry{Namespace: "shop", Name: "web", Group: "apps", Kind: "Deployment"}, true},
+		"shop_cfg__ConfigMap":      {InventoryEntry{Namespace: "shop", Name: "cfg", Kind: "ConfigMap"}, true},
+		"_shop__Namespace":         {InventoryEntry{Name: "shop", Kind: "Namespace"}, true},
+		"shop_Deployment":          {InventoryEntry{}, false},
+		"":                         {InventoryEntry{}, false},
+	}
+	for id, test := range tests {
+		entry, ok := ParseInventoryID(id)
+		if ok != test.ok || entry != test.expected {
+			t.Errorf("ParseInventoryID(%q) = %+v, %v; expected %+v, %v", id, entry, ok, test.expected, test.ok)
+		}
+	}
+}
+
+func TestDetectReconcilersForNamespace(t *testing.T) {
+	apps := newReconciler(kustomizationGVR, KustomizationKind, "flux-system", "apps", map[string]interface{}{"prune": true},
+		"_shop__Namespace", "shop_web_apps_Deployment", "flux-system_shop-release_helm.toolkit.fluxcd.io_HelmRelease")
+	release := newReconciler(helmReleaseGVR, HelmReleaseKind, "flux-system", "shop-release", map[string]interface{}{"targetNamespace": "shop"})
+	local := newReconciler(helmReleaseGVR, HelmReleaseKind, "shop", "cache", nil)
+	other := newReconciler(kustomizationGVR, KustomizationKind, "flux-system", "infra", nil, "infra_ingress_apps_Deployment")
+	root := newReconciler(kustomizationGVR, KustomizationKind, "flux-system", "root", nil,
+		"flux-system_apps_kustomize.toolkit.fluxcd.io_Kustomization", "flux-system_infra_kustomize.toolkit.fluxcd.io_Kustomization")
+
+	detector := NewDetector(newFakeKubeClient(), newFakeDynamicClient(apps, release, local, other, root))
+	ctx := context.TODO()
+
+	installed, err := detector.IsInstalled()
+	if err != nil || !installed {
+		t.Fatalf("expected Flux to be installed, got %v, %v", installed, err)
+	}
+
+	reconcilers, err := detector.DetectReconcilersForNamespace(ctx, "shop")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(reconcilers) != 3 {
+		t.Fatalf("expected 3 reconcilers, got %v", reconcilers)
+	}
+	expected := []string{"HelmRelease flux-system/shop-release", "HelmRelease shop/cache", "Kustomization flux-system/apps"}
+	for i, name := range expected {
+		if reconcilers[i].String() != name {
+			t.Errorf("expected reconciler %d to be %s, got %s", i, name, reconcilers[i])
+		}
+	}
+	if apps := reconcilers[2]; !apps.ManagesNamespace || apps.Inventory != 1 || !apps.Prunes() {
+		t.Errorf("expected apps to prune the namespace and one object, got %+v", apps)
+	}
+	if apps := reconcilers[2]; apps.Outside != 1 || len(apps.OtherNamespaces) != 1 || apps.OtherNamespaces[0] != "flux-system" || !apps.PrunesOutside() {
+		t.Errorf("expected apps to also prune its HelmRelease in flux-system, got %+v", apps)
+	}
+	if reconcilers[0].GVR != helmReleaseGVR {
+		t.Errorf("expected the preferred HelmRelease version to be discovered, got %v", reconcilers[0].GVR)
+	}
+
+	parents, err := detector.FindParents(ctx, reconcilers)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(parents) != 1 || parents[0].Object.GetName() != "root" {
+		t.Errorf("expected root to be the only parent, got %v", parents)
+	}
+}
+
+func TestIsInstalledWithoutFlux(t *testing.T) {
+	installed, err := NewDetector(k8sfake.NewSimpleClientset(), nil).IsInstalled()
+	if err != nil || installed {
+		t.Errorf("expected Flux not to be installed, got %v, %v", installed, err)
+	}
+}
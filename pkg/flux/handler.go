@@ -0,0 +1,191 @@
+package flux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+// Flux handling modes
+const (
+	// ModeSuspend keeps the reconcilers but sets spec.suspend so they stop reapplying
+	ModeSuspend = "suspend"
+	// ModeDelete deletes the reconcilers, letting Flux prune what they applied
+	ModeDelete = "delete"
+	// ModeOrphan deletes the reconcilers without pruning: Kustomizations get spec.prune false and
+	// HelmReleases are suspended first, so helm-controller skips the uninstall
+	ModeOrphan = "orphan"
+)
+
+// Timeout is how long to wait for Flux to finalize a deleted reconciler before removing its finalizer
+const Timeout = 60 * time.Second
+
+// Handler handles suspending and deleting Flux reconcilers
+type Handler struct {
+	dynamicClient dynamic.Interface
+	timeout       time.Duration
+}
+
+// NewHandler creates a new Flux handler
+func NewHandler(dynamicClient dynamic.Interface) *Handler {
+	return &Handler{
+		dynamicClient: dynamicClient,
+		timeout:       Timeout,
+	}
+}
+
+// WithTimeout sets how long to wait for a deleted reconciler before removing its finalizer
+func (h *Handler) WithTimeout(timeout time.Duration) *Handler {
+	if timeout > 0 {
+		h.timeout = timeout
+	}
+	return h
+}
+
+// HandleReconcilers suspends, deletes or orphan-deletes the reconcilers according to mode
+func (h *Handler) HandleReconcilers(ctx context.Context, reconcilers []Reconciler, mode string) error {
+	for _, r := range reconcilers {
+		var err error
+		switch mode {
+		case ModeSuspend:
+			fmt.Printf("⏸️  Suspending Flux %s\n", r)
+			if err = h.Suspend(ctx, r); err == nil {
+				fmt.Printf("✅ Suspended Flux %s (resume with: flux resume %s %s -n %s)\n",
+					r, lowerKind(r.Kind()), r.Object.GetName(), r.Object.GetNamespace())
+			}
+		case ModeDelete, "":
+			fmt.Printf("🔄 Deleting Flux %s\n", r)
+			if err = h.Delete(ctx, r); err == nil {
+				fmt.Printf("✅ Deleted Flux %s\n", r)
+			}
+		case ModeOrphan:
+			fmt.Printf("🔄 Orphaning Flux %s\n", r)
+			if err = h.Orphan(ctx, r); err == nil {
+				fmt.Printf("✅ Deleted Flux %s and left what it applied in place\n", r)
+			}
+		default:
+			return fmt.Errorf("unknown Flux mode %q", mode)
+		}
+		if err != nil {
+			fmt.Printf("⚠️ Warning: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// Suspend sets spec.suspend so the reconciler stops applying and pruning
+func (h *Handler) Suspend(ctx context.Context, r Reconciler) error {
+	return h.patchSpec(ctx, r, map[string]interface{}{"suspend": true})
+}
+
+// Orphan deletes the reconciler without removing what it applied
+func (h *Handler) Orphan(ctx context.Context, r Reconciler) error {
+	spec := map[string]interface{}{"suspend": true}
+	if r.Kind() == KustomizationKind {
+		spec = map[string]interface{}{"prune": false}
+	}
+	if err := h.patchSpec(ctx, r, spec); err != nil {
+		return err
+	}
+	return h.Delete(ctx, r)
+}
+
+// Delete deletes the reconciler and waits for Flux to finalize it. If the controller doesn't finish within the
+// timeout, finalizers.fluxcd.io is removed so the reconciler and its namespace can go.
+func (h *Handler) Delete(ctx context.Context, r Reconciler) error {
+	resourceClient := h.dynamicClient.Resource(r.GVR).Namespace(r.Object.GetNamespace())
+	name := r.Object.GetName()
+
+	if err := resourceClient.Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete Flux %s: %w", r, err)
+	}
+
+	err := wait.PollImmediate(2*time.Second, h.timeout, func() (bool, error) {
+		_, err := resourceClient.Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err == nil {
+		return nil
+	}
+
+	fmt.Printf("⚠️  Flux %s still present after %v; removing %s\n", r, h.timeout, Finalizer)
+	if _, err := RemoveFinalizer(ctx, resourceClient, name); err != nil {
+		return fmt.Errorf("failed to remove %s from Flux %s: %w", Finalizer, r, err)
+	}
+	return nil
+}
+
+// patchSpec merges fields into the reconciler's spec
+func (h *Handler) patchSpec(ctx context.Context, r Reconciler, spec map[string]interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{"spec": spec})
+	if err != nil {
+		return err
+	}
+	_, err = h.dynamicClient.Resource(r.GVR).Namespace(r.Object.GetNamespace()).Patch(
+		ctx, r.Object.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to patch Flux %s: %w", r, err)
+	}
+	return nil
+}
+
+// RemoveFinalizer removes finalizers.fluxcd.io from an object with a guarded JSON patch, leaving any other
+// finalizers in place. It returns false when the object is gone or doesn't carry the finalizer.
+func RemoveFinalizer(ctx context.Context, resourceClient dynamic.ResourceInterface, name string) (bool, error) {
+	current, err := resourceClient.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	var ops []map[string]interface{}
+	finalizers := current.GetFinalizers()
+	for i := len(finalizers) - 1; i >= 0; i-- {
+		if finalizers[i] != Finalizer {
+			continue
+		}
+		path := fmt.Sprintf("/metadata/finalizers/%d", i)
+		ops = append(ops,
+			map[string]interface{}{"op": "test", "path": path, "value": finalizers[i]},
+			map[string]interface{}{"op": "remove", "path": path},
+		)
+	}
+	if len(ops) == 0 {
+		return false, nil
+	}
+
+	patch, err := json.Marshal(ops)
+	if err != nil {
+		return false, err
+	}
+	if _, err := resourceClient.Patch(ctx, name, types.JSONPatchType, patch, metav1.PatchOptions{}); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// lowerKind returns the flux CLI noun for a kind
+func lowerKind(kind string) string {
+	if kind == HelmReleaseKind {
+		return "helmrelease"
+	}
+	return "kustomization"
+}
@@ -0,0 +1,79 @@
+package flux
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestSuspend(t *testing.T) {
+	apps := newReconciler(kustomizationGVR, KustomizationKind, "flux-system", "apps", map[string]interface{}{"prune": true})
+	dynamicClient := newFakeDynamicClient(apps)
+	ctx := context.TODO()
+
+	if err := NewHandler(dynamicClient).HandleReconcilers(ctx, []Reconciler{{Object: *apps, GVR: kustomizationGVR}}, ModeSuspend); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	obj, err := dynamicClient.Resource(kustomizationGVR).Namespace("flux-system").Get(ctx, "apps", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get apps: %v", err)
+	}
+	if suspended, _, _ := unstructured.NestedBool(obj.Object, "spec", "suspend"); !suspended {
+		t.Errorf("expected apps to be suspended, got spec %v", obj.Object["spec"])
+	}
+	if prune, _, _ := unstructured.NestedBool(obj.Object, "spec", "prune"); !prune {
+		t.Errorf("expected suspending to keep spec.prune, got spec %v", obj.Object["spec"])
+	}
+}
+
+func TestOrphan(t *testing.T) {
+	apps := newReconciler(kustomizationGVR, KustomizationKind, "flux-system", "apps", map[string]interface{}{"prune": true})
+	dynamicClient := newFakeDynamicClient(apps)
+	ctx := context.TODO()
+
+	handler := NewHandler(dynamicClient).WithTimeout(time.Second)
+	if err := handler.Orphan(ctx, Reconciler{Object: *apps, GVR: kustomizationGVR}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := dynamicClient.Resource(kustomizationGVR).Namespace("flux-system").Get(ctx, "apps", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expected apps to be deleted, got %v", err)
+	}
+
+	var patched bool
+	for _, action := range dynamicClient.Actions() {
+		if patch, ok := action.(clienttesting.PatchAction); ok {
+			patched = string(patch.GetPatch()) == `{"spec":{"prune":false}}`
+			break
+		}
+	}
+	if !patched {
+		t.Errorf("expected spec.prune to be turned off before the delete, got actions %v", dynamicClient.Actions())
+	}
+}
+
+func TestRemoveFinalizer(t *testing.T) {
+	release := newReconciler(helmReleaseGVR, HelmReleaseKind, "shop", "cache", nil)
+	release.SetFinalizers([]string{"other.example.com/guard", Finalizer})
+	dynamicClient := newFakeDynamicClient(release)
+	resourceClient := dynamicClient.Resource(helmReleaseGVR).Namespace("shop")
+	ctx := context.TODO()
+
+	removed, err := RemoveFinalizer(ctx, resourceClient, "cache")
+	if err != nil || !removed {
+		t.Fatalf("expected the finalizer to be removed, got %v, %v", removed, err)
+	}
+	obj, _ := resourceClient.Get(ctx, "cache", metav1.GetOptions{})
+	if finalizers := obj.GetFinalizers(); len(finalizers) != 1 || finalizers[0] != "other.example.com/guard" {
+		t.Errorf("expected only the other finalizer to remain, got %v", finalizers)
+	}
+
+	if removed, err := RemoveFinalizer(ctx, resourceClient, "cache"); err != nil || removed {
+		t.Errorf("expected nothing to remove, got %v, %v", removed, err)
+	}
+}
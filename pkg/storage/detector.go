@@ -0,0 +1,327 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Annotations recording which provisioner created or should create a volume
+const (
+	AnnotationProvisionedBy          = "pv.kubernetes.io/provisioned-by"
+	AnnotationStorageProvisioner     = "volume.kubernetes.io/storage-provisioner"
+	AnnotationBetaStorageProvisioner = "volume.beta.kubernetes.io/storage-provisioner"
+)
+
+// Where a volume's driver was found
+const (
+	SourcePersistentVolume = "PersistentVolume"
+	SourcePVCAnnotation    = "PVC annotation"
+	SourceStorageClass     = "StorageClass"
+)
+
+// ControllerPod is a pod running a storage driver
+type ControllerPod struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Node      string `json:"node,omitempty"`
+	Phase     string `json:"phase"`
+	Ready     bool   `json:"ready"`
+	Restarts  int32  `json:"restarts"`
+}
+
+// Driver is a CSI driver or provisioner found in the cluster
+type Driver struct {
+	Name string `json:"name"`
+	// Provider is the registry provider the driver belongs to, empty when it isn't in the registry
+	Provider string `json:"provider,omitempty"`
+	// CSIDriver is set when the driver is registered as a storage.k8s.io CSIDriver
+	CSIDriver      bool     `json:"csiDriver"`
+	StorageClasses []string `json:"storageClasses,omitempty"`
+	// Pods run the driver; found by the driver name in their arguments, environment or host paths
+	Pods []ControllerPod `json:"pods,omitempty"`
+	// TerminatingNamespaces lists the namespaces of Pods that are being deleted
+	TerminatingNamespaces []string `json:"terminatingNamespaces,omitempty"`
+}
+
+// DisplayName returns the provider and driver, or just the driver when the provider is unknown
+func (d Driver) DisplayName() string {
+	if d.Provider == "" {
+		return d.Name
+	}
+	return fmt.Sprintf("%s (%s)", d.Provider, d.Name)
+}
+
+// Healthy reports whether the driver has pods and all of them are ready
+func (d Driver) Healthy() bool {
+	if len(d.Pods) == 0 {
+		return false
+	}
+	for _, pod := range d.Pods {
+		if !pod.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+// VolumeBacking records the driver behind a PVC
+type VolumeBacking struct {
+	PVC          string `json:"pvc"`
+	Phase        string `json:"phase"`
+	StorageClass string `json:"storageClass,omitempty"`
+	Volume       string `json:"volume,omitempty"`
+	Driver       string `json:"driver,omitempty"`
+	Provider     string `json:"provider,omitempty"`
+	// Source is where the driver was found: the bound PersistentVolume, a PVC annotation, or the StorageClass
+	Source string `json:"source,omitempty"`
+}
+
+// Report is the storage found for a namespace
+type Report struct {
+	Namespace string          `json:"namespace"`
+	Volumes   []VolumeBacking `json:"volumes"`
+	Drivers   []Driver        `json:"drivers"`
+}
+
+// Driver returns the named driver, or nil
+func (r *Report) Driver(name string) *Driver {
+	for i := range r.Drivers {
+		if r.Drivers[i].Name == name {
+			return &r.Drivers[i]
+		}
+	}
+	return nil
+}
+
+// DriversInUse returns the drivers backing the namespace's PVCs
+func (r *Report) DriversInUse() []Driver {
+	used := map[string]bool{}
+	for _, v := range r.Volumes {
+		used[v.Driver] = true
+	}
+	var drivers []Driver
+	for _, d := range r.Drivers {
+		if used[d.Name] {
+			drivers = append(drivers, d)
+		}
+	}
+	return drivers
+}
+
+// Detector finds the storage drivers in a cluster and the ones backing a namespace's volumes
+type Detector struct {
+	kubeClient kubernetes.Interface
+	registry   *Registry
+}
+
+// NewDetector creates a new storage detector
+func NewDetector(kubeClient kubernetes.Interface, registry *Registry) *Detector {
+	return &Detector{kubeClient: kubeClient, registry: registry}
+}
+
+// DetectForNamespace finds the drivers registered as CSIDrivers or named by StorageClass provisioners, the driver
+// behind each PVC in the namespace, and the pods running each driver
+func (d *Detector) DetectForNamespace(ctx context.Context, namespace string) (*Report, error) {
+	report := &Report{Namespace: namespace}
+	drivers := map[string]*Driver{}
+	driver := func(name string) *Driver {
+		if drivers[name] == nil {
+			drivers[name] = &Driver{Name: name, Provider: d.providerName(name)}
+		}
+		return drivers[name]
+	}
+
+	csiDrivers, err := d.kubeClient.StorageV1().CSIDrivers().List(ctx, metav1.ListOptions{})
+	if err != nil && !errors.IsNotFound(err) && !errors.IsForbidden(err) {
+		return nil, fmt.Errorf("failed to list CSI drivers: %w", err)
+	}
+	if csiDrivers != nil {
+		for _, csiDriver := range csiDrivers.Items {
+			driver(csiDriver.Name).CSIDriver = true
+		}
+	}
+
+	storageClasses, err := d.kubeClient.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage classes: %w", err)
+	}
+	provisioners := map[string]string{}
+	for _, sc := range storageClasses.Items {
+		provisioners[sc.Name] = sc.Provisioner
+		driver(sc.Provisioner).StorageClasses = append(driver(sc.Provisioner).StorageClasses, sc.Name)
+	}
+
+	pvcs, err := d.kubeClient.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PVCs: %w", err)
+	}
+	for _, pvc := range pvcs.Items {
+		volume := d.volumeBacking(ctx, pvc, provisioners)
+		if volume.Driver != "" {
+			driver(volume.Driver)
+		}
+		report.Volumes = append(report.Volumes, volume)
+	}
+
+	pods, err := d.kubeClient.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	terminating := map[string]bool{}
+	for _, dr := range drivers {
+		for _, pod := range pods.Items {
+			if podRunsDriver(pod, dr.Name) {
+				dr.Pods = append(dr.Pods, newControllerPod(pod))
+			}
+		}
+		for _, ns := range podNamespaces(dr.Pods) {
+			isTerminating, checked := terminating[ns]
+			if !checked {
+				namespaceObj, err := d.kubeClient.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+				isTerminating = err == nil && namespaceObj.Status.Phase == corev1.NamespaceTerminating
+				terminating[ns] = isTerminating
+			}
+			if isTerminating {
+				dr.TerminatingNamespaces = append(dr.TerminatingNamespaces, ns)
+			}
+		}
+	}
+
+	for _, dr := range drivers {
+		report.Drivers = append(report.Drivers, *dr)
+	}
+	sort.Slice(report.Drivers, func(i, j int) bool { return report.Drivers[i].Name < report.Drivers[j].Name })
+	sort.Slice(report.Volumes, func(i, j int) bool { return report.Volumes[i].PVC < report.Volumes[j].PVC })
+	return report, nil
+}
+
+// volumeBacking finds the driver behind a PVC: from its bound PV, else its provisioner annotation, else its
+// StorageClass
+func (d *Detector) volumeBacking(ctx context.Context, pvc corev1.PersistentVolumeClaim, provisioners map[string]string) VolumeBacking {
+	volume := VolumeBacking{
+		PVC:    pvc.Name,
+		Phase:  string(pvc.Status.Phase),
+		Volume: pvc.Spec.VolumeName,
+	}
+	if pvc.Spec.StorageClassName != nil {
+		volume.StorageClass = *pvc.Spec.StorageClassName
+	}
+
+	if pvc.Spec.VolumeName != "" {
+		pv, err := d.kubeClient.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+		if err == nil {
+			if pv.Spec.CSI != nil {
+				volume.Driver = pv.Spec.CSI.Driver
+			} else {
+				volume.Driver = pv.Annotations[AnnotationProvisionedBy]
+			}
+			if volume.StorageClass == "" {
+				volume.StorageClass = pv.Spec.StorageClassName
+			}
+			if volume.Driver != "" {
+				volume.Source = SourcePersistentVolume
+			}
+		}
+	}
+	if volume.Driver == "" {
+		for _, annotation := range []string{AnnotationStorageProvisioner, AnnotationBetaStorageProvisioner} {
+			if provisioner := pvc.Annotations[annotation]; provisioner != "" {
+				volume.Driver, volume.Source = provisioner, SourcePVCAnnotation
+				break
+			}
+		}
+	}
+	if volume.Driver == "" && provisioners[volume.StorageClass] != "" {
+		volume.Driver, volume.Source = provisioners[volume.StorageClass], SourceStorageClass
+	}
+
+	volume.Provider = d.providerName(volume.Driver)
+	return volume
+}
+
+// providerName returns the registry provider behind a driver, or an empty string
+func (d *Detector) providerName(driver string) string {
+	if d.registry == nil || driver == "" {
+		return ""
+	}
+	if provider := d.registry.ForProvisioner(driver); provider != nil {
+		return provider.Name
+	}
+	return ""
+}
+
+// podRunsDriver reports whether a pod runs a driver: the driver name appears in a container's command, arguments
+// or environment, or in a host path, where CSI plugins keep their sockets
+func podRunsDriver(pod corev1.Pod, driver string) bool {
+	if driver == "" {
+		return false
+	}
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			if containsDriver(container.Command, driver) || containsDriver(container.Args, driver) {
+				return true
+			}
+			for _, env := range container.Env {
+				if strings.Contains(env.Value, driver) {
+					return true
+				}
+			}
+		}
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.HostPath != nil && strings.Contains(volume.HostPath.Path, driver) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsDriver reports whether any value mentions the driver
+func containsDriver(values []string, driver string) bool {
+	for _, value := range values {
+		if strings.Contains(value, driver) {
+			return true
+		}
+	}
+	return false
+}
+
+// newControllerPod summarises a driver pod
+func newControllerPod(pod corev1.Pod) ControllerPod {
+	controllerPod := ControllerPod{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Node:      pod.Spec.NodeName,
+		Phase:     string(pod.Status.Phase),
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			controllerPod.Ready = condition.Status == corev1.ConditionTrue
+		}
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		controllerPod.Restarts += status.RestartCount
+	}
+	return controllerPod
+}
+
+// podNamespaces returns the distinct namespaces of the pods, sorted
+func podNamespaces(pods []ControllerPod) []string {
+	seen := map[string]bool{}
+	var namespaces []string
+	for _, pod := range pods {
+		if !seen[pod.Namespace] {
+			seen[pod.Namespace] = true
+			namespaces = append(namespaces, pod.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
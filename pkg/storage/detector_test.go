@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newDriverPod(namespace, name, driver string, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: corev1.PodSpec{
+			NodeName:   "node-1",
+			Containers: []corev1.Container{{Name: "csi-plugin", Args: []string{"--drivername=" + driver}}},
+		},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+func TestDetectForNamespace(t *testing.T) {
+	const rbd = "storage-ceph.rbd.csi.ceph.com"
+	fast := "fast"
+	kubeClient := k8sfake.NewSimpleClientset(
+		&storagev1.CSIDriver{ObjectMeta: metav1.ObjectMeta{Name: rbd}},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "ceph-block"}, Provisioner: rbd},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: fast}, Provisioner: "csi.acme.example.com"},
+		&corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-data"},
+			Spec: corev1.PersistentVolumeSpec{
+				StorageClassName:       "ceph-block",
+				PersistentVolumeSource: corev1.PersistentVolumeSource{CSI: &corev1.CSIPersistentVolumeSource{Driver: rbd}},
+			},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "data"},
+			Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "pv-data"},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "cache"},
+			Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: &fast},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+		},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "storage-ceph"}, Status: corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating}},
+		newDriverPod("storage-ceph", "csi-rbdplugin-provisioner", rbd, true),
+		newDriverPod("storage-ceph", "csi-rbdplugin-node", rbd, false),
+		newDriverPod("shop", "web", "", true),
+	)
+
+	report, err := NewDetector(kubeClient, DefaultRegistry()).DetectForNamespace(context.TODO(), "shop")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(report.Volumes) != 2 {
+		t.Fatalf("expected 2 volumes, got %+v", report.Volumes)
+	}
+	cache, data := report.Volumes[0], report.Volumes[1]
+	if data.Driver != rbd || data.Provider != "Rook-Ceph" || data.Source != SourcePersistentVolume || data.StorageClass != "ceph-block" {
+		t.Errorf("expected data to be backed by Rook-Ceph through its PV, got %+v", data)
+	}
+	if cache.Driver != "csi.acme.example.com" || cache.Provider != "" || cache.Source != SourceStorageClass {
+		t.Errorf("expected cache to be backed by the unknown acme driver through its StorageClass, got %+v", cache)
+	}
+
+	ceph := report.Driver(rbd)
+	if ceph == nil || !ceph.CSIDriver || len(ceph.Pods) != 2 || ceph.Healthy() {
+		t.Fatalf("expected the Rook-Ceph CSIDriver with 2 pods, one unready, got %+v", ceph)
+	}
+	if len(ceph.TerminatingNamespaces) != 1 || ceph.TerminatingNamespaces[0] != "storage-ceph" {
+		t.Errorf("expected storage-ceph to be reported terminating, got %v", ceph.TerminatingNamespaces)
+	}
+	if acme := report.Driver("csi.acme.example.com"); acme == nil || len(acme.Pods) != 0 || acme.Healthy() {
+		t.Errorf("expected the acme driver without pods, got %+v", acme)
+	}
+	if len(report.DriversInUse()) != 2 {
+		t.Errorf("expected 2 drivers in use, got %+v", report.DriversInUse())
+	}
+}
@@ -0,0 +1,341 @@
+package storage
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// Post-teardown wait conditions
+const (
+	// WaitForDeleted waits until the deleted objects are gone
+	WaitForDeleted = "deleted"
+	// WaitForNone moves on as soon as the deletes are issued
+	WaitForNone = "none"
+)
+
+// DefaultWaitTimeout bounds the post-teardown wait when a provider doesn't set one
+const DefaultWaitTimeout = 30 * time.Second
+
+//go:embed providers.yaml
+var builtinProviders []byte
+
+// Resource names one of a provider's custom resources, by plural resource name or kind
+type Resource struct {
+	Name string `json:"name,omitempty"`
+	Kind string `json:"kind,omitempty"`
+	// VolumeField is the dot-separated path of the PV name or CSI volume handle a cluster-scoped object belongs
+	// to. Such objects are torn down with the namespace whose PVCs are bound to those volumes; cluster-scoped
+	// objects without one are torn down with the namespace holding the provider's namespaced objects.
+	VolumeField string `json:"volumeField,omitempty"`
+}
+
+// Matches reports whether a discovered API resource is the one named
+func (r Resource) Matches(apiResource metav1.APIResource) bool {
+	if r.Name != "" && strings.EqualFold(r.Name, apiResource.Name) {
+		return true
+	}
+	return r.Kind != "" && strings.EqualFold(r.Kind, apiResource.Kind)
+}
+
+// String returns the resource name, or the kind when no name is set
+func (r Resource) String() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Kind
+}
+
+// Hints tell how to recognise a provider in the cluster. Provisioners and webhooks match as substrings.
+type Hints struct {
+	Provisioners []string `json:"provisioners,omitempty"`
+	Webhooks     []string `json:"webhooks,omitempty"`
+}
+
+// Wait is what to wait for after a provider's resources are deleted
+type Wait struct {
+	For     string          `json:"for,omitempty"`
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+// Operator identifies the controller that finalizes a provider's custom resources
+type Operator struct {
+	// Selector is a label selector matching the operator's pods, in any namespace
+	Selector string `json:"selector"`
+}
+
+// DataCleanup is a merge patch telling the operator to wipe a resource's data when it is deleted
+type DataCleanup struct {
+	Resource string                 `json:"resource"`
+	Patch    map[string]interface{} `json:"patch"`
+}
+
+// Matches reports whether the patch applies to a discovered API resource, named by plural resource name or kind
+func (c DataCleanup) Matches(apiResource metav1.APIResource) bool {
+	return Resource{Name: c.Resource, Kind: c.Resource}.Matches(apiResource)
+}
+
+// Detach names the provider resources that tell whether its volumes are attached. Objects of both are named
+// after the volumes' CSI volume handles.
+type Detach struct {
+	// Volumes is the resource holding the provider's volumes
+	Volumes string `json:"volumes,omitempty"`
+	// Attachments is the resource recording who attached each volume, besides Kubernetes VolumeAttachments
+	Attachments string `json:"attachments,omitempty"`
+}
+
+// Confirmation is a setting the operator checks before it lets its resources be deleted
+type Confirmation struct {
+	Resource string `json:"resource"`
+	Name     string `json:"name"`
+	// Field is the dot-separated path of the setting's value in the object
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+// Provider declares a storage provider's custom resources, how to recognise it, and how to tear it down
+type Provider struct {
+	Name   string   `json:"name"`
+	Groups []string `json:"groups,omitempty"`
+	// Resources lists the custom resources in teardown order; when empty, every namespaced resource served in
+	// Groups is torn down
+	Resources []Resource `json:"resources,omitempty"`
+	Hints     Hints      `json:"hints,omitempty"`
+	Wait      Wait       `json:"wait,omitempty"`
+	// Operator, when set, makes the teardown ordered: each resource is deleted and left to the operator, and
+	// finalizers are only removed when the operator is gone or doesn't finish within the wait timeout
+	Operator *Operator `json:"operator,omitempty"`
+	// DestroyData lists the patches applied before the teardown when destroying the stored data is requested
+	DestroyData []DataCleanup `json:"destroyData,omitempty"`
+	// Detach, when set, keeps the provider from being torn down while its volumes are attached
+	Detach *Detach `json:"detach,omitempty"`
+	// Confirmation is checked before the teardown, and set when destroying the stored data is requested
+	Confirmation *Confirmation `json:"confirmation,omitempty"`
+	// Finalizers name the finalizers the provider's controllers set, matching as substrings; the diagnostics
+	// point out the objects they hold
+	Finalizers []string `json:"finalizers,omitempty"`
+}
+
+// OwnsGroup reports whether the API group belongs to the provider
+func (p Provider) OwnsGroup(group string) bool {
+	for _, g := range p.Groups {
+		if strings.EqualFold(g, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// Order returns the teardown position of a discovered resource: its index in Resources, len(Resources) for
+// resources of an empty list, and -1 when the provider doesn't tear it down
+func (p Provider) Order(group string, apiResource metav1.APIResource) int {
+	if !p.OwnsGroup(group) {
+		return -1
+	}
+	if len(p.Resources) == 0 {
+		return 0
+	}
+	for i, r := range p.Resources {
+		if r.Matches(apiResource) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Resource returns the listed resource matching a discovered API resource, or nil
+func (p Provider) Resource(group string, apiResource metav1.APIResource) *Resource {
+	if i := p.Order(group, apiResource); i >= 0 && i < len(p.Resources) {
+		return &p.Resources[i]
+	}
+	return nil
+}
+
+// OwnsFinalizer reports whether a finalizer is set by one of the provider's controllers
+func (p Provider) OwnsFinalizer(finalizer string) bool {
+	return containsAny(finalizer, p.Finalizers)
+}
+
+// WaitCondition returns the post-teardown wait condition, defaulting to WaitForDeleted
+func (p Provider) WaitCondition() string {
+	if p.Wait.For == "" {
+		return WaitForDeleted
+	}
+	return p.Wait.For
+}
+
+// WaitTimeout returns how long to wait after teardown, defaulting to DefaultWaitTimeout
+func (p Provider) WaitTimeout() time.Duration {
+	if p.Wait.Timeout.Duration <= 0 {
+		return DefaultWaitTimeout
+	}
+	return p.Wait.Timeout.Duration
+}
+
+// MatchesProvisioner reports whether a CSI driver or StorageClass provisioner belongs to the provider
+func (p Provider) MatchesProvisioner(provisioner string) bool {
+	return containsAny(provisioner, p.Hints.Provisioners)
+}
+
+// MatchesWebhook reports whether an admission webhook configuration belongs to the provider
+func (p Provider) MatchesWebhook(name string) bool {
+	return containsAny(name, p.Hints.Webhooks)
+}
+
+// validate checks the fields a provider needs
+func (p Provider) validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("provider without a name")
+	}
+	if len(p.Resources) > 0 && len(p.Groups) == 0 {
+		return fmt.Errorf("provider %s lists resources but no API groups", p.Name)
+	}
+	for _, r := range p.Resources {
+		if r.Name == "" && r.Kind == "" {
+			return fmt.Errorf("provider %s has a resource without a name or kind", p.Name)
+		}
+	}
+	if p.Operator != nil {
+		if _, err := labels.Parse(p.Operator.Selector); err != nil || p.Operator.Selector == "" {
+			return fmt.Errorf("provider %s has an invalid operator selector %q", p.Name, p.Operator.Selector)
+		}
+	}
+	for _, cleanup := range p.DestroyData {
+		if cleanup.Resource == "" || len(cleanup.Patch) == 0 {
+			return fmt.Errorf("provider %s has a destroyData entry without a resource or patch", p.Name)
+		}
+	}
+	if p.Detach != nil && p.Detach.Volumes == "" {
+		return fmt.Errorf("provider %s has a detach section without a volumes resource", p.Name)
+	}
+	if c := p.Confirmation; c != nil && (c.Resource == "" || c.Name == "" || c.Field == "") {
+		return fmt.Errorf("provider %s has a confirmation without a resource, name or field", p.Name)
+	}
+	switch p.Wait.For {
+	case "", WaitForDeleted, WaitForNone:
+	default:
+		return fmt.Errorf("provider %s has unknown wait condition %q: must be %s or %s", p.Name, p.Wait.For, WaitForDeleted, WaitForNone)
+	}
+	return nil
+}
+
+// Registry is the ordered set of known storage providers
+type Registry struct {
+	Providers []Provider `json:"providers"`
+}
+
+// DefaultRegistry returns the built-in providers. It panics if the embedded definitions are invalid.
+func DefaultRegistry() *Registry {
+	registry, err := ParseRegistry(builtinProviders)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in storage providers: %v", err))
+	}
+	return registry
+}
+
+// LoadRegistry returns the built-in providers extended with those in a user YAML file. A user provider with the
+// name of a built-in one replaces it; the others are added after the built-in ones.
+func LoadRegistry(path string) (*Registry, error) {
+	registry := DefaultRegistry()
+	if path == "" {
+		return registry, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage providers: %w", err)
+	}
+	user, err := ParseRegistry(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	registry.Merge(user)
+	return registry, nil
+}
+
+// ParseRegistry parses and validates provider definitions
+func ParseRegistry(data []byte) (*Registry, error) {
+	var registry Registry
+	if err := yaml.UnmarshalStrict(data, &registry); err != nil {
+		return nil, fmt.Errorf("failed to parse storage providers: %w", err)
+	}
+	seen := map[string]bool{}
+	for _, p := range registry.Providers {
+		if err := p.validate(); err != nil {
+			return nil, err
+		}
+		if seen[strings.ToLower(p.Name)] {
+			return nil, fmt.Errorf("provider %s is defined twice", p.Name)
+		}
+		seen[strings.ToLower(p.Name)] = true
+	}
+	return &registry, nil
+}
+
+// Merge adds the other registry's providers, replacing those with the same name
+func (r *Registry) Merge(other *Registry) {
+	for _, p := range other.Providers {
+		if existing := r.Find(p.Name); existing != nil {
+			*existing = p
+			continue
+		}
+		r.Providers = append(r.Providers, p)
+	}
+}
+
+// Find returns the provider with the name, or nil
+func (r *Registry) Find(name string) *Provider {
+	for i := range r.Providers {
+		if strings.EqualFold(r.Providers[i].Name, name) {
+			return &r.Providers[i]
+		}
+	}
+	return nil
+}
+
+// ForGroup returns the provider owning the API group, or nil
+func (r *Registry) ForGroup(group string) *Provider {
+	for i := range r.Providers {
+		if r.Providers[i].OwnsGroup(group) {
+			return &r.Providers[i]
+		}
+	}
+	return nil
+}
+
+// ForProvisioner returns the provider behind a CSI driver or StorageClass provisioner, or nil
+func (r *Registry) ForProvisioner(provisioner string) *Provider {
+	for i := range r.Providers {
+		if r.Providers[i].MatchesProvisioner(provisioner) {
+			return &r.Providers[i]
+		}
+	}
+	return nil
+}
+
+// ForWebhook returns the provider owning an admission webhook configuration, or nil
+func (r *Registry) ForWebhook(name string) *Provider {
+	for i := range r.Providers {
+		if r.Providers[i].MatchesWebhook(name) {
+			return &r.Providers[i]
+		}
+	}
+	return nil
+}
+
+// containsAny reports whether value contains any of the substrings, ignoring case
+func containsAny(value string, substrings []string) bool {
+	value = strings.ToLower(value)
+	for _, s := range substrings {
+		if s != "" && strings.Contains(value, strings.ToLower(s)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDefaultRegistry(t *testing.T) {
+	registry := DefaultRegistry()
+
+	longhorn := registry.Find("longhorn")
+	if longhorn == nil {
+		t.Fatalf("expected a built-in Longhorn provider")
+	}
+	if got := longhorn.Order("longhorn.io", metav1.APIResource{Name: "volumes", Kind: "Volume"}); got >= longhorn.Order("longhorn.io", metav1.APIResource{Name: "replicas"}) {
+		t.Errorf("expected Longhorn volumes to be deleted before longhorn-manager cleans up their replicas, got position %d", got)
+	}
+	if longhorn.Operator == nil || longhorn.Detach == nil || longhorn.Confirmation == nil || longhorn.Confirmation.Name != "deleting-confirmation-flag" {
+		t.Errorf("expected Longhorn to be detached and torn down through longhorn-manager, got %+v", longhorn)
+	}
+	if got := longhorn.Order("longhorn.io", metav1.APIResource{Name: "settings"}); got != -1 {
+		t.Errorf("expected unlisted Longhorn resources to be left alone, got position %d", got)
+	}
+
+	rook := registry.Find("Rook-Ceph")
+	if rook == nil || rook.Operator == nil || rook.Operator.Selector != "app=rook-ceph-operator" {
+		t.Fatalf("expected Rook-Ceph to be torn down through its operator, got %+v", rook)
+	}
+	if got := rook.Order("ceph.rook.io", metav1.APIResource{Name: "cephclusters"}); got != len(rook.Resources)-1 {
+		t.Errorf("expected CephClusters to be torn down last, got position %d", got)
+	}
+	if len(rook.DestroyData) != 1 || !rook.DestroyData[0].Matches(metav1.APIResource{Name: "cephclusters", Kind: "CephCluster"}) {
+		t.Errorf("expected a cleanupPolicy patch for CephClusters, got %+v", rook.DestroyData)
+	}
+
+	portworx := registry.Find("Portworx")
+	if portworx == nil || portworx.Operator == nil || len(portworx.DestroyData) != 1 || !portworx.DestroyData[0].Matches(metav1.APIResource{Name: "storageclusters"}) {
+		t.Errorf("expected Portworx to be uninstalled through its operator, got %+v", portworx)
+	}
+	if r := registry.Find("TopoLVM").Resource("topolvm.io", metav1.APIResource{Name: "logicalvolumes"}); r == nil || r.VolumeField != "status.volumeID" {
+		t.Errorf("expected TopoLVM LogicalVolumes to be matched to the namespace's volumes, got %+v", r)
+	}
+	trident := registry.Find("Trident")
+	if trident.Order("trident.netapp.io", metav1.APIResource{Name: "tridentvolumes"}) >= trident.Order("trident.netapp.io", metav1.APIResource{Name: "tridentbackends"}) {
+		t.Errorf("expected Trident volumes to be torn down before their backends")
+	}
+	if !trident.OwnsFinalizer("trident.netapp.io") || trident.OwnsFinalizer("kubernetes.io/pvc-protection") {
+		t.Errorf("expected only Trident's own finalizers to be recognised")
+	}
+	for provisioner, name := range map[string]string{"pxd.portworx.com": "Portworx", "topolvm.io": "TopoLVM", "csi.trident.netapp.io": "Trident", "csi.vsphere.vmware.com": "vSphere CNS"} {
+		if p := registry.ForProvisioner(provisioner); p == nil || p.Name != name {
+			t.Errorf("expected %s to be %s, got %v", provisioner, name, p)
+		}
+	}
+
+	if p := registry.ForProvisioner("driver.longhorn.io"); p == nil || p.Name != "Longhorn" {
+		t.Errorf("expected driver.longhorn.io to be Longhorn, got %v", p)
+	}
+	if p := registry.ForWebhook("rook-ceph-webhook"); p == nil || p.Name != "Rook-Ceph" {
+		t.Errorf("expected rook-ceph-webhook to be Rook-Ceph, got %v", p)
+	}
+	if p := registry.ForGroup("example.com"); p != nil {
+		t.Errorf("expected no provider for example.com, got %v", p)
+	}
+}
+
+func TestLoadRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "providers.yaml")
+	userProviders := `
+providers:
+  - name: longhorn
+    groups: [longhorn.io]
+    resources:
+      - kind: Volume
+    wait:
+      for: none
+  - name: Acme
+    groups: [storage.acme.example.com]
+    hints:
+      provisioners: [csi.acme.example.com]
+    wait:
+      timeout: 2m
+`
+	if err := os.WriteFile(path, []byte(userProviders), 0o600); err != nil {
+		t.Fatalf("failed to write providers: %v", err)
+	}
+
+	registry, err := LoadRegistry(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(registry.Providers) != len(DefaultRegistry().Providers)+1 {
+		t.Errorf("expected Longhorn to be replaced and Acme added, got %d providers", len(registry.Providers))
+	}
+
+	longhorn := registry.Find("Longhorn")
+	if len(longhorn.Resources) != 1 || longhorn.WaitCondition() != WaitForNone {
+		t.Errorf("expected the user's Longhorn to replace the built-in one, got %+v", longhorn)
+	}
+	acme := registry.ForProvisioner("csi.acme.example.com")
+	if acme == nil || acme.WaitTimeout() != 2*time.Minute || acme.WaitCondition() != WaitForDeleted {
+		t.Fatalf("expected Acme with a 2m wait for deletion, got %+v", acme)
+	}
+	if got := acme.Order("storage.acme.example.com", metav1.APIResource{Name: "pools"}); got != 0 {
+		t.Errorf("expected every resource in Acme's group to be torn down, got position %d", got)
+	}
+}
+
+func TestParseRegistryRejectsInvalidProviders(t *testing.T) {
+	tests := map[string]string{
+		"unnamed":        "providers:\n  - groups: [a.example.com]\n",
+		"no groups":      "providers:\n  - name: A\n    resources:\n      - name: volumes\n",
+		"empty resource": "providers:\n  - name: A\n    groups: [a.example.com]\n    resources:\n      - {}\n",
+		"bad wait":       "providers:\n  - name: A\n    wait:\n      for: ready\n",
+		"duplicate":      "providers:\n  - name: A\n  - name: a\n",
+		"bad operator":   "providers:\n  - name: A\n    operator:\n      selector: 'app in ('\n",
+		"empty cleanup":  "providers:\n  - name: A\n    destroyData:\n      - resource: clusters\n",
+		"empty detach":   "providers:\n  - name: A\n    detach:\n      attachments: attachments\n",
+		"no setting":     "providers:\n  - name: A\n    confirmation:\n      resource: settings\n",
+		"unknown field":  "providers:\n  - name: A\n    provisioner: a.example.com\n",
+		"malformed":      "providers: [",
+	}
+	for name, data := range tests {
+		if _, err := ParseRegistry([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}